@@ -5,19 +5,30 @@ package main
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	_ "github.com/lib/pq"
+	gometrics "github.com/rcrowley/go-metrics"
 
-	"iiot-backend/services/core/data/application"
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/auth"
+	"iiot-backend/middleware/ratelimit"
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/services/core/data"
 	"iiot-backend/pkg/common"
+	auditlog "iiot-backend/services/security/audit"
+	"iiot-backend/services/security/encryption"
+	"iiot-backend/services/security/servertls"
+	"iiot-backend/services/security/users"
+	"iiot-backend/services/support/notifications"
 )
 
 func main() {
@@ -48,74 +59,52 @@ func main() {
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
-	// Initialize EdgeX Core Data service
-	dataService := application.NewDataService()
-
-	// EdgeX v3 API routes for Core Data
-	v3 := e.Group("/api/v3")
-
-	// Event routes (following EdgeX patterns)
-	v3.GET("/event/all", func(c echo.Context) error {
-		offset, _ := strconv.Atoi(c.QueryParam("offset"))
-		limit, _ := strconv.Atoi(c.QueryParam("limit"))
-		deviceName := c.QueryParam("device")
-		
-		response, edgeErr := dataService.AllEvents(c.Request().Context(), offset, limit, deviceName)
-		if edgeErr.Code() != 0 {
-			return c.JSON(edgeErr.Code(), map[string]string{"error": edgeErr.Message()})
-		}
-		
-		return c.JSON(http.StatusOK, response)
-	})
+	// Mutating calls are recorded in the audit log shared with the backend, chained with its key
+	auditLog, err := auditlog.LogFromEnvironment(context.Background(), db, common.CoreDataServiceKey)
+	if err != nil {
+		panic("Failed to load audit log key: " + err.Error())
+	}
+	e.Use(audit.Record(auditLog))
 
-	v3.GET("/event/id/:id", func(c echo.Context) error {
-		id := c.Param("id")
-		
-		response, edgeErr := dataService.EventById(c.Request().Context(), id)
-		if edgeErr.Code() != 0 {
-			return c.JSON(edgeErr.Code(), map[string]string{"error": edgeErr.Message()})
-		}
-		
-		return c.JSON(http.StatusOK, response)
+	// Requests are limited per authenticated client, or per remote IP on public routes
+	rateLimits, err := ratelimit.FromEnvironment(data.RateLimits())
+	if err != nil {
+		panic("Failed to load rate limits: " + err.Error())
+	}
+	go rateLimits.Watch(context.Background(), func(err error) {
+		fmt.Printf("Keeping the current rate limits: %v\n", err)
 	})
 
-	v3.DELETE("/event/id/:id", func(c echo.Context) error {
-		id := c.Param("id")
-		
-		response, edgeErr := dataService.DeleteEventById(c.Request().Context(), id)
-		if edgeErr.Code() != 0 {
-			return c.JSON(edgeErr.Code(), map[string]string{"error": edgeErr.Message()})
-		}
-		
-		return c.JSON(http.StatusOK, response)
+	// Events sent by device services have the transforms of their profile resources applied to
+	// their readings, violations are tagged and notified
+	keys, err := encryption.KeysFromEnvironment(context.Background(), common.CoreDataServiceKey)
+	if err != nil {
+		panic("Failed to load field encryption keys: " + err.Error())
+	}
+	dataService := data.NewService(db)
+	notificationService := notifications.NewService(db, keys)
+	dataService.EnableResourceTransforms(func(scope tenant.Scope) data.ReadingNotifier {
+		return notificationService.WithTenant(scope)
 	})
 
-	// Reading routes
-	v3.GET("/reading/device/name/:name", func(c echo.Context) error {
-		deviceName := c.Param("name")
-		offset, _ := strconv.Atoi(c.QueryParam("offset"))
-		limit, _ := strconv.Atoi(c.QueryParam("limit"))
-		
-		response, edgeErr := dataService.ReadingsByDeviceName(c.Request().Context(), deviceName, offset, limit)
-		if edgeErr.Code() != 0 {
-			return c.JSON(edgeErr.Code(), map[string]string{"error": edgeErr.Message()})
-		}
-		
-		return c.JSON(http.StatusOK, response)
-	})
+	// EdgeX v3 API routes for Core Data, served for the tenant of the caller
+	permissions := data.RegisterEdgeXRoutes(e.Group("/api/v3"), dataService)
+	permissions[auth.Route(http.MethodGet, "/api/v3/config")] = []string{"system:read"}
+	publicRoutes := []string{"/api/v3/ping", "/api/v3/version"}
 
-	v3.GET("/reading/resource/name/:name", func(c echo.Context) error {
-		resourceName := c.Param("name")
-		offset, _ := strconv.Atoi(c.QueryParam("offset"))
-		limit, _ := strconv.Atoi(c.QueryParam("limit"))
-		
-		response, edgeErr := dataService.ReadingsByResourceName(c.Request().Context(), resourceName, offset, limit)
-		if edgeErr.Code() != 0 {
-			return c.JSON(edgeErr.Code(), map[string]string{"error": edgeErr.Message()})
-		}
-		
-		return c.JSON(http.StatusOK, response)
-	})
+	// Every route except the public ones requires a token, API key or client certificate holding
+	// the route's permissions
+	usersService := users.NewService(db)
+	secret, _, err := auth.SigningSecret()
+	if err != nil {
+		panic("Failed to load token signing key: " + err.Error())
+	}
+	verifiers, err := auth.TokenVerifiersFromEnvironment(auth.NewTokenAuthority(secret, time.Hour), usersService)
+	if err != nil {
+		panic("Failed to configure token verification: " + err.Error())
+	}
+	e.Use(auth.Authenticate(verifiers, usersService, usersService, publicRoutes...), ratelimit.Limit(rateLimits.Get, ratelimit.MetricsCounter(gometrics.DefaultRegistry)),
+		auth.RequirePermissions(permissions), tenant.Resolve())
 
 	// EdgeX common routes
 	e.GET("/api/v3/ping", func(c echo.Context) error {
@@ -169,6 +158,13 @@ func main() {
 		port = "59880" // EdgeX Core Data standard port
 	}
 
+	// Serve HTTPS when certificate files are configured, client certificates identify users and
+	// services like their tokens do
+	tlsConfig, err := servertls.FromEnvironment()
+	if err != nil {
+		panic("Failed to configure TLS: " + err.Error())
+	}
+
 	// Graceful shutdown handling
 	ctx, cancel := context.WithCancel(context.Background())
 	var wg sync.WaitGroup
@@ -176,7 +172,15 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := e.Start(":" + port); err != nil {
+		var err error
+		if tlsConfig != nil {
+			e.TLSServer.Addr = ":" + port
+			e.TLSServer.TLSConfig = tlsConfig
+			err = e.StartServer(e.TLSServer)
+		} else {
+			err = e.Start(":" + port)
+		}
+		if err != nil {
 			cancel()
 		}
 	}()
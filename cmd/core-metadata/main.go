@@ -5,16 +5,28 @@ package main
 import (
 	"context"
 	"database/sql"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	_ "github.com/lib/pq"
 
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/auth"
+	"iiot-backend/middleware/sensitive"
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
 	"iiot-backend/services/core/metadata"
+	auditlog "iiot-backend/services/security/audit"
+	"iiot-backend/services/security/encryption"
+	"iiot-backend/services/security/secretstore"
+	"iiot-backend/services/security/servertls"
+	"iiot-backend/services/security/users"
 	"iiot-backend/pkg/common"
 )
 
@@ -46,12 +58,59 @@ func main() {
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
+	// Mutating calls are recorded in the audit log shared with the backend, chained with its key
+	ctx, cancel := context.WithCancel(context.Background())
+	auditLog, err := auditlog.LogFromEnvironment(ctx, db, common.CoreMetadataServiceKey)
+	if err != nil {
+		panic("Failed to load audit log key: " + err.Error())
+	}
+	e.Use(audit.Record(auditLog))
+
+	// Device protocol credentials are encrypted at rest with the keys shared with the backend
+	keys, err := encryption.KeysFromEnvironment(ctx, common.CoreMetadataServiceKey)
+	if err != nil {
+		panic("Failed to load field encryption keys: " + err.Error())
+	}
+
+	// Devices reference their credentials by name from the secret store, without one they carry
+	// them inline
+	secretStore, err := secretstore.FromEnvironment(ctx, common.CoreMetadataServiceKey, logger.NewClient(common.CoreMetadataServiceKey, "INFO"))
+	if err != nil {
+		panic("Failed to open secret store: " + err.Error())
+	}
+
 	// Initialize EdgeX Core Metadata service
-	metadataService := metadata.NewWorkingMetadataService(db)
+	metadataService := metadata.NewWorkingMetadataService(db, keys, secretStore)
 
 	// Register EdgeX v3 API routes
 	v3 := e.Group("/api/v3")
-	metadata.RegisterWorkingEdgeXRoutes(v3, metadataService)
+	permissions := metadata.RegisterWorkingEdgeXRoutes(v3, metadataService)
+	for route, required := range encryption.RegisterRoutes(v3, keys, map[string]encryption.Reencrypter{"devices": metadataService}) {
+		permissions[route] = required
+	}
+	permissions[auth.Route(http.MethodGet, "/api/v3/config")] = []string{"system:read"}
+	var publicRoutes []string
+	for _, route := range metadata.PublicRoutes {
+		publicRoutes = append(publicRoutes, "/api/v3"+route)
+	}
+
+	// Every route except the public ones requires a token, API key or client certificate holding the
+	// route's permissions
+	secret, _, err := auth.SigningSecret()
+	if err != nil {
+		panic("Failed to load token signing key: " + err.Error())
+	}
+	usersService := users.NewService(db)
+	verifiers, err := auth.TokenVerifiersFromEnvironment(auth.NewTokenAuthority(secret, time.Hour), usersService)
+	if err != nil {
+		panic("Failed to configure token verification: " + err.Error())
+	}
+	// Core-command and the device services send tokens issued by the secret store they share
+	if secretStore != nil {
+		verifiers = auth.TokenVerifiers{verifiers, auth.NewServiceTokenVerifier(secretStore, metadata.ServicePermissions...)}
+	}
+	e.Use(auth.Authenticate(verifiers, usersService, usersService, publicRoutes...),
+		auth.RequirePermissions(permissions), tenant.Resolve(), sensitive.Resolve())
 
 	// EdgeX common routes
 	e.GET("/api/v3/ping", func(c echo.Context) error {
@@ -99,14 +158,28 @@ func main() {
 		port = "59881" // EdgeX Core Metadata standard port
 	}
 
+	// Serve HTTPS when certificate files are configured, client certificates identify users and
+	// services like their tokens do
+	tlsConfig, err := servertls.FromEnvironment()
+	if err != nil {
+		panic("Failed to configure TLS: " + err.Error())
+	}
+
 	// Graceful shutdown handling
-	ctx, cancel := context.WithCancel(context.Background())
 	var wg sync.WaitGroup
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := e.Start(":" + port); err != nil {
+		var err error
+		if tlsConfig != nil {
+			e.TLSServer.Addr = ":" + port
+			e.TLSServer.TLSConfig = tlsConfig
+			err = e.StartServer(e.TLSServer)
+		} else {
+			err = e.Start(":" + port)
+		}
+		if err != nil {
 			cancel()
 		}
 	}()
@@ -0,0 +1,211 @@
+// Package main provides a mock OpenID Connect issuer for exercising the backend's OIDC token
+// validation locally. It serves a discovery document and JWKS, mints RS256 tokens on request and
+// can rotate its signing key.
+//
+//	go run ./cmd/mock-oidc-issuer -addr :9000
+//	OIDC_ISSUER=http://localhost:9000 OIDC_AUDIENCE=iiot-backend go run .
+//	curl -X POST 'http://localhost:9000/token?sub=alice&roles=operator'
+//	curl -X POST http://localhost:9000/rotate
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type signingKey struct {
+	id  string
+	key *rsa.PrivateKey
+}
+
+// issuer keeps the current signing key first, the previous one stays published so that tokens
+// signed before a rotation remain valid until they expire
+type issuer struct {
+	url      string
+	audience string
+
+	mutex sync.Mutex
+	keys  []signingKey
+}
+
+func newSigningKey() (signingKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return signingKey{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return signingKey{}, err
+	}
+	return signingKey{id: hex.EncodeToString(id), key: key}, nil
+}
+
+func (i *issuer) rotate() (string, error) {
+	key, err := newSigningKey()
+	if err != nil {
+		return "", err
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.keys = append([]signingKey{key}, i.keys...)
+	if len(i.keys) > 2 {
+		i.keys = i.keys[:2]
+	}
+	return key.id, nil
+}
+
+func (i *issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"issuer":                                i.url,
+		"jwks_uri":                              i.url + "/jwks",
+		"token_endpoint":                        i.url + "/token",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (i *issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	keys := make([]map[string]string, 0, len(i.keys))
+	for _, k := range i.keys {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": k.id,
+			"n":   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+		})
+	}
+	writeJSON(w, map[string]interface{}{"keys": keys})
+}
+
+// token mints an access token for the sub, username, roles and groups query parameters. ttl
+// sets its lifetime and aud overrides the audience, which allows invalid tokens to be produced.
+func (i *issuer) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	subject := query.Get("sub")
+	if subject == "" {
+		http.Error(w, "sub is required", http.StatusBadRequest)
+		return
+	}
+	ttl := time.Hour
+	if value := query.Get("ttl"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			http.Error(w, "invalid ttl", http.StatusBadRequest)
+			return
+		}
+		ttl = parsed
+	}
+	audience := i.audience
+	if query.Has("aud") {
+		audience = query.Get("aud")
+	}
+	username := query.Get("username")
+	if username == "" {
+		username = subject
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                i.url,
+		"sub":                subject,
+		"aud":                audience,
+		"iat":                now.Unix(),
+		"nbf":                now.Unix(),
+		"exp":                now.Add(ttl).Unix(),
+		"preferred_username": username,
+		"roles":              splitList(query.Get("roles")),
+		"groups":             splitList(query.Get("groups")),
+	}
+
+	i.mutex.Lock()
+	current := i.keys[0]
+	i.mutex.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = current.id
+	signed, err := token.SignedString(current.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"access_token": signed,
+		"token_type":   "Bearer",
+		"expires_in":   int(ttl.Seconds()),
+	})
+}
+
+func (i *issuer) rotateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := i.rotate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"kid": id})
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	url := flag.String("issuer", "", "issuer URL, defaults to http://localhost<addr>")
+	audience := flag.String("audience", "iiot-backend", "audience of minted tokens")
+	flag.Parse()
+
+	i := &issuer{url: *url, audience: *audience}
+	if i.url == "" {
+		i.url = "http://localhost" + *addr
+	}
+	if _, err := i.rotate(); err != nil {
+		fmt.Printf("Failed to generate signing key: %v\n", err)
+		os.Exit(1)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("/jwks", i.jwks)
+	mux.HandleFunc("/token", i.token)
+	mux.HandleFunc("/rotate", i.rotateHandler)
+
+	fmt.Printf("Mock OIDC issuer %s listening on %s\n", i.url, *addr)
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		fmt.Printf("Server stopped: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"strconv"
 )
@@ -63,7 +64,7 @@ func Load() (*Config, error) {
 		RedisPort: getEnvAsInt("REDIS_PORT", 6379),
 
 		// Security configuration
-		JWTSecret:     getEnv("JWT_SECRET", "iiot-backend-secret-key"),
+		JWTSecret:     getEnv("JWT_SECRET", ""),
 		APIKeyEnabled: getEnvAsBool("API_KEY_ENABLED", false),
 
 		// Service configuration
@@ -80,6 +81,11 @@ func Load() (*Config, error) {
 		LogFormat: getEnv("LOG_FORMAT", "json"),
 	}
 
+	// tokens are either signed with JWT_SECRET or issued by an OpenID Connect provider
+	if config.JWTSecret == "" && os.Getenv("OIDC_ISSUER") == "" {
+		return nil, errors.New("JWT_SECRET must be set unless OIDC_ISSUER configures an OpenID Connect provider")
+	}
+
 	return config, nil
 }
 
@@ -20,6 +20,7 @@ require (
         github.com/spf13/cast v1.9.2
         github.com/spiffe/go-spiffe/v2 v2.5.0
         github.com/stretchr/testify v1.10.0
+        golang.org/x/crypto v0.38.0
         gopkg.in/yaml.v3 v3.0.1
         iiot-backend/pkg/go-mod-bootstrap v0.0.0-00010101000000-000000000000
         iiot-backend/pkg/go-mod-core-contracts v0.0.0-00010101000000-000000000000
@@ -104,7 +105,6 @@ require (
         go.opentelemetry.io/otel v1.32.0 // indirect
         go.opentelemetry.io/otel/metric v1.32.0 // indirect
         go.opentelemetry.io/otel/trace v1.32.0 // indirect
-        golang.org/x/crypto v0.38.0 // indirect
         golang.org/x/net v0.40.0 // indirect
         golang.org/x/oauth2 v0.30.0 // indirect
         golang.org/x/sync v0.14.0 // indirect
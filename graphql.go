@@ -0,0 +1,663 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/models"
+	"iiot-backend/services/core/metadata"
+	"iiot-backend/services/support/notifications"
+)
+
+const (
+	ApiGraphQLRoute = ApiBase + "/graphql"
+
+	graphQLDefaultLimit = 20
+	graphQLMaxLimit     = 1000
+	// graphQLMaxScanned bounds the objects a filtered list walks through to find its matches
+	graphQLMaxScanned = 10 * graphQLMaxLimit
+)
+
+// GraphQLHandler serves read-only GraphQL queries over devices and their profiles, services, core
+// commands, readings and notifications. Resolvers delegate to the existing service layer.
+type GraphQLHandler struct {
+	service       *UnifiedIIOTService
+	metadata      *metadata.WorkingMetadataService
+	notifications *notifications.Service
+	schema        graphQLSchema
+}
+
+func NewGraphQLHandler(service *UnifiedIIOTService, metadataService *metadata.WorkingMetadataService, notificationService *notifications.Service) *GraphQLHandler {
+	h := &GraphQLHandler{
+		service:       service,
+		metadata:      metadataService,
+		notifications: notificationService,
+	}
+	h.schema = h.buildSchema()
+	return h
+}
+
+// Handle executes a query sent either as a JSON POST body or through the query, operationName and
+// variables URL parameters of a GET request
+func (h *GraphQLHandler) Handle(c echo.Context) error {
+	var req graphQLRequest
+	if c.Request().Method == http.MethodGet {
+		req.Query = c.QueryParam("query")
+		req.OperationName = c.QueryParam("operationName")
+		if variables := c.QueryParam("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return c.JSON(http.StatusBadRequest, graphQLResponse{Errors: []graphQLError{{Message: "variables must be a JSON object"}}})
+			}
+		}
+	} else if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, graphQLResponse{Errors: []graphQLError{{Message: "request body must be a JSON object with a query"}}})
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return c.JSON(http.StatusBadRequest, graphQLResponse{Errors: []graphQLError{{Message: "query is required"}}})
+	}
+
+	ctx := context.WithValue(c.Request().Context(), graphQLLoaderKey{}, newGraphQLLoader())
+	response := executeGraphQL(ctx, h.schema, req)
+	if response.Data == nil {
+		return c.JSON(http.StatusBadRequest, response)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// graphQLLoader memoizes profile and device service lookups for the lifetime of one request, as
+// devices listed together commonly share them
+type graphQLLoader struct {
+	profiles map[string]map[string]interface{}
+	services map[string]map[string]interface{}
+}
+
+type graphQLLoaderKey struct{}
+
+func newGraphQLLoader() *graphQLLoader {
+	return &graphQLLoader{
+		profiles: make(map[string]map[string]interface{}),
+		services: make(map[string]map[string]interface{}),
+	}
+}
+
+func loaderFrom(ctx context.Context) *graphQLLoader {
+	if l, ok := ctx.Value(graphQLLoaderKey{}).(*graphQLLoader); ok {
+		return l
+	}
+	return newGraphQLLoader()
+}
+
+func (h *GraphQLHandler) buildSchema() graphQLSchema {
+	return graphQLSchema{
+		"Query": {
+			"devices":        {Type: "Device", List: true, Resolve: h.resolveDevices},
+			"device":         {Type: "Device", Resolve: h.resolveDevice},
+			"deviceServices": {Type: "DeviceService", List: true, Resolve: h.resolveDeviceServices},
+			"deviceService":  {Type: "DeviceService", Resolve: h.resolveDeviceService},
+			"deviceProfiles": {Type: "DeviceProfile", List: true, Resolve: h.resolveDeviceProfiles},
+			"deviceProfile":  {Type: "DeviceProfile", Resolve: h.resolveDeviceProfile},
+			"events":         {Type: "Event", List: true, Resolve: h.resolveEvents},
+			"readings":       {Type: "Reading", List: true, Resolve: h.resolveReadings},
+			"notifications":  {Type: "Notification", List: true, Resolve: h.resolveNotifications},
+		},
+		"Device": leafFields(
+			[]string{"id", "name", "description", "adminState", "operatingState", "serviceName", "profileName",
+				"labels", "location", "assetNode", "profileVersion", "protocols", "autoEvents", "created", "modified"},
+			map[string]graphQLField{
+				"profile": {Type: "DeviceProfile", Resolve: func(ctx context.Context, source map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+					return h.loadDeviceProfile(ctx, stringField(source, "profileName"))
+				}},
+				"service": {Type: "DeviceService", Resolve: func(ctx context.Context, source map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+					return h.loadDeviceService(ctx, stringField(source, "serviceName"))
+				}},
+				"coreCommands":  {Type: "CoreCommand", List: true, Resolve: h.resolveDeviceCoreCommands},
+				"readings":      {Type: "Reading", List: true, Resolve: h.resolveDeviceReadings},
+				"events":        {Type: "Event", List: true, Resolve: h.resolveDeviceEvents},
+				"notifications": {Type: "Notification", List: true, Resolve: h.resolveDeviceNotifications},
+			}),
+		"DeviceService": leafFields(
+			[]string{"id", "name", "description", "baseAddress", "adminState", "labels", "created", "modified"},
+			map[string]graphQLField{
+				"devices": {Type: "Device", List: true, Resolve: func(ctx context.Context, source map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+					args["serviceName"] = stringField(source, "name")
+					return h.resolveDevices(ctx, nil, args)
+				}},
+			}),
+		"DeviceProfile": leafFields(
+			[]string{"id", "name", "description", "manufacturer", "model", "labels", "deviceResources",
+				"deviceCommands", "version", "created", "modified"},
+			map[string]graphQLField{
+				"devices": {Type: "Device", List: true, Resolve: func(ctx context.Context, source map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+					args["profileName"] = stringField(source, "name")
+					return h.resolveDevices(ctx, nil, args)
+				}},
+			}),
+		"CoreCommand": leafFields([]string{"name", "get", "set", "path", "parameters"}, nil),
+		"Event": leafFields(
+			[]string{"id", "deviceName", "profileName", "sourceName", "profileVersion", "origin", "tags", "created", "modified"},
+			map[string]graphQLField{
+				"readings": {Type: "Reading", List: true},
+				"device":   {Type: "Device", Resolve: h.resolveSourceDevice},
+			}),
+		"Reading": leafFields(
+			[]string{"id", "eventId", "deviceName", "resourceName", "profileName", "valueType", "value", "binaryValue",
+				"mediaType", "units", "tags", "origin", "created", "modified"},
+			map[string]graphQLField{
+				"device": {Type: "Device", Resolve: h.resolveSourceDevice},
+			}),
+		"Notification": leafFields(
+			[]string{"id", "slug", "sender", "category", "severity", "content", "description", "status", "labels",
+				"contentType", "created", "modified"},
+			nil),
+	}
+}
+
+func leafFields(names []string, objects map[string]graphQLField) map[string]graphQLField {
+	fields := make(map[string]graphQLField, len(names)+len(objects))
+	for _, name := range names {
+		fields[name] = graphQLField{}
+	}
+	for name, field := range objects {
+		fields[name] = field
+	}
+	return fields
+}
+
+// Query resolvers
+
+func (h *GraphQLHandler) resolveDevices(ctx context.Context, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	offset, limit, err := pagingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	filters := make(map[string]string)
+	filtered := false
+	for _, name := range []string{"name", "profileName", "serviceName", "adminState", "operatingState", "assetNode", "label"} {
+		if filters[name], err = stringArg(args, name); err != nil {
+			return nil, err
+		}
+		filtered = filtered || filters[name] != ""
+	}
+
+	devices, err := pageFiltered(func(offset, limit int) ([]metadata.Device, error) {
+		devices, _, edgeErr := h.metadata.GetAllDevices(ctx, offset, limit)
+		return devices, edgeXErr(edgeErr)
+	}, func(d metadata.Device) bool {
+		return matchFilter(filters["name"], d.Name) &&
+			matchFilter(filters["profileName"], d.ProfileName) &&
+			matchFilter(filters["serviceName"], d.ServiceName) &&
+			matchFilter(filters["adminState"], d.AdminState) &&
+			matchFilter(filters["operatingState"], d.OperatingState) &&
+			matchFilter(filters["assetNode"], d.AssetNode) &&
+			(filters["label"] == "" || containsString(d.Labels, filters["label"]))
+	}, offset, limit, filtered)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphQLObjects(devices)
+}
+
+func (h *GraphQLHandler) resolveDevice(ctx context.Context, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	name, err := requiredStringArg(args, "name")
+	if err != nil {
+		return nil, err
+	}
+	return h.loadDevice(ctx, name)
+}
+
+func (h *GraphQLHandler) resolveDeviceServices(ctx context.Context, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	offset, limit, err := pagingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	adminState, err := stringArg(args, "adminState")
+	if err != nil {
+		return nil, err
+	}
+	label, err := stringArg(args, "label")
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := pageFiltered(func(offset, limit int) ([]metadata.DeviceService, error) {
+		services, _, edgeErr := h.metadata.GetAllDeviceServices(ctx, offset, limit)
+		return services, edgeXErr(edgeErr)
+	}, func(s metadata.DeviceService) bool {
+		return matchFilter(adminState, s.AdminState) && (label == "" || containsString(s.Labels, label))
+	}, offset, limit, adminState != "" || label != "")
+	if err != nil {
+		return nil, err
+	}
+	return toGraphQLObjects(services)
+}
+
+func (h *GraphQLHandler) resolveDeviceService(ctx context.Context, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	name, err := requiredStringArg(args, "name")
+	if err != nil {
+		return nil, err
+	}
+	return h.loadDeviceService(ctx, name)
+}
+
+func (h *GraphQLHandler) resolveDeviceProfiles(ctx context.Context, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	offset, limit, err := pagingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	manufacturer, err := stringArg(args, "manufacturer")
+	if err != nil {
+		return nil, err
+	}
+	model, err := stringArg(args, "model")
+	if err != nil {
+		return nil, err
+	}
+	label, err := stringArg(args, "label")
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := pageFiltered(func(offset, limit int) ([]metadata.DeviceProfile, error) {
+		profiles, _, edgeErr := h.metadata.GetAllDeviceProfiles(ctx, offset, limit)
+		return profiles, edgeXErr(edgeErr)
+	}, func(p metadata.DeviceProfile) bool {
+		return matchFilter(manufacturer, p.Manufacturer) && matchFilter(model, p.Model) &&
+			(label == "" || containsString(p.Labels, label))
+	}, offset, limit, manufacturer != "" || model != "" || label != "")
+	if err != nil {
+		return nil, err
+	}
+	return toGraphQLObjects(profiles)
+}
+
+func (h *GraphQLHandler) resolveDeviceProfile(ctx context.Context, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	name, err := requiredStringArg(args, "name")
+	if err != nil {
+		return nil, err
+	}
+	return h.loadDeviceProfile(ctx, name)
+}
+
+func (h *GraphQLHandler) resolveEvents(ctx context.Context, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	offset, limit, err := pagingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	deviceName, err := stringArg(args, "deviceName")
+	if err != nil {
+		return nil, err
+	}
+	events, err := h.service.GetAllEvents(ctx, offset, limit, deviceName)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphQLObjects(events)
+}
+
+func (h *GraphQLHandler) resolveReadings(ctx context.Context, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	offset, limit, err := pagingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var filters [3]string
+	for i, name := range []string{"deviceName", "resourceName", "assetNode"} {
+		if filters[i], err = stringArg(args, name); err != nil {
+			return nil, err
+		}
+	}
+	readings, err := h.service.GetAllReadings(ctx, offset, limit, filters[0], filters[1], filters[2])
+	if err != nil {
+		return nil, err
+	}
+	return toGraphQLObjects(readings)
+}
+
+func (h *GraphQLHandler) resolveNotifications(ctx context.Context, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	label, err := stringArg(args, "label")
+	if err != nil {
+		return nil, err
+	}
+	return h.queryNotifications(ctx, args, label)
+}
+
+// Device resolvers
+
+// resolveDeviceCoreCommands derives the device's core commands from its profile in the same way
+// core-command does, hidden commands and resources are left out
+func (h *GraphQLHandler) resolveDeviceCoreCommands(ctx context.Context, source map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+	deviceName := stringField(source, "name")
+	profile, err := h.loadDeviceProfile(ctx, stringField(source, "profileName"))
+	if err != nil || profile == nil {
+		return nil, err
+	}
+
+	resources := make(map[string]map[string]interface{})
+	resourceList, _ := profile["deviceResources"].([]interface{})
+	for _, r := range resourceList {
+		if resource, ok := r.(map[string]interface{}); ok {
+			resources[stringField(resource, "name")] = resource
+		}
+	}
+
+	var commands []map[string]interface{}
+	seen := make(map[string]bool)
+	commandList, _ := profile["deviceCommands"].([]interface{})
+	for _, c := range commandList {
+		command, ok := c.(map[string]interface{})
+		if !ok || boolField(command, "isHidden") {
+			continue
+		}
+		var parameters []map[string]interface{}
+		operations, _ := command["resourceOperations"].([]interface{})
+		for _, o := range operations {
+			operation, _ := o.(map[string]interface{})
+			resource, exists := resources[stringField(operation, "deviceResource")]
+			if !exists {
+				return nil, fmt.Errorf("device command's resource %s doesn't match any device resource", stringField(operation, "deviceResource"))
+			}
+			parameters = append(parameters, coreCommandParameter(resource))
+		}
+		name := stringField(command, "name")
+		seen[name] = true
+		commands = append(commands, coreCommand(deviceName, name, stringField(command, "readWrite"), parameters))
+	}
+	for _, r := range resourceList {
+		resource, ok := r.(map[string]interface{})
+		name := stringField(resource, "name")
+		if !ok || seen[name] || boolField(resource, "isHidden") {
+			continue
+		}
+		properties, _ := resource["properties"].(map[string]interface{})
+		commands = append(commands, coreCommand(deviceName, name, stringField(properties, "readWrite"),
+			[]map[string]interface{}{coreCommandParameter(resource)}))
+	}
+	return commands, nil
+}
+
+func coreCommand(deviceName, name, readWrite string, parameters []map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       name,
+		"get":        strings.Contains(readWrite, "R"),
+		"set":        strings.Contains(readWrite, "W"),
+		"path":       fmt.Sprintf("%s/%s/%s", ApiDeviceCommandRoute, deviceName, name),
+		"parameters": parameters,
+	}
+}
+
+func coreCommandParameter(resource map[string]interface{}) map[string]interface{} {
+	properties, _ := resource["properties"].(map[string]interface{})
+	return map[string]interface{}{
+		"resourceName": stringField(resource, "name"),
+		"valueType":    stringField(properties, "valueType"),
+	}
+}
+
+// resolveDeviceReadings returns the device's latest readings first
+func (h *GraphQLHandler) resolveDeviceReadings(ctx context.Context, source map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	offset, limit, err := pagingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	resourceName, err := stringArg(args, "resourceName")
+	if err != nil {
+		return nil, err
+	}
+	readings, err := h.service.GetAllReadings(ctx, offset, limit, stringField(source, "name"), resourceName, "")
+	if err != nil {
+		return nil, err
+	}
+	return toGraphQLObjects(readings)
+}
+
+func (h *GraphQLHandler) resolveDeviceEvents(ctx context.Context, source map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	offset, limit, err := pagingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	events, err := h.service.GetAllEvents(ctx, offset, limit, stringField(source, "name"))
+	if err != nil {
+		return nil, err
+	}
+	return toGraphQLObjects(events)
+}
+
+// resolveDeviceNotifications returns the notifications labeled with the device name
+func (h *GraphQLHandler) resolveDeviceNotifications(ctx context.Context, source map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	return h.queryNotifications(ctx, args, stringField(source, "name"))
+}
+
+func (h *GraphQLHandler) resolveSourceDevice(ctx context.Context, source map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+	return h.loadDevice(ctx, stringField(source, "deviceName"))
+}
+
+func (h *GraphQLHandler) queryNotifications(ctx context.Context, args map[string]interface{}, label string) (interface{}, error) {
+	offset, limit, err := pagingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var filter models.NotificationFilter
+	if filter.Category, err = stringArg(args, "category"); err != nil {
+		return nil, err
+	}
+	if filter.Severity, err = stringArg(args, "severity"); err != nil {
+		return nil, err
+	}
+	if filter.Status, err = stringArg(args, "status"); err != nil {
+		return nil, err
+	}
+
+	notificationList, err := pageFiltered(func(offset, limit int) ([]models.Notification, error) {
+		filter.Offset, filter.Limit = offset, limit
+		return h.notifications.WithTenant(tenant.FromContext(ctx)).GetNotifications(filter)
+	}, func(n models.Notification) bool {
+		return label == "" || containsString(n.Labels, label)
+	}, offset, limit, label != "")
+	if err != nil {
+		return nil, err
+	}
+	return toGraphQLObjects(notificationList)
+}
+
+// Lookups by name, a missing entity resolves to null rather than an error
+
+func (h *GraphQLHandler) loadDevice(ctx context.Context, name string) (interface{}, error) {
+	if name == "" {
+		return nil, nil
+	}
+	device, edgeErr := h.metadata.GetDeviceByName(ctx, name)
+	if edgeErr.Code == http.StatusNotFound {
+		return nil, nil
+	} else if edgeErr.Code != 0 {
+		return nil, edgeErr
+	}
+	return toGraphQLObject(device)
+}
+
+func (h *GraphQLHandler) loadDeviceProfile(ctx context.Context, name string) (map[string]interface{}, error) {
+	l := loaderFrom(ctx)
+	if profile, ok := l.profiles[name]; ok || name == "" {
+		return profile, nil
+	}
+	profile, edgeErr := h.metadata.GetDeviceProfileByName(ctx, name)
+	if edgeErr.Code == http.StatusNotFound {
+		l.profiles[name] = nil
+		return nil, nil
+	} else if edgeErr.Code != 0 {
+		return nil, edgeErr
+	}
+	object, err := toGraphQLObject(profile)
+	if err != nil {
+		return nil, err
+	}
+	l.profiles[name] = object
+	return object, nil
+}
+
+func (h *GraphQLHandler) loadDeviceService(ctx context.Context, name string) (map[string]interface{}, error) {
+	l := loaderFrom(ctx)
+	if service, ok := l.services[name]; ok || name == "" {
+		return service, nil
+	}
+	service, edgeErr := h.metadata.GetDeviceServiceByName(ctx, name)
+	if edgeErr.Code == http.StatusNotFound {
+		l.services[name] = nil
+		return nil, nil
+	} else if edgeErr.Code != 0 {
+		return nil, edgeErr
+	}
+	object, err := toGraphQLObject(service)
+	if err != nil {
+		return nil, err
+	}
+	l.services[name] = object
+	return object, nil
+}
+
+// Helpers
+
+// pageFiltered applies offset and limit after filtering. The underlying services only paginate, so
+// when filtering it walks their pages until enough matches are collected, giving up after
+// graphQLMaxScanned objects.
+func pageFiltered[T any](fetch func(offset, limit int) ([]T, error), match func(T) bool, offset, limit int, filtered bool) ([]T, error) {
+	if !filtered {
+		return fetch(offset, limit)
+	}
+	var matches []T
+	skipped := 0
+	for pageOffset := 0; len(matches) < limit; pageOffset += graphQLMaxLimit {
+		if pageOffset >= graphQLMaxScanned {
+			return nil, fmt.Errorf("the filters match too few of the first %d objects, narrow them down", graphQLMaxScanned)
+		}
+		page, err := fetch(pageOffset, graphQLMaxLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page {
+			if !match(item) {
+				continue
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if len(matches) < limit {
+				matches = append(matches, item)
+			}
+		}
+		if len(page) < graphQLMaxLimit {
+			break
+		}
+	}
+	return matches, nil
+}
+
+func edgeXErr(err metadata.EdgeXError) error {
+	if err.Code == 0 {
+		return nil
+	}
+	return err
+}
+
+// toGraphQLObject converts a model into its JSON object form so that field names match the REST API
+func toGraphQLObject(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func toGraphQLObjects[T any](items []T) ([]map[string]interface{}, error) {
+	objects := make([]map[string]interface{}, len(items))
+	for i := range items {
+		object, err := toGraphQLObject(items[i])
+		if err != nil {
+			return nil, err
+		}
+		objects[i] = object
+	}
+	return objects, nil
+}
+
+func pagingArgs(args map[string]interface{}) (offset int, limit int, err error) {
+	if offset, err = intArg(args, "offset", 0); err != nil {
+		return 0, 0, err
+	}
+	if limit, err = intArg(args, "limit", graphQLDefaultLimit); err != nil {
+		return 0, 0, err
+	}
+	if offset < 0 {
+		return 0, 0, fmt.Errorf("offset must not be negative")
+	}
+	if limit <= 0 || limit > graphQLMaxLimit {
+		return 0, 0, fmt.Errorf("limit must be between 1 and %d", graphQLMaxLimit)
+	}
+	return offset, limit, nil
+}
+
+func intArg(args map[string]interface{}, name string, defaultValue int) (int, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return defaultValue, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		// variables decoded from JSON arrive as float64
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("argument %s must be an integer", name)
+		}
+		return int(v), nil
+	}
+	return 0, fmt.Errorf("argument %s must be an integer", name)
+}
+
+func stringArg(args map[string]interface{}, name string) (string, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("argument %s must be a string", name)
+}
+
+func requiredStringArg(args map[string]interface{}, name string) (string, error) {
+	v, err := stringArg(args, name)
+	if err == nil && v == "" {
+		err = fmt.Errorf("argument %s is required", name)
+	}
+	return v, err
+}
+
+func stringField(object map[string]interface{}, name string) string {
+	s, _ := object[name].(string)
+	return s
+}
+
+func boolField(object map[string]interface{}, name string) bool {
+	b, _ := object[name].(bool)
+	return b
+}
+
+func matchFilter(filter, value string) bool {
+	return filter == "" || filter == value
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
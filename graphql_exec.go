@@ -0,0 +1,820 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file holds a deliberately small GraphQL engine: a query-only parser and executor covering
+// fields, aliases, arguments, variables, fragments and the @include/@skip directives. Mutations,
+// subscriptions and introspection are not supported since the endpoint only serves reads.
+
+// Limits protecting the backend from expensive queries. The cost of a query estimates the objects it
+// resolves to: each list field counts its limit argument times the objects it is selected on.
+const (
+	graphQLMaxDepth   = 10
+	graphQLMaxCost    = 10000
+	graphQLMaxAliases = 20
+	graphQLMaxResults = 10000
+)
+
+type graphQLField struct {
+	// Type names the object type the field resolves to, empty for leaf values
+	Type string
+	// List marks fields resolving to a list, of up to their limit argument or graphQLDefaultLimit objects
+	List bool
+	// Resolve computes the field value, when nil the value is read from the source object
+	Resolve func(ctx context.Context, source map[string]interface{}, args map[string]interface{}) (interface{}, error)
+}
+
+type graphQLSchema map[string]map[string]graphQLField
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   *graphQLResult `json:"data,omitempty"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+// graphQLResult keeps response keys in selection order as required by the GraphQL spec
+type graphQLResult struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newGraphQLResult() *graphQLResult {
+	return &graphQLResult{values: make(map[string]interface{})}
+}
+
+func (r *graphQLResult) set(key string, value interface{}) {
+	if _, ok := r.values[key]; !ok {
+		r.keys = append(r.keys, key)
+	}
+	r.values[key] = value
+}
+
+func (r *graphQLResult) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Lexer
+
+const (
+	gqlEOF byte = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+type gqlToken struct {
+	kind  byte
+	value string
+	pos   int
+}
+
+func lexGraphQL(src string) ([]gqlToken, error) {
+	var tokens []gqlToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		case strings.IndexByte("!$():=@[]{}|&", c) >= 0:
+			tokens = append(tokens, gqlToken{kind: gqlPunct, value: string(c), pos: i})
+			i++
+		case c == '.':
+			if !strings.HasPrefix(src[i:], "...") {
+				return nil, fmt.Errorf("unexpected character '.' at position %d", i)
+			}
+			tokens = append(tokens, gqlToken{kind: gqlPunct, value: "...", pos: i})
+			i += 3
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+			for i < len(src) && (src[i] == '_' || (src[i] >= 'a' && src[i] <= 'z') || (src[i] >= 'A' && src[i] <= 'Z') || (src[i] >= '0' && src[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, gqlToken{kind: gqlName, value: src[start:i], pos: start})
+		case c == '-' || (c >= '0' && c <= '9'):
+			start := i
+			kind := gqlInt
+			i++
+			for i < len(src) && strings.IndexByte("0123456789.eE+-", src[i]) >= 0 {
+				if src[i] == '.' || src[i] == 'e' || src[i] == 'E' {
+					kind = gqlFloat
+				}
+				i++
+			}
+			tokens = append(tokens, gqlToken{kind: kind, value: src[start:i], pos: start})
+		case c == '"':
+			if strings.HasPrefix(src[i:], `"""`) {
+				end := strings.Index(src[i+3:], `"""`)
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated block string at position %d", i)
+				}
+				tokens = append(tokens, gqlToken{kind: gqlString, value: src[i+3 : i+3+end], pos: i})
+				i += end + 6
+				continue
+			}
+			start := i
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' {
+					i++
+				}
+				if i < len(src) && (src[i] == '\n' || src[i] == '\r') {
+					return nil, fmt.Errorf("unterminated string at position %d", start)
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			value, err := strconv.Unquote(src[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d", start)
+			}
+			tokens = append(tokens, gqlToken{kind: gqlString, value: value, pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	return append(tokens, gqlToken{kind: gqlEOF, pos: len(src)}), nil
+}
+
+// Parser
+
+type gqlVariable string
+
+type gqlSelection struct {
+	alias      string
+	name       string
+	args       map[string]interface{}
+	directives map[string]map[string]interface{}
+	selections []gqlSelection
+	// fragment is set for fragment spreads
+	fragment string
+	// inline is set for inline fragments, typeCondition may stay empty
+	inline        bool
+	typeCondition string
+}
+
+type gqlOperation struct {
+	kind       string
+	name       string
+	defaults   map[string]interface{}
+	selections []gqlSelection
+}
+
+type gqlFragment struct {
+	typeCondition string
+	selections    []gqlSelection
+}
+
+type gqlDocument struct {
+	operations []gqlOperation
+	fragments  map[string]gqlFragment
+}
+
+type gqlParser struct {
+	tokens []gqlToken
+	pos    int
+}
+
+func parseGraphQL(src string) (gqlDocument, error) {
+	tokens, err := lexGraphQL(src)
+	if err != nil {
+		return gqlDocument{}, err
+	}
+	p := &gqlParser{tokens: tokens}
+	doc := gqlDocument{fragments: make(map[string]gqlFragment)}
+	for p.peek().kind != gqlEOF {
+		t := p.peek()
+		switch {
+		case t.kind == gqlPunct && t.value == "{":
+			selections, err := p.selectionSet()
+			if err != nil {
+				return doc, err
+			}
+			doc.operations = append(doc.operations, gqlOperation{kind: "query", selections: selections})
+		case t.kind == gqlName && t.value == "fragment":
+			p.next()
+			name, err := p.expectName()
+			if err != nil {
+				return doc, err
+			}
+			if err := p.expectKeyword("on"); err != nil {
+				return doc, err
+			}
+			typeCondition, err := p.expectName()
+			if err != nil {
+				return doc, err
+			}
+			if _, err := p.directives(); err != nil {
+				return doc, err
+			}
+			selections, err := p.selectionSet()
+			if err != nil {
+				return doc, err
+			}
+			doc.fragments[name] = gqlFragment{typeCondition: typeCondition, selections: selections}
+		case t.kind == gqlName:
+			op, err := p.operation()
+			if err != nil {
+				return doc, err
+			}
+			doc.operations = append(doc.operations, op)
+		default:
+			return doc, p.unexpected(t)
+		}
+	}
+	if len(doc.operations) == 0 {
+		return doc, fmt.Errorf("document does not contain any operation")
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) peek() gqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *gqlParser) next() gqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != gqlEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *gqlParser) unexpected(t gqlToken) error {
+	if t.kind == gqlEOF {
+		return fmt.Errorf("unexpected end of document")
+	}
+	return fmt.Errorf("unexpected %q at position %d", t.value, t.pos)
+}
+
+func (p *gqlParser) skipPunct(value string) bool {
+	if t := p.peek(); t.kind == gqlPunct && t.value == value {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *gqlParser) expectPunct(value string) error {
+	if !p.skipPunct(value) {
+		return p.unexpected(p.peek())
+	}
+	return nil
+}
+
+func (p *gqlParser) expectName() (string, error) {
+	t := p.next()
+	if t.kind != gqlName {
+		return "", p.unexpected(t)
+	}
+	return t.value, nil
+}
+
+func (p *gqlParser) expectKeyword(keyword string) error {
+	t := p.next()
+	if t.kind != gqlName || t.value != keyword {
+		return p.unexpected(t)
+	}
+	return nil
+}
+
+func (p *gqlParser) operation() (gqlOperation, error) {
+	op := gqlOperation{defaults: make(map[string]interface{})}
+	kind, _ := p.expectName()
+	op.kind = kind
+	if t := p.peek(); t.kind == gqlName {
+		op.name = p.next().value
+	}
+	if p.skipPunct("(") {
+		for !p.skipPunct(")") {
+			if err := p.expectPunct("$"); err != nil {
+				return op, err
+			}
+			name, err := p.expectName()
+			if err != nil {
+				return op, err
+			}
+			if err := p.expectPunct(":"); err != nil {
+				return op, err
+			}
+			if err := p.typeRef(); err != nil {
+				return op, err
+			}
+			if p.skipPunct("=") {
+				value, err := p.value(true)
+				if err != nil {
+					return op, err
+				}
+				op.defaults[name] = value
+			}
+			if _, err := p.directives(); err != nil {
+				return op, err
+			}
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return op, err
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return op, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+// typeRef skips over a variable type, variables are coerced by the resolvers that consume them
+func (p *gqlParser) typeRef() error {
+	if p.skipPunct("[") {
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return err
+	}
+	p.skipPunct("!")
+	return nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	var selections []gqlSelection
+	for !p.skipPunct("}") {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, s)
+	}
+	if len(selections) == 0 {
+		return nil, fmt.Errorf("selection set must not be empty")
+	}
+	return selections, nil
+}
+
+func (p *gqlParser) selection() (gqlSelection, error) {
+	var s gqlSelection
+	var err error
+	if p.skipPunct("...") {
+		if t := p.peek(); t.kind == gqlName && t.value != "on" {
+			s.fragment = p.next().value
+			s.directives, err = p.directives()
+			return s, err
+		}
+		s.inline = true
+		if t := p.peek(); t.kind == gqlName && t.value == "on" {
+			p.next()
+			if s.typeCondition, err = p.expectName(); err != nil {
+				return s, err
+			}
+		}
+		if s.directives, err = p.directives(); err != nil {
+			return s, err
+		}
+		s.selections, err = p.selectionSet()
+		return s, err
+	}
+
+	if s.name, err = p.expectName(); err != nil {
+		return s, err
+	}
+	if p.skipPunct(":") {
+		s.alias = s.name
+		if s.name, err = p.expectName(); err != nil {
+			return s, err
+		}
+	}
+	if s.args, err = p.arguments(); err != nil {
+		return s, err
+	}
+	if s.directives, err = p.directives(); err != nil {
+		return s, err
+	}
+	if t := p.peek(); t.kind == gqlPunct && t.value == "{" {
+		s.selections, err = p.selectionSet()
+	}
+	return s, err
+}
+
+func (p *gqlParser) arguments() (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	if !p.skipPunct("(") {
+		return args, nil
+	}
+	for !p.skipPunct(")") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.value(false)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = value
+	}
+	return args, nil
+}
+
+func (p *gqlParser) directives() (map[string]map[string]interface{}, error) {
+	var directives map[string]map[string]interface{}
+	for p.skipPunct("@") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		if directives == nil {
+			directives = make(map[string]map[string]interface{})
+		}
+		directives[name] = args
+	}
+	return directives, nil
+}
+
+func (p *gqlParser) value(constant bool) (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case gqlInt:
+		return strconv.ParseInt(t.value, 10, 64)
+	case gqlFloat:
+		return strconv.ParseFloat(t.value, 64)
+	case gqlString:
+		return t.value, nil
+	case gqlName:
+		switch t.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		// enum values are handed to resolvers as plain strings
+		return t.value, nil
+	case gqlPunct:
+		switch t.value {
+		case "$":
+			if constant {
+				return nil, fmt.Errorf("variables are not allowed in default values")
+			}
+			name, err := p.expectName()
+			return gqlVariable(name), err
+		case "[":
+			list := []interface{}{}
+			for !p.skipPunct("]") {
+				v, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, nil
+		case "{":
+			object := make(map[string]interface{})
+			for !p.skipPunct("}") {
+				name, err := p.expectName()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expectPunct(":"); err != nil {
+					return nil, err
+				}
+				v, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				object[name] = v
+			}
+			return object, nil
+		}
+	}
+	return nil, p.unexpected(t)
+}
+
+// Executor
+
+type gqlExecutor struct {
+	schema    graphQLSchema
+	fragments map[string]gqlFragment
+	variables map[string]interface{}
+	errors    []graphQLError
+	// results counts the objects of the lists resolved so far
+	results int
+}
+
+func executeGraphQL(ctx context.Context, schema graphQLSchema, req graphQLRequest) graphQLResponse {
+	doc, err := parseGraphQL(req.Query)
+	if err != nil {
+		return graphQLResponse{Errors: []graphQLError{{Message: fmt.Sprintf("syntax error: %v", err)}}}
+	}
+
+	var op *gqlOperation
+	for i := range doc.operations {
+		if req.OperationName == "" || doc.operations[i].name == req.OperationName {
+			if op != nil {
+				return graphQLResponse{Errors: []graphQLError{{Message: "operationName is required when the document contains several operations"}}}
+			}
+			op = &doc.operations[i]
+		}
+	}
+	if op == nil {
+		return graphQLResponse{Errors: []graphQLError{{Message: fmt.Sprintf("unknown operation %s", req.OperationName)}}}
+	}
+	if op.kind != "query" {
+		return graphQLResponse{Errors: []graphQLError{{Message: fmt.Sprintf("%s operations are not supported, the endpoint is read-only", op.kind)}}}
+	}
+
+	variables := make(map[string]interface{})
+	for name, value := range op.defaults {
+		variables[name] = value
+	}
+	for name, value := range req.Variables {
+		variables[name] = value
+	}
+
+	e := &gqlExecutor{schema: schema, fragments: doc.fragments, variables: variables}
+	aliases := 0
+	if cost := e.cost("Query", op.selections, 1, 0, &aliases); cost > graphQLMaxCost {
+		return graphQLResponse{Errors: []graphQLError{{Message: fmt.Sprintf("query exceeds the maximum cost of %d, lower the limits of nested lists", graphQLMaxCost)}}}
+	}
+	if aliases > graphQLMaxAliases {
+		return graphQLResponse{Errors: []graphQLError{{Message: fmt.Sprintf("query uses more than %d aliases", graphQLMaxAliases)}}}
+	}
+	data := e.selectionSet(ctx, "Query", nil, op.selections, nil)
+	return graphQLResponse{Data: data, Errors: e.errors}
+}
+
+func (e *gqlExecutor) addError(path []interface{}, format string, args ...interface{}) {
+	e.errors = append(e.errors, graphQLError{
+		Message: fmt.Sprintf(format, args...),
+		Path:    append([]interface{}(nil), path...),
+	})
+}
+
+func (e *gqlExecutor) resolveValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case gqlVariable:
+		return e.variables[string(value)]
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i := range value {
+			list[i] = e.resolveValue(value[i])
+		}
+		return list
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for k := range value {
+			object[k] = e.resolveValue(value[k])
+		}
+		return object
+	}
+	return v
+}
+
+func (e *gqlExecutor) included(directives map[string]map[string]interface{}) bool {
+	if skip, ok := directives["skip"]; ok {
+		if b, _ := e.resolveValue(skip["if"]).(bool); b {
+			return false
+		}
+	}
+	if include, ok := directives["include"]; ok {
+		if b, _ := e.resolveValue(include["if"]).(bool); !b {
+			return false
+		}
+	}
+	return true
+}
+
+// collectFields flattens fragments and groups the selected fields by response key
+func (e *gqlExecutor) collectFields(typeName string, selections []gqlSelection, keys *[]string, fields map[string][]gqlSelection, visited map[string]bool) {
+	for _, s := range selections {
+		if !e.included(s.directives) {
+			continue
+		}
+		switch {
+		case s.fragment != "":
+			if visited[s.fragment] {
+				continue
+			}
+			visited[s.fragment] = true
+			fragment, ok := e.fragments[s.fragment]
+			if !ok || fragment.typeCondition != typeName {
+				continue
+			}
+			e.collectFields(typeName, fragment.selections, keys, fields, visited)
+		case s.inline:
+			if s.typeCondition != "" && s.typeCondition != typeName {
+				continue
+			}
+			e.collectFields(typeName, s.selections, keys, fields, visited)
+		default:
+			key := s.name
+			if s.alias != "" {
+				key = s.alias
+			}
+			if _, ok := fields[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			fields[key] = append(fields[key], s)
+		}
+	}
+}
+
+// cost estimates the number of objects the selections resolve to when selected on multiplier objects,
+// and adds the aliases they use to aliases. It stops counting once the cost exceeds graphQLMaxCost.
+func (e *gqlExecutor) cost(typeName string, selections []gqlSelection, multiplier int, depth int, aliases *int) int {
+	var keys []string
+	fields := make(map[string][]gqlSelection)
+	e.collectFields(typeName, selections, &keys, fields, make(map[string]bool))
+
+	total := 0
+	for _, key := range keys {
+		for _, s := range fields[key] {
+			if s.alias != "" {
+				*aliases++
+			}
+		}
+		s := fields[key][0]
+		def, ok := e.schema[typeName][s.name]
+		if !ok || def.Type == "" {
+			continue
+		}
+		if depth >= graphQLMaxDepth {
+			// execution rejects the query anyway
+			continue
+		}
+
+		nodes := multiplier
+		if def.List {
+			args, _ := e.resolveValue(s.args).(map[string]interface{})
+			limit, err := intArg(args, "limit", graphQLDefaultLimit)
+			if err != nil || limit <= 0 || limit > graphQLMaxLimit {
+				limit = graphQLMaxLimit
+			}
+			nodes *= limit
+		}
+
+		var subSelections []gqlSelection
+		for _, sel := range fields[key] {
+			subSelections = append(subSelections, sel.selections...)
+		}
+		total += nodes
+		if total <= graphQLMaxCost {
+			total += e.cost(def.Type, subSelections, nodes, depth+1, aliases)
+		}
+		if total > graphQLMaxCost {
+			return total
+		}
+	}
+	return total
+}
+
+// countResults adds the objects of a resolved list to the results of the query, reporting an error
+// once they exceed graphQLMaxResults
+func (e *gqlExecutor) countResults(path []interface{}, count int) bool {
+	e.results += count
+	if e.results > graphQLMaxResults {
+		e.addError(path, "query exceeds the maximum of %d results", graphQLMaxResults)
+		return false
+	}
+	return true
+}
+
+func (e *gqlExecutor) selectionSet(ctx context.Context, typeName string, source map[string]interface{}, selections []gqlSelection, path []interface{}) *graphQLResult {
+	var keys []string
+	fields := make(map[string][]gqlSelection)
+	e.collectFields(typeName, selections, &keys, fields, make(map[string]bool))
+
+	result := newGraphQLResult()
+	for _, key := range keys {
+		result.set(key, e.field(ctx, typeName, source, fields[key], append(path, key)))
+	}
+	return result
+}
+
+func (e *gqlExecutor) field(ctx context.Context, typeName string, source map[string]interface{}, selections []gqlSelection, path []interface{}) interface{} {
+	s := selections[0]
+	if s.name == "__typename" {
+		return typeName
+	}
+	def, ok := e.schema[typeName][s.name]
+	if !ok {
+		e.addError(path, "cannot query field %s on type %s", s.name, typeName)
+		return nil
+	}
+
+	var subSelections []gqlSelection
+	for _, sel := range selections {
+		subSelections = append(subSelections, sel.selections...)
+	}
+	if def.Type == "" && len(subSelections) > 0 {
+		e.addError(path, "field %s of type %s must not have a selection", s.name, typeName)
+		return nil
+	}
+	if def.Type != "" && len(subSelections) == 0 {
+		e.addError(path, "field %s of type %s must have a selection of subfields", s.name, typeName)
+		return nil
+	}
+	if def.Type != "" && len(path) > graphQLMaxDepth {
+		e.addError(path, "query exceeds the maximum depth of %d", graphQLMaxDepth)
+		return nil
+	}
+
+	var value interface{}
+	if def.Resolve == nil {
+		value = source[s.name]
+	} else {
+		args := e.resolveValue(s.args).(map[string]interface{})
+		var err error
+		if value, err = def.Resolve(ctx, source, args); err != nil {
+			e.addError(path, "%v", err)
+			return nil
+		}
+	}
+	if def.Type == "" || value == nil {
+		return value
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return nil
+		}
+		return e.selectionSet(ctx, def.Type, v, subSelections, path)
+	case []map[string]interface{}:
+		if !e.countResults(path, len(v)) {
+			return nil
+		}
+		list := make([]interface{}, len(v))
+		for i := range v {
+			list[i] = e.selectionSet(ctx, def.Type, v[i], subSelections, append(path, i))
+		}
+		return list
+	case []interface{}:
+		if !e.countResults(path, len(v)) {
+			return nil
+		}
+		list := make([]interface{}, len(v))
+		for i := range v {
+			if object, ok := v[i].(map[string]interface{}); ok {
+				list[i] = e.selectionSet(ctx, def.Type, object, subSelections, append(path, i))
+			}
+		}
+		return list
+	}
+	e.addError(path, "field %s of type %s resolved to an unexpected value", s.name, typeName)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
         "context"
         "database/sql"
         "encoding/json"
+        "errors"
         "fmt"
         "net/http"
         "os"
@@ -15,10 +16,24 @@ import (
         "github.com/labstack/echo/v4"
         "github.com/labstack/echo/v4/middleware"
         _ "github.com/lib/pq"
+        gometrics "github.com/rcrowley/go-metrics"
 
+        "iiot-backend/middleware/audit"
+        "iiot-backend/middleware/auth"
+        "iiot-backend/middleware/ratelimit"
+        "iiot-backend/middleware/sensitive"
+        "iiot-backend/middleware/tenant"
         "iiot-backend/models"
+        "iiot-backend/pkg/go-mod-bootstrap/config"
         "iiot-backend/services/core/command/application"
         "iiot-backend/services/core/command/controller"
+        "iiot-backend/services/core/data"
+        "iiot-backend/services/core/metadata"
+        auditlog "iiot-backend/services/security/audit"
+        "iiot-backend/services/security/encryption"
+        "iiot-backend/services/security/servertls"
+        "iiot-backend/services/security/users"
+        "iiot-backend/services/support/notifications"
 )
 
 // Core service constants
@@ -51,11 +66,12 @@ const (
 
 // UnifiedIIOTService provides all core functionality using your shared libraries
 type UnifiedIIOTService struct {
-        db *sql.DB
+        db   *sql.DB
+        keys *sensitive.Keyring
 }
 
-func NewUnifiedIIOTService(db *sql.DB) *UnifiedIIOTService {
-        return &UnifiedIIOTService{db: db}
+func NewUnifiedIIOTService(db *sql.DB, keys *sensitive.Keyring) *UnifiedIIOTService {
+        return &UnifiedIIOTService{db: db, keys: keys}
 }
 
 // Core Metadata Service Methods
@@ -67,12 +83,14 @@ func (s *UnifiedIIOTService) GetAllDeviceServices(ctx context.Context, offset, l
                 offset = 0
         }
 
+        args := []interface{}{limit, offset}
         query := `
                 SELECT id, name, description, base_address, admin_state, labels, created, modified
                 FROM device_services
+                WHERE ` + tenant.FromContext(ctx).Condition("tenant_id", &args) + `
                 ORDER BY name LIMIT $1 OFFSET $2`
 
-        rows, err := s.db.QueryContext(ctx, query, limit, offset)
+        rows, err := s.db.QueryContext(ctx, query, args...)
         if err != nil {
                 return nil, fmt.Errorf("failed to query device services: %w", err)
         }
@@ -118,13 +136,15 @@ func (s *UnifiedIIOTService) GetAllDevices(ctx context.Context, offset, limit in
                 offset = 0
         }
 
+        args := []interface{}{limit, offset}
         query := `
                 SELECT id, name, description, admin_state, operating_state, protocols, labels,
                        service_name, profile_name, created, modified
                 FROM devices
+                WHERE ` + tenant.FromContext(ctx).Condition("tenant_id", &args) + `
                 ORDER BY name LIMIT $1 OFFSET $2`
 
-        rows, err := s.db.QueryContext(ctx, query, limit, offset)
+        rows, err := s.db.QueryContext(ctx, query, args...)
         if err != nil {
                 return nil, fmt.Errorf("failed to query devices: %w", err)
         }
@@ -142,11 +162,11 @@ func (s *UnifiedIIOTService) GetAllDevices(ctx context.Context, offset, limit in
                         continue
                 }
 
-                var protocols map[string]interface{}
-                var labels []string
-                if len(protocolsJSON) > 0 {
-                        json.Unmarshal(protocolsJSON, &protocols)
+                protocols, err := s.deviceProtocols(ctx, id, protocolsJSON)
+                if err != nil {
+                        return nil, err
                 }
+                var labels []string
                 if len(labelsJSON) > 0 {
                         json.Unmarshal(labelsJSON, &labels)
                 }
@@ -179,14 +199,16 @@ func (s *UnifiedIIOTService) GetAllEvents(ctx context.Context, offset, limit int
                 offset = 0
         }
 
+        args := []interface{}{deviceName, limit, offset}
         query := `
                 SELECT id, device_name, profile_name, source_name, origin, tags, created, modified
                 FROM events
                 WHERE ($1 = '' OR device_name = $1)
+                  AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args) + `
                 ORDER BY created DESC
                 LIMIT $2 OFFSET $3`
 
-        rows, err := s.db.QueryContext(ctx, query, deviceName, limit, offset)
+        rows, err := s.db.QueryContext(ctx, query, args...)
         if err != nil {
                 return nil, fmt.Errorf("failed to query events: %w", err)
         }
@@ -219,7 +241,7 @@ func (s *UnifiedIIOTService) GetAllEvents(ctx context.Context, offset, limit int
         return events, nil
 }
 
-func (s *UnifiedIIOTService) GetAllReadings(ctx context.Context, offset, limit int, deviceName, resourceName string) ([]models.Reading, error) {
+func (s *UnifiedIIOTService) GetAllReadings(ctx context.Context, offset, limit int, deviceName, resourceName, assetNode string) ([]models.Reading, error) {
         if limit <= 0 {
                 limit = 20
         }
@@ -227,16 +249,19 @@ func (s *UnifiedIIOTService) GetAllReadings(ctx context.Context, offset, limit i
                 offset = 0
         }
 
+        args := []interface{}{deviceName, resourceName, limit, offset, assetNode}
         query := `
                 SELECT id, event_id, device_name, resource_name, profile_name, value_type,
                        value, binary_value, media_type, units, tags, origin, created, modified
                 FROM readings
                 WHERE ($1 = '' OR device_name = $1)
                   AND ($2 = '' OR resource_name = $2)
+                  AND ($5 = '' OR device_name IN (` + metadata.AssetDevicesSQL("$5") + `))
+                  AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args) + `
                 ORDER BY created DESC
                 LIMIT $3 OFFSET $4`
 
-        rows, err := s.db.QueryContext(ctx, query, deviceName, resourceName, limit, offset)
+        rows, err := s.db.QueryContext(ctx, query, args...)
         if err != nil {
                 return nil, fmt.Errorf("failed to query readings: %w", err)
         }
@@ -304,27 +329,28 @@ func (s *UnifiedIIOTService) getReadingsByEventID(ctx context.Context, eventID s
 
 // Core Command Service Methods
 func (s *UnifiedIIOTService) GetDeviceByName(ctx context.Context, name string) (map[string]interface{}, error) {
+        args := []interface{}{name}
         query := `
                 SELECT id, name, description, admin_state, operating_state, protocols, labels,
                        service_name, profile_name, created, modified
                 FROM devices
-                WHERE name = $1`
+                WHERE name = $1 AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args)
 
         var id, deviceName, description, adminState, operatingState, serviceName, profileName string
         var protocolsJSON, labelsJSON []byte
         var created, modified int64
 
-        err := s.db.QueryRowContext(ctx, query, name).Scan(&id, &deviceName, &description, &adminState,
+        err := s.db.QueryRowContext(ctx, query, args...).Scan(&id, &deviceName, &description, &adminState,
                 &operatingState, &protocolsJSON, &labelsJSON, &serviceName, &profileName, &created, &modified)
         if err != nil {
                 return nil, err
         }
 
-        var protocols map[string]interface{}
-        var labels []string
-        if len(protocolsJSON) > 0 {
-                json.Unmarshal(protocolsJSON, &protocols)
+        protocols, err := s.deviceProtocols(ctx, id, protocolsJSON)
+        if err != nil {
+                return nil, err
         }
+        var labels []string
         if len(labelsJSON) > 0 {
                 json.Unmarshal(labelsJSON, &labels)
         }
@@ -344,6 +370,26 @@ func (s *UnifiedIIOTService) GetDeviceByName(ctx context.Context, name string) (
         }, nil
 }
 
+// DeviceInScope reports whether the device name exists within the tenant scope of ctx
+func (s *UnifiedIIOTService) DeviceInScope(ctx context.Context, name string) (bool, error) {
+        args := []interface{}{name}
+        query := `SELECT EXISTS (SELECT 1 FROM devices WHERE name = $1 AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args) + `)`
+
+        var exists bool
+        err := s.db.QueryRowContext(ctx, query, args...).Scan(&exists)
+        return exists, err
+}
+
+// deviceProtocols parses the stored protocols of the device with the given id, decrypting their
+// credentials for callers allowed to reveal them and masking them for the others
+func (s *UnifiedIIOTService) deviceProtocols(ctx context.Context, id string, protocolsJSON []byte) (map[string]interface{}, error) {
+        var protocols map[string]interface{}
+        if len(protocolsJSON) > 0 {
+                json.Unmarshal(protocolsJSON, &protocols)
+        }
+        return s.keys.DecryptProperties(metadata.ProtocolsField(id), protocols, sensitive.Revealed(ctx))
+}
+
 func main() {
         // Load database configuration from environment
         databaseURL := os.Getenv("DATABASE_URL")
@@ -374,15 +420,78 @@ func main() {
         e.Use(middleware.Recover())
         e.Use(middleware.CORS())
 
+        // Graceful shutdown
+        ctx, cancel := context.WithCancel(context.Background())
+        defer cancel()
+
+        // Sensitive fields are encrypted at rest with keys from the secret store or the environment
+        keys, err := encryption.KeysFromEnvironment(ctx, "iiot-backend")
+        if err != nil {
+                panic("Failed to load field encryption keys: " + err.Error())
+        }
+        if !keys.Enabled() {
+                fmt.Println("WARNING: no field encryption key configured, sensitive fields are stored in plaintext")
+        }
+
+        // Mutating API calls are recorded in the audit log, chained with a key from the secret store or the environment
+        auditLog, err := auditlog.LogFromEnvironment(ctx, db, "iiot-backend")
+        if err != nil {
+                panic("Failed to load audit log key: " + err.Error())
+        }
+
         // Initialize unified service
-        service := NewUnifiedIIOTService(db)
+        service := NewUnifiedIIOTService(db, keys)
+        metadataService := metadata.NewWorkingMetadataService(db, keys, nil)
+        notificationService := notifications.NewService(db, keys)
+
+        // Events sent by device services have the transforms of their profile resources applied
+        dataService := data.NewService(db)
+        dataService.EnableResourceTransforms(func(scope tenant.Scope) data.ReadingNotifier {
+                return notificationService.WithTenant(scope)
+        })
 
         // Initialize Core Command service components (EdgeX-Go style)
         commandService := application.NewCommandService()
         commandController := controller.NewCommandController(commandService)
 
+        // Initialize the read-only GraphQL endpoint on top of the existing services
+        graphQLHandler := NewGraphQLHandler(service, metadataService, notificationService)
+
+        // Initialize user, role and API key management
+        usersService := users.NewService(db)
+        tokens := auth.NewTokenAuthority(tokenSecret(), tokenTTL())
+        ensureAdminUser(usersService)
+
         // Setup routes
-        setupRoutes(e, service, commandController)
+        setupRoutes(e, service, dataService, commandController, graphQLHandler)
+
+        // Every route except the public ones requires a token or API key holding the route's permissions
+        permissions := users.RegisterRoutes(e.Group(ApiBase), usersService, tokens)
+        for route, required := range auditlog.RegisterRoutes(e.Group(ApiBase), auditlog.NewService(db, auditLog)) {
+                permissions[route] = required
+        }
+        reencrypters := map[string]encryption.Reencrypter{"devices": metadataService, "subscriptions": notificationService}
+        for route, required := range encryption.RegisterRoutes(e.Group(ApiBase), keys, reencrypters) {
+                permissions[route] = required
+        }
+        for route, required := range data.RegisterRoutes(e.Group(ApiBase), dataService) {
+                permissions[route] = required
+        }
+        for route, required := range routePermissions {
+                permissions[route] = required
+        }
+
+        // Every mutating call is recorded in the audit log, including those rejected for missing
+        // credentials or permissions. GraphQL only reads, also when it arrives over POST.
+        e.Use(audit.Record(auditLog, ApiGraphQLRoute))
+        // Requests are limited per authenticated client, or per remote IP on public routes
+        e.Use(auth.Authenticate(tokenVerifiers(tokens, usersService), usersService, usersService, publicRoutes...), ratelimit.Limit(rateLimits(ctx), ratelimit.MetricsCounter(gometrics.DefaultRegistry)), auth.RequirePermissions(permissions), tenant.Resolve(), sensitive.Resolve())
+
+        // Serve HTTPS when certificate files are configured
+        tlsConfig, err := servertls.FromEnvironment()
+        if err != nil {
+                panic("Failed to configure TLS: " + err.Error())
+        }
 
         // Start server
         port := os.Getenv("SERVICE_PORT")
@@ -390,14 +499,18 @@ func main() {
                 port = "5000"
         }
 
-        fmt.Printf("Starting IIOT Backend server on port %s\n", port)
-
-        // Graceful shutdown
-        ctx, cancel := context.WithCancel(context.Background())
-        defer cancel()
+        fmt.Printf("Starting IIOT Backend server on port %s (TLS: %t)\n", port, tlsConfig != nil)
 
         go func() {
-                if err := e.Start("0.0.0.0:" + port); err != nil && err != http.ErrServerClosed {
+                var err error
+                if tlsConfig != nil {
+                        e.TLSServer.Addr = "0.0.0.0:" + port
+                        e.TLSServer.TLSConfig = tlsConfig
+                        err = e.StartServer(e.TLSServer)
+                } else {
+                        err = e.Start("0.0.0.0:" + port)
+                }
+                if err != nil && err != http.ErrServerClosed {
                         fmt.Printf("Failed to start server: %v\n", err)
                         cancel()
                 }
@@ -422,7 +535,129 @@ func main() {
         fmt.Println("Server exited")
 }
 
-func setupRoutes(e *echo.Echo, service *UnifiedIIOTService, commandController *controller.CommandController) {
+// publicRoutes are reachable without credentials
+var publicRoutes = []string{
+        "/",
+        "/health",
+        ApiPingRoute,
+        ApiVersionRoute,
+        ApiBase + users.LoginPath,
+}
+
+// routePermissions lists the permissions required by the routes registered in setupRoutes
+var routePermissions = auth.RoutePermissions{
+        auth.Route(http.MethodGet, ApiConfigRoute):                          {"system:read"},
+        auth.Route(http.MethodGet, ApiAllDeviceHandlerRoute):                {"device:read"},
+        auth.Route(http.MethodGet, ApiAllDeviceRoute):                       {"device:read"},
+        auth.Route(http.MethodGet, ApiAllDataEventRoute):                    {"data:read"},
+        auth.Route(http.MethodGet, ApiAllMeasurementRoute):                  {"data:read"},
+        auth.Route(http.MethodGet, ApiDeviceCommandRoute+"/:name"):          {"command:read"},
+        auth.Route(http.MethodGet, ApiDeviceCommandRoute+"/:name/:command"): {"command:get"},
+        auth.Route(http.MethodPut, ApiDeviceCommandRoute+"/:name/:command"): {"command:set"},
+        auth.Route(http.MethodGet, ApiGraphQLRoute):                         {"device:read", "data:read", "notification:read"},
+        auth.Route(http.MethodPost, ApiGraphQLRoute):                        {"device:read", "data:read", "notification:read"},
+}
+
+// tokenSecret returns the key access tokens are signed with
+func tokenSecret() []byte {
+        secret, generated, err := auth.SigningSecret()
+        if err != nil {
+                panic("Failed to load token signing key: " + err.Error())
+        }
+        if generated {
+                fmt.Println("JWT_SECRET is not set, signing access tokens with a random key")
+        }
+        return secret
+}
+
+// tokenVerifiers returns the verifiers bearer tokens are checked with, shared with core-data and
+// core-metadata
+func tokenVerifiers(tokens *auth.TokenAuthority, usersService *users.Service) auth.TokenVerifier {
+        verifiers, err := auth.TokenVerifiersFromEnvironment(tokens, usersService)
+        if err != nil {
+                panic(err.Error())
+        }
+        if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+                fmt.Printf("Accepting tokens issued by %s\n", issuer)
+        }
+        return verifiers
+}
+
+// requireDeviceInTenant answers 404 for devices outside the tenant scope of the request, as if they
+// did not exist
+func requireDeviceInTenant(service *UnifiedIIOTService) echo.MiddlewareFunc {
+        return func(next echo.HandlerFunc) echo.HandlerFunc {
+                return func(c echo.Context) error {
+                        exists, err := service.DeviceInScope(c.Request().Context(), c.Param("name"))
+                        if err != nil {
+                                return c.JSON(500, map[string]string{"error": "Failed to retrieve device"})
+                        }
+                        if !exists {
+                                return c.JSON(404, map[string]string{"error": "Device not found"})
+                        }
+                        return next(c)
+                }
+        }
+}
+
+// requireAllTenants rejects requests that are not scoped to every tenant
+func requireAllTenants(next echo.HandlerFunc) echo.HandlerFunc {
+        return func(c echo.Context) error {
+                if !tenant.FromContext(c.Request().Context()).All {
+                        return c.JSON(403, map[string]string{"error": "Listing the commands of every device requires access to all tenants"})
+                }
+                return next(c)
+        }
+}
+
+// rateLimits returns the per-client rate limits of the API, those of the event and reading routes
+// and of device commands unless RATE_LIMITS_FILE or RATE_LIMITS configures others. Limits read from
+// a file follow its changes until ctx is done.
+func rateLimits(ctx context.Context) func() *config.RateLimitInfo {
+        defaults := data.RateLimits()
+        defaults.Groups["command"] = config.RateLimitGroupInfo{
+                Routes:  []string{ApiDeviceCommandRoute},
+                Methods: []string{http.MethodPut},
+                Limit:   5,
+                Burst:   10,
+        }
+        limits, err := ratelimit.FromEnvironment(defaults)
+        if err != nil {
+                panic("Failed to load rate limits: " + err.Error())
+        }
+        go limits.Watch(ctx, func(err error) {
+                fmt.Printf("Keeping the current rate limits: %v\n", err)
+        })
+        return limits.Get
+}
+
+func tokenTTL() time.Duration {
+        if ttl, err := time.ParseDuration(os.Getenv("JWT_TTL")); err == nil && ttl > 0 {
+                return ttl
+        }
+        return time.Hour
+}
+
+// ensureAdminUser creates the first administrator from ADMIN_USERNAME and ADMIN_PASSWORD when no
+// user exists yet
+func ensureAdminUser(usersService *users.Service) {
+        password := os.Getenv("ADMIN_PASSWORD")
+        if password == "" {
+                return
+        }
+        username := os.Getenv("ADMIN_USERNAME")
+        if username == "" {
+                username = "admin"
+        }
+        created, err := usersService.EnsureAdmin(username, password)
+        if err != nil {
+                fmt.Printf("Failed to create admin user: %v\n", err)
+        } else if created {
+                fmt.Printf("Created admin user %s\n", username)
+        }
+}
+
+func setupRoutes(e *echo.Echo, service *UnifiedIIOTService, dataService *data.Service, commandController *controller.CommandController, graphQLHandler *GraphQLHandler) {
         // Root route for external access
         e.GET("/", func(c echo.Context) error {
                 return c.JSON(200, map[string]interface{}{
@@ -435,6 +670,7 @@ func setupRoutes(e *echo.Echo, service *UnifiedIIOTService, commandController *c
                                 "ping":    ApiPingRoute,
                                 "version": ApiVersionRoute,
                                 "config":  ApiConfigRoute,
+                                "graphql": ApiGraphQLRoute,
                         },
                 })
         })
@@ -539,12 +775,23 @@ func setupRoutes(e *echo.Echo, service *UnifiedIIOTService, commandController *c
                 limit, _ := strconv.Atoi(c.QueryParam("limit"))
                 deviceName := c.QueryParam("device")
                 resourceName := c.QueryParam("resourceName")
+                assetNode := c.QueryParam("assetNode")
 
-                readings, err := service.GetAllReadings(c.Request().Context(), offset, limit, deviceName, resourceName)
+                readings, err := service.GetAllReadings(c.Request().Context(), offset, limit, deviceName, resourceName, assetNode)
                 if err != nil {
                         return c.JSON(500, map[string]string{"error": "Failed to get readings"})
                 }
 
+                // unit converts numeric readings to a unit of the core-metadata unit catalogue
+                if unit := c.QueryParam("unit"); unit != "" {
+                        err := dataService.ConvertReadings(readings, unit)
+                        if errors.Is(err, data.ErrUnknownUnit) {
+                                return c.JSON(400, map[string]string{"error": err.Error()})
+                        } else if err != nil {
+                                return c.JSON(500, map[string]string{"error": "Failed to convert readings"})
+                        }
+                }
+
                 return c.JSON(200, map[string]interface{}{
                         "apiVersion": ApiVersion,
                         "statusCode": 200,
@@ -553,8 +800,15 @@ func setupRoutes(e *echo.Echo, service *UnifiedIIOTService, commandController *c
         })
 
         // Core Command routes - EdgeX-Go style implementation
-        e.GET("/api/v3/device/all", commandController.AllDeviceCoreCommands)
-        e.GET("/api/v3/device/name/:name", commandController.DeviceCoreCommandsByDeviceName)
-        e.GET("/api/v3/device/name/:name/:command", commandController.IssueGetCommandByName)
-        e.PUT("/api/v3/device/name/:name/:command", commandController.IssueSetCommandByName)
+        // The command service is not tenant aware, commands are only issued to devices of the
+        // caller's tenant and only callers acting for every tenant may list all devices' commands
+        deviceInTenant := requireDeviceInTenant(service)
+        e.GET("/api/v3/device/all", commandController.AllDeviceCoreCommands, requireAllTenants)
+        e.GET("/api/v3/device/name/:name", commandController.DeviceCoreCommandsByDeviceName, deviceInTenant)
+        e.GET("/api/v3/device/name/:name/:command", commandController.IssueGetCommandByName, deviceInTenant)
+        e.PUT("/api/v3/device/name/:name/:command", commandController.IssueSetCommandByName, deviceInTenant)
+
+        // GraphQL query endpoint
+        e.GET(ApiGraphQLRoute, graphQLHandler.Handle)
+        e.POST(ApiGraphQLRoute, graphQLHandler.Handle)
 }
\ No newline at end of file
@@ -0,0 +1,313 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"iiot-backend/models"
+)
+
+// genesisHash is the previous hash of the first entry
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// appendLockKey is the advisory lock serializing appends, so that every entry links to its
+// predecessor even when several services write to the same log
+const appendLockKey = 0x61756469746c6f67
+
+// Secret store location and environment variable of the key the log is chained with
+const (
+	KeySecretName = "audit-log"
+	KeySecretKey  = "key"
+	KeyVariable   = "AUDIT_LOG_KEY"
+)
+
+// minKeyLength is the shortest key accepted, the size of the SHA-256 block the HMAC is built on
+// being the most it can use
+const minKeyLength = 32
+
+// ErrNoKey is returned by LogKey when neither the secret store nor the environment holds a key
+var ErrNoKey = fmt.Errorf("the audit log key must be set in secret %s or %s", KeySecretName, KeyVariable)
+
+// Conn is the database the log is stored in, satisfied by *sql.DB and by pools replacing their
+// connections when credentials rotate
+type Conn interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Log is the append-only audit log table. Entries are chained by HMAC-SHA256 under a key kept out
+// of the database, and the last entry is recorded in a head signed with the same key, so that
+// entries can be neither rewritten nor removed from the end of the log without the key.
+type Log struct {
+	db  Conn
+	key []byte
+}
+
+// NewLog returns the log stored in db, chained with key. Every service writing to the same log
+// must use the same key.
+func NewLog(db Conn, key []byte) *Log {
+	return &Log{db: db, key: key}
+}
+
+// LogKey returns the key of the log from the audit-log secret read with retrieve, or else from
+// AUDIT_LOG_KEY. retrieve may be nil when there is no secret store. The key must be at least 32
+// bytes long.
+func LogKey(retrieve func(secretName string, keys ...string) (map[string]string, error)) ([]byte, error) {
+	key := os.Getenv(KeyVariable)
+	if retrieve != nil {
+		if secret, err := retrieve(KeySecretName, KeySecretKey); err == nil && secret[KeySecretKey] != "" {
+			key = secret[KeySecretKey]
+		}
+	}
+	if key == "" {
+		return nil, ErrNoKey
+	}
+	if len(key) < minKeyLength {
+		return nil, fmt.Errorf("the audit log key must be at least %d bytes long", minKeyLength)
+	}
+	return []byte(key), nil
+}
+
+// hashed holds the fields an entry's hash covers, in a fixed order
+type hashed struct {
+	ID         string `json:"id"`
+	Created    int64  `json:"created"`
+	UserID     string `json:"userId"`
+	Username   string `json:"username"`
+	Tenant     string `json:"tenant"`
+	Method     string `json:"method"`
+	Route      string `json:"route"`
+	Path       string `json:"path"`
+	EntityType string `json:"entityType"`
+	EntityID   string `json:"entityId"`
+	StatusCode int    `json:"statusCode"`
+	RemoteAddr string `json:"remoteAddr"`
+	RequestID  string `json:"requestId"`
+	Before     string `json:"before"`
+	After      string `json:"after"`
+}
+
+// Hash returns the HMAC-SHA256 under key of an entry chained to the hash of the entry before it.
+// Entries written before the log was keyed are hashed with plain SHA-256, which a nil key selects.
+func Hash(e *models.AuditEntry, prevHash string, key []byte) string {
+	fields, _ := json.Marshal(hashed{
+		ID: e.ID, Created: e.Created, UserID: e.UserID, Username: e.Username, Tenant: e.Tenant,
+		Method: e.Method, Route: e.Route, Path: e.Path, EntityType: e.EntityType, EntityID: e.EntityID,
+		StatusCode: e.StatusCode, RemoteAddr: e.RemoteAddr, RequestID: e.RequestID,
+		Before: string(e.Before), After: string(e.After),
+	})
+	if key == nil {
+		sum := sha256.Sum256(append([]byte(prevHash), fields...))
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(prevHash))
+	mac.Write(fields)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Head is the last entry of the log, signed so that it cannot be moved back to an earlier entry
+// without the key. Exported heads let the log be checked against a copy kept out of the database.
+type Head struct {
+	Seq       int64
+	Hash      string
+	Signature string
+}
+
+// signHead returns the signature of the head pointing at the entry seq with the given hash
+func signHead(seq int64, hash string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("head:" + strconv.FormatInt(seq, 10) + ":" + hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// nullableState stores an empty state as NULL
+func nullableState(state json.RawMessage) interface{} {
+	if len(state) == 0 {
+		return nil
+	}
+	return string(state)
+}
+
+// Append links the entry to the last one in the log and stores it, setting its hashes and
+// sequence number
+func (l *Log) Append(ctx context.Context, e *models.AuditEntry) error {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, appendLockKey); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	e.PrevHash = genesisHash
+	err = tx.QueryRowContext(ctx, `SELECT hash FROM audit_log ORDER BY seq DESC LIMIT 1`).Scan(&e.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read last audit entry: %w", err)
+	}
+	e.Keyed = true
+	e.Hash = Hash(e, e.PrevHash, l.key)
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO audit_log (id, created, user_id, username, tenant_id, method, route, path, entity_type,
+			entity_id, status_code, remote_addr, request_id, before, after, prev_hash, hash, keyed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING seq`,
+		e.ID, e.Created, e.UserID, e.Username, e.Tenant, e.Method, e.Route, e.Path, e.EntityType,
+		e.EntityID, e.StatusCode, e.RemoteAddr, e.RequestID, nullableState(e.Before), nullableState(e.After),
+		e.PrevHash, e.Hash, e.Keyed).Scan(&e.Seq)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_log_head (id, seq, hash, signature) VALUES (TRUE, $1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET seq = EXCLUDED.seq, hash = EXCLUDED.hash, signature = EXCLUDED.signature`,
+		e.Seq, e.Hash, signHead(e.Seq, e.Hash, l.key))
+	if err != nil {
+		return fmt.Errorf("failed to update audit log head: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// Columns lists the audit_log columns in the order ScanEntry reads them
+const Columns = `seq, id, created, user_id, username, tenant_id, method, route, path, entity_type, entity_id,
+	status_code, remote_addr, request_id, before, after, prev_hash, hash, keyed`
+
+// ScanEntry reads a row selected with Columns
+func ScanEntry(scan func(dest ...interface{}) error) (models.AuditEntry, error) {
+	var e models.AuditEntry
+	var before, after sql.NullString
+	err := scan(&e.Seq, &e.ID, &e.Created, &e.UserID, &e.Username, &e.Tenant, &e.Method, &e.Route, &e.Path,
+		&e.EntityType, &e.EntityID, &e.StatusCode, &e.RemoteAddr, &e.RequestID, &before, &after,
+		&e.PrevHash, &e.Hash, &e.Keyed)
+	if err != nil {
+		return e, err
+	}
+	if before.Valid {
+		e.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		e.After = json.RawMessage(after.String)
+	}
+	return e, nil
+}
+
+// chain checks entries in log order against the hash chain
+type chain struct {
+	key      []byte
+	prevHash string
+	lastSeq  int64
+	checked  int64
+	keyed    bool
+}
+
+func newChain(key []byte) *chain {
+	return &chain{key: key, prevHash: genesisHash}
+}
+
+// next checks that e's hash matches its fields and links to the entry checked before it, returning
+// the failed verification when it does not. Unkeyed entries are only accepted before the first
+// keyed one.
+func (c *chain) next(e *models.AuditEntry) *models.AuditVerification {
+	c.checked++
+	var key []byte
+	if e.Keyed {
+		key, c.keyed = c.key, true
+	}
+	switch {
+	case !e.Keyed && c.keyed:
+		return &models.AuditVerification{Checked: c.checked, BrokenAt: e.Seq,
+			Message: "entry is not keyed although entries before it are, the entry was forged"}
+	case e.PrevHash != c.prevHash:
+		return &models.AuditVerification{Checked: c.checked, BrokenAt: e.Seq,
+			Message: "entry does not link to the entry before it, entries were removed or reordered"}
+	case !hmac.Equal([]byte(Hash(e, e.PrevHash, key)), []byte(e.Hash)):
+		return &models.AuditVerification{Checked: c.checked, BrokenAt: e.Seq,
+			Message: "entry hash does not match its fields, the entry was modified"}
+	}
+	c.prevHash, c.lastSeq = e.Hash, e.Seq
+	return nil
+}
+
+// end checks that the last entry checked is the one head points at, head being nil when the log
+// has none, and returns the verification of the whole log
+func (c *chain) end(head *Head) *models.AuditVerification {
+	switch {
+	case head == nil && !c.keyed:
+		return &models.AuditVerification{Valid: true, Checked: c.checked}
+	case head == nil:
+		return &models.AuditVerification{Checked: c.checked, BrokenAt: c.lastSeq,
+			Message: "the head of the log is missing, it was removed"}
+	case !hmac.Equal([]byte(signHead(head.Seq, head.Hash, c.key)), []byte(head.Signature)):
+		return &models.AuditVerification{Checked: c.checked, BrokenAt: head.Seq,
+			Message: "the head of the log is not signed with the key, it was modified"}
+	case head.Seq != c.lastSeq || head.Hash != c.prevHash:
+		return &models.AuditVerification{Checked: c.checked, BrokenAt: head.Seq,
+			Message: "the log does not end at its head, entries were removed from its end"}
+	}
+	return &models.AuditVerification{Valid: true, Checked: c.checked, HeadSeq: head.Seq, HeadHash: head.Hash}
+}
+
+// Head returns the signed head of the log, nil when nothing was appended with a key yet
+func (l *Log) Head(ctx context.Context) (*Head, error) {
+	rows, err := l.db.QueryContext(ctx, `SELECT seq, hash, signature FROM audit_log_head`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log head: %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	var head Head
+	if err := rows.Scan(&head.Seq, &head.Hash, &head.Signature); err != nil {
+		return nil, fmt.Errorf("failed to scan audit log head: %w", err)
+	}
+	return &head, nil
+}
+
+// Verify walks the whole log and checks that every entry's hash matches its fields and links to
+// the entry before it, and that the log ends at its signed head
+func (l *Log) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	// the head is read first, entries appended meanwhile are after it and not checked
+	head, err := l.Head(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + Columns + ` FROM audit_log ORDER BY seq`
+	args := []interface{}{}
+	if head != nil {
+		query = `SELECT ` + Columns + ` FROM audit_log WHERE seq <= $1 ORDER BY seq`
+		args = append(args, head.Seq)
+	}
+	rows, err := l.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := newChain(l.key)
+	for rows.Next() {
+		e, err := ScanEntry(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if broken := entries.next(&e); broken != nil {
+			return broken, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries.end(head), nil
+}
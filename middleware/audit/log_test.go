@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"iiot-backend/models"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+// chainedEntries builds n entries linked the way Append links them
+func chainedEntries(n int) []models.AuditEntry {
+	entries := make([]models.AuditEntry, n)
+	prevHash := genesisHash
+	for i := range entries {
+		e := &entries[i]
+		e.Seq = int64(i + 1)
+		e.ID = fmt.Sprintf("entry-%d", i+1)
+		e.Created = int64(1700000000 + i)
+		e.Username = "admin"
+		e.Method = "PUT"
+		e.Route = "/api/v3/device"
+		e.Path = "/api/v3/device"
+		e.EntityType = "device"
+		e.EntityID = "pump-1"
+		e.StatusCode = 200
+		e.Before = json.RawMessage(fmt.Sprintf(`{"adminState":"LOCKED","revision":%d}`, i))
+		e.After = json.RawMessage(fmt.Sprintf(`{"adminState":"UNLOCKED","revision":%d}`, i+1))
+		e.PrevHash = prevHash
+		e.Keyed = true
+		e.Hash = Hash(e, prevHash, testKey)
+		prevHash = e.Hash
+	}
+	return entries
+}
+
+// headOf returns the head Append leaves after the last of entries
+func headOf(entries []models.AuditEntry) *Head {
+	last := entries[len(entries)-1]
+	return &Head{Seq: last.Seq, Hash: last.Hash, Signature: signHead(last.Seq, last.Hash, testKey)}
+}
+
+// verify runs the entries through the chain the way Log.Verify does
+func verify(entries []models.AuditEntry, head *Head, key []byte) *models.AuditVerification {
+	c := newChain(key)
+	for i := range entries {
+		if broken := c.next(&entries[i]); broken != nil {
+			return broken
+		}
+	}
+	return c.end(head)
+}
+
+func TestVerifyIntactChain(t *testing.T) {
+	entries := chainedEntries(5)
+	result := verify(entries, headOf(entries), testKey)
+	assert.True(t, result.Valid)
+	assert.Equal(t, int64(5), result.Checked)
+	assert.Equal(t, int64(5), result.HeadSeq)
+	assert.Equal(t, entries[4].Hash, result.HeadHash)
+}
+
+func TestVerifyAcceptsEntriesRecordedBeforeTheLogWasKeyed(t *testing.T) {
+	entries := chainedEntries(4)
+	for i := range entries[:2] {
+		entries[i].Keyed = false
+		entries[i].Hash = Hash(&entries[i], entries[i].PrevHash, nil)
+		entries[i+1].PrevHash = entries[i].Hash
+	}
+	entries[2].Hash = Hash(&entries[2], entries[2].PrevHash, testKey)
+	entries[3].PrevHash = entries[2].Hash
+	entries[3].Hash = Hash(&entries[3], entries[3].PrevHash, testKey)
+
+	assert.True(t, verify(entries, headOf(entries), testKey).Valid)
+	// a log never keyed has no head
+	assert.True(t, verify(entries[:2], nil, testKey).Valid)
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(entries []models.AuditEntry) []models.AuditEntry
+		brokenAt int64
+	}{
+		{"modified field", func(entries []models.AuditEntry) []models.AuditEntry {
+			entries[2].Username = "someone-else"
+			return entries
+		}, 3},
+		{"modified state", func(entries []models.AuditEntry) []models.AuditEntry {
+			entries[1].After = json.RawMessage(`{"adminState":"LOCKED","revision":2}`)
+			return entries
+		}, 2},
+		{"rehashed entry", func(entries []models.AuditEntry) []models.AuditEntry {
+			entries[1].StatusCode = 500
+			entries[1].Hash = Hash(&entries[1], entries[1].PrevHash, testKey)
+			return entries
+		}, 3},
+		{"rehashed chain without the key", func(entries []models.AuditEntry) []models.AuditEntry {
+			entries[1].StatusCode = 500
+			for i := 1; i < len(entries); i++ {
+				entries[i].PrevHash = entries[i-1].Hash
+				entries[i].Hash = Hash(&entries[i], entries[i].PrevHash, []byte("guessed-key-guessed-key-guessed!"))
+			}
+			return entries
+		}, 2},
+		{"entry forged as recorded before the log was keyed", func(entries []models.AuditEntry) []models.AuditEntry {
+			entries[3].Keyed = false
+			entries[3].Hash = Hash(&entries[3], entries[3].PrevHash, nil)
+			entries[4].PrevHash = entries[3].Hash
+			return entries
+		}, 4},
+		{"removed entry", func(entries []models.AuditEntry) []models.AuditEntry {
+			return append(entries[:2], entries[3:]...)
+		}, 4},
+		{"removed first entry", func(entries []models.AuditEntry) []models.AuditEntry {
+			return entries[1:]
+		}, 2},
+		{"reordered entries", func(entries []models.AuditEntry) []models.AuditEntry {
+			entries[1], entries[2] = entries[2], entries[1]
+			return entries
+		}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := chainedEntries(5)
+			head := headOf(entries)
+			result := verify(tt.tamper(entries), head, testKey)
+			require.False(t, result.Valid)
+			assert.Equal(t, tt.brokenAt, result.BrokenAt)
+			assert.NotEmpty(t, result.Message)
+		})
+	}
+}
+
+func TestVerifyDetectsTruncation(t *testing.T) {
+	entries := chainedEntries(5)
+	head := headOf(entries)
+
+	tests := []struct {
+		name     string
+		entries  []models.AuditEntry
+		head     *Head
+		brokenAt int64
+	}{
+		{"removed last entries", entries[:3], head, 5},
+		{"removed head", entries, nil, 5},
+		{"head moved back without the key", entries[:3], &Head{Seq: 3, Hash: entries[2].Hash, Signature: head.Signature}, 3},
+		{"head signed with another key", entries[:3], &Head{Seq: 3, Hash: entries[2].Hash,
+			Signature: signHead(3, entries[2].Hash, []byte("guessed-key-guessed-key-guessed!"))}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := verify(tt.entries, tt.head, testKey)
+			require.False(t, result.Valid)
+			assert.Equal(t, tt.brokenAt, result.BrokenAt)
+			assert.NotEmpty(t, result.Message)
+		})
+	}
+
+	// the log does not verify with another key
+	assert.False(t, verify(entries, head, []byte("guessed-key-guessed-key-guessed!")).Valid)
+}
+
+func TestLogKey(t *testing.T) {
+	t.Setenv(KeyVariable, "")
+	_, err := LogKey(nil)
+	assert.ErrorIs(t, err, ErrNoKey)
+
+	t.Setenv(KeyVariable, "short")
+	_, err = LogKey(nil)
+	assert.Error(t, err)
+
+	t.Setenv(KeyVariable, string(testKey))
+	key, err := LogKey(nil)
+	require.NoError(t, err)
+	assert.Equal(t, testKey, key)
+
+	// the secret store takes precedence over the environment
+	stored := "fedcba9876543210fedcba9876543210"
+	key, err = LogKey(func(secretName string, keys ...string) (map[string]string, error) {
+		return map[string]string{KeySecretKey: stored}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte(stored), key)
+}
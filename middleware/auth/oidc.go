@@ -0,0 +1,406 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	// minJWKSRefreshInterval bounds how often a token with an unknown key id can trigger a refetch
+	minJWKSRefreshInterval = 10 * time.Second
+	oidcRequestTimeout     = 10 * time.Second
+	maxOIDCResponseSize    = 1 << 20
+)
+
+// oidcSigningMethods are the asymmetric algorithms accepted from an identity provider, HMAC is
+// excluded since the provider's keys are public
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// OIDCConfig configures validation of access tokens issued by an OpenID Connect provider
+type OIDCConfig struct {
+	// IssuerURL is the provider's issuer identifier, its discovery document is read from
+	// IssuerURL/.well-known/openid-configuration
+	IssuerURL string
+	// Audience must be contained in the token's aud claim, no token is accepted when it is empty
+	Audience string
+	// RoleClaims name the claims holding the caller's roles or groups, nested claims are written
+	// with dots such as realm_access.roles
+	RoleClaims []string
+	// RoleMapping maps provider roles or groups onto backend roles. When empty, provider roles are
+	// taken as backend role names.
+	RoleMapping map[string]string
+	// UsernameClaim names the claim used as username, preferred_username when empty
+	UsernameClaim string
+	// TenantClaim names the claim holding the caller's tenant, tenant when empty
+	TenantClaim string
+	// RefreshInterval is how long fetched signing keys are used before the JWKS is fetched again
+	RefreshInterval time.Duration
+	// Leeway tolerates clock skew when checking exp and nbf
+	Leeway time.Duration
+	// HTTPClient fetches the discovery document and JWKS, http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// RoleResolver returns the union of the permissions held by the named roles
+type RoleResolver interface {
+	RolePermissions(ctx context.Context, roles []string) ([]string, error)
+}
+
+// ErrNoOIDCAudience is returned by TokenVerifiersFromEnvironment when OIDC_ISSUER is set without
+// OIDC_AUDIENCE, as any token the provider issues to another client would then be accepted
+var ErrNoOIDCAudience = errors.New("OIDC_AUDIENCE must be set when OIDC_ISSUER is set")
+
+// TokenVerifiersFromEnvironment returns the verifiers bearer tokens are checked with: the tokens
+// issued by the login endpoint and, when OIDC_ISSUER is set, those of the OpenID Connect provider
+// configured by the OIDC_ variables. Every service authenticating operators uses it so that a token
+// accepted by one is accepted by all.
+func TokenVerifiersFromEnvironment(tokens *TokenAuthority, roles RoleResolver) (TokenVerifier, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return tokens, nil
+	}
+	audience := os.Getenv("OIDC_AUDIENCE")
+	if audience == "" {
+		return nil, ErrNoOIDCAudience
+	}
+
+	config := OIDCConfig{
+		IssuerURL:     issuer,
+		Audience:      audience,
+		RoleClaims:    []string{"roles", "groups", "realm_access.roles"},
+		RoleMapping:   map[string]string{},
+		UsernameClaim: os.Getenv("OIDC_USERNAME_CLAIM"),
+	}
+	if claims := os.Getenv("OIDC_ROLES_CLAIM"); claims != "" {
+		config.RoleClaims = strings.Split(claims, ",")
+	}
+	// OIDC_ROLE_MAPPING maps provider groups or roles onto backend roles, as group=role pairs
+	// separated by commas
+	for _, pair := range strings.Split(os.Getenv("OIDC_ROLE_MAPPING"), ",") {
+		if group, role, ok := strings.Cut(strings.TrimSpace(pair), "="); ok {
+			config.RoleMapping[group] = role
+		}
+	}
+	if interval, err := time.ParseDuration(os.Getenv("OIDC_JWKS_REFRESH")); err == nil {
+		config.RefreshInterval = interval
+	}
+	if leeway, err := time.ParseDuration(os.Getenv("OIDC_LEEWAY")); err == nil {
+		config.Leeway = leeway
+	}
+	return TokenVerifiers{tokens, NewOIDCVerifier(config, roles)}, nil
+}
+
+// OIDCVerifier validates RS/PS/ES signed tokens against the keys an OpenID Connect provider
+// publishes, keeping up with key rotation by refetching the JWKS
+type OIDCVerifier struct {
+	config OIDCConfig
+	roles  RoleResolver
+
+	mutex       sync.Mutex
+	issuer      string
+	jwksURI     string
+	keys        map[string]interface{}
+	fetched     time.Time
+	lastAttempt time.Time
+}
+
+func NewOIDCVerifier(config OIDCConfig, roles RoleResolver) *OIDCVerifier {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaultJWKSRefreshInterval
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "preferred_username"
+	}
+	if config.TenantClaim == "" {
+		config.TenantClaim = "tenant"
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &OIDCVerifier{config: config, roles: roles}
+}
+
+// Verify checks the token signature against the provider's keys and its iss, aud, exp and nbf
+// claims, then maps its roles onto backend roles
+func (v *OIDCVerifier) Verify(token string) (*Principal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), oidcRequestTimeout)
+	defer cancel()
+
+	issuer, err := v.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.config.Leeway),
+	}
+	if v.config.Audience == "" {
+		return nil, errors.New("no audience is configured for the OpenID Connect provider")
+	}
+	options = append(options, jwt.WithAudience(v.config.Audience))
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.signingKey(ctx, kid)
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	username, _ := claims[v.config.UsernameClaim].(string)
+	if username == "" {
+		username = subject
+	}
+
+	p := &Principal{UserID: subject, Username: username, Roles: v.mapRoles(claims)}
+	if tenants := claimStrings(claims, v.config.TenantClaim); len(tenants) > 0 {
+		p.Tenant = tenants[0]
+	}
+	if len(p.Roles) > 0 {
+		if p.Permissions, err = v.roles.RolePermissions(ctx, p.Roles); err != nil {
+			return nil, fmt.Errorf("failed to resolve role permissions: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// mapRoles collects the provider roles from the configured claims and translates them to
+// backend roles, provider roles without a mapping are dropped
+func (v *OIDCVerifier) mapRoles(claims jwt.MapClaims) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, claim := range v.config.RoleClaims {
+		for _, providerRole := range claimStrings(claims, claim) {
+			role := providerRole
+			if len(v.config.RoleMapping) > 0 {
+				if role = v.config.RoleMapping[providerRole]; role == "" {
+					continue
+				}
+			}
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+// claimStrings reads a string or string list claim, following dots into nested objects
+func claimStrings(claims map[string]interface{}, path string) []string {
+	var value interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+
+	switch v := value.(type) {
+	case string:
+		// some providers send space separated lists, as in the scope claim
+		return strings.Fields(v)
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// discover reads the issuer and JWKS location from the provider's discovery document once
+func (v *OIDCVerifier) discover(ctx context.Context) (string, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.jwksURI != "" {
+		return v.issuer, nil
+	}
+
+	var document struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	url := strings.TrimSuffix(v.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := v.fetchJSON(ctx, url, &document); err != nil {
+		return "", fmt.Errorf("failed to discover OIDC issuer: %w", err)
+	}
+	if strings.TrimSuffix(document.Issuer, "/") != strings.TrimSuffix(v.config.IssuerURL, "/") {
+		return "", fmt.Errorf("discovery document issuer %s does not match the configured issuer %s", document.Issuer, v.config.IssuerURL)
+	}
+	if document.JWKSURI == "" {
+		return "", errors.New("discovery document has no jwks_uri")
+	}
+	v.issuer, v.jwksURI = document.Issuer, document.JWKSURI
+	return v.issuer, nil
+}
+
+// signingKey returns the key for kid, refetching the JWKS when the cached keys are stale or when
+// kid is unknown because the provider rotated its keys
+func (v *OIDCVerifier) signingKey(ctx context.Context, kid string) (interface{}, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	now := time.Now()
+	key, known := v.lookupKey(kid)
+	stale := now.Sub(v.fetched) >= v.config.RefreshInterval
+	if (stale || !known) && now.Sub(v.lastAttempt) >= minJWKSRefreshInterval {
+		v.lastAttempt = now
+		keys, err := v.fetchKeys(ctx)
+		if err != nil && !known {
+			return nil, err
+		}
+		if err == nil {
+			v.keys, v.fetched = keys, now
+			key, known = v.lookupKey(kid)
+		}
+	}
+	if !known {
+		return nil, fmt.Errorf("no signing key with id %q", kid)
+	}
+	return key, nil
+}
+
+// lookupKey finds the key for kid, tokens without kid are accepted when the JWKS holds one key
+func (v *OIDCVerifier) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *OIDCVerifier) fetchKeys(ctx context.Context) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.fetchJSON(ctx, v.jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// skip keys of unsupported types rather than rejecting the whole set
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64URLInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64URLInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64URLInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64URLInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func base64URLInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (v *OIDCVerifier) fetchJSON(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := v.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxOIDCResponseSize)).Decode(target)
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAudience = "iiot-backend"
+
+type staticRoles map[string][]string
+
+func (r staticRoles) RolePermissions(ctx context.Context, roles []string) ([]string, error) {
+	var permissions []string
+	for _, role := range roles {
+		permissions = append(permissions, r[role]...)
+	}
+	return permissions, nil
+}
+
+// testProvider serves the discovery document and the JWKS of a single P-256 key
+type testProvider struct {
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	p := &testProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": p.server.URL, "jwks_uri": p.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jsonWebKey{{
+			Kty: "EC", Kid: "key-1", Use: "sig", Crv: "P-256",
+			X: encode(key.PublicKey.X.FillBytes(make([]byte, 32))),
+			Y: encode(key.PublicKey.Y.FillBytes(make([]byte, 32))),
+		}}})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testProvider) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString(p.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func (p *testProvider) claims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                p.server.URL,
+		"aud":                testAudience,
+		"sub":                "user-1",
+		"preferred_username": "alice",
+		"groups":             []string{"plant-operators"},
+		"tenant":             "plant-a",
+		"exp":                time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func (p *testProvider) verifier(audience string) *OIDCVerifier {
+	return NewOIDCVerifier(OIDCConfig{
+		IssuerURL:   p.server.URL,
+		Audience:    audience,
+		RoleClaims:  []string{"groups"},
+		RoleMapping: map[string]string{"plant-operators": "operator"},
+	}, staticRoles{"operator": {"device:read"}})
+}
+
+func TestOIDCVerifierAcceptsValidToken(t *testing.T) {
+	provider := newTestProvider(t)
+
+	principal, err := provider.verifier(testAudience).Verify(provider.sign(t, provider.claims()))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", principal.UserID)
+	assert.Equal(t, "alice", principal.Username)
+	assert.Equal(t, "plant-a", principal.Tenant)
+	assert.Equal(t, []string{"operator"}, principal.Roles)
+	assert.Equal(t, []string{"device:read"}, principal.Permissions)
+}
+
+func TestOIDCVerifierRejectsInvalidClaims(t *testing.T) {
+	provider := newTestProvider(t)
+
+	tests := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+	}{
+		{"wrong audience", func(claims jwt.MapClaims) { claims["aud"] = "another-client" }},
+		{"no audience", func(claims jwt.MapClaims) { delete(claims, "aud") }},
+		{"wrong issuer", func(claims jwt.MapClaims) { claims["iss"] = "https://attacker.example" }},
+		{"expired", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{"no expiry", func(claims jwt.MapClaims) { delete(claims, "exp") }},
+		{"not yet valid", func(claims jwt.MapClaims) { claims["nbf"] = time.Now().Add(time.Minute).Unix() }},
+		{"no subject", func(claims jwt.MapClaims) { delete(claims, "sub") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := provider.claims()
+			tt.modify(claims)
+			_, err := provider.verifier(testAudience).Verify(provider.sign(t, claims))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestOIDCVerifierRejectsUnexpectedSignatures(t *testing.T) {
+	provider := newTestProvider(t)
+	verifier := provider.verifier(testAudience)
+
+	// HMAC tokens could be forged with the provider's public key as secret
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, provider.claims()).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = verifier.Verify(hmacToken)
+	assert.Error(t, err)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodES256, provider.claims())
+	forged.Header["kid"] = "key-1"
+	forgedToken, err := forged.SignedString(otherKey)
+	require.NoError(t, err)
+	_, err = verifier.Verify(forgedToken)
+	assert.Error(t, err)
+}
+
+func TestOIDCVerifierRequiresAudience(t *testing.T) {
+	provider := newTestProvider(t)
+
+	_, err := provider.verifier("").Verify(provider.sign(t, provider.claims()))
+	assert.Error(t, err)
+}
+
+func TestSigningSecretRequiresSecretOrIssuer(t *testing.T) {
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("OIDC_ISSUER", "")
+	_, _, err := SigningSecret()
+	assert.ErrorIs(t, err, ErrNoSigningSecret)
+
+	t.Setenv("OIDC_ISSUER", "https://issuer.example")
+	secret, generated, err := SigningSecret()
+	require.NoError(t, err)
+	assert.True(t, generated)
+	assert.Len(t, secret, 32)
+
+	t.Setenv("JWT_SECRET", "configured")
+	secret, generated, err = SigningSecret()
+	require.NoError(t, err)
+	assert.False(t, generated)
+	assert.Equal(t, []byte("configured"), secret)
+}
+
+func TestTokenVerifiersFromEnvironment(t *testing.T) {
+	tokens := NewTokenAuthority([]byte("secret"), time.Hour)
+
+	t.Setenv("OIDC_ISSUER", "")
+	verifier, err := TokenVerifiersFromEnvironment(tokens, nil)
+	require.NoError(t, err)
+	assert.Same(t, tokens, verifier)
+
+	t.Setenv("OIDC_ISSUER", "https://issuer.example")
+	t.Setenv("OIDC_AUDIENCE", "")
+	_, err = TokenVerifiersFromEnvironment(tokens, nil)
+	assert.ErrorIs(t, err, ErrNoOIDCAudience)
+
+	t.Setenv("OIDC_AUDIENCE", "iiot-backend")
+	t.Setenv("OIDC_ROLE_MAPPING", "plant-operators=operator, admins=admin")
+	verifier, err = TokenVerifiersFromEnvironment(tokens, nil)
+	require.NoError(t, err)
+	verifiers, ok := verifier.(TokenVerifiers)
+	require.True(t, ok)
+	require.Len(t, verifiers, 2)
+	oidc, ok := verifiers[1].(*OIDCVerifier)
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"plant-operators": "operator", "admins": "admin"}, oidc.config.RoleMapping)
+}
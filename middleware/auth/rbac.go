@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/mtls/peer"
+)
+
+const (
+	principalContextKey = "principal"
+
+	// PermissionWildcard matches any resource or verb in a permission
+	PermissionWildcard = "*"
+)
+
+// Principal is the authenticated caller of a request, either a user logged in with a token or
+// one of the user's API keys
+type Principal struct {
+	UserID      string
+	Username    string
+	Roles       []string
+	Permissions []string
+	// Tenant is the tenant the principal belongs to, empty for the default tenant
+	Tenant string
+	// APIKeyID and Scopes are only set when the request authenticated with an API key, the scopes
+	// further restrict the permissions the key's owner holds
+	APIKeyID string
+	Scopes   []string
+}
+
+// Can reports whether the principal holds the given permission
+func (p *Principal) Can(permission string) bool {
+	if !matchesAnyPermission(p.Permissions, permission) {
+		return false
+	}
+	return len(p.Scopes) == 0 || matchesAnyPermission(p.Scopes, permission)
+}
+
+// ValidPermission reports whether permission is a resource:verb pair, either part may be a wildcard
+func ValidPermission(permission string) bool {
+	resource, verb, ok := strings.Cut(permission, ":")
+	return ok && resource != "" && verb != "" && !strings.Contains(verb, ":")
+}
+
+// PermissionMatches reports whether a granted permission covers the required one
+func PermissionMatches(granted, required string) bool {
+	grantedResource, grantedVerb, ok := strings.Cut(granted, ":")
+	if !ok {
+		return false
+	}
+	requiredResource, requiredVerb, ok := strings.Cut(required, ":")
+	if !ok {
+		return false
+	}
+	return (grantedResource == PermissionWildcard || grantedResource == requiredResource) &&
+		(grantedVerb == PermissionWildcard || grantedVerb == requiredVerb)
+}
+
+func matchesAnyPermission(granted []string, required string) bool {
+	for _, g := range granted {
+		if PermissionMatches(g, required) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrincipalFrom returns the principal Authenticate stored on the request, nil for public routes
+func PrincipalFrom(c echo.Context) *Principal {
+	p, _ := c.Get(principalContextKey).(*Principal)
+	return p
+}
+
+// APIKeyValidator resolves an API key to the principal owning it
+type APIKeyValidator interface {
+	ValidateAPIKey(ctx context.Context, key string) (*Principal, error)
+}
+
+// TokenVerifier resolves a bearer token to the principal it was issued for
+type TokenVerifier interface {
+	Verify(token string) (*Principal, error)
+}
+
+// TokenVerifiers accepts a token any of its verifiers accepts, so that tokens issued by the login
+// endpoint and by an external identity provider can be used side by side
+type TokenVerifiers []TokenVerifier
+
+func (v TokenVerifiers) Verify(token string) (*Principal, error) {
+	err := errors.New("no token verifier configured")
+	for _, verifier := range v {
+		var principal *Principal
+		if principal, err = verifier.Verify(token); err == nil {
+			return principal, nil
+		}
+	}
+	return nil, err
+}
+
+// CertificateValidator resolves the identities of a verified client certificate, as listed by
+// peer.Identities, to the principal they belong to
+type CertificateValidator interface {
+	ValidateCertificate(ctx context.Context, identities []string) (*Principal, error)
+}
+
+// Authenticate identifies the caller from a bearer token accepted by tokens, from an API key sent
+// in the X-API-Key header or, when certs is set, from the client certificate verified by a TLS
+// listener. The request is rejected when none of them is valid. Routes listed in publicRoutes,
+// matched against the registered route path, are let through anonymously.
+func Authenticate(tokens TokenVerifier, keys APIKeyValidator, certs CertificateValidator, publicRoutes ...string) echo.MiddlewareFunc {
+	public := make(map[string]bool, len(publicRoutes))
+	for _, route := range publicRoutes {
+		public[route] = true
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Method == http.MethodOptions || public[c.Path()] {
+				return next(c)
+			}
+
+			var principal *Principal
+			var err error
+			if apiKey := c.Request().Header.Get("X-API-Key"); apiKey != "" {
+				principal, err = keys.ValidateAPIKey(c.Request().Context(), apiKey)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusUnauthorized, map[string]interface{}{
+						"error":   "Unauthorized",
+						"message": "Invalid API key",
+						"code":    "INVALID_API_KEY",
+					})
+				}
+			} else if token, ok := bearerToken(c.Request()); ok {
+				principal, err = tokens.Verify(token)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusUnauthorized, map[string]interface{}{
+						"error":   "Unauthorized",
+						"message": "Invalid or expired token",
+						"code":    "INVALID_TOKEN",
+					})
+				}
+			} else if certificate := peer.Certificate(c.Request()); certs != nil && certificate != nil {
+				principal, err = certs.ValidateCertificate(c.Request().Context(), peer.Identities(certificate))
+				if err != nil {
+					return echo.NewHTTPError(http.StatusUnauthorized, map[string]interface{}{
+						"error":   "Unauthorized",
+						"message": "Client certificate does not identify a user",
+						"code":    "INVALID_CERTIFICATE",
+					})
+				}
+			} else {
+				return echo.NewHTTPError(http.StatusUnauthorized, map[string]interface{}{
+					"error":   "Unauthorized",
+					"message": "A bearer token, API key or client certificate is required",
+					"code":    "CREDENTIALS_REQUIRED",
+				})
+			}
+
+			c.Set(principalContextKey, principal)
+			return next(c)
+		}
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return strings.TrimSpace(token), ok && strings.TrimSpace(token) != ""
+}
+
+// RoutePermissions maps a route, written as the HTTP method and the registered path separated by a
+// space, to the permissions a caller must hold to use it
+type RoutePermissions map[string][]string
+
+// Route builds the RoutePermissions key for a method and registered path
+func Route(method, path string) string {
+	return method + " " + path
+}
+
+// RequirePermissions enforces routes on every request Authenticate identified. Routes missing from
+// the map are denied so that new endpoints are not exposed before a permission is assigned.
+func RequirePermissions(routes RoutePermissions) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := PrincipalFrom(c)
+			if principal == nil {
+				// anonymous requests only get this far on public routes
+				return next(c)
+			}
+
+			required, ok := routes[Route(c.Request().Method, c.Path())]
+			if !ok {
+				return echo.NewHTTPError(http.StatusForbidden, map[string]interface{}{
+					"error":   "Forbidden",
+					"message": "No permission is assigned to this route",
+					"code":    "ROUTE_NOT_PERMITTED",
+				})
+			}
+			for _, permission := range required {
+				if !principal.Can(permission) {
+					return echo.NewHTTPError(http.StatusForbidden, map[string]interface{}{
+						"error":               "Forbidden",
+						"message":             "Insufficient permissions",
+						"code":                "INSUFFICIENT_PERMISSIONS",
+						"required_permission": permission,
+					})
+				}
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticTokens map[string]*Principal
+
+func (t staticTokens) Verify(token string) (*Principal, error) {
+	if p, ok := t[token]; ok {
+		return p, nil
+	}
+	return nil, errors.New("unknown token")
+}
+
+type staticKeys map[string]*Principal
+
+func (k staticKeys) ValidateAPIKey(ctx context.Context, key string) (*Principal, error) {
+	if p, ok := k[key]; ok {
+		return p, nil
+	}
+	return nil, errors.New("unknown key")
+}
+
+// newRBACServer registers a public route, a route requiring device:read, a route requiring
+// device:write and a route without permissions behind Authenticate and RequirePermissions
+func newRBACServer() *echo.Echo {
+	e := echo.New()
+	tokens := staticTokens{
+		"reader": {UserID: "1", Username: "reader", Permissions: []string{"device:read"}},
+		"admin":  {UserID: "2", Username: "admin", Permissions: []string{"*:*"}},
+	}
+	keys := staticKeys{
+		"scoped-key": {UserID: "2", Username: "admin", Permissions: []string{"*:*"}, APIKeyID: "k1", Scopes: []string{"device:read"}},
+	}
+	e.Use(Authenticate(tokens, keys, nil, "/health"), RequirePermissions(RoutePermissions{
+		Route(http.MethodGet, "/devices"):  {"device:read"},
+		Route(http.MethodPost, "/devices"): {"device:write"},
+	}))
+
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/health", ok)
+	e.GET("/devices", ok)
+	e.POST("/devices", ok)
+	e.GET("/unassigned", ok)
+	return e
+}
+
+func TestRequirePermissions(t *testing.T) {
+	e := newRBACServer()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		header   string
+		value    string
+		expected int
+	}{
+		{"public route without credentials", http.MethodGet, "/health", "", "", http.StatusOK},
+		{"protected route without credentials", http.MethodGet, "/devices", "", "", http.StatusUnauthorized},
+		{"invalid token", http.MethodGet, "/devices", "Authorization", "Bearer forged", http.StatusUnauthorized},
+		{"invalid API key", http.MethodGet, "/devices", "X-API-Key", "forged", http.StatusUnauthorized},
+		{"granted permission", http.MethodGet, "/devices", "Authorization", "Bearer reader", http.StatusOK},
+		{"missing permission", http.MethodPost, "/devices", "Authorization", "Bearer reader", http.StatusForbidden},
+		{"wildcard permission", http.MethodPost, "/devices", "Authorization", "Bearer admin", http.StatusOK},
+		{"route without permission is denied", http.MethodGet, "/unassigned", "Authorization", "Bearer admin", http.StatusForbidden},
+		{"API key within its scopes", http.MethodGet, "/devices", "X-API-Key", "scoped-key", http.StatusOK},
+		{"API key beyond its scopes", http.MethodPost, "/devices", "X-API-Key", "scoped-key", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expected, rec.Code)
+		})
+	}
+}
+
+func TestPermissionMatches(t *testing.T) {
+	assert.True(t, PermissionMatches("device:read", "device:read"))
+	assert.True(t, PermissionMatches("device:*", "device:write"))
+	assert.True(t, PermissionMatches("*:read", "data:read"))
+	assert.False(t, PermissionMatches("device:read", "device:write"))
+	assert.False(t, PermissionMatches("device", "device:read"))
+	assert.False(t, PermissionMatches("*", "device:read"))
+}
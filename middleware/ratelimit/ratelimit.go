@@ -0,0 +1,205 @@
+// Package ratelimit limits the rate of requests per client to the services that are not started
+// through bootstrap, with the token buckets and the rate limit settings of go-mod-bootstrap.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	gometrics "github.com/rcrowley/go-metrics"
+
+	"iiot-backend/middleware/auth"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/ratelimit"
+	"iiot-backend/pkg/go-mod-bootstrap/config"
+)
+
+const (
+	// EnvironmentVariable holds the rate limits as the JSON of a config.RateLimitInfo
+	EnvironmentVariable = "RATE_LIMITS"
+	// FileVariable names a file holding the JSON of a config.RateLimitInfo, re-read when it changes
+	FileVariable = "RATE_LIMITS_FILE"
+
+	// AllowedMetricName and RejectedMetricName count the requests let through and rejected per
+	// group, named like the counters of the bootstrap rate limit middleware
+	AllowedMetricName  = "RateLimitAllowed"
+	RejectedMetricName = "RateLimitRejected"
+)
+
+// fileCheckInterval bounds how often the rate limits file is checked for changes
+const fileCheckInterval = 5 * time.Second
+
+// Source holds the current rate limits, replaced at runtime when they are read from a file
+type Source struct {
+	mutex    sync.RWMutex
+	limits   *config.RateLimitInfo
+	path     string
+	modified time.Time
+}
+
+// FromEnvironment returns the rate limits read from the file named by FileVariable, or else set in
+// EnvironmentVariable, or defaults when neither is set. Limits read from a file follow its changes
+// once Watch runs.
+func FromEnvironment(defaults config.RateLimitInfo) (*Source, error) {
+	if path := os.Getenv(FileVariable); path != "" {
+		source := &Source{path: path}
+		if err := source.Reload(); err != nil {
+			return nil, err
+		}
+		return source, nil
+	}
+
+	value := os.Getenv(EnvironmentVariable)
+	if value == "" {
+		return &Source{limits: &defaults}, nil
+	}
+	limits, err := parse([]byte(value))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid rate limit configuration: %w", EnvironmentVariable, err)
+	}
+	return &Source{limits: limits}, nil
+}
+
+// parse decodes rate limits and checks the period of every group
+func parse(data []byte) (*config.RateLimitInfo, error) {
+	var limits config.RateLimitInfo
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return nil, err
+	}
+	for name, group := range limits.Groups {
+		if _, err := ratelimit.GroupQuota(group); err != nil {
+			return nil, fmt.Errorf("rate limit group %s has an invalid Period: %w", name, err)
+		}
+	}
+	return &limits, nil
+}
+
+// Get returns the current rate limits
+func (s *Source) Get() *config.RateLimitInfo {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.limits
+}
+
+// Reload reads the rate limits file again, keeping the current limits when it is not valid. It
+// does nothing for limits that were not read from a file.
+func (s *Source) Reload() error {
+	if s.path == "" {
+		return nil
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("unable to read rate limits: %w", err)
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("unable to read rate limits: %w", err)
+	}
+	limits, err := parse(data)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid rate limit configuration: %w", s.path, err)
+	}
+
+	s.mutex.Lock()
+	s.limits, s.modified = limits, info.ModTime()
+	s.mutex.Unlock()
+	return nil
+}
+
+// Watch reloads the rate limits whenever their file changes until ctx is done, reporting the
+// changes that could not be applied to onError
+func (s *Source) Watch(ctx context.Context, onError func(error)) {
+	if s.path == "" {
+		return
+	}
+	ticker := time.NewTicker(fileCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.path)
+			s.mutex.RLock()
+			changed := err == nil && !info.ModTime().Equal(s.modified)
+			s.mutex.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Counter is told the outcome of every limited request, with the name of its group
+type Counter func(group string, allowed bool)
+
+// MetricsCounter counts outcomes in registry, with one counter per outcome and group named after
+// AllowedMetricName or RejectedMetricName and the group
+func MetricsCounter(registry gometrics.Registry) Counter {
+	return func(group string, allowed bool) {
+		name := RejectedMetricName
+		if allowed {
+			name = AllowedMetricName
+		}
+		gometrics.GetOrRegisterCounter(name+"-"+group, registry).Inc(1)
+	}
+}
+
+// Limit limits the rate of requests per client and group of routes as configured by limits, which
+// is called on every request so that reloaded limits apply at once. Each response carries the
+// RateLimit-* headers of its group, requests over the limit are rejected with 429 and a
+// Retry-After header. Outcomes are passed to count when it is set. It must run after
+// auth.Authenticate, so that clients are told apart by their verified principal.
+func Limit(limits func() *config.RateLimitInfo, count Counter) echo.MiddlewareFunc {
+	limiter := ratelimit.NewLimiter()
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			info := limits()
+			if info == nil || !info.Enabled || r.Method == http.MethodOptions {
+				return next(c)
+			}
+
+			name, group, ok := info.Match(r.Method, r.URL.Path)
+			if !ok || group.Limit <= 0 {
+				return next(c)
+			}
+			quota, err := ratelimit.GroupQuota(group)
+			if err != nil {
+				return next(c)
+			}
+
+			result := limiter.Allow(name+"|"+Client(c), quota)
+			ratelimit.SetHeaders(c.Response().Header(), result, quota)
+			if count != nil {
+				count(name, result.Allowed)
+			}
+			if !result.Allowed {
+				return c.JSON(http.StatusTooManyRequests, map[string]string{
+					"error": fmt.Sprintf("Rate limit of %s exceeded, retry in %s seconds", name, ratelimit.CeilSeconds(result.RetryAfter)),
+				})
+			}
+			return next(c)
+		}
+	}
+}
+
+// Client returns the client requests are counted against: the API key or the user that
+// authenticated the request, or the remote IP of requests without a principal
+func Client(c echo.Context) string {
+	if principal := auth.PrincipalFrom(c); principal != nil {
+		if principal.APIKeyID != "" {
+			return "key:" + principal.APIKeyID
+		}
+		return "user:" + principal.UserID
+	}
+	return "ip:" + ratelimit.RemoteIP(c)
+}
@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"iiot-backend/middleware/auth"
+	"iiot-backend/pkg/go-mod-bootstrap/config"
+)
+
+type staticTokens map[string]*auth.Principal
+
+func (t staticTokens) Verify(token string) (*auth.Principal, error) {
+	if p, ok := t[token]; ok {
+		return p, nil
+	}
+	return nil, errors.New("unknown token")
+}
+
+func (t staticTokens) ValidateAPIKey(ctx context.Context, key string) (*auth.Principal, error) {
+	return nil, errors.New("unknown key")
+}
+
+// newServer limits GET /reading to one request per minute and client, behind authentication that
+// leaves /public open
+func newServer() *echo.Echo {
+	return newCountingServer(nil)
+}
+
+func newCountingServer(count Counter) *echo.Echo {
+	tokens := staticTokens{
+		"alice": {UserID: "1", Username: "alice"},
+		"bob":   {UserID: "2", Username: "bob"},
+	}
+	limits := &config.RateLimitInfo{Enabled: true, Groups: map[string]config.RateLimitGroupInfo{
+		"query": {Routes: []string{"/reading", "/public"}, Limit: 1, Period: "1m"},
+	}}
+
+	e := echo.New()
+	e.Use(auth.Authenticate(tokens, tokens, nil, "/public"), Limit(func() *config.RateLimitInfo { return limits }, count))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/reading", ok)
+	e.GET("/public", ok)
+	return e
+}
+
+func get(e *echo.Echo, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "10.0.0.1:40000"
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLimitPerPrincipal(t *testing.T) {
+	e := newServer()
+	alice := http.Header{"Authorization": {"Bearer alice"}}
+
+	rec := get(e, "/reading", alice)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	rec = get(e, "/reading", alice)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	// another user from the same address has a bucket of its own
+	assert.Equal(t, http.StatusOK, get(e, "/reading", http.Header{"Authorization": {"Bearer bob"}}).Code)
+}
+
+func TestLimitAnonymousByRemoteIP(t *testing.T) {
+	e := newServer()
+
+	assert.Equal(t, http.StatusOK, get(e, "/public", nil).Code)
+
+	// forwarding headers are not trusted without an IP extractor, they do not yield a fresh bucket
+	rec := get(e, "/public", http.Header{"X-Forwarded-For": {"192.0.2.7"}, "X-Real-Ip": {"192.0.2.8"}})
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
+
+func TestLimitIgnoresUnverifiedCredentials(t *testing.T) {
+	e := newServer()
+
+	// a forged token is rejected before it is counted, and never selects a bucket
+	assert.Equal(t, http.StatusUnauthorized, get(e, "/reading", http.Header{"Authorization": {"Bearer forged"}}).Code)
+	assert.Equal(t, http.StatusOK, get(e, "/reading", http.Header{"Authorization": {"Bearer alice"}}).Code)
+}
+
+func TestFromEnvironment(t *testing.T) {
+	defaults := config.RateLimitInfo{Enabled: true}
+
+	t.Setenv(EnvironmentVariable, "")
+	limits, err := FromEnvironment(defaults)
+	require.NoError(t, err)
+	assert.Equal(t, defaults, *limits.Get())
+
+	t.Setenv(EnvironmentVariable, `{"Enabled":true,"Groups":{"query":{"Routes":["/api/v3/reading"],"Limit":5,"Period":"10s"}}}`)
+	limits, err = FromEnvironment(defaults)
+	require.NoError(t, err)
+	assert.Equal(t, 5, limits.Get().Groups["query"].Limit)
+
+	t.Setenv(EnvironmentVariable, `{"Enabled":true,"Groups":{"query":{"Limit":5,"Period":"soon"}}}`)
+	_, err = FromEnvironment(defaults)
+	assert.Error(t, err)
+}
+
+func TestLimitCountsOutcomes(t *testing.T) {
+	registry := gometrics.NewRegistry()
+	e := newCountingServer(MetricsCounter(registry))
+	alice := http.Header{"Authorization": {"Bearer alice"}}
+	get(e, "/reading", alice)
+	get(e, "/reading", alice)
+	get(e, "/reading", alice)
+
+	assert.Equal(t, int64(1), gometrics.GetOrRegisterCounter(AllowedMetricName+"-query", registry).Count())
+	assert.Equal(t, int64(2), gometrics.GetOrRegisterCounter(RejectedMetricName+"-query", registry).Count())
+}
+
+func TestSourceReloadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimits.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"Enabled":true,"Groups":{"query":{"Limit":5,"Period":"1s"}}}`), 0600))
+	t.Setenv(FileVariable, path)
+	t.Setenv(EnvironmentVariable, `{"Enabled":false}`)
+
+	// the file takes precedence over the environment
+	source, err := FromEnvironment(config.RateLimitInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, 5, source.Get().Groups["query"].Limit)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"Enabled":true,"Groups":{"query":{"Limit":50,"Period":"1s"}}}`), 0600))
+	require.NoError(t, source.Reload())
+	assert.Equal(t, 50, source.Get().Groups["query"].Limit)
+
+	// invalid limits are reported and the current ones are kept
+	require.NoError(t, os.WriteFile(path, []byte(`{"Enabled":true,"Groups":{"query":{"Limit":1,"Period":"soon"}}}`), 0600))
+	assert.Error(t, source.Reload())
+	assert.Equal(t, 50, source.Get().Groups["query"].Limit)
+}
@@ -0,0 +1,304 @@
+package sensitive
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	// SecretName is the secret holding the field encryption keys. Each key is stored under its id as
+	// base64, the primary entry names the key new values are encrypted with.
+	SecretName = "field-encryption"
+
+	primaryKey = "primary"
+	keySize    = 32
+
+	// prefix marks every encrypted value, prefixV2 the values bound to their field and legacyPrefix
+	// the values encrypted before, which are rewritten by the next re-encryption
+	prefix       = "enc:"
+	prefixV2     = "enc:v2:"
+	legacyPrefix = "enc:v1:"
+)
+
+var (
+	// ErrKeyUnavailable is returned when a value was encrypted with a key the keyring does not hold
+	ErrKeyUnavailable = errors.New("field encryption key is not available")
+
+	// ErrEncryptedValue is returned when a value to encrypt already looks encrypted. Callers supplying
+	// ciphertext could otherwise have it stored and decrypted for them.
+	ErrEncryptedValue = errors.New("sensitive value must not be encrypted by the caller")
+)
+
+// Field names where an encrypted value is stored. It is bound to the ciphertext, so that a value
+// copied into another field or record fails to decrypt.
+type Field struct {
+	Table string
+	Row   string
+	Name  string
+}
+
+// Child returns the field of a property nested in f
+func (f Field) Child(name string) Field {
+	if f.Name != "" {
+		name = f.Name + "." + name
+	}
+	return Field{Table: f.Table, Row: f.Row, Name: name}
+}
+
+// additionalData returns the data authenticated along with a value of the field, the header
+// followed by the NUL separated table, row and name
+func (f Field) additionalData(header string) []byte {
+	return []byte(header + "\x00" + f.Table + "\x00" + f.Row + "\x00" + f.Name)
+}
+
+// Source retrieves the field encryption keys, the secret provider of the bootstrap and the secret
+// clients of go-mod-secrets satisfy it
+type Source interface {
+	RetrieveSecret(secretName string, keys ...string) (map[string]string, error)
+}
+
+// StaticSource serves keys that are not kept in a secret store, such as keys configured through the
+// environment
+type StaticSource map[string]string
+
+// RetrieveSecret returns the keys, whatever the secret name
+func (s StaticSource) RetrieveSecret(_ string, _ ...string) (map[string]string, error) {
+	return s, nil
+}
+
+// ParseKeys parses a comma separated list of id:base64-key pairs, the first key being the primary one
+func ParseKeys(spec string) (StaticSource, error) {
+	source := StaticSource{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, key, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || id == primaryKey {
+			return nil, fmt.Errorf("field encryption key '%s' must be given as id:base64-key", pair)
+		}
+		if _, exists := source[primaryKey]; !exists {
+			source[primaryKey] = id
+		}
+		source[id] = key
+	}
+	return source, nil
+}
+
+// Keyring encrypts sensitive fields with per-value data keys wrapped by the primary key encryption
+// key, and decrypts values encrypted with any key it holds. Retired keys stay in the secret until
+// every value was re-encrypted under the new primary key. A nil or empty keyring leaves values in
+// plaintext, so that installations without keys keep working.
+type Keyring struct {
+	source     Source
+	secretName string
+
+	mutex   sync.RWMutex
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyring loads the keys from the named secret of source
+func NewKeyring(source Source, secretName string) (*Keyring, error) {
+	k := &Keyring{source: source, secretName: secretName}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload fetches the keys again, after a key was added or the primary key was changed
+func (k *Keyring) Reload() error {
+	if k == nil || k.source == nil {
+		return nil
+	}
+	data, err := k.source.RetrieveSecret(k.secretName)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve field encryption keys: %w", err)
+	}
+
+	keys := make(map[string][]byte, len(data))
+	for id, encoded := range data {
+		if id == primaryKey {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return fmt.Errorf("field encryption key '%s' is not valid base64: %w", id, err)
+		}
+		if len(key) != keySize {
+			return fmt.Errorf("field encryption key '%s' must be %d bytes, got %d", id, keySize, len(key))
+		}
+		keys[id] = key
+	}
+	primary := data[primaryKey]
+	if primary == "" && len(keys) == 1 {
+		for id := range keys {
+			primary = id
+		}
+	}
+	if _, ok := keys[primary]; len(keys) > 0 && !ok {
+		return fmt.Errorf("primary field encryption key '%s' is not defined", primary)
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.primary, k.keys = primary, keys
+	return nil
+}
+
+// Enabled reports whether new values are encrypted
+func (k *Keyring) Enabled() bool {
+	return k.PrimaryKeyID() != ""
+}
+
+// PrimaryKeyID returns the id of the key new values are encrypted with, empty when encryption is
+// disabled
+func (k *Keyring) PrimaryKeyID() string {
+	if k == nil {
+		return ""
+	}
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.primary
+}
+
+// Encrypt encrypts value of field with a fresh data key wrapped by the primary key. Empty values
+// are returned unchanged, as is every value when encryption is disabled. Values that already look
+// encrypted are rejected with ErrEncryptedValue.
+func (k *Keyring) Encrypt(field Field, value string) (string, error) {
+	if IsEncrypted(value) {
+		return "", ErrEncryptedValue
+	}
+	if value == "" || k == nil {
+		return value, nil
+	}
+	k.mutex.RLock()
+	id, kek := k.primary, k.keys[k.primary]
+	k.mutex.RUnlock()
+	if kek == nil {
+		return value, nil
+	}
+
+	dek := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return "", err
+	}
+	header := prefixV2 + id
+	additionalData := field.additionalData(header)
+	wrapped, err := seal(kek, dek, additionalData)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dek, []byte(value), additionalData)
+	if err != nil {
+		return "", err
+	}
+	return header + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt returns the plaintext of an encrypted value of field, values stored before encryption was
+// enabled are returned unchanged. Values encrypted for another field fail to decrypt.
+func (k *Keyring) Decrypt(field Field, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	version := prefixV2
+	if strings.HasPrefix(value, legacyPrefix) {
+		version = legacyPrefix
+	}
+	parts := strings.Split(strings.TrimPrefix(value, version), ":")
+	if !strings.HasPrefix(value, version) || len(parts) != 3 {
+		return "", errors.New("malformed encrypted field")
+	}
+	id := parts[0]
+	var kek []byte
+	if k != nil {
+		k.mutex.RLock()
+		kek = k.keys[id]
+		k.mutex.RUnlock()
+	}
+	if kek == nil {
+		return "", fmt.Errorf("%w: %s", ErrKeyUnavailable, id)
+	}
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.New("malformed encrypted field")
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("malformed encrypted field")
+	}
+	// legacy values were bound to their header only
+	additionalData := []byte(version + id)
+	if version == prefixV2 {
+		additionalData = field.additionalData(version + id)
+	}
+	dek, err := open(kek, wrapped, additionalData)
+	if err != nil {
+		return "", fmt.Errorf("unable to unwrap data key of encrypted field: %w", err)
+	}
+	plaintext, err := open(dek, ciphertext, additionalData)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt field: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Stale reports whether a stored value must be rewritten to be encrypted with the primary key,
+// either because it is still in plaintext, because it was encrypted with a retired key or because
+// it was encrypted before values were bound to their field
+func (k *Keyring) Stale(value string) bool {
+	primary := k.PrimaryKeyID()
+	if value == "" || primary == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, prefixV2+primary+":")
+}
+
+// IsEncrypted reports whether value was produced by Encrypt, or looks like it was
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,157 @@
+package sensitive
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testField = Field{Table: "interval_actions", Row: "action-1", Name: "password"}
+
+func newTestKey(t *testing.T) string {
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestKeyringRotation(t *testing.T) {
+	source := StaticSource{primaryKey: "k1", "k1": newTestKey(t)}
+	keys, err := NewKeyring(source, SecretName)
+	require.NoError(t, err)
+
+	encrypted, err := keys.Encrypt(testField, "s3cret")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, prefixV2+"k1:"))
+	assert.False(t, keys.Stale(encrypted))
+
+	// a new primary key is added, the retired one stays until every value was re-encrypted
+	source["k2"] = newTestKey(t)
+	source[primaryKey] = "k2"
+	require.NoError(t, keys.Reload())
+	assert.Equal(t, "k2", keys.PrimaryKeyID())
+	assert.True(t, keys.Stale(encrypted))
+
+	plaintext, err := keys.Decrypt(testField, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", plaintext)
+
+	reencrypted, err := keys.Encrypt(testField, plaintext)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(reencrypted, prefixV2+"k2:"))
+	assert.False(t, keys.Stale(reencrypted))
+
+	// once the retired key is removed only the re-encrypted value can be read
+	delete(source, "k1")
+	require.NoError(t, keys.Reload())
+	_, err = keys.Decrypt(testField, encrypted)
+	assert.ErrorIs(t, err, ErrKeyUnavailable)
+	plaintext, err = keys.Decrypt(testField, reencrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", plaintext)
+}
+
+func TestKeyringRejectsEncryptedInput(t *testing.T) {
+	keys, err := NewKeyring(StaticSource{"k1": newTestKey(t)}, SecretName)
+	require.NoError(t, err)
+
+	encrypted, err := keys.Encrypt(testField, "s3cret")
+	require.NoError(t, err)
+	for _, value := range []string{encrypted, "enc:v1:k1:AAAA:AAAA", "enc:anything"} {
+		_, err := keys.Encrypt(testField, value)
+		assert.ErrorIs(t, err, ErrEncryptedValue, value)
+	}
+
+	// without keys the value would be stored as is and decrypted for the caller later
+	var disabled *Keyring
+	_, err = disabled.Encrypt(testField, encrypted)
+	assert.ErrorIs(t, err, ErrEncryptedValue)
+}
+
+func TestKeyringBindsValuesToTheirField(t *testing.T) {
+	keys, err := NewKeyring(StaticSource{"k1": newTestKey(t)}, SecretName)
+	require.NoError(t, err)
+
+	encrypted, err := keys.Encrypt(testField, "s3cret")
+	require.NoError(t, err)
+
+	for _, field := range []Field{
+		{Table: "interval_actions", Row: "action-2", Name: "password"},
+		{Table: "interval_actions", Row: "action-1", Name: "user"},
+		{Table: "subscriptions", Row: "action-1", Name: "password"},
+	} {
+		_, err := keys.Decrypt(field, encrypted)
+		assert.Error(t, err, field)
+	}
+}
+
+func TestKeyringDecryptsLegacyValues(t *testing.T) {
+	key := newTestKey(t)
+	keys, err := NewKeyring(StaticSource{"k1": key}, SecretName)
+	require.NoError(t, err)
+
+	// values written before fields were bound authenticate their header only
+	kek, err := base64.StdEncoding.DecodeString(key)
+	require.NoError(t, err)
+	dek := make([]byte, keySize)
+	_, err = rand.Read(dek)
+	require.NoError(t, err)
+	header := []byte(legacyPrefix + "k1")
+	legacy := legacyPrefix + "k1:" + base64.RawStdEncoding.EncodeToString(testSeal(t, kek, dek, header)) + ":" +
+		base64.RawStdEncoding.EncodeToString(testSeal(t, dek, []byte("s3cret"), header))
+
+	plaintext, err := keys.Decrypt(testField, legacy)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", plaintext)
+	assert.True(t, keys.Stale(legacy))
+}
+
+func TestPropertiesBindPaths(t *testing.T) {
+	keys, err := NewKeyring(StaticSource{"k1": newTestKey(t)}, SecretName)
+	require.NoError(t, err)
+	field := Field{Table: "devices", Row: "device-1", Name: "protocols"}
+
+	protocols := map[string]interface{}{
+		"mqtt": map[string]interface{}{"host": "broker", "password": "s3cret"},
+		"http": map[string]interface{}{"password": "other"},
+	}
+	encrypted, err := keys.EncryptProperties(field, protocols)
+	require.NoError(t, err)
+	assert.Equal(t, "broker", encrypted["mqtt"].(map[string]interface{})["host"])
+	assert.True(t, HasEncrypted(encrypted))
+
+	decrypted, err := keys.DecryptProperties(field, encrypted, true)
+	require.NoError(t, err)
+	assert.Equal(t, protocols, decrypted)
+
+	masked, err := keys.DecryptProperties(field, encrypted, false)
+	require.NoError(t, err)
+	assert.Equal(t, Mask, masked["mqtt"].(map[string]interface{})["password"])
+
+	// a credential moved to another protocol or device no longer decrypts
+	swapped := map[string]interface{}{"http": map[string]interface{}{"password": encrypted["mqtt"].(map[string]interface{})["password"]}}
+	_, err = keys.DecryptProperties(field, swapped, true)
+	assert.Error(t, err)
+	_, err = keys.DecryptProperties(Field{Table: "devices", Row: "device-2", Name: "protocols"}, encrypted, true)
+	assert.Error(t, err)
+
+	_, err = keys.EncryptProperties(field, encrypted)
+	assert.ErrorIs(t, err, ErrEncryptedValue)
+}
+
+func testSeal(t *testing.T, key, plaintext, additionalData []byte) []byte {
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	require.NoError(t, err)
+	return aead.Seal(nonce, nonce, plaintext, additionalData)
+}
@@ -0,0 +1,201 @@
+// Package sensitive protects credentials stored alongside ordinary configuration, such as passwords
+// of scheduled actions, mail server passwords, webhook authorization headers and device protocol
+// credentials. Services encrypt them at rest with a Keyring and mask them in API responses unless
+// the caller holds PermissionReveal.
+package sensitive
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"iiot-backend/middleware/auth"
+)
+
+const (
+	// Mask replaces sensitive values in responses to callers that may not reveal them. Writing the
+	// mask back keeps the stored value, so that masked records can be edited and saved.
+	Mask = "********"
+
+	// PermissionReveal lets a principal read sensitive values in plaintext
+	PermissionReveal = "secret:reveal"
+
+	// PermissionRotate lets a principal re-encrypt the stored values after a key rotation
+	PermissionRotate = "secret:rotate"
+
+	// SecretReference is the property naming a stored secret that holds the credentials, such as a
+	// device protocol referencing its credentials. The name is not a credential and is never masked.
+	SecretReference = "secretName"
+)
+
+// ErrMasked is returned when a new record carries the mask in place of a sensitive value, there is
+// no stored value for the mask to stand for
+var ErrMasked = errors.New("sensitive value is masked, provide the actual value")
+
+type contextKey struct{}
+
+// WithReveal returns a copy of ctx telling services whether sensitive values may be revealed
+func WithReveal(ctx context.Context, reveal bool) context.Context {
+	return context.WithValue(ctx, contextKey{}, reveal)
+}
+
+// Revealed reports whether sensitive values may be returned in plaintext. Contexts that Resolve or
+// WithReveal did not allow it mask them, background jobs needing plaintext opt in with
+// WithReveal(ctx, true).
+func Revealed(ctx context.Context) bool {
+	reveal, _ := ctx.Value(contextKey{}).(bool)
+	return reveal
+}
+
+// Resolve lets each authenticated request reveal sensitive values when its principal holds
+// PermissionReveal. It must run after auth.Authenticate.
+func Resolve() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := auth.PrincipalFrom(c)
+			if principal == nil {
+				return next(c)
+			}
+			reveal := principal.Can(PermissionReveal)
+			c.SetRequest(c.Request().WithContext(WithReveal(c.Request().Context(), reveal)))
+			return next(c)
+		}
+	}
+}
+
+// Keep returns the stored value when incoming is the mask, and incoming otherwise
+func Keep(incoming, stored string) string {
+	if incoming == Mask {
+		return stored
+	}
+	return incoming
+}
+
+// MaskValue returns the mask for non-empty values, so that callers can tell a value is set
+func MaskValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return Mask
+}
+
+// sensitiveNames are the fragments of property names holding credentials, compared in lower case
+// without separators
+var sensitiveNames = []string{"password", "passwd", "secret", "token", "apikey", "privatekey", "passphrase", "credential"}
+
+// IsSensitiveName reports whether a property with the given name holds a credential
+func IsSensitiveName(name string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "", ".", "").Replace(strings.ToLower(name))
+	if normalized == strings.ToLower(SecretReference) {
+		return false
+	}
+	for _, fragment := range sensitiveNames {
+		if strings.Contains(normalized, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSensitiveHeader reports whether an HTTP header carries credentials
+func IsSensitiveHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Authorization", "Proxy-Authorization", "Cookie":
+		return true
+	}
+	return IsSensitiveName(name)
+}
+
+// EncryptProperties returns a copy of properties, such as device protocols, with the values of
+// sensitive properties encrypted at any depth. Each value is bound to its path below field.
+func (k *Keyring) EncryptProperties(field Field, properties map[string]interface{}) (map[string]interface{}, error) {
+	return transformProperties(field, properties, func(field Field, value string) (string, error) {
+		if value == Mask {
+			return "", ErrMasked
+		}
+		return k.Encrypt(field, value)
+	})
+}
+
+// DecryptProperties returns a copy of properties with the values of sensitive properties decrypted,
+// or masked when reveal is false
+func (k *Keyring) DecryptProperties(field Field, properties map[string]interface{}, reveal bool) (map[string]interface{}, error) {
+	if !reveal {
+		return MaskProperties(properties), nil
+	}
+	return transformProperties(field, properties, k.Decrypt)
+}
+
+// PropertiesStale reports whether any sensitive property must be rewritten under the primary key
+func (k *Keyring) PropertiesStale(properties map[string]interface{}) bool {
+	stale := false
+	transformProperties(Field{}, properties, func(_ Field, value string) (string, error) {
+		stale = stale || k.Stale(value)
+		return value, nil
+	})
+	return stale
+}
+
+// HasMask reports whether any sensitive property carries the mask instead of a value
+func HasMask(properties map[string]interface{}) bool {
+	masked := false
+	transformProperties(Field{}, properties, func(_ Field, value string) (string, error) {
+		masked = masked || value == Mask
+		return value, nil
+	})
+	return masked
+}
+
+// HasEncrypted reports whether any sensitive property carries an encrypted value, which callers
+// must not supply
+func HasEncrypted(properties map[string]interface{}) bool {
+	encrypted := false
+	transformProperties(Field{}, properties, func(_ Field, value string) (string, error) {
+		encrypted = encrypted || IsEncrypted(value)
+		return value, nil
+	})
+	return encrypted
+}
+
+// MaskProperties returns a copy of properties with the values of sensitive properties masked
+func MaskProperties(properties map[string]interface{}) map[string]interface{} {
+	masked, _ := transformProperties(Field{}, properties, func(_ Field, value string) (string, error) {
+		return MaskValue(value), nil
+	})
+	return masked
+}
+
+// transformProperties copies properties, applying transform to the string values of sensitive
+// properties along with their path below field
+func transformProperties(field Field, properties map[string]interface{}, transform func(Field, string) (string, error)) (map[string]interface{}, error) {
+	if properties == nil {
+		return nil, nil
+	}
+	result := make(map[string]interface{}, len(properties))
+	for name, value := range properties {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			nested, err := transformProperties(field.Child(name), v, transform)
+			if err != nil {
+				return nil, err
+			}
+			result[name] = nested
+		case string:
+			if !IsSensitiveName(name) {
+				result[name] = v
+				continue
+			}
+			transformed, err := transform(field.Child(name), v)
+			if err != nil {
+				return nil, err
+			}
+			result[name] = transformed
+		default:
+			result[name] = value
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,104 @@
+// Package tenant carries the tenant a request acts for from the authenticated principal down to the
+// service layer queries, so that customers and sites sharing one backend only see their own data
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"iiot-backend/middleware/auth"
+)
+
+const (
+	// DefaultTenant owns the data of installations that do not use tenants and of principals that
+	// are not assigned one
+	DefaultTenant = "default"
+
+	// PermissionAllTenants lets a principal read and manage the data of every tenant
+	PermissionAllTenants = "tenant:all"
+
+	// Header lets a principal holding PermissionAllTenants act for a single tenant
+	Header = "X-Tenant-ID"
+)
+
+type contextKey struct{}
+
+// Scope is the set of tenants a request may access. The zero Scope allows no tenant.
+type Scope struct {
+	// Tenant is the tenant the request acts for, records it creates belong to this tenant
+	Tenant string
+	// All lifts the restriction to Tenant when reading, updating and deleting records
+	All bool
+}
+
+// Unrestricted returns the scope of internal callers acting for every tenant, the records they create
+// belong to the default tenant
+func Unrestricted() Scope {
+	return Scope{Tenant: DefaultTenant, All: true}
+}
+
+// WithScope returns a copy of ctx scoped to s
+func WithScope(ctx context.Context, s Scope) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// FromContext returns the scope Resolve or WithScope attached to ctx. Contexts without a scope get
+// the zero Scope, which allows no tenant, so background jobs acting for every tenant have to opt in
+// with WithScope(ctx, Unrestricted()).
+func FromContext(ctx context.Context) Scope {
+	s, _ := ctx.Value(contextKey{}).(Scope)
+	return s
+}
+
+// Allows reports whether a record of the given tenant is visible in the scope
+func (s Scope) Allows(tenant string) bool {
+	return s.All || (s.Tenant != "" && tenant == s.Tenant)
+}
+
+// Condition returns a SQL condition restricting column to the scope's tenant, appending the tenant
+// to args as the next positional parameter. Unrestricted scopes yield TRUE and the zero Scope FALSE.
+func (s Scope) Condition(column string, args *[]interface{}) string {
+	if s.All {
+		return "TRUE"
+	}
+	if s.Tenant == "" {
+		return "FALSE"
+	}
+	*args = append(*args, s.Tenant)
+	return fmt.Sprintf("%s = $%d", column, len(*args))
+}
+
+// Resolve scopes each authenticated request to the tenant of its principal, and every tenant for
+// principals holding PermissionAllTenants unless they select one with the X-Tenant-ID header. It must
+// run after auth.Authenticate.
+func Resolve() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := auth.PrincipalFrom(c)
+			if principal == nil {
+				return next(c)
+			}
+
+			s := Scope{Tenant: principal.Tenant, All: principal.Can(PermissionAllTenants)}
+			if s.Tenant == "" {
+				s.Tenant = DefaultTenant
+			}
+			if requested := c.Request().Header.Get(Header); requested != "" {
+				if !s.All && requested != s.Tenant {
+					return echo.NewHTTPError(http.StatusForbidden, map[string]interface{}{
+						"error":   "Forbidden",
+						"message": "Access to tenant " + requested + " is not permitted",
+						"code":    "TENANT_FORBIDDEN",
+					})
+				}
+				s = Scope{Tenant: requested}
+			}
+
+			c.SetRequest(c.Request().WithContext(WithScope(c.Request().Context(), s)))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"iiot-backend/middleware/auth"
+)
+
+func TestFromContextFailsClosed(t *testing.T) {
+	scope := FromContext(context.Background())
+	assert.False(t, scope.All)
+	assert.False(t, scope.Allows(DefaultTenant))
+	assert.False(t, scope.Allows(""))
+
+	var args []interface{}
+	assert.Equal(t, "FALSE", scope.Condition("tenant_id", &args))
+	assert.Empty(t, args)
+
+	scope = FromContext(WithScope(context.Background(), Unrestricted()))
+	assert.True(t, scope.Allows("plant-a"))
+	assert.Equal(t, "TRUE", scope.Condition("tenant_id", &args))
+	assert.Empty(t, args)
+}
+
+func TestScopeCondition(t *testing.T) {
+	args := []interface{}{"device-1"}
+	scope := Scope{Tenant: "plant-a"}
+	assert.Equal(t, "tenant_id = $2", scope.Condition("tenant_id", &args))
+	assert.Equal(t, []interface{}{"device-1", "plant-a"}, args)
+	assert.True(t, scope.Allows("plant-a"))
+	assert.False(t, scope.Allows("plant-b"))
+}
+
+// principalToken authenticates the bearer token "test" as principal
+type principalToken struct{ principal *auth.Principal }
+
+func (p principalToken) Verify(token string) (*auth.Principal, error) {
+	if token != "test" {
+		return nil, errors.New("unknown token")
+	}
+	return p.principal, nil
+}
+
+func (p principalToken) ValidateAPIKey(ctx context.Context, key string) (*auth.Principal, error) {
+	return nil, errors.New("unknown key")
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		header    string
+		expected  int
+		scope     Scope
+	}{
+		{"anonymous request to a public route", nil, "", http.StatusOK, Scope{}},
+		{"principal without tenant", &auth.Principal{}, "", http.StatusOK, Scope{Tenant: DefaultTenant}},
+		{"principal of a tenant", &auth.Principal{Tenant: "plant-a"}, "", http.StatusOK, Scope{Tenant: "plant-a"}},
+		{"own tenant selected", &auth.Principal{Tenant: "plant-a"}, "plant-a", http.StatusOK, Scope{Tenant: "plant-a"}},
+		{"other tenant selected", &auth.Principal{Tenant: "plant-a"}, "plant-b", http.StatusForbidden, Scope{}},
+		{"all tenants", &auth.Principal{Permissions: []string{PermissionAllTenants}}, "", http.StatusOK, Scope{Tenant: DefaultTenant, All: true}},
+		{"all tenants selecting one", &auth.Principal{Permissions: []string{PermissionAllTenants}}, "plant-b", http.StatusOK, Scope{Tenant: "plant-b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(auth.Authenticate(principalToken{tt.principal}, principalToken{tt.principal}, nil, "/public"), Resolve())
+			var scope Scope
+			handler := func(c echo.Context) error {
+				scope = FromContext(c.Request().Context())
+				return c.NoContent(http.StatusOK)
+			}
+			e.GET("/", handler)
+			e.GET("/public", handler)
+
+			path := "/"
+			if tt.principal == nil {
+				path = "/public"
+			}
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if tt.principal != nil {
+				req.Header.Set("Authorization", "Bearer test")
+			}
+			if tt.header != "" {
+				req.Header.Set(Header, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expected, rec.Code)
+			assert.Equal(t, tt.scope, scope)
+		})
+	}
+}
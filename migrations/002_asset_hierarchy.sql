@@ -0,0 +1,21 @@
+-- Asset hierarchy for ISA-95 style organisation of devices
+-- (site -> area -> line -> cell -> device)
+
+CREATE TABLE IF NOT EXISTS asset_nodes (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) UNIQUE NOT NULL,
+    description TEXT,
+    level VARCHAR(50) NOT NULL,
+    parent_name VARCHAR(255),
+    labels JSONB DEFAULT '[]',
+    created BIGINT NOT NULL,
+    modified BIGINT NOT NULL,
+    FOREIGN KEY (parent_name) REFERENCES asset_nodes(name) ON UPDATE CASCADE ON DELETE RESTRICT
+);
+
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS asset_node VARCHAR(255)
+    REFERENCES asset_nodes(name) ON UPDATE CASCADE ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_asset_nodes_parent_name ON asset_nodes(parent_name);
+CREATE INDEX IF NOT EXISTS idx_asset_nodes_level ON asset_nodes(level);
+CREATE INDEX IF NOT EXISTS idx_devices_asset_node ON devices(asset_node);
@@ -0,0 +1,39 @@
+-- Device profile revision history
+-- Every change to a profile is stored as a full snapshot so old readings can
+-- be interpreted with the resource properties they were produced under.
+
+ALTER TABLE device_profiles ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS device_profile_versions (
+    id UUID PRIMARY KEY,
+    profile_name VARCHAR(255) NOT NULL,
+    version INTEGER NOT NULL,
+    profile JSONB NOT NULL,
+    change_description TEXT,
+    created BIGINT NOT NULL,
+    UNIQUE (profile_name, version),
+    FOREIGN KEY (profile_name) REFERENCES device_profiles(name) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+-- Seed the history with the current revision of existing profiles
+INSERT INTO device_profile_versions (id, profile_name, version, profile, change_description, created)
+SELECT gen_random_uuid(), p.name, p.version,
+    jsonb_build_object(
+        'id', p.id, 'name', p.name, 'description', COALESCE(p.description, ''),
+        'manufacturer', COALESCE(p.manufacturer, ''), 'model', COALESCE(p.model, ''),
+        'labels', p.labels, 'deviceResources', p.device_resources,
+        'deviceCommands', p.device_commands, 'coreCommands', p.core_commands,
+        'version', p.version),
+    'initial revision', EXTRACT(EPOCH FROM NOW())::BIGINT
+FROM device_profiles p
+WHERE NOT EXISTS (
+    SELECT 1 FROM device_profile_versions v WHERE v.profile_name = p.name AND v.version = p.version
+);
+
+-- Devices pinned to a profile version keep using it after the profile changes
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS profile_version INTEGER;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS profile_version INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_device_profile_versions_profile_name ON device_profile_versions(profile_name);
+CREATE INDEX IF NOT EXISTS idx_events_profile_version ON events(profile_name, profile_version);
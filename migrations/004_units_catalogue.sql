@@ -0,0 +1,39 @@
+-- Unit-of-measure catalogue
+-- A value in a unit converts to the base unit of its quantity as
+-- value * factor + offset. Symbols follow UCUM, with the spellings device
+-- vendors commonly report added as separate entries.
+
+CREATE TABLE IF NOT EXISTS units (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    quantity VARCHAR(100) NOT NULL,
+    description TEXT,
+    factor DOUBLE PRECISION NOT NULL DEFAULT 1,
+    "offset" DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created BIGINT NOT NULL,
+    modified BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_units_quantity ON units(quantity);
+
+INSERT INTO units (id, name, quantity, description, factor, "offset", created, modified) VALUES
+    (gen_random_uuid(), 'K', 'temperature', 'kelvin', 1, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'Cel', 'temperature', 'degree Celsius', 1, 273.15, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), '°C', 'temperature', 'degree Celsius', 1, 273.15, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), '[degF]', 'temperature', 'degree Fahrenheit', 5.0 / 9.0, 273.15 - 32.0 * 5.0 / 9.0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), '°F', 'temperature', 'degree Fahrenheit', 5.0 / 9.0, 273.15 - 32.0 * 5.0 / 9.0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'Pa', 'pressure', 'pascal', 1, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'kPa', 'pressure', 'kilopascal', 1000, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'MPa', 'pressure', 'megapascal', 1000000, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'bar', 'pressure', 'bar', 100000, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'mbar', 'pressure', 'millibar', 100, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), '[psi]', 'pressure', 'pound per square inch', 6894.757293168, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'psi', 'pressure', 'pound per square inch', 6894.757293168, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'm3/s', 'volumetric flow', 'cubic metre per second', 1, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'm3/h', 'volumetric flow', 'cubic metre per hour', 1.0 / 3600.0, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'm³/h', 'volumetric flow', 'cubic metre per hour', 1.0 / 3600.0, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'l/min', 'volumetric flow', 'litre per minute', 0.001 / 60.0, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'L/min', 'volumetric flow', 'litre per minute', 0.001 / 60.0, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), 'l/s', 'volumetric flow', 'litre per second', 0.001, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    (gen_random_uuid(), '%', 'ratio', 'percent', 0.01, 0, EXTRACT(EPOCH FROM NOW())::BIGINT, EXTRACT(EPOCH FROM NOW())::BIGINT)
+ON CONFLICT (name) DO NOTHING;
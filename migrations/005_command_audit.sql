@@ -0,0 +1,24 @@
+-- Command audit log
+-- Every command core-command forwards to a device service, whether it
+-- arrived over REST, the internal message bus or external MQTT.
+-- Timestamps and latency are in milliseconds.
+
+CREATE TABLE IF NOT EXISTS command_audits (
+    id UUID PRIMARY KEY,
+    source VARCHAR(50) NOT NULL,
+    caller VARCHAR(255),
+    device_name VARCHAR(255) NOT NULL,
+    command_name VARCHAR(255) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    query_params TEXT,
+    settings JSONB,
+    status_code INTEGER NOT NULL,
+    error_message TEXT,
+    latency BIGINT NOT NULL DEFAULT 0,
+    correlation_id VARCHAR(255),
+    created BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_command_audits_created ON command_audits(created);
+CREATE INDEX IF NOT EXISTS idx_command_audits_device_name ON command_audits(device_name, created);
+CREATE INDEX IF NOT EXISTS idx_command_audits_correlation_id ON command_audits(correlation_id);
@@ -0,0 +1,22 @@
+-- Command queue
+-- Set commands core-command holds for devices that are down or whose
+-- device service is unreachable. seq keeps the commands of a device in
+-- the order they were queued. Timestamps are in milliseconds.
+
+CREATE TABLE IF NOT EXISTS command_queue (
+    id UUID PRIMARY KEY,
+    seq BIGSERIAL NOT NULL,
+    device_name VARCHAR(255) NOT NULL,
+    command_name VARCHAR(255) NOT NULL,
+    query_params TEXT,
+    settings JSONB NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    caller VARCHAR(255),
+    correlation_id VARCHAR(255),
+    reason TEXT,
+    expires BIGINT NOT NULL,
+    created BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_command_queue_device_name ON command_queue(device_name, seq);
+CREATE INDEX IF NOT EXISTS idx_command_queue_expires ON command_queue(expires);
@@ -0,0 +1,59 @@
+-- Users, roles and API keys
+-- Permissions are resource:verb pairs such as device:write or command:set,
+-- either part may be the * wildcard. A user holds the union of the
+-- permissions of its roles. API keys are stored as SHA-256 hashes, only the
+-- prefix is kept so operators can tell their keys apart, and an API key's
+-- scopes narrow its owner's permissions.
+
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY,
+    username VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    display_name VARCHAR(255),
+    email VARCHAR(255),
+    enabled BOOLEAN DEFAULT TRUE,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS roles (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) UNIQUE NOT NULL,
+    description TEXT,
+    permissions JSONB DEFAULT '[]',
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modified TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id UUID NOT NULL,
+    role_id UUID NOT NULL,
+    PRIMARY KEY (user_id, role_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(32) NOT NULL,
+    key_hash VARCHAR(64) UNIQUE NOT NULL,
+    scopes JSONB DEFAULT '[]',
+    expires_at TIMESTAMP,
+    last_used TIMESTAMP,
+    revoked BOOLEAN DEFAULT FALSE,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles(role_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+
+INSERT INTO roles (id, name, description, permissions) VALUES
+    (gen_random_uuid(), 'admin', 'Full access, including user and role management', '["*:*"]'),
+    (gen_random_uuid(), 'operator', 'Manages devices and rules and issues commands',
+        '["system:read", "device:read", "device:write", "command:read", "command:get", "command:set", "data:read", "notification:read", "rule:manage", "apikey:manage"]'),
+    (gen_random_uuid(), 'viewer', 'Read-only access to metadata, commands and data',
+        '["system:read", "device:read", "command:read", "data:read", "notification:read", "apikey:manage"]')
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,49 @@
+-- Multi-tenancy
+-- Every device, asset, reading, rule, schedule, notification and user belongs
+-- to a tenant. Existing rows and rows written by installations that do not
+-- use tenants belong to the default tenant. Names stay unique across all
+-- tenants, so devices and profiles keep being addressed by name alone.
+-- Units and roles are shared by every tenant.
+
+CREATE TABLE IF NOT EXISTS tenants (
+    name VARCHAR(255) PRIMARY KEY,
+    description TEXT,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO tenants (name, description) VALUES ('default', 'Owns the data of principals without a tenant')
+ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE device_services ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE device_profiles ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE asset_nodes ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE readings ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE intervals ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE interval_actions ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE pipelines ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default' REFERENCES tenants(name);
+
+CREATE INDEX IF NOT EXISTS idx_device_services_tenant_id ON device_services(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_device_profiles_tenant_id ON device_profiles(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_devices_tenant_id ON devices(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_asset_nodes_tenant_id ON asset_nodes(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_events_tenant_id_origin ON events(tenant_id, origin);
+CREATE INDEX IF NOT EXISTS idx_readings_tenant_id_origin ON readings(tenant_id, origin);
+CREATE INDEX IF NOT EXISTS idx_intervals_tenant_id ON intervals(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_interval_actions_tenant_id ON interval_actions(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_tenant_id ON notifications(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_tenant_id ON subscriptions(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_rules_tenant_id ON rules(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_pipelines_tenant_id ON pipelines(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_users_tenant_id ON users(tenant_id);
+
+-- Roles without tenant:all are confined to the tenant of their user
+INSERT INTO roles (id, name, description, permissions) VALUES
+    (gen_random_uuid(), 'tenant-admin', 'Manages the devices, rules and users of its own tenant',
+        '["system:read", "device:read", "device:write", "command:read", "command:get", "command:set", "data:read", "notification:read", "rule:manage", "apikey:manage", "user:read", "user:manage", "role:read", "tenant:read"]')
+ON CONFLICT (name) DO NOTHING;
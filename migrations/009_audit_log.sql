@@ -0,0 +1,42 @@
+-- Audit log of mutating API calls
+-- Every POST, PUT, PATCH and DELETE request, who made it, the entity it
+-- changed and the entity's state before and after. Entries are chained by
+-- SHA-256 hashes over their fields and the previous entry's hash, so edits
+-- and deletions made directly in the database are detectable. Before and
+-- after are stored as text so that their hashed bytes are kept verbatim.
+-- Timestamps are in milliseconds.
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    seq BIGSERIAL PRIMARY KEY,
+    id UUID UNIQUE NOT NULL,
+    created BIGINT NOT NULL,
+    user_id VARCHAR(255) NOT NULL DEFAULT '',
+    username VARCHAR(255) NOT NULL DEFAULT '',
+    tenant_id VARCHAR(255) NOT NULL DEFAULT '',
+    method VARCHAR(10) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    path TEXT NOT NULL,
+    entity_type VARCHAR(100) NOT NULL DEFAULT '',
+    entity_id VARCHAR(255) NOT NULL DEFAULT '',
+    status_code INTEGER NOT NULL,
+    remote_addr VARCHAR(255) NOT NULL DEFAULT '',
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    before TEXT,
+    after TEXT,
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) UNIQUE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created);
+CREATE INDEX IF NOT EXISTS idx_audit_log_username ON audit_log(username, created);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id, created);
+CREATE INDEX IF NOT EXISTS idx_audit_log_tenant_id ON audit_log(tenant_id, created);
+
+-- The log is append-only, updates and deletes are discarded
+CREATE OR REPLACE RULE audit_log_no_update AS ON UPDATE TO audit_log DO INSTEAD NOTHING;
+CREATE OR REPLACE RULE audit_log_no_delete AS ON DELETE TO audit_log DO INSTEAD NOTHING;
+
+INSERT INTO roles (id, name, description, permissions) VALUES
+    (gen_random_uuid(), 'auditor', 'Reads and exports the audit log',
+        '["system:read", "audit:read"]')
+ON CONFLICT (name) DO NOTHING;
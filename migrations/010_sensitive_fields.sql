@@ -0,0 +1,14 @@
+-- Encryption of sensitive fields at rest
+-- Credentials stored with interval actions, notification channels and device protocols are
+-- encrypted at rest with envelope encryption and masked in responses unless the caller holds
+-- secret:reveal. Encrypted values carry a key id, a wrapped data key and a nonce, which outgrows
+-- the original column size.
+
+ALTER TABLE interval_actions ALTER COLUMN password TYPE TEXT;
+
+-- Granted alongside another role to those who must read stored credentials or re-encrypt them
+-- after a key rotation
+INSERT INTO roles (id, name, description, permissions) VALUES
+    (gen_random_uuid(), 'secret-custodian', 'Reveals stored credentials and re-encrypts them after a key rotation',
+        '["secret:reveal", "secret:rotate"]')
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,20 @@
+-- Scheduled commands
+-- Set commands scheduled with ds-writeat, kept until core-command issues
+-- them so that their command jobs are resumed after a restart. Timestamps
+-- are in milliseconds.
+
+CREATE TABLE IF NOT EXISTS scheduled_commands (
+    job_id UUID PRIMARY KEY,
+    device_name VARCHAR(255) NOT NULL,
+    command_name VARCHAR(255) NOT NULL,
+    query_params TEXT,
+    settings JSONB NOT NULL,
+    callback TEXT,
+    source VARCHAR(50) NOT NULL,
+    caller VARCHAR(255),
+    correlation_id VARCHAR(255),
+    scheduled BIGINT NOT NULL,
+    created BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_commands_scheduled ON scheduled_commands(scheduled);
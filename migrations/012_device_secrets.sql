@@ -0,0 +1,7 @@
+-- Device secrets
+-- Devices reference named credentials kept in the secret store of core-metadata.
+-- secret:read lists them and their keys, never their values, and secret:write
+-- creates or replaces them.
+
+UPDATE roles SET permissions = permissions || '["secret:read", "secret:write"]'
+WHERE name = 'secret-custodian' AND NOT permissions @> '["secret:write"]';
@@ -0,0 +1,16 @@
+-- Keyed audit log chain
+-- Entries are now chained by HMAC-SHA256 under a key kept out of the
+-- database, so that the chain cannot be recomputed after rewriting entries.
+-- Entries recorded before are kept with their SHA-256 hashes and marked
+-- unkeyed. The head points at the last entry and is signed with the same
+-- key, so that entries removed from the end of the log are detectable.
+
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS keyed BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- A single row replaced by every append
+CREATE TABLE IF NOT EXISTS audit_log_head (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    seq BIGINT NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    signature VARCHAR(64) NOT NULL
+);
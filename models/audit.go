@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+)
+
+// AuditEntry represents a mutating API call recorded in the append-only audit log. Each entry's
+// hash covers its fields and the hash of the entry before it, so that changed, removed or
+// reordered entries break the chain. Keyed entries are hashed with a key kept out of the
+// database, entries recorded before the log was keyed are not. Times are in milliseconds.
+type AuditEntry struct {
+	Seq        int64                       `json:"seq"`
+	ID         string                      `json:"id"`
+	Created    int64                       `json:"created"`
+	UserID     string                      `json:"userId,omitempty"`
+	Username   string                      `json:"username,omitempty"`
+	Tenant     string                      `json:"tenant,omitempty"`
+	Method     string                      `json:"method"`
+	Route      string                      `json:"route"`
+	Path       string                      `json:"path"`
+	EntityType string                      `json:"entityType,omitempty"`
+	EntityID   string                      `json:"entityId,omitempty"`
+	StatusCode int                         `json:"statusCode"`
+	RemoteAddr string                      `json:"remoteAddr,omitempty"`
+	RequestID  string                      `json:"requestId,omitempty"`
+	Before     json.RawMessage             `json:"before,omitempty"`
+	After      json.RawMessage             `json:"after,omitempty"`
+	Diff       map[string]AuditFieldChange `json:"diff,omitempty"`
+	PrevHash   string                      `json:"prevHash"`
+	Hash       string                      `json:"hash"`
+	Keyed      bool                        `json:"keyed"`
+}
+
+// AuditFieldChange represents the values of an entity field before and after a change
+type AuditFieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditVerification represents the result of checking the audit log hash chain
+type AuditVerification struct {
+	Valid   bool  `json:"valid"`
+	Checked int64 `json:"checked"`
+	// BrokenAt is the sequence number of the first entry whose hash or link does not match
+	BrokenAt int64  `json:"brokenAt,omitempty"`
+	Message  string `json:"message,omitempty"`
+	// HeadSeq and HeadHash identify the signed head the log was verified up to, to be compared
+	// with a copy kept out of the database
+	HeadSeq  int64  `json:"headSeq,omitempty"`
+	HeadHash string `json:"headHash,omitempty"`
+}
@@ -10,6 +10,7 @@ type Event struct {
 	DeviceName  string    `json:"deviceName" db:"device_name"`
 	ProfileName string    `json:"profileName" db:"profile_name"`
 	SourceName  string    `json:"sourceName" db:"source_name"`
+	ProfileVersion int    `json:"profileVersion,omitempty" db:"profile_version"`
 	Origin      int64     `json:"origin" db:"origin"`
 	Tags        map[string]string `json:"tags" db:"tags"`
 	Readings    []Reading `json:"readings" db:"-"`
@@ -40,6 +41,7 @@ type EventRequest struct {
 	DeviceName  string    `json:"deviceName" validate:"required"`
 	ProfileName string    `json:"profileName" validate:"required"`
 	SourceName  string    `json:"sourceName" validate:"required"`
+	ProfileVersion int    `json:"profileVersion,omitempty"`
 	Origin      int64     `json:"origin"`
 	Tags        map[string]string `json:"tags"`
 	Readings    []ReadingRequest `json:"readings" validate:"required,min=1"`
@@ -64,6 +66,7 @@ type EventFilter struct {
 	DeviceName  string    `json:"deviceName"`
 	ProfileName string    `json:"profileName"`
 	SourceName  string    `json:"sourceName"`
+	AssetNode   string    `json:"assetNode"`
 	Start       time.Time `json:"start"`
 	End         time.Time `json:"end"`
 	Limit       int       `json:"limit"`
@@ -76,6 +79,8 @@ type ReadingFilter struct {
 	ResourceName string    `json:"resourceName"`
 	ProfileName  string    `json:"profileName"`
 	ValueType    string    `json:"valueType"`
+	AssetNode    string    `json:"assetNode"`
+	Unit         string    `json:"unit"`
 	Start        time.Time `json:"start"`
 	End          time.Time `json:"end"`
 	Limit        int       `json:"limit"`
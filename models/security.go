@@ -0,0 +1,111 @@
+package models
+
+import (
+	"time"
+)
+
+// User represents an operator account, the password hash never leaves the service
+type User struct {
+	ID          string    `json:"id" db:"id"`
+	Username    string    `json:"username" db:"username"`
+	DisplayName string    `json:"displayName" db:"display_name"`
+	Email       string    `json:"email" db:"email"`
+	Enabled     bool      `json:"enabled" db:"enabled"`
+	Roles       []string  `json:"roles" db:"-"`
+	Tenant      string    `json:"tenant" db:"tenant_id"`
+	Created     time.Time `json:"created" db:"created"`
+	Modified    time.Time `json:"modified" db:"modified"`
+}
+
+// Tenant represents a customer or site whose devices, data and users are isolated from other tenants
+type Tenant struct {
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Created     time.Time `json:"created" db:"created"`
+}
+
+// Role represents a named set of permissions, each written as resource:verb such as
+// device:write or command:set, where either part may be the * wildcard
+type Role struct {
+	ID          string    `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Permissions []string  `json:"permissions" db:"permissions"`
+	Created     time.Time `json:"created" db:"created"`
+	Modified    time.Time `json:"modified" db:"modified"`
+}
+
+// APIKey represents a user's API key, only its prefix is kept in clear text
+type APIKey struct {
+	ID        string     `json:"id" db:"id"`
+	Username  string     `json:"username" db:"-"`
+	Name      string     `json:"name" db:"name"`
+	Prefix    string     `json:"prefix" db:"prefix"`
+	Scopes    []string   `json:"scopes" db:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" db:"expires_at"`
+	LastUsed  *time.Time `json:"lastUsed,omitempty" db:"last_used"`
+	Revoked   bool       `json:"revoked" db:"revoked"`
+	Created   time.Time  `json:"created" db:"created"`
+}
+
+// UserRequest represents a request to create a user
+type UserRequest struct {
+	Username    string   `json:"username" validate:"required"`
+	Password    string   `json:"password" validate:"required"`
+	DisplayName string   `json:"displayName"`
+	Email       string   `json:"email"`
+	Enabled     *bool    `json:"enabled"`
+	Roles       []string `json:"roles"`
+	// Tenant defaults to the tenant of the caller
+	Tenant string `json:"tenant"`
+}
+
+// UpdateUserRequest represents a request to update a user, omitted fields are left unchanged
+type UpdateUserRequest struct {
+	Password    *string   `json:"password"`
+	DisplayName *string   `json:"displayName"`
+	Email       *string   `json:"email"`
+	Enabled     *bool     `json:"enabled"`
+	Roles       *[]string `json:"roles"`
+	Tenant      *string   `json:"tenant"`
+}
+
+// RoleRequest represents a request to create/update a role
+type RoleRequest struct {
+	Name        string   `json:"name" validate:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+// APIKeyRequest represents a request to create an API key. Scopes narrow the owner's permissions,
+// an empty list grants the key everything its owner may do.
+type APIKeyRequest struct {
+	Name      string     `json:"name" validate:"required"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// APIKeyResponse carries a newly created API key, the key itself is only ever returned here
+type APIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// TenantRequest represents a request to create a tenant
+type TenantRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+}
+
+// LoginRequest represents a request to log in with a username and password
+type LoginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// LoginResponse carries the access token issued on login
+type LoginResponse struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"tokenType"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,22 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+// Package caller carries the identity the authentication hook verified for a request, such as the subject of its
+// JWT, to the handlers. It has no dependencies on the bootstrap container, so that services can read it without
+// pulling in the handlers.
+package caller
+
+import "context"
+
+type contextKey struct{}
+
+// WithSubject returns a copy of ctx carrying the verified subject of the request
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, contextKey{}, subject)
+}
+
+// Subject returns the subject the authentication hook verified for the request, empty when it verified none
+func Subject(ctx context.Context) string {
+	subject, _ := ctx.Value(contextKey{}).(string)
+	return subject
+}
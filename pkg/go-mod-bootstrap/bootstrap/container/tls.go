@@ -0,0 +1,22 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+package container
+
+import (
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/mtls"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+)
+
+// TLSCredentialsName contains the name of the service's mtls.Credentials in the DIC.
+var TLSCredentialsName = di.TypeInstanceToName((*mtls.Credentials)(nil))
+
+// TLSCredentialsFrom helper function queries the DIC and returns the service's mtls.Credentials.
+func TLSCredentialsFrom(get di.Get) *mtls.Credentials {
+	credentials, ok := get(TLSCredentialsName).(*mtls.Credentials)
+	if !ok {
+		return nil
+	}
+
+	return credentials
+}
@@ -18,6 +18,7 @@ import (
 	"iiot-backend/pkg/go-mod-core-contracts/common"
 	commonDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/common"
 	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	secretsPkg "iiot-backend/pkg/go-mod-secrets/pkg"
 
 	"github.com/labstack/echo/v4"
 	"github.com/mitchellh/mapstructure"
@@ -63,6 +64,8 @@ func NewCommonController(dic *di.Container, r *echo.Echo, serviceName string, se
 	r.GET(common.ApiVersionRoute, c.Version, authenticationHook)
 	r.GET(common.ApiConfigRoute, c.Config, authenticationHook)
 	r.POST(common.ApiSecretRoute, c.AddSecret, authenticationHook)
+	r.POST(common.ApiSecretRotateRoute, c.RotateSecret, authenticationHook)
+	r.GET(common.ApiSecretVersionsByNameRoute, c.SecretVersions, authenticationHook)
 
 	return &c
 }
@@ -164,6 +167,103 @@ func addSecret(dic *di.Container, request commonDTO.SecretRequest) errors.IIOT {
 	return nil
 }
 
+// RotateSecret handles the request to the /secret/rotate endpoint. Is used to store a new version of a secret, or to
+// restore a kept version, in the service's Secret Store and in those of the listed services sharing it. The
+// registered SecretUpdatedCallbacks of every one of these services are invoked.
+func (c *CommonController) RotateSecret(e echo.Context) error {
+	request := e.Request()
+	writer := e.Response()
+
+	defer func() {
+		_ = request.Body.Close()
+	}()
+
+	rotationRequest := commonDTO.SecretRotationRequest{}
+	err := json.NewDecoder(request.Body).Decode(&rotationRequest)
+	if err != nil {
+		c.lc.Errorf("%v", err.Error())
+		return utils.SendJsonErrResp(c.lc, writer, request, errors.KindContractInvalid, "JSON decode failed", err, "")
+	}
+
+	err = rotateSecret(c.dic, rotationRequest)
+	if err != nil {
+		return utils.SendJsonErrResp(c.lc, writer, request, errors.Kind(err), err.Error(), err, rotationRequest.RequestId)
+	}
+
+	response := commonDTO.NewBaseResponse(rotationRequest.RequestId, "", http.StatusOK)
+
+	return utils.SendJsonResp(c.lc, writer, request, response, http.StatusOK)
+}
+
+// SecretVersions handles the request to the /secret/name/{name}/versions endpoint. Is used to list the kept
+// versions of a secret. The secret data is not returned.
+func (c *CommonController) SecretVersions(e echo.Context) error {
+	request := e.Request()
+	writer := e.Response()
+	secretName := e.Param(common.Name)
+
+	rotator, edgeErr := secretRotator(c.dic)
+	if edgeErr != nil {
+		return utils.SendJsonErrResp(c.lc, writer, request, errors.Kind(edgeErr), edgeErr.Error(), edgeErr, "")
+	}
+
+	versions, err := rotator.SecretVersions(secretName)
+	if err != nil {
+		return utils.SendJsonErrResp(c.lc, writer, request, secretErrorKind(err), "retrieving secret versions failed", err, "")
+	}
+
+	dtos := make([]commonDTO.SecretVersion, 0, len(versions))
+	for _, v := range versions {
+		dtos = append(dtos, commonDTO.SecretVersion{Version: v.Version, Created: v.Created})
+	}
+	response := commonDTO.NewSecretVersionsResponse("", "", http.StatusOK, secretName, dtos)
+
+	return utils.SendJsonResp(c.lc, writer, request, response, http.StatusOK)
+}
+
+// rotateSecret rotates the secret to the new data or to the data of a kept version
+func rotateSecret(dic *di.Container, request commonDTO.SecretRotationRequest) errors.IIOT {
+	rotator, edgeErr := secretRotator(dic)
+	if edgeErr != nil {
+		return edgeErr
+	}
+
+	secretName, secret := prepareSecret(commonDTO.SecretRequest{SecretName: request.SecretName, SecretData: request.SecretData})
+	if request.Version > 0 {
+		var err error
+		secret, err = rotator.RetrieveSecretVersion(secretName, request.Version)
+		if err != nil {
+			return errors.NewCommonIIOT(secretErrorKind(err), "retrieving secret version failed", err)
+		}
+	}
+
+	if err := rotator.RotateSecret(secretName, secret, request.Services...); err != nil {
+		return errors.NewCommonIIOT(errors.Kind(err), "rotating secret failed", err)
+	}
+	return nil
+}
+
+// secretRotator returns the secret provider when its store keeps secret versions
+func secretRotator(dic *di.Container) (interfaces.SecretRotator, errors.IIOT) {
+	secretProvider := container.SecretProviderFrom(dic.Get)
+	if secretProvider == nil {
+		return nil, errors.NewCommonIIOT(errors.KindServerError, "secret provider is missing. Make sure it is specified to be used in bootstrap.Run()", nil)
+	}
+	rotator, ok := secretProvider.(interfaces.SecretRotator)
+	if !ok {
+		return nil, errors.NewCommonIIOT(errors.KindNotImplemented, "the secret provider does not support secret rotation", nil)
+	}
+	return rotator, nil
+}
+
+// secretErrorKind tells a missing secret or secret version apart from secret store failures
+func secretErrorKind(err error) errors.ErrKind {
+	if _, ok := err.(secretsPkg.ErrSecretNameNotFound); ok {
+		return errors.KindEntityDoesNotExist
+	}
+	return errors.KindServerError
+}
+
 func prepareSecret(request commonDTO.SecretRequest) (string, map[string]string) {
 	var secretsKV = make(map[string]string)
 	for _, secret := range request.SecretData {
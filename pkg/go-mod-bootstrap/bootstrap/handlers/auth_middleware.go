@@ -10,8 +10,11 @@ import (
 	"net/http"
 	"strings"
 
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/caller"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/handlers/headers"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/mtls"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/mtls/peer"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/zerotrust"
 	"iiot-backend/pkg/go-mod-bootstrap/di"
 	dtoCommon "iiot-backend/pkg/go-mod-core-contracts/dtos/common"
@@ -23,7 +26,8 @@ import (
 )
 
 // AuthenticationHandlerFunc prefixes an existing HandlerFunc,
-// performing authentication checks based on OpenBao-issued JWTs or external JWTs by checking the Authorization header. Usage:
+// performing authentication checks based on OpenBao-issued JWTs or external JWTs by checking the Authorization header,
+// or on the client certificate of an allowed peer verified by a mutual TLS web server. Usage:
 //
 // authenticationHook := handlers.NilAuthenticationHandlerFunc()
 //
@@ -60,6 +64,18 @@ func AuthenticationHandlerFunc(dic *di.Container) echo.MiddlewareFunc {
 				lc.Debug("zero trust was enabled, but no marker was found. this is unexpected. falling back to token-based auth")
 			}
 
+			// a client certificate verified against the service CA bundle identifies a peer service under mutual TLS,
+			// which is admitted only when its identity is one of the configured AllowedPeers
+			if certificate := peer.Certificate(r); certificate != nil {
+				allowedPeers := container.ConfigurationFrom(dic.Get).GetBootstrap().Service.SecurityOptions[mtls.AllowedPeersKey]
+				if identity, ok := peer.Allowed(certificate, allowedPeers); ok {
+					lc.Debugf("Authorizing incoming call to '%s' via client certificate of %s", r.URL.Path, identity)
+					c.SetRequest(r.WithContext(caller.WithSubject(r.Context(), identity)))
+					return next(c)
+				}
+				lc.Debugf("Client certificate %v of call to '%s' is not an allowed peer, requiring a JWT", peer.Identities(certificate), r.URL.Path)
+			}
+
 			authParts := strings.Split(authHeader, " ")
 			if len(authParts) >= 2 && strings.EqualFold(authParts[0], "Bearer") {
 				token := authParts[1]
@@ -86,9 +102,12 @@ func AuthenticationHandlerFunc(dic *di.Container) echo.MiddlewareFunc {
 				if err != nil {
 					errResp := dtoCommon.NewBaseResponse("", err.Error(), err.Code())
 					return c.JSON(err.Code(), errResp)
-				} else {
-					return next(c)
 				}
+				// the token is verified, its subject identifies the caller to the handlers
+				if subject, _ := parsedToken.Claims.GetSubject(); subject != "" {
+					c.SetRequest(r.WithContext(caller.WithSubject(r.Context(), subject)))
+				}
+				return next(c)
 			}
 			err := fmt.Errorf("unable to parse JWT for call to '%s'; unauthorized", r.URL.Path)
 			lc.Errorf("%v", err)
@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
 	"sync"
 	"time"
 
@@ -21,6 +22,7 @@ import (
 
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/config"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/mtls"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/secret"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/startup"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/zerotrust"
@@ -52,6 +54,21 @@ func (cb *ClientsBootstrap) BootstrapHandler(
 	cb.registry = container.RegistryFrom(dic.Get)
 
 	if cfg.GetBootstrap().Clients != nil {
+		// all clients share the SecretProvider's transport, so it presents the service certificate as soon as
+		// one client requires TLS. It still serves the plain http clients.
+		var tlsTransport http.RoundTripper
+		for _, serviceInfo := range *cfg.GetBootstrap().Clients {
+			if mtls.IsEnabled(serviceInfo.SecurityOptions[config.SecurityModeKey]) {
+				credentials, err := tlsCredentials(dic, serviceInfo.SecurityOptions)
+				if err != nil {
+					lc.Errorf("unable to load TLS credentials for the clients: %v", err)
+					return false
+				}
+				tlsTransport = credentials.Transport()
+				break
+			}
+		}
+
 		for serviceKey, serviceInfo := range *cfg.GetBootstrap().Clients {
 			var urlFunc clients.ClientBaseUrlFunc
 
@@ -64,6 +81,9 @@ func (cb *ClientsBootstrap) BootstrapHandler(
 				lc.Errorf("could not obtain an http client for use with zero trust provider: %v", transpErr)
 				return false
 			} else {
+				if tlsTransport != nil && !sp.IsZeroTrustEnabled() {
+					rt = tlsTransport
+				}
 				sp.SetHttpTransport(rt) //only need to set the transport when using SecretProviderExt
 				sp.SetFallbackDialer(&net.Dialer{})
 			}
@@ -75,7 +95,7 @@ func (cb *ClientsBootstrap) BootstrapHandler(
 					urlFunc = clients.GetDefaultClientBaseUrlFunc(serviceInfo.Url())
 				} else {
 					lc.Infof("Using ClientBaseUrlFunc for '%s' clients", serviceKey)
-					urlFunc = cb.clientUrlFunc(serviceKey, lc)
+					urlFunc = cb.clientUrlFunc(serviceKey, serviceInfo.Protocol, lc)
 				}
 			}
 
@@ -181,7 +201,11 @@ func (cb *ClientsBootstrap) BootstrapHandler(
 	return true
 }
 
-func (cb *ClientsBootstrap) clientUrlFunc(serviceKey string, lc logger.LoggerClient) clients.ClientBaseUrlFunc {
+func (cb *ClientsBootstrap) clientUrlFunc(serviceKey, protocol string, lc logger.LoggerClient) clients.ClientBaseUrlFunc {
+	if protocol == "" {
+		protocol = "http"
+	}
+
 	return func() (string, error) {
 		var err error
 		var endpoint types.ServiceEndpoint
@@ -191,7 +215,7 @@ func (cb *ClientsBootstrap) clientUrlFunc(serviceKey string, lc logger.LoggerCli
 			return "", fmt.Errorf("unable to Get service endpoint for '%s': %s", serviceKey, err.Error())
 		}
 
-		url := fmt.Sprintf("http://%s:%v", endpoint.Host, endpoint.Port)
+		url := fmt.Sprintf("%s://%s:%v", protocol, endpoint.Host, endpoint.Port)
 
 		lc.Infof("Using registry for URL for '%s': %s", serviceKey, url)
 
@@ -70,6 +70,7 @@ func (e *ExternalMQTT) BootstrapHandler(ctx context.Context, wg *sync.WaitGroup,
 		lc.Warn("AuthMode not set, defaulting to \"" + messaging.AuthModeNone + "\"")
 	}
 
+	var credentials *mqttCredentials
 	//get the secrets from the secret provider and populate the struct
 	secretData, err := messaging.RetrieveSecretData(authMode, brokerConfig.SecretName, secretProvider)
 	if err != nil {
@@ -92,8 +93,9 @@ func (e *ExternalMQTT) BootstrapHandler(ctx context.Context, wg *sync.WaitGroup,
 		}
 		switch authMode {
 		case messaging.AuthModeUsernamePassword:
-			opts.SetUsername(secretData.Username)
-			opts.SetPassword(secretData.Password)
+			// The credentials provider is called on every connect, so that updated credentials are used
+			credentials = &mqttCredentials{username: secretData.Username, password: secretData.Password}
+			opts.SetCredentialsProvider(credentials.get)
 		case messaging.AuthModeCert:
 			cert, err := tls.X509KeyPair(secretData.CertPemBlock, secretData.KeyPemBlock)
 			if err != nil {
@@ -147,6 +149,10 @@ func (e *ExternalMQTT) BootstrapHandler(ctx context.Context, wg *sync.WaitGroup,
 				},
 			})
 
+			if credentials != nil {
+				reconnectOnSecretUpdate(mqttClient, credentials, brokerConfig.SecretName, dic)
+			}
+
 			lc.Infof(
 				"Connected to external MQTT broker @ %s with AuthMode='%s'",
 				brokerConfig.Url,
@@ -160,6 +166,55 @@ func (e *ExternalMQTT) BootstrapHandler(ctx context.Context, wg *sync.WaitGroup,
 	return false
 }
 
+// mqttCredentials holds the username and password of the external MQTT broker, replaced when their secret is updated
+type mqttCredentials struct {
+	mutex    sync.RWMutex
+	username string
+	password string
+}
+
+func (c *mqttCredentials) get() (string, string) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.username, c.password
+}
+
+func (c *mqttCredentials) set(username string, password string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.username, c.password = username, password
+}
+
+// reconnectOnSecretUpdate reconnects the external MQTT client with the new username and password whenever its
+// secret is updated. The client is reconnected in place so that the instance in the DIC stays valid, and the
+// onConnectHandler re-creates the subscriptions.
+func reconnectOnSecretUpdate(mqttClient mqtt.Client, credentials *mqttCredentials, secretName string, dic *di.Container) {
+	lc := container.LoggerClientFrom(dic.Get)
+	secretProvider := container.SecretProviderFrom(dic.Get)
+
+	err := secretProvider.RegisterSecretUpdatedCallback(secretName, func(secretName string) {
+		secretData, err := messaging.RetrieveSecretData(messaging.AuthModeUsernamePassword, secretName, secretProvider)
+		if err == nil {
+			err = messaging.ValidateSecretData(messaging.AuthModeUsernamePassword, secretName, secretData)
+		}
+		if err != nil {
+			lc.Errorf("Unable to use the updated external MQTT secret '%s': %v", secretName, err)
+			return
+		}
+
+		credentials.set(secretData.Username, secretData.Password)
+		mqttClient.Disconnect(0)
+		if token := mqttClient.Connect(); token.Wait() && token.Error() != nil {
+			lc.Errorf("Unable to reconnect to external MQTT broker with the updated credentials: %v", token.Error())
+			return
+		}
+		lc.Infof("Reconnected to external MQTT broker with the updated credentials of secret '%s'", secretName)
+	})
+	if err != nil {
+		lc.Warnf("External MQTT credentials will not follow updates of secret '%s': %v", secretName, err)
+	}
+}
+
 func createMqttClient(opts *mqtt.ClientOptions) (mqtt.Client, error) {
 	mqttClient := mqtt.NewClient(opts)
 	if token := mqttClient.Connect(); token.Wait() && token.Error() != nil {
@@ -17,7 +17,10 @@ import (
 	"iiot-backend/pkg/go-mod-core-contracts/common"
 	commonDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/common"
 
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/config"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/interfaces"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/mtls"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/startup"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/zerotrust"
 	"iiot-backend/pkg/go-mod-bootstrap/di"
@@ -104,6 +107,11 @@ func (b *HttpServer) BootstrapHandler(
 	b.router.Use(LoggingMiddleware(lc))
 	b.router.Use(UrlDecodeMiddleware(lc))
 
+	// limit the rate of requests per client for services configuring rate limits
+	if rateLimitConfig, ok := container.ConfigurationFrom(dic.Get).(interfaces.RateLimitConfig); ok {
+		b.router.Use(RateLimitMiddleware(rateLimitConfig.GetRateLimitInfo, dic, lc))
+	}
+
 	timeout, err := time.ParseDuration(bootstrapConfig.Service.RequestTimeout)
 	if err != nil {
 		lc.Errorf("unable to parse RequestTimeout value of %s to a duration: %v", bootstrapConfig.Service.RequestTimeout, err)
@@ -127,6 +135,16 @@ func (b *HttpServer) BootstrapHandler(
 		ReadHeaderTimeout: 5 * time.Second, // G112: A configured ReadHeaderTimeout in the http.Server averts a potential Slowloris Attack
 	}
 
+	// serve HTTPS when the service security mode asks for TLS, requiring client certificates for mutual TLS
+	if listenMode := bootstrapConfig.Service.SecurityOptions[config.SecurityModeKey]; mtls.IsEnabled(listenMode) {
+		credentials, err := tlsCredentials(dic, bootstrapConfig.Service.SecurityOptions)
+		if err != nil {
+			lc.Errorf("unable to load TLS credentials for the web server: %v", err)
+			return false
+		}
+		server.TLSConfig = credentials.ServerTLSConfig(listenMode == mtls.MutualTLSMode)
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
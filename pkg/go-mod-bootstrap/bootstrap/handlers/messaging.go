@@ -16,6 +16,7 @@ import (
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/startup"
 	"iiot-backend/pkg/go-mod-bootstrap/config"
 	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
 )
 
 // MessagingBootstrapHandler fulfills the BootstrapHandler contract.  If creates and initializes the Messaging client
@@ -46,6 +47,13 @@ func MessagingBootstrapHandler(ctx context.Context, wg *sync.WaitGroup, startupT
 		}
 	}
 
+	if len(messageBusInfo.Optional[boostrapMessaging.OptionsPayloadSecretNameKey]) > 0 {
+		if err := boostrapMessaging.SetOptionsPayloadKeys(&messageBusInfo, lc, dic); err != nil {
+			lc.Errorf("setting the MessageBus payload keys failed: %v", err)
+			return false
+		}
+	}
+
 	msgClient, err := messaging.NewMessageClient(
 		types.MessageBusConfig{
 			Broker: types.HostInfo{
@@ -89,6 +97,8 @@ func MessagingBootstrapHandler(ctx context.Context, wg *sync.WaitGroup, startupT
 					return msgClient
 				},
 			})
+			updateCredentialsOnSecretUpdate(msgClient, messageBusInfo, lc, dic)
+			updatePayloadKeysOnSecretUpdate(msgClient, messageBusInfo, lc, dic)
 
 			lc.Infof(
 				"Connected to %s Message Bus @ %s://%s:%d with AuthMode='%s'",
@@ -106,6 +116,64 @@ func MessagingBootstrapHandler(ctx context.Context, wg *sync.WaitGroup, startupT
 	return false
 }
 
+// updateCredentialsOnSecretUpdate reconnects the MessageBus client with the new username and password whenever its
+// secret is updated, so that rotated credentials take effect without a restart
+func updateCredentialsOnSecretUpdate(msgClient messaging.MessageClient, messageBusInfo config.MessageBusInfo,
+	lc logger.LoggerClient, dic *di.Container) {
+	updater, ok := msgClient.(messaging.CredentialsUpdater)
+	if !ok || !strings.EqualFold(strings.TrimSpace(messageBusInfo.AuthMode), boostrapMessaging.AuthModeUsernamePassword) {
+		return
+	}
+
+	secretProvider := container.SecretProviderFrom(dic.Get)
+	err := secretProvider.RegisterSecretUpdatedCallback(messageBusInfo.SecretName, func(secretName string) {
+		secretData, err := boostrapMessaging.RetrieveSecretData(messageBusInfo.AuthMode, secretName, secretProvider)
+		if err == nil {
+			err = boostrapMessaging.ValidateSecretData(messageBusInfo.AuthMode, secretName, secretData)
+		}
+		if err != nil {
+			lc.Errorf("Unable to use the updated MessageBus secret '%s': %v", secretName, err)
+			return
+		}
+
+		if err := updater.UpdateCredentials(secretData.Username, secretData.Password); err != nil {
+			lc.Errorf("Unable to reconnect MessageBus with the updated credentials: %v", err)
+			return
+		}
+		lc.Infof("Reconnected to MessageBus with the updated credentials of secret '%s'", secretName)
+	})
+	if err != nil {
+		lc.Warnf("MessageBus credentials will not follow updates of secret '%s': %v", messageBusInfo.SecretName, err)
+	}
+}
+
+// updatePayloadKeysOnSecretUpdate replaces the keys the MessageBus payloads are protected with whenever their secret is
+// updated, so that rotated keys take effect without a restart
+func updatePayloadKeysOnSecretUpdate(msgClient messaging.MessageClient, messageBusInfo config.MessageBusInfo,
+	lc logger.LoggerClient, dic *di.Container) {
+	updater, ok := msgClient.(messaging.PayloadKeysUpdater)
+	secretName := messageBusInfo.Optional[boostrapMessaging.OptionsPayloadSecretNameKey]
+	if !ok || len(secretName) == 0 {
+		return
+	}
+
+	secretProvider := container.SecretProviderFrom(dic.Get)
+	err := secretProvider.RegisterSecretUpdatedCallback(secretName, func(secretName string) {
+		keys, err := boostrapMessaging.RetrievePayloadKeys(secretName, secretProvider)
+		if err == nil {
+			err = updater.UpdatePayloadKeys(keys.SigningKeys, keys.VerifyKeys, keys.EncryptionKeys)
+		}
+		if err != nil {
+			lc.Errorf("Unable to use the updated MessageBus payload keys of secret '%s': %v", secretName, err)
+			return
+		}
+		lc.Infof("Protecting MessageBus payloads with the updated keys of secret '%s'", secretName)
+	})
+	if err != nil {
+		lc.Warnf("MessageBus payload keys will not follow updates of secret '%s': %v", secretName, err)
+	}
+}
+
 func deepCopy(target config.MessageBusInfo) config.MessageBusInfo {
 	result := config.MessageBusInfo{
 		Disabled:        target.Disabled,
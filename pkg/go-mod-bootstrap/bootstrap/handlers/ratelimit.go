@@ -0,0 +1,122 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	commonDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/common"
+
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/mtls/peer"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/ratelimit"
+	"iiot-backend/pkg/go-mod-bootstrap/config"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+
+	"github.com/labstack/echo/v4"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+const (
+	// RateLimitAllowedMetricName counts the requests let through per group, enabled for every group at once in
+	// Writable.Telemetry.Metrics and reported with the group as tag
+	RateLimitAllowedMetricName = "RateLimitAllowed"
+	// RateLimitRejectedMetricName counts the requests rejected per group for exceeding their limit
+	RateLimitRejectedMetricName = "RateLimitRejected"
+
+	rateLimitGroupTag = "group"
+)
+
+// RateLimitMiddleware limits the rate of requests per client and group of routes as configured by rateLimits, which
+// is read on every request so that changes to Writable apply at runtime. Each response carries the RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers of its group, and requests over the limit are
+// rejected with 429 and a Retry-After header. Outcomes are counted by the metrics manager of dic, when there is one.
+func RateLimitMiddleware(rateLimits func() *config.RateLimitInfo, dic *di.Container, lc logger.LoggerClient) echo.MiddlewareFunc {
+	limiter := ratelimit.NewLimiter()
+	invalidPeriods := sync.Map{}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			info := rateLimits()
+			if info == nil || !info.Enabled || r.Method == http.MethodOptions {
+				return next(c)
+			}
+
+			groupName, group, ok := info.Match(r.Method, r.URL.Path)
+			if !ok || group.Limit <= 0 {
+				return next(c)
+			}
+			quota, err := ratelimit.GroupQuota(group)
+			if err != nil {
+				// report each invalid period once rather than on every request
+				if _, reported := invalidPeriods.LoadOrStore(group.Period, true); !reported {
+					lc.Errorf("rate limit group %s has an invalid Period '%s', its routes are not limited", groupName, group.Period)
+				}
+				return next(c)
+			}
+
+			result := limiter.Allow(groupName+"|"+rateLimitClient(c), quota)
+
+			w := c.Response()
+			ratelimit.SetHeaders(w.Header(), result, quota)
+
+			if !result.Allowed {
+				incrementRateLimitCounter(dic, RateLimitRejectedMetricName, groupName, lc)
+
+				response := commonDTO.NewBaseResponse("", fmt.Sprintf("rate limit of %s exceeded, retry in %s seconds", groupName, ratelimit.CeilSeconds(result.RetryAfter)), http.StatusTooManyRequests)
+				lc.Debugf("%s %s: %s", r.Method, r.URL.Path, response.Message)
+
+				w.Header().Set(common.ContentType, common.ContentTypeJSON)
+				w.WriteHeader(response.StatusCode)
+				if err := json.NewEncoder(w).Encode(response); err != nil {
+					lc.Errorf("Error encoding the data:  %v", err)
+				}
+				return nil
+			}
+
+			incrementRateLimitCounter(dic, RateLimitAllowedMetricName, groupName, lc)
+			return next(c)
+		}
+	}
+}
+
+// rateLimitClient identifies the client sending a request by the identity of the client certificate the server
+// verified, or else by its remote IP. Credentials in headers are not verified before the route's authentication runs,
+// so they would let a client pick a fresh bucket for every request and are not used.
+func rateLimitClient(c echo.Context) string {
+	if certificate := peer.Certificate(c.Request()); certificate != nil {
+		if identities := peer.Identities(certificate); len(identities) > 0 {
+			return "peer:" + identities[0]
+		}
+	}
+	return "ip:" + ratelimit.RemoteIP(c)
+}
+
+// incrementRateLimitCounter increments the counter of metricName for group, registering it on first use
+func incrementRateLimitCounter(dic *di.Container, metricName string, group string, lc logger.LoggerClient) {
+	manager := container.MetricsManagerFrom(dic.Get)
+	if manager == nil {
+		return
+	}
+
+	name := metricName + "-" + group
+	counter := manager.GetCounter(name)
+	if counter == nil {
+		counter = gometrics.NewCounter()
+		if err := manager.Register(name, counter, map[string]string{rateLimitGroupTag: group}); err != nil {
+			// registered concurrently by another request
+			if counter = manager.GetCounter(name); counter == nil {
+				lc.Errorf("unable to register metric %s: %v", name, err)
+				return
+			}
+		}
+	}
+	counter.Inc(1)
+}
@@ -0,0 +1,40 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+package handlers
+
+import (
+	"fmt"
+
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/mtls"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+)
+
+// tlsCredentials returns the TLS credentials named by securityOptions. The service's credentials are
+// loaded once and shared through the DIC by the web server and the clients, since the SecretProvider
+// accepts a single update callback per secret.
+func tlsCredentials(dic *di.Container, securityOptions map[string]string) (*mtls.Credentials, error) {
+	secretName := mtls.SecretName(securityOptions)
+	if credentials := container.TLSCredentialsFrom(dic.Get); credentials != nil && credentials.SecretName() == secretName {
+		return credentials, nil
+	}
+
+	secretProvider := container.SecretProviderFrom(dic.Get)
+	if secretProvider == nil {
+		return nil, fmt.Errorf("no SecretProvider available to load TLS secret '%s'", secretName)
+	}
+	credentials, err := mtls.NewCredentials(secretProvider, secretName, container.LoggerClientFrom(dic.Get))
+	if err != nil {
+		return nil, err
+	}
+
+	if container.TLSCredentialsFrom(dic.Get) == nil {
+		dic.Update(di.ServiceConstructorMap{
+			container.TLSCredentialsName: func(get di.Get) interface{} {
+				return credentials
+			},
+		})
+	}
+	return credentials, nil
+}
@@ -53,3 +53,11 @@ type Configuration interface {
 	// GetWritablePtr gets the config.WritablePtr section from the ConfigurationStruct
 	GetWritablePtr() any
 }
+
+// RateLimitConfig is implemented by the configuration of services limiting the rate of requests to their REST API.
+// Bootstrap applies the limits in front of every route of such services.
+type RateLimitConfig interface {
+	// GetRateLimitInfo gets the config.RateLimitInfo section from the Writable of the ConfigurationStruct, so that
+	// changes to the limits apply at runtime
+	GetRateLimitInfo() *config.RateLimitInfo
+}
@@ -7,6 +7,8 @@ import (
 	"net"
 	"net/http"
 	"time"
+
+	"iiot-backend/pkg/go-mod-secrets/pkg/types"
 )
 
 // SecretProvider defines the contract for secret provider implementations that
@@ -38,6 +40,21 @@ type SecretProvider interface {
 	DeregisterSecretUpdatedCallback(secretName string)
 }
 
+// SecretRotator is implemented by secret providers whose store keeps previous versions of secrets, such as the
+// file secret store.
+type SecretRotator interface {
+	// SecretVersions returns the kept versions of the secret, oldest first.
+	SecretVersions(secretName string) ([]types.SecretVersion, error)
+
+	// RetrieveSecretVersion retrieves the data of a kept version of the secret.
+	RetrieveSecretVersion(secretName string, version int) (map[string]string, error)
+
+	// RotateSecret stores a new version of the secret and invokes its SecretUpdatedCallback. The copies of the
+	// secret held by the listed services sharing the secret store are rotated too, their callbacks run once they
+	// see the change.
+	RotateSecret(secretName string, secrets map[string]string, serviceKeys ...string) error
+}
+
 // SecretProviderExt defines the extended contract for secret provider implementations that
 // provide additional APIs needed only from the bootstrap code.
 type SecretProviderExt interface {
@@ -36,6 +36,15 @@ const (
 	OptionsCertPEMBlockKey = "CertPEMBlock"
 	OptionsKeyPEMBlockKey  = "KeyPEMBlock"
 	OptionsCaPEMBlockKey   = "CaPEMBlock"
+
+	SecretPayloadSigningKeys    = "signingkeys"
+	SecretPayloadVerifyKeys     = "verifykeys"
+	SecretPayloadEncryptionKeys = "encryptionkeys"
+
+	OptionsPayloadSecretNameKey     = "PayloadSecretName"
+	OptionsPayloadSigningKeysKey    = "PayloadSigningKeys"
+	OptionsPayloadVerifyKeysKey     = "PayloadVerifyKeys"
+	OptionsPayloadEncryptionKeysKey = "PayloadEncryptionKeys"
 )
 
 type SecretDataProvider interface {
@@ -91,6 +100,49 @@ func SetOptionsAuthData(messageBusInfo *config.MessageBusInfo, lc logger.LoggerC
 	return nil
 }
 
+// PayloadKeys are the keys message payloads are signed and encrypted with, each a comma separated list of
+// id:base64-key pairs
+type PayloadKeys struct {
+	SigningKeys    string
+	VerifyKeys     string
+	EncryptionKeys string
+}
+
+// SetOptionsPayloadKeys sets the keys the MessageBus payloads are protected with from the secret named by the
+// PayloadSecretName option, so that the keys need not be kept in the configuration
+func SetOptionsPayloadKeys(messageBusInfo *config.MessageBusInfo, lc logger.LoggerClient, dic *di.Container) error {
+	secretName := messageBusInfo.Optional[OptionsPayloadSecretNameKey]
+	lc.Infof("Setting options for MessageBus payload protection with SecretName='%s'", secretName)
+
+	secretProvider := container.SecretProviderFrom(dic.Get)
+	if secretProvider == nil {
+		return errors.New("secret provider is missing. Make sure it is specified to be used in bootstrap.Run()")
+	}
+
+	keys, err := RetrievePayloadKeys(secretName, secretProvider)
+	if err != nil {
+		return fmt.Errorf("Unable to get payload keys for secure message bus: %w", err)
+	}
+
+	messageBusInfo.Optional[OptionsPayloadSigningKeysKey] = keys.SigningKeys
+	messageBusInfo.Optional[OptionsPayloadVerifyKeysKey] = keys.VerifyKeys
+	messageBusInfo.Optional[OptionsPayloadEncryptionKeysKey] = keys.EncryptionKeys
+	return nil
+}
+
+// RetrievePayloadKeys retrieves the keys message payloads are protected with from the named secret
+func RetrievePayloadKeys(secretName string, provider SecretDataProvider) (*PayloadKeys, error) {
+	secrets, err := provider.RetrieveSecret(secretName)
+	if err != nil {
+		return nil, err
+	}
+	return &PayloadKeys{
+		SigningKeys:    secrets[SecretPayloadSigningKeys],
+		VerifyKeys:     secrets[SecretPayloadVerifyKeys],
+		EncryptionKeys: secrets[SecretPayloadEncryptionKeys],
+	}, nil
+}
+
 func RetrieveSecretData(authMode string, secretName string, provider SecretDataProvider) (*SecretData, error) {
 	// No Auth? No Problem!...No secrets required.
 	if authMode == AuthModeNone {
@@ -0,0 +1,40 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+package mtls
+
+const (
+	// TLSMode is the SecurityOptions Mode serving HTTPS with the service certificate, or using HTTPS for a client
+	TLSMode = "tls"
+	// MutualTLSMode is the SecurityOptions Mode serving HTTPS that requires client certificates issued by the CA
+	// bundle, or presenting the service certificate as a client
+	MutualTLSMode = "mtls"
+
+	// SecretNameKey is the SecurityOptions key naming the secret holding the TLS credentials
+	SecretNameKey = "TLSSecretName"
+	// AllowedPeersKey is the SecurityOptions key listing, separated by commas, the client certificate identities
+	// admitted without a JWT. Clients presenting another certificate authenticate with a JWT.
+	AllowedPeersKey = "AllowedPeers"
+
+	// DefaultSecretName is the secret the TLS credentials are read from when SecretNameKey is not set
+	DefaultSecretName = "tls"
+
+	// CertKey, PrivateKeyKey and CAKey are the keys of the PEM encoded certificate, private key and CA bundle
+	// within the TLS secret
+	CertKey       = "cert"
+	PrivateKeyKey = "key"
+	CAKey         = "ca"
+)
+
+// IsEnabled reports whether a SecurityOptions Mode selects TLS or mutual TLS
+func IsEnabled(mode string) bool {
+	return mode == TLSMode || mode == MutualTLSMode
+}
+
+// SecretName returns the TLS secret name configured in SecurityOptions
+func SecretName(securityOptions map[string]string) string {
+	if name := securityOptions[SecretNameKey]; name != "" {
+		return name
+	}
+	return DefaultSecretName
+}
@@ -0,0 +1,183 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/interfaces"
+	"iiot-backend/pkg/go-mod-bootstrap/config"
+	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
+)
+
+// Credentials holds the service's certificate, private key and CA bundle, loaded from a secret in the
+// SecretProvider. The secret is reloaded whenever the SecretProvider reports it updated, so servers and
+// clients built from Credentials pick up rotated certificates without a restart.
+type Credentials struct {
+	secretProvider interfaces.SecretProvider
+	secretName     string
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	caPool      *x509.CertPool
+	transports  []*reloadingTransport
+}
+
+// NewCredentials loads the certificate pair and CA bundle stored at secretName and registers for updates
+// of that secret.
+func NewCredentials(secretProvider interfaces.SecretProvider, secretName string, lc logger.LoggerClient) (*Credentials, error) {
+	credentials := &Credentials{
+		secretProvider: secretProvider,
+		secretName:     secretName,
+	}
+	if err := credentials.Reload(); err != nil {
+		return nil, err
+	}
+
+	err := secretProvider.RegisterSecretUpdatedCallback(secretName, func(string) {
+		if err := credentials.Reload(); err != nil {
+			lc.Errorf("failed to reload TLS credentials from secret '%s', keeping the previous certificate: %v", secretName, err)
+			return
+		}
+		lc.Infof("reloaded TLS credentials from secret '%s'", secretName)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to register for updates of TLS secret '%s': %w", secretName, err)
+	}
+
+	return credentials, nil
+}
+
+// SecretName returns the name of the secret the credentials are loaded from
+func (c *Credentials) SecretName() string {
+	return c.secretName
+}
+
+// GetCertificateKeyPair retrieves the PEM encoded certificate pair stored at secretName, fulfilling the
+// interfaces.CertificateProvider contract.
+func (c *Credentials) GetCertificateKeyPair(secretName string) (config.CertKeyPair, error) {
+	secrets, err := c.secretProvider.RetrieveSecret(secretName, CertKey, PrivateKeyKey)
+	if err != nil {
+		return config.CertKeyPair{}, err
+	}
+	return config.CertKeyPair{Cert: secrets[CertKey], Key: secrets[PrivateKeyKey]}, nil
+}
+
+// Reload reads the secret again and replaces the certificate and CA bundle. The previous credentials are
+// kept when the secret is incomplete or invalid.
+func (c *Credentials) Reload() error {
+	pair, err := c.GetCertificateKeyPair(c.secretName)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve TLS certificate pair from secret '%s': %w", c.secretName, err)
+	}
+	certificate, err := tls.X509KeyPair([]byte(pair.Cert), []byte(pair.Key))
+	if err != nil {
+		return fmt.Errorf("invalid TLS certificate pair in secret '%s': %w", c.secretName, err)
+	}
+
+	secrets, err := c.secretProvider.RetrieveSecret(c.secretName, CAKey)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve CA bundle from secret '%s': %w", c.secretName, err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM([]byte(secrets[CAKey])) {
+		return fmt.Errorf("no CA certificate found in secret '%s'", c.secretName)
+	}
+
+	c.mutex.Lock()
+	c.certificate = &certificate
+	c.caPool = caPool
+	transports := c.transports
+	c.mutex.Unlock()
+
+	for _, transport := range transports {
+		transport.reload()
+	}
+	return nil
+}
+
+func (c *Credentials) current() (*tls.Certificate, *x509.CertPool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.certificate, c.caPool
+}
+
+// ServerTLSConfig returns the TLS configuration for a server presenting the service certificate. When
+// requireClientCert is set, clients must present a certificate issued by the CA bundle. Every handshake
+// uses the current credentials.
+func (c *Credentials) ServerTLSConfig(requireClientCert bool) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certificate, caPool := c.current()
+			serverConfig := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*certificate},
+				ClientCAs:    caPool,
+				ClientAuth:   tls.VerifyClientCertIfGiven,
+			}
+			if requireClientCert {
+				serverConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return serverConfig, nil
+		},
+	}
+}
+
+// ClientTLSConfig returns the TLS configuration for a client presenting the service certificate and
+// verifying servers against the CA bundle, built from the current credentials.
+func (c *Credentials) ClientTLSConfig() *tls.Config {
+	certificate, caPool := c.current()
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*certificate},
+		RootCAs:      caPool,
+	}
+}
+
+// Transport returns an http.RoundTripper presenting the service certificate. Its connections are
+// re-established with the new credentials after a reload.
+func (c *Credentials) Transport() http.RoundTripper {
+	transport := &reloadingTransport{credentials: c}
+	transport.reload()
+
+	c.mutex.Lock()
+	c.transports = append(c.transports, transport)
+	c.mutex.Unlock()
+	return transport
+}
+
+// reloadingTransport swaps its underlying transport when the credentials are reloaded, since the
+// certificate and CA bundle of an http.Transport cannot be replaced in place.
+type reloadingTransport struct {
+	credentials *Credentials
+
+	mutex     sync.RWMutex
+	transport *http.Transport
+}
+
+func (t *reloadingTransport) reload() {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = t.credentials.ClientTLSConfig()
+
+	t.mutex.Lock()
+	previous := t.transport
+	t.transport = transport
+	t.mutex.Unlock()
+
+	if previous != nil {
+		previous.CloseIdleConnections()
+	}
+}
+
+func (t *reloadingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mutex.RLock()
+	transport := t.transport
+	t.mutex.RUnlock()
+	return transport.RoundTrip(req)
+}
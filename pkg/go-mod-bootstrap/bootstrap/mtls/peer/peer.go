@@ -0,0 +1,54 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+// Package peer identifies the clients of mutual TLS connections from their verified certificates. It
+// has no dependencies on the bootstrap container, so that middleware outside of it can use it.
+package peer
+
+import (
+	"crypto/x509"
+	"net/http"
+	"strings"
+)
+
+// Identities lists the names a client certificate identifies its holder by, most specific first: the
+// URI SANs such as SPIFFE IDs, the DNS and email SANs, then the subject common name.
+func Identities(certificate *x509.Certificate) []string {
+	var identities []string
+	for _, uri := range certificate.URIs {
+		identities = append(identities, uri.String())
+	}
+	identities = append(identities, certificate.DNSNames...)
+	identities = append(identities, certificate.EmailAddresses...)
+	if certificate.Subject.CommonName != "" {
+		identities = append(identities, certificate.Subject.CommonName)
+	}
+	return identities
+}
+
+// Certificate returns the client certificate the server verified for the request, nil when the
+// request did not arrive over TLS with a verified client certificate.
+func Certificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// Allowed returns the first identity of the certificate listed in allowed, a comma separated list of
+// identities such as the AllowedPeers SecurityOptions value, and whether there is one. No identity is
+// allowed when the list is empty.
+func Allowed(certificate *x509.Certificate, allowed string) (string, bool) {
+	permitted := make(map[string]bool)
+	for _, identity := range strings.Split(allowed, ",") {
+		if identity = strings.TrimSpace(identity); identity != "" {
+			permitted[identity] = true
+		}
+	}
+	for _, identity := range Identities(certificate) {
+		if permitted[identity] {
+			return identity, true
+		}
+	}
+	return "", false
+}
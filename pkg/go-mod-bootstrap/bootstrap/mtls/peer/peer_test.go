@@ -0,0 +1,30 @@
+package peer
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowed(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://iiot/core-data")
+	certificate := &x509.Certificate{
+		URIs:     []*url.URL{spiffeID},
+		DNSNames: []string{"core-data.iiot.local"},
+		Subject:  pkix.Name{CommonName: "core-data"},
+	}
+
+	identity, ok := Allowed(certificate, "device-modbus, core-data.iiot.local,core-data")
+	assert.True(t, ok)
+	assert.Equal(t, "core-data.iiot.local", identity)
+
+	_, ok = Allowed(certificate, "device-modbus")
+	assert.False(t, ok)
+	_, ok = Allowed(certificate, "")
+	assert.False(t, ok)
+	_, ok = Allowed(&x509.Certificate{}, " , ")
+	assert.False(t, ok)
+}
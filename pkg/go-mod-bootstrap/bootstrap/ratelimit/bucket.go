@@ -0,0 +1,126 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that refilled completely are dropped, a full bucket behaving like a new one
+const sweepInterval = time.Minute
+
+// Quota allows Limit requests per Period, refilled continuously, with bursts of up to Burst requests
+type Quota struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// capacity returns the most requests the quota allows at once
+func (q Quota) capacity() float64 {
+	if q.Burst > 0 {
+		return float64(q.Burst)
+	}
+	return float64(q.Limit)
+}
+
+// rate returns the number of requests the quota allows per second
+func (q Quota) rate() float64 {
+	return float64(q.Limit) / q.Period.Seconds()
+}
+
+// Result tells whether a request was allowed and the state of the bucket it was counted against
+type Result struct {
+	Allowed bool
+	// Limit is the capacity of the bucket
+	Limit int
+	// Remaining is the number of requests the bucket allows right away
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the bucket allows the next request, zero when the request was allowed
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	quota   Quota
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens earned since the last update
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(b.quota.capacity(), b.tokens+elapsed*b.quota.rate())
+		b.updated = now
+	}
+}
+
+// Limiter keeps a token bucket per key, such as a client within a group of routes
+type Limiter struct {
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter creates a Limiter without any buckets
+func NewLimiter() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket), lastSweep: time.Now(), now: time.Now}
+}
+
+// Allow takes a token from the bucket of key, which starts full. A bucket whose quota changed since the last request
+// keeps its tokens up to the new capacity, so that limits changed at runtime apply right away.
+func (l *Limiter) Allow(key string, quota Quota) Result {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{quota: quota, tokens: quota.capacity(), updated: now}
+		l.buckets[key] = b
+	} else {
+		if b.quota != quota {
+			b.quota = quota
+			b.tokens = math.Min(b.tokens, quota.capacity())
+		}
+		b.refill(now)
+	}
+
+	result := Result{Limit: int(quota.capacity())}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / quota.rate())
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((quota.capacity() - b.tokens) / quota.rate())
+	return result
+}
+
+// sweep drops the buckets that refilled completely
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= b.quota.capacity() {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,88 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestLimiter returns a Limiter whose clock only moves when the returned function advances it
+func newTestLimiter() (*Limiter, func(time.Duration)) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewLimiter()
+	limiter.now = func() time.Time { return now }
+	limiter.lastSweep = now
+	return limiter, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLimiterAllowsBurstThenRejects(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	quota := Quota{Limit: 2, Period: time.Second, Burst: 4}
+
+	for i := 0; i < 4; i++ {
+		result := limiter.Allow("client", quota)
+		assert.True(t, result.Allowed, "request %d", i+1)
+		assert.Equal(t, 4, result.Limit)
+		assert.Equal(t, 3-i, result.Remaining)
+	}
+
+	result := limiter.Allow("client", quota)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 2*time.Second, result.Reset)
+}
+
+func TestLimiterRefillsContinuously(t *testing.T) {
+	limiter, advance := newTestLimiter()
+	quota := Quota{Limit: 2, Period: time.Second}
+
+	assert.True(t, limiter.Allow("client", quota).Allowed)
+	assert.True(t, limiter.Allow("client", quota).Allowed)
+	assert.False(t, limiter.Allow("client", quota).Allowed)
+
+	// half a period earns one token
+	advance(500 * time.Millisecond)
+	assert.True(t, limiter.Allow("client", quota).Allowed)
+	assert.False(t, limiter.Allow("client", quota).Allowed)
+
+	// the bucket never holds more than its capacity
+	advance(time.Hour)
+	assert.Equal(t, 1, limiter.Allow("client", quota).Remaining)
+}
+
+func TestLimiterKeepsClientsApart(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	quota := Quota{Limit: 1, Period: time.Minute}
+
+	assert.True(t, limiter.Allow("ingest|ip:10.0.0.1", quota).Allowed)
+	assert.False(t, limiter.Allow("ingest|ip:10.0.0.1", quota).Allowed)
+	assert.True(t, limiter.Allow("ingest|ip:10.0.0.2", quota).Allowed)
+	assert.True(t, limiter.Allow("query|ip:10.0.0.1", quota).Allowed)
+}
+
+func TestLimiterAppliesChangedQuota(t *testing.T) {
+	limiter, _ := newTestLimiter()
+
+	assert.True(t, limiter.Allow("client", Quota{Limit: 10, Period: time.Second}).Allowed)
+
+	// lowering the limit caps the tokens left right away
+	lowered := Quota{Limit: 1, Period: time.Second}
+	assert.True(t, limiter.Allow("client", lowered).Allowed)
+	assert.False(t, limiter.Allow("client", lowered).Allowed)
+}
+
+func TestLimiterSweepsFullBuckets(t *testing.T) {
+	limiter, advance := newTestLimiter()
+	quota := Quota{Limit: 1, Period: time.Second}
+
+	limiter.Allow("idle", quota)
+	advance(sweepInterval)
+	limiter.Allow("active", quota)
+	assert.NotContains(t, limiter.buckets, "idle")
+	assert.Contains(t, limiter.buckets, "active")
+}
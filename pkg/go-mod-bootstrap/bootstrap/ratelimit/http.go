@@ -0,0 +1,62 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"iiot-backend/pkg/go-mod-bootstrap/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GroupQuota returns the quota of a rate limit group, its Period defaulting to one second
+func GroupQuota(group config.RateLimitGroupInfo) (Quota, error) {
+	period := time.Second
+	if group.Period != "" {
+		var err error
+		if period, err = time.ParseDuration(group.Period); err != nil {
+			return Quota{}, err
+		}
+	}
+	if period <= 0 {
+		return Quota{}, fmt.Errorf("period %s is not positive", group.Period)
+	}
+	return Quota{Limit: group.Limit, Period: period, Burst: group.Burst}, nil
+}
+
+// SetHeaders sets the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers of result,
+// and the Retry-After header of rejected requests
+func SetHeaders(header http.Header, result Result, quota Quota) {
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", CeilSeconds(result.Reset))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s;burst=%d", quota.Limit, CeilSeconds(quota.Period), result.Limit))
+	if !result.Allowed {
+		header.Set("Retry-After", CeilSeconds(result.RetryAfter))
+	}
+}
+
+// RemoteIP returns the IP address of the client of c. Forwarding headers are only trusted when the server was given
+// an IPExtractor for the proxies in front of it, since any client could set them to get a fresh bucket per request.
+func RemoteIP(c echo.Context) string {
+	if c.Echo().IPExtractor != nil {
+		return c.RealIP()
+	}
+	host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		return c.Request().RemoteAddr
+	}
+	return host
+}
+
+// CeilSeconds formats d as whole seconds, rounded up
+func CeilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -100,6 +100,10 @@ func NewSecretProvider(
 					provider = secureProvider
 					lc.Info("Created SecretClient")
 
+					if err = secureProvider.WatchSecrets(secretStoreConfig.FileStore.WatchInterval); err != nil {
+						return nil, err
+					}
+
 					lc.Debugf("SecretsFile is '%s'", secretConfig.SecretsFile)
 
 					if len(strings.TrimSpace(secretConfig.SecretsFile)) == 0 {
@@ -212,6 +216,13 @@ func getSecretConfig(secretStoreInfo *config.SecretStoreInfo,
 		ServerName:           secretStoreInfo.ServerName,
 		Authentication:       secretStoreInfo.Authentication,
 		RuntimeTokenProvider: secretStoreInfo.RuntimeTokenProvider,
+		FileStore:            secretStoreInfo.FileStore,
+	}
+
+	// the file secret store is opened with its master key or passphrase, there is no token to load
+	if secretStoreInfo.Type == secrets.FileSecretStore {
+		lc.Info("file secret store")
+		return secretConfig, nil
 	}
 
 	// maybe insecure mode
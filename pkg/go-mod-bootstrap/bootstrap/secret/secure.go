@@ -25,6 +25,7 @@ import (
 
 	"iiot-backend/pkg/go-mod-secrets/pkg/token/authtokenloader"
 	"iiot-backend/pkg/go-mod-secrets/pkg/token/runtimetokenprovider"
+	"iiot-backend/pkg/go-mod-secrets/pkg/types"
 	"iiot-backend/pkg/go-mod-secrets/secrets"
 )
 
@@ -187,19 +188,100 @@ func (p *SecureProvider) SaveSecret(secretName string, secrets map[string]string
 		return err
 	}
 
+	// Clearing cache before the callbacks so that they retrieve the new secret
+	p.clearSecretsCache()
+
 	// Execute Callbacks on registered secret secretNames.
 	p.SecretUpdatedAtSecretName(secretName)
+	return nil
+}
 
+// clearSecretsCache invalidates the cache, as a new secret possibly invalidates the cached ones
+func (p *SecureProvider) clearSecretsCache() {
 	// Synchronize cache access before clearing
 	p.cacheMutex.Lock()
-	// Clearing cache because adding a new secret(p) possibly invalidates the previous cache
 	p.secretsCache = make(map[string]map[string]string)
 	p.cacheMutex.Unlock()
 	//indicate to the SDK that the cache has been invalidated
 	p.lastUpdated = time.Now()
+}
+
+// WatchSecrets invokes the SecretUpdatedCallbacks of the secrets other services sharing the secret store change,
+// when the store keeps secret versions. interval is how often the store is checked, i.e. "5s".
+func (p *SecureProvider) WatchSecrets(interval string) error {
+	client, ok := p.secretClient.(secrets.VersionedSecretClient)
+	if !ok {
+		return nil
+	}
+
+	duration, err := time.ParseDuration(interval)
+	if err != nil {
+		return fmt.Errorf("invalid secret store WatchInterval '%s': %v", interval, err)
+	}
+
+	go client.WatchSecrets(p.ctx, duration, func(secretNames []string) {
+		p.clearSecretsCache()
+		for _, secretName := range secretNames {
+			p.lc.Infof("Secret '%s' was updated by another service", secretName)
+			p.SecretUpdatedAtSecretName(secretName)
+		}
+	})
 	return nil
 }
 
+// SecretVersions returns the kept versions of the secret, oldest first.
+func (p *SecureProvider) SecretVersions(secretName string) ([]types.SecretVersion, error) {
+	client, err := p.versionedClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.SecretVersions(secretName)
+}
+
+// RetrieveSecretVersion retrieves the data of a kept version of the secret.
+func (p *SecureProvider) RetrieveSecretVersion(secretName string, version int) (map[string]string, error) {
+	client, err := p.versionedClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.RetrieveSecretVersion(secretName, version)
+}
+
+// RotateSecret stores a new version of the secret and invokes its SecretUpdatedCallback. The copies of the secret
+// held by the listed services sharing the secret store are rotated too, their callbacks run once they see the change.
+func (p *SecureProvider) RotateSecret(secretName string, data map[string]string, serviceKeys ...string) error {
+	var client secrets.VersionedSecretClient
+	if len(serviceKeys) > 0 {
+		var err error
+		if client, err = p.versionedClient(); err != nil {
+			return err
+		}
+	}
+
+	if err := p.SaveSecret(secretName, data); err != nil {
+		return err
+	}
+
+	for _, serviceKey := range serviceKeys {
+		if err := client.SaveServiceSecret(serviceKey, secretName, data); err != nil {
+			return fmt.Errorf("unable to rotate secret '%s' of service '%s': %w", secretName, serviceKey, err)
+		}
+		p.securitySecretsStored.Inc(1)
+	}
+	return nil
+}
+
+func (p *SecureProvider) versionedClient() (secrets.VersionedSecretClient, error) {
+	if p.secretClient == nil {
+		return nil, errors.New("secure secret provider is not properly initialized")
+	}
+	client, ok := p.secretClient.(secrets.VersionedSecretClient)
+	if !ok {
+		return nil, fmt.Errorf("the '%s' secret store does not keep secret versions", p.secretStoreInfo.Type)
+	}
+	return client, nil
+}
+
 func (p *SecureProvider) reloadTokenOnAuthError(err error) (bool, error) {
 	if err == nil {
 		return false, nil
@@ -70,5 +70,9 @@ func ListenOnMode(bootstrapConfig config.BootstrapConfiguration, serverKey, addr
 	if listenErr != nil {
 		return listenErr
 	}
+	if server.TLSConfig != nil {
+		// the certificates are provided by server.TLSConfig
+		return server.ServeTLS(ln, "", "")
+	}
 	return server.Serve(ln)
 }
@@ -171,6 +171,8 @@ type SecretStoreInfo struct {
 
 	// RuntimeTokenProvider is optional if not using delayed start from spiffe-token provider
 	RuntimeTokenProvider types.RuntimeTokenProviderInfo
+	// FileStore is only used when Type is the file secret store
+	FileStore types.FileStoreInfo
 }
 
 func NewSecretStoreInfo(serviceKey string) SecretStoreInfo {
@@ -199,6 +201,13 @@ func NewSecretStoreInfo(serviceKey string) SecretStoreInfo {
 			EndpointSocket:  "/tmp/iiot/secrets/spiffe/public/api.sock",
 			RequiredSecrets: "redisdb",
 		},
+		FileStore: types.FileStoreInfo{
+			Path:          "/tmp/iiot/secrets/secretstore.json",
+			MasterKeyFile: "",
+			Passphrase:    "",
+			MaxVersions:   10,
+			WatchInterval: "5s",
+		},
 	}
 }
 
@@ -352,3 +361,57 @@ func (t *TelemetryInfo) GetEnabledMetricName(metricName string) (string, bool) {
 	// Service's metric name did not match any config Metric name.
 	return "", false
 }
+
+// RateLimitInfo configures per-client rate limiting of a service's REST API. When Enabled, each request is counted
+// against the group matching it and the client sending it, clients being told apart by their verified identity, such
+// as the user or API key authentication resolved or their client certificate, or else by remote IP. Requests matching
+// no group are not limited.
+type RateLimitInfo struct {
+	Enabled bool
+	// Groups maps the name of each group of routes, such as ingest, query or command, to its limit
+	Groups map[string]RateLimitGroupInfo
+}
+
+// RateLimitGroupInfo limits each client to Limit requests per Period on the routes of the group, refilled
+// continuously, with bursts of up to Burst requests. Period defaults to 1s and Burst to Limit. Routes are path prefixes and Methods,
+// when set, restrict the group to those HTTP methods. A group without a Limit is not limited.
+type RateLimitGroupInfo struct {
+	Routes  []string
+	Methods []string
+	Limit   int
+	Period  string
+	Burst   int
+}
+
+// Match returns the name and settings of the group a request is counted against, the group with the longest route
+// prefix matching path among those allowing method. Groups matching equally are chosen by name.
+func (r *RateLimitInfo) Match(method string, path string) (string, RateLimitGroupInfo, bool) {
+	matchedName, matchedLength := "", -1
+	for name, group := range r.Groups {
+		if len(group.Methods) > 0 && !containsFold(group.Methods, method) {
+			continue
+		}
+		for _, route := range group.Routes {
+			if !strings.HasPrefix(path, route) {
+				continue
+			}
+			if len(route) > matchedLength || (len(route) == matchedLength && name < matchedName) {
+				matchedName, matchedLength = name, len(route)
+			}
+		}
+	}
+
+	if matchedLength < 0 {
+		return "", RateLimitGroupInfo{}, false
+	}
+	return matchedName, r.Groups[matchedName], true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
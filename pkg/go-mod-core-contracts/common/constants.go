@@ -115,12 +115,24 @@ const (
 	ApiScheduleActionRecordRouteByJobNameRoute          = ApiScheduleActionRecordRoute + "/" + Job + "/" + Name + "/:" + Name
 	ApiScheduleActionRecordRouteByJobNameAndStatusRoute = ApiScheduleActionRecordRoute + "/" + Job + "/" + Name + "/:" + Name + "/" + Status + "/:" + Status
 
+	ApiCommandAuditRoute      = ApiBase + "/" + Command + "/" + Audit
+	ApiCommandAuditByAgeRoute = ApiCommandAuditRoute + "/" + Age + "/:" + Age
+	ApiBatchCommandRoute      = ApiBase + "/" + Command + "/" + Batch
+	ApiCommandJobByIdRoute    = ApiBase + "/" + Command + "/" + Job + "/:" + Id
+
+	ApiCommandQueueRoute             = ApiBase + "/" + Command + "/" + Queue
+	ApiCommandQueueByIdRoute         = ApiCommandQueueRoute + "/" + Id + "/:" + Id
+	ApiCommandQueueByDeviceNameRoute = ApiCommandQueueRoute + "/" + Device + "/" + Name + "/:" + Name
+
 	ApiConfigRoute         = ApiBase + "/config"
 	ApiPingRoute           = ApiBase + "/ping"
 	ApiVersionRoute        = ApiBase + "/version"
 	ApiSecretRoute         = ApiBase + "/secret"
 	ApiUnitsOfMeasureRoute = ApiBase + "/uom"
 
+	ApiSecretRotateRoute         = ApiSecretRoute + "/rotate"
+	ApiSecretVersionsByNameRoute = ApiSecretRoute + "/" + Name + "/:" + Name + "/versions"
+
 	ApiSystemRoute      = ApiBase + "/system"
 	ApiOperationRoute   = ApiSystemRoute + "/operation"
 	ApiHealthRoute      = ApiSystemRoute + "/health"
@@ -160,6 +172,11 @@ const (
 	Service       = "service"
 	Services      = "services"
 	Command       = "command"
+	Audit         = "audit"
+	Batch         = "batch"
+	Queue         = "queue"
+	Method        = "method"
+	Source        = "source"
 	ProfileName   = "profileName"
 	SourceName    = "sourceName"
 	ServiceName   = "serviceName"
@@ -200,6 +217,13 @@ const (
 	Labels        = "labels"         //query string to specify associated user-defined labels for querying a given object. More than one label may be specified via a comma-delimited list
 	PushDataEvent     = "ds-pushevent"   //query string to specify if an event should be pushed to the IIOT system
 	ReturnDataEvent   = "ds-returnevent" //query string to specify if an event should be returned from device service
+	UseCache          = "ds-usecache"    //query string to specify if core-command may serve a get command from its read cache
+	Async             = "ds-async"       //query string to specify if core-command should run the command as a job and return the job at once
+	Callback          = "ds-callback"    //query string to specify the URL core-command posts the finished job to
+	JobId             = "ds-jobid"       //query string passing the id of the job running the command to the device service
+	WriteAt           = "ds-writeat"     //query string to specify the time, in milliseconds, at which core-command should issue a set command
+	QueueCommand      = "ds-queue"       //query string to specify if core-command should queue a set command while the device is unreachable
+	QueueExpiry       = "ds-queueexpiry" //query string to specify how long a queued set command stays deliverable, as a duration such as 12h
 	RegexCommand  = "ds-regexcmd"    //query string to specify if the command name is in regular expression format
 	DescendantsOf = "descendantsOf"  //Limit returned devices to those who have parent, grandparent, etc. of the given device name
 	MaxLevels     = "maxLevels"      //Limit returned devices to this many levels below 'descendantsOf' (0=unlimited)
@@ -380,10 +404,14 @@ const (
 	CoreCommandDeviceRequestPublishTopic  = "device/command/request" // <DeviceHandlerName>/<DeviceName>/<CommandName>/<CommandMethod> are appended
 	CoreCommandRequestSubscribeTopic      = "core/command/request/#"
 	CoreCommandQueryRequestSubscribeTopic = "core/commandquery/request/#"
+	CoreCommandBatchRequestSubscribeTopic = "core/command/batch/request"
+	CoreCommandJobProgressSubscribeTopic  = "core/command/job/progress/#"
 
 	// Command Client Topics
 	CoreCommandQueryRequestPublishTopic = "core/commandquery/request" // <deviceName>|all is prepended
 	CoreCommandRequestPublishTopic      = "core/command/request"      // <DeviceName>/<CommandName>/<CommandMethod> are appended
+	CoreCommandBatchRequestPublishTopic = "core/command/batch/request"
+	CoreCommandJobProgressPublishTopic  = "core/command/job/progress" // <JobId> is appended
 
 	// Support Alerts
 	// No Topics Yet
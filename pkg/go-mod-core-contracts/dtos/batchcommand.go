@@ -0,0 +1,14 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dtos
+
+// BatchCommandResult is the outcome of a batch command for one device. Response holds the response of the
+// device service when the command reached it.
+type BatchCommandResult struct {
+	DeviceName string `json:"deviceName"`
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message,omitempty"`
+	Response   any    `json:"response,omitempty"`
+}
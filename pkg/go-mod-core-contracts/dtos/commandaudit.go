@@ -0,0 +1,54 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dtos
+
+import (
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+)
+
+// CommandAudit is the DTO of a command audit record. Latency is in milliseconds.
+type CommandAudit struct {
+	Id            string         `json:"id"`
+	Source        string         `json:"source"`
+	Caller        string         `json:"caller,omitempty"`
+	DeviceName    string         `json:"deviceName"`
+	CommandName   string         `json:"commandName"`
+	Method        string         `json:"method"`
+	QueryParams   string         `json:"queryParams,omitempty"`
+	Settings      map[string]any `json:"settings,omitempty"`
+	StatusCode    int            `json:"statusCode"`
+	ErrorMessage  string         `json:"errorMessage,omitempty"`
+	Latency       int64          `json:"latency"`
+	CorrelationId string         `json:"correlationId,omitempty"`
+	Created       int64          `json:"created"`
+}
+
+// FromCommandAuditModelToDTO transforms a CommandAudit Model to a CommandAudit DTO
+func FromCommandAuditModelToDTO(audit models.CommandAudit) CommandAudit {
+	return CommandAudit{
+		Id:            audit.Id,
+		Source:        string(audit.Source),
+		Caller:        audit.Caller,
+		DeviceName:    audit.DeviceName,
+		CommandName:   audit.CommandName,
+		Method:        audit.Method,
+		QueryParams:   audit.QueryParams,
+		Settings:      audit.Settings,
+		StatusCode:    audit.StatusCode,
+		ErrorMessage:  audit.ErrorMessage,
+		Latency:       audit.Latency,
+		CorrelationId: audit.CorrelationId,
+		Created:       audit.Created,
+	}
+}
+
+// FromCommandAuditModelsToDTOs transforms a CommandAudit model array to a CommandAudit DTO array
+func FromCommandAuditModelsToDTOs(audits []models.CommandAudit) []CommandAudit {
+	dtos := make([]CommandAudit, len(audits))
+	for i, a := range audits {
+		dtos[i] = FromCommandAuditModelToDTO(a)
+	}
+	return dtos
+}
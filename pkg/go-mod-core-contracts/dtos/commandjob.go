@@ -0,0 +1,31 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dtos
+
+// CommandJob tracks a command run asynchronously by core-command. Status is one of PENDING, RUNNING, SUCCEEDED
+// and FAILED, and Progress the percentage last reported by the device service. A scheduled job stays PENDING
+// until Scheduled, in milliseconds. Once the job has finished, StatusCode and Result hold the outcome of the
+// command.
+type CommandJob struct {
+	Id          string `json:"id"`
+	DeviceName  string `json:"deviceName"`
+	CommandName string `json:"commandName"`
+	Method      string `json:"method"`
+	Status      string `json:"status"`
+	Progress    int    `json:"progress"`
+	Message     string `json:"message,omitempty"`
+	StatusCode  int    `json:"statusCode,omitempty"`
+	Result      any    `json:"result,omitempty"`
+	Callback    string `json:"callback,omitempty"`
+	Scheduled   int64  `json:"scheduled,omitempty"`
+	Created     int64  `json:"created"`
+	Modified    int64  `json:"modified"`
+}
+
+// CommandJobProgress is the progress of a command job that a device service publishes while running the command
+type CommandJobProgress struct {
+	Progress int    `json:"progress"`
+	Message  string `json:"message,omitempty"`
+}
@@ -61,3 +61,68 @@ func NewSecretRequest(secretName string, secretData []SecretDataKeyValue) Secret
 		SecretData:  secretData,
 	}
 }
+
+// SecretRotationRequest is the request DTO for rotating the secret at a given SecretName, either to new SecretData or
+// back to a kept Version, in the Secret Store of the service and of the listed Services sharing it
+type SecretRotationRequest struct {
+	BaseRequest `json:",inline"`
+	SecretName  string               `json:"secretName" validate:"required"`
+	SecretData  []SecretDataKeyValue `json:"secretData,omitempty" validate:"omitempty,dive"`
+	Version     int                  `json:"version,omitempty" validate:"gte=0"`
+	Services    []string             `json:"services,omitempty" validate:"omitempty,dive,required"`
+}
+
+// Validate satisfies the Validator interface
+func (sr *SecretRotationRequest) Validate() error {
+	if err := common.Validate(sr); err != nil {
+		return err
+	}
+	if (len(sr.SecretData) > 0) == (sr.Version > 0) {
+		return errors.NewCommonIIOT(errors.KindContractInvalid, "exactly one of SecretData and Version must be set", nil)
+	}
+	return nil
+}
+
+// UnmarshalJSON implements the Unmarshaler interface for the SecretRotationRequest type
+func (sr *SecretRotationRequest) UnmarshalJSON(b []byte) error {
+	var alias struct {
+		BaseRequest
+		SecretName string
+		SecretData []SecretDataKeyValue
+		Version    int
+		Services   []string
+	}
+
+	if err := json.Unmarshal(b, &alias); err != nil {
+		return errors.NewCommonIIOT(errors.KindContractInvalid, "Failed to unmarshal SecretRotationRequest body as JSON.", err)
+	}
+
+	*sr = SecretRotationRequest(alias)
+
+	// validate SecretRotationRequest DTO
+	if err := sr.Validate(); err != nil {
+		return errors.NewCommonIIOT(errors.KindContractInvalid, "SecretRotationRequest validation failed.", err)
+	}
+	return nil
+}
+
+// SecretVersion describes one kept version of a secret
+type SecretVersion struct {
+	Version int   `json:"version"`
+	Created int64 `json:"created"`
+}
+
+// SecretVersionsResponse defines the kept versions of a secret, oldest first. The last one is the current version.
+type SecretVersionsResponse struct {
+	BaseResponse `json:",inline"`
+	SecretName   string          `json:"secretName"`
+	Versions     []SecretVersion `json:"versions"`
+}
+
+func NewSecretVersionsResponse(requestId string, message string, statusCode int, secretName string, versions []SecretVersion) SecretVersionsResponse {
+	return SecretVersionsResponse{
+		BaseResponse: NewBaseResponse(requestId, message, statusCode),
+		SecretName:   secretName,
+		Versions:     versions,
+	}
+}
@@ -0,0 +1,51 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dtos
+
+import (
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+)
+
+// QueuedCommand is the DTO of a set command queued for an unreachable device. Reason tells why the command could
+// not be delivered when it was issued.
+type QueuedCommand struct {
+	Id            string         `json:"id"`
+	DeviceName    string         `json:"deviceName"`
+	CommandName   string         `json:"commandName"`
+	QueryParams   string         `json:"queryParams,omitempty"`
+	Settings      map[string]any `json:"settings"`
+	Source        string         `json:"source"`
+	Caller        string         `json:"caller,omitempty"`
+	CorrelationId string         `json:"correlationId,omitempty"`
+	Reason        string         `json:"reason,omitempty"`
+	Expires       int64          `json:"expires"`
+	Created       int64          `json:"created"`
+}
+
+// FromQueuedCommandModelToDTO transforms a QueuedCommand Model to a QueuedCommand DTO
+func FromQueuedCommandModelToDTO(command models.QueuedCommand) QueuedCommand {
+	return QueuedCommand{
+		Id:            command.Id,
+		DeviceName:    command.DeviceName,
+		CommandName:   command.CommandName,
+		QueryParams:   command.QueryParams,
+		Settings:      command.Settings,
+		Source:        string(command.Source),
+		Caller:        command.Caller,
+		CorrelationId: command.CorrelationId,
+		Reason:        command.Reason,
+		Expires:       command.Expires,
+		Created:       command.Created,
+	}
+}
+
+// FromQueuedCommandModelsToDTOs transforms a QueuedCommand model array to a QueuedCommand DTO array
+func FromQueuedCommandModelsToDTOs(commands []models.QueuedCommand) []QueuedCommand {
+	dtos := make([]QueuedCommand, len(commands))
+	for i, c := range commands {
+		dtos[i] = FromQueuedCommandModelToDTO(c)
+	}
+	return dtos
+}
@@ -0,0 +1,83 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package requests
+
+import (
+	"encoding/json"
+	"strings"
+
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	dtoCommon "iiot-backend/pkg/go-mod-core-contracts/dtos/common"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+)
+
+// BatchCommandRequest defines the Request Content for issuing one get or set command to many devices. The
+// devices are selected by exactly one of DeviceNames, Labels and ProfileName. Concurrency and Timeout override
+// the service defaults for the number of devices commanded at once and the time allowed per device.
+type BatchCommandRequest struct {
+	dtoCommon.BaseRequest `json:",inline"`
+	DeviceNames           []string          `json:"deviceNames,omitempty"`
+	Labels                []string          `json:"labels,omitempty"`
+	ProfileName           string            `json:"profileName,omitempty"`
+	CommandName           string            `json:"commandName" validate:"required,iiot-dto-none-empty-string"`
+	Method                string            `json:"method" validate:"required,oneof='get' 'set'"`
+	QueryParams           map[string]string `json:"queryParams,omitempty"`
+	Settings              map[string]any    `json:"settings,omitempty"`
+	Concurrency           int               `json:"concurrency,omitempty" validate:"gte=0"`
+	Timeout               string            `json:"timeout,omitempty" validate:"omitempty,iiot-dto-duration"`
+}
+
+// Validate satisfies the Validator interface
+func (b BatchCommandRequest) Validate() error {
+	if err := common.Validate(b); err != nil {
+		return err
+	}
+
+	selectors := 0
+	if len(b.DeviceNames) > 0 {
+		selectors++
+	}
+	if len(b.Labels) > 0 {
+		selectors++
+	}
+	if b.ProfileName != "" {
+		selectors++
+	}
+	if selectors != 1 {
+		return errors.NewCommonIIOT(errors.KindContractInvalid, "exactly one of deviceNames, labels and profileName must be specified", nil)
+	}
+	if b.Method == "set" && len(b.Settings) == 0 {
+		return errors.NewCommonIIOT(errors.KindContractInvalid, "settings are required for a set command", nil)
+	}
+	return nil
+}
+
+// UnmarshalJSON implements the Unmarshaler interface for the BatchCommandRequest type
+func (b *BatchCommandRequest) UnmarshalJSON(data []byte) error {
+	var alias struct {
+		dtoCommon.BaseRequest
+		DeviceNames []string
+		Labels      []string
+		ProfileName string
+		CommandName string
+		Method      string
+		QueryParams map[string]string
+		Settings    map[string]any
+		Concurrency int
+		Timeout     string
+	}
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return errors.NewCommonIIOT(errors.KindContractInvalid, "Failed to unmarshal request body as JSON.", err)
+	}
+	alias.Method = strings.ToLower(alias.Method)
+
+	*b = BatchCommandRequest(alias)
+
+	// validate BatchCommandRequest DTO
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
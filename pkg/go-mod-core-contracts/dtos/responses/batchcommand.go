@@ -0,0 +1,23 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package responses
+
+import (
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/common"
+)
+
+// BatchCommandResponse defines the Response Content for a batch command, with one result per device.
+type BatchCommandResponse struct {
+	common.BaseResponse `json:",inline"`
+	Results             []dtos.BatchCommandResult `json:"results"`
+}
+
+func NewBatchCommandResponse(requestId string, message string, statusCode int, results []dtos.BatchCommandResult) BatchCommandResponse {
+	return BatchCommandResponse{
+		BaseResponse: common.NewBaseResponse(requestId, message, statusCode),
+		Results:      results,
+	}
+}
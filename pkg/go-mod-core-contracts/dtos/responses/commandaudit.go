@@ -0,0 +1,23 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package responses
+
+import (
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/common"
+)
+
+// MultiCommandAuditsResponse defines the Response Content for GET multiple CommandAudit DTOs.
+type MultiCommandAuditsResponse struct {
+	common.BaseWithTotalCountResponse `json:",inline"`
+	CommandAudits                     []dtos.CommandAudit `json:"commandAudits"`
+}
+
+func NewMultiCommandAuditsResponse(requestId string, message string, statusCode int, totalCount uint32, commandAudits []dtos.CommandAudit) MultiCommandAuditsResponse {
+	return MultiCommandAuditsResponse{
+		BaseWithTotalCountResponse: common.NewBaseWithTotalCountResponse(requestId, message, statusCode, totalCount),
+		CommandAudits:              commandAudits,
+	}
+}
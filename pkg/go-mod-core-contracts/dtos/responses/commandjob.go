@@ -0,0 +1,23 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package responses
+
+import (
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/common"
+)
+
+// CommandJobResponse defines the Response Content for a CommandJob DTO.
+type CommandJobResponse struct {
+	common.BaseResponse `json:",inline"`
+	Job                 dtos.CommandJob `json:"job"`
+}
+
+func NewCommandJobResponse(requestId string, message string, statusCode int, job dtos.CommandJob) CommandJobResponse {
+	return CommandJobResponse{
+		BaseResponse: common.NewBaseResponse(requestId, message, statusCode),
+		Job:          job,
+	}
+}
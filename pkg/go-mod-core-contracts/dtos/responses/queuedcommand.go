@@ -0,0 +1,36 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package responses
+
+import (
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/common"
+)
+
+// QueuedCommandResponse defines the Response Content for a QueuedCommand DTO.
+type QueuedCommandResponse struct {
+	common.BaseResponse `json:",inline"`
+	QueuedCommand       dtos.QueuedCommand `json:"queuedCommand"`
+}
+
+func NewQueuedCommandResponse(requestId string, message string, statusCode int, queuedCommand dtos.QueuedCommand) QueuedCommandResponse {
+	return QueuedCommandResponse{
+		BaseResponse:  common.NewBaseResponse(requestId, message, statusCode),
+		QueuedCommand: queuedCommand,
+	}
+}
+
+// MultiQueuedCommandsResponse defines the Response Content for GET multiple QueuedCommand DTOs.
+type MultiQueuedCommandsResponse struct {
+	common.BaseWithTotalCountResponse `json:",inline"`
+	QueuedCommands                    []dtos.QueuedCommand `json:"queuedCommands"`
+}
+
+func NewMultiQueuedCommandsResponse(requestId string, message string, statusCode int, totalCount uint32, queuedCommands []dtos.QueuedCommand) MultiQueuedCommandsResponse {
+	return MultiQueuedCommandsResponse{
+		BaseWithTotalCountResponse: common.NewBaseWithTotalCountResponse(requestId, message, statusCode, totalCount),
+		QueuedCommands:             queuedCommands,
+	}
+}
@@ -3,15 +3,19 @@ module iiot-backend/pkg/edgex/go-mod-core-contracts
 go 1.19
 
 require (
+	github.com/fxamacker/cbor/v2 v2.9.4
+	github.com/go-kit/log v0.2.1
 	github.com/go-playground/validator/v10 v10.26.0
 	github.com/google/uuid v1.6.0
 )
 
 require (
 	github.com/gabriel-vasile/mimetype v1.4.8 // indirect
+	github.com/go-logfmt/logfmt v0.5.1 // indirect
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 	golang.org/x/crypto v0.33.0 // indirect
 	golang.org/x/net v0.34.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
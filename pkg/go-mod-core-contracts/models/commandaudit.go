@@ -0,0 +1,32 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package models
+
+// CommandAudit records a single command forwarded by core-command to a device service
+type CommandAudit struct {
+	Id            string
+	Source        CommandAuditSource
+	Caller        string
+	DeviceName    string
+	CommandName   string
+	Method        string
+	QueryParams   string
+	Settings      map[string]any
+	StatusCode    int
+	ErrorMessage  string
+	Latency       int64
+	CorrelationId string
+	Created       int64
+}
+
+// CommandAuditSource indicates how the command reached core-command
+type CommandAuditSource string
+
+// Constants for CommandAuditSource
+const (
+	CommandAuditSourceREST         = "REST"
+	CommandAuditSourceMessageBus   = "MESSAGEBUS"
+	CommandAuditSourceExternalMQTT = "EXTERNALMQTT"
+)
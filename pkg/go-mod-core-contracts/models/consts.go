@@ -8,12 +8,6 @@ const (
 	Unlocked = "UNLOCKED"
 )
 
-// Constants for ChannelType
-const (
-	Rest  = "REST"
-	Email = "EMAIL"
-)
-
 // Constants for AlertSeverity
 const (
 	Minor    = "MINOR"
@@ -31,18 +25,24 @@ const (
 	EscalatedContentNotice     = "This notification is escalated by the transmission"
 )
 
-// Constants for DeliveryStatus and ScheduleActionRecordStatus
+// Constants for DeliveryStatus, ScheduleActionRecordStatus and CommandJobStatus
 const (
 	Failed       = "FAILED"
 	Sent         = "SENT"
 	Acknowledged = "ACKNOWLEDGED"
 	RESENDING    = "RESENDING"
 
-	// Constants for ScheduleActionRecordStatus only
+	// Constants for ScheduleActionRecordStatus and CommandJobStatus only
 	Succeeded = "SUCCEEDED"
 	Missed    = "MISSED"
 )
 
+// Constants for CommandJobStatus only
+const (
+	Pending = "PENDING"
+	Running = "RUNNING"
+)
+
 // Constants for both AlertStatus and DeliveryStatus
 const (
 	Escalated = "ESCALATED"
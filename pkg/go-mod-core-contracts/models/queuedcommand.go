@@ -0,0 +1,21 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package models
+
+// QueuedCommand is a set command held by core-command until its device is reachable again. Commands of a device
+// are delivered in the order they were queued, and dropped once Expires, in milliseconds, has passed.
+type QueuedCommand struct {
+	Id            string
+	DeviceName    string
+	CommandName   string
+	QueryParams   string
+	Settings      map[string]any
+	Source        CommandAuditSource
+	Caller        string
+	CorrelationId string
+	Reason        string
+	Expires       int64
+	Created       int64
+}
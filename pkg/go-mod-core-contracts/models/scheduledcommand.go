@@ -0,0 +1,22 @@
+//
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package models
+
+// ScheduledCommand is a set command scheduled with ds-writeat, kept by core-command until it is issued so that the
+// command job survives a restart. JobId is the id of the command job tracking it and Scheduled, in milliseconds, the
+// time the command is issued at.
+type ScheduledCommand struct {
+	JobId         string
+	DeviceName    string
+	CommandName   string
+	QueryParams   string
+	Settings      map[string]any
+	Callback      string
+	Source        CommandAuditSource
+	Caller        string
+	CorrelationId string
+	Scheduled     int64
+	Created       int64
+}
@@ -24,9 +24,6 @@ type EventSubscription struct {
 	ServiceState     ServiceState
 }
 
-// ChannelType controls the range of values which constitute valid delivery types for channels
-type ChannelType string
-
 func (subscription *EventSubscription) UnmarshalJSON(b []byte) error {
 	var alias struct {
 		DBTimestamp
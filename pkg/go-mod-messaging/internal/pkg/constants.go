@@ -39,4 +39,15 @@ const (
 	AutoProvision           = "AutoProvision"
 	Deliver                 = "Deliver"
 	DefaultPubRetryAttempts = "DefaultPubRetryAttempts"
+
+	// Payload protection configurations
+	PayloadSigning    = "PayloadSigning"
+	PayloadEncryption = "PayloadEncryption"
+	PayloadMaxAge     = "PayloadMaxAge"
+	PayloadSecretName = "PayloadSecretName"
+
+	// Payload protection keys, given as comma separated id:base64-key pairs
+	PayloadSigningKeys    = "PayloadSigningKeys"
+	PayloadVerifyKeys     = "PayloadVerifyKeys"
+	PayloadEncryptionKeys = "PayloadEncryptionKeys"
 )
@@ -41,6 +41,8 @@ type Client struct {
 	unmarshaller          MessageUnmarshaller
 	existingEventSubscriptions map[string]existingEventSubscription
 	subscriptionMutex     *sync.Mutex
+	// clientMutex guards mqttClient and configuration, which UpdateCredentials replaces
+	clientMutex           *sync.RWMutex
 }
 
 type existingEventSubscription struct {
@@ -59,6 +61,7 @@ func NewMQTTClient(config types.MessageBusConfig) (*Client, error) {
 		unmarshaller:          json.Unmarshal,
 		existingEventSubscriptions: map[string]existingEventSubscription{},
 		subscriptionMutex:     new(sync.Mutex),
+		clientMutex:           new(sync.RWMutex),
 	}
 
 	return client, nil
@@ -78,6 +81,7 @@ func NewMQTTClientWithCreator(
 		unmarshaller:          unmarshaller,
 		existingEventSubscriptions: make(map[string]existingEventSubscription),
 		subscriptionMutex:     new(sync.Mutex),
+		clientMutex:           new(sync.RWMutex),
 	}
 
 	return client, nil
@@ -86,32 +90,49 @@ func NewMQTTClientWithCreator(
 // Connect establishes a connection to a MQTT server.
 // This must be called before any other functionality provided by the Client.
 func (mc *Client) Connect() error {
+	mc.clientMutex.Lock()
 	if mc.mqttClient == nil {
 		// Move created MQTT Client here since we need to set the onConnectHandler which needs to have access to
 		// the Client's activeEventSubscriptions. This was not possible from the factory method.
 		mqttClient, err := mc.creator(mc.configuration, mc.onConnectHandler)
 		if err != nil {
+			mc.clientMutex.Unlock()
 			return err
 		}
 		mc.mqttClient = mqttClient
 	}
+	mqttClient := mc.mqttClient
+	mc.clientMutex.Unlock()
 
 	// Avoid reconnecting if already connected.
-	if mc.mqttClient.IsConnected() {
+	if mqttClient.IsConnected() {
 		return nil
 	}
 
-	optionsReader := mc.mqttClient.OptionsReader()
+	return connect(mqttClient)
+}
+
+func connect(mqttClient pahoMqtt.Client) error {
+	optionsReader := mqttClient.OptionsReader()
 
 	return getTokenError(
-		mc.mqttClient.Connect(),
+		mqttClient.Connect(),
 		optionsReader.ConnectTimeout(),
 		ConnectOperation,
 		"Unable to connect")
 }
 
-func (mc *Client) onConnectHandler(_ pahoMqtt.Client) {
-	optionsReader := mc.mqttClient.OptionsReader()
+// client returns the paho client currently in use
+func (mc *Client) client() pahoMqtt.Client {
+	mc.clientMutex.RLock()
+	defer mc.clientMutex.RUnlock()
+	return mc.mqttClient
+}
+
+// onConnectHandler re-creates the subscriptions on the paho client that connected, which is not yet the one in use
+// while UpdateCredentials connects its replacement
+func (mc *Client) onConnectHandler(mqttClient pahoMqtt.Client) {
+	optionsReader := mqttClient.OptionsReader()
 
 	mc.subscriptionMutex.Lock()
 	defer mc.subscriptionMutex.Unlock()
@@ -119,7 +140,7 @@ func (mc *Client) onConnectHandler(_ pahoMqtt.Client) {
 	// existingEventSubscriptions will be empty on the first connection.
 	// On a re-connect is when the subscriptions must be re-created.
 	for _, subscription := range mc.existingEventSubscriptions {
-		token := mc.mqttClient.Subscribe(subscription.topic, subscription.qos, subscription.handler)
+		token := mqttClient.Subscribe(subscription.topic, subscription.qos, subscription.handler)
 		message := fmt.Sprintf("Failed to re-create subscription for topic=%s", subscription.topic)
 		err := getTokenError(token, optionsReader.ConnectTimeout(), SubscribeOperation, message)
 		if err != nil {
@@ -135,10 +156,11 @@ func (mc *Client) Publish(message types.MessageEnvelope, topic string) error {
 		return NewOperationErr(PublishOperation, err.Error())
 	}
 
-	optionsReader := mc.mqttClient.OptionsReader()
+	mqttClient := mc.client()
+	optionsReader := mqttClient.OptionsReader()
 
 	return getTokenError(
-		mc.mqttClient.Publish(
+		mqttClient.Publish(
 			topic,
 			optionsReader.WillQos(),
 			optionsReader.WillRetained(),
@@ -160,10 +182,11 @@ func (mc *Client) PublishWithSizeLimit(message types.MessageEnvelope, topic stri
 		return fmt.Errorf("message size exceed limit(%d KB)", limit)
 	}
 
-	optionsReader := mc.mqttClient.OptionsReader()
+	mqttClient := mc.client()
+	optionsReader := mqttClient.OptionsReader()
 
 	return getTokenError(
-		mc.mqttClient.Publish(
+		mqttClient.Publish(
 			topic,
 			optionsReader.WillQos(),
 			optionsReader.WillRetained(),
@@ -188,7 +211,7 @@ func (mc *Client) Unsubscribe(topics ...string) error {
 	mc.subscriptionMutex.Lock()
 	defer mc.subscriptionMutex.Unlock()
 
-	token := mc.mqttClient.Unsubscribe(topics...)
+	token := mc.client().Unsubscribe(topics...)
 	if token.Error() != nil {
 		return token.Error()
 	}
@@ -204,12 +227,67 @@ func (mc *Client) Unsubscribe(topics ...string) error {
 func (mc *Client) Disconnect() error {
 	// Specify a wait time equal to the write timeout so that we allow other any queued processing to complete before
 	// disconnecting.
-	optionsReader := mc.mqttClient.OptionsReader()
-	mc.mqttClient.Disconnect(uint(optionsReader.ConnectTimeout() * time.Millisecond))
+	disconnect(mc.client())
 
 	return nil
 }
 
+func disconnect(mqttClient pahoMqtt.Client) {
+	optionsReader := mqttClient.OptionsReader()
+	mqttClient.Disconnect(uint(optionsReader.ConnectTimeout() * time.Millisecond))
+}
+
+// UpdateCredentials replaces the username and password used to authenticate with the MQTT server. When the client
+// was connected, a new paho client is connected with the new credentials, re-creating the subscriptions, before it
+// replaces the current one. The current client stays in use when the new one cannot connect.
+func (mc *Client) UpdateCredentials(username string, password string) error {
+	mc.clientMutex.RLock()
+	configuration := mc.configuration
+	current := mc.mqttClient
+	mc.clientMutex.RUnlock()
+
+	optional := make(map[string]string, len(configuration.Optional)+2)
+	for key, value := range configuration.Optional {
+		optional[key] = value
+	}
+	optional[pkg.Username] = username
+	optional[pkg.Password] = password
+	configuration.Optional = optional
+
+	if current == nil {
+		mc.clientMutex.Lock()
+		mc.configuration = configuration
+		mc.clientMutex.Unlock()
+		return nil
+	}
+
+	// No subscription is added or removed until the replacement is in use, its onConnectHandler runs once the
+	// lock is released and subscribes to every topic the client is subscribed to
+	mc.subscriptionMutex.Lock()
+	defer mc.subscriptionMutex.Unlock()
+
+	// The paho client keeps the options it was created with, so a new one is created with the new credentials
+	replacement, err := mc.creator(configuration, mc.onConnectHandler)
+	if err != nil {
+		return err
+	}
+	if err := connect(replacement); err != nil {
+		// stops the replacement from retrying to connect
+		disconnect(replacement)
+		return err
+	}
+
+	mc.clientMutex.Lock()
+	mc.mqttClient = replacement
+	mc.configuration = configuration
+	mc.clientMutex.Unlock()
+
+	// Both clients use the same client id, the broker closed the current client's connection when the replacement
+	// connected and disconnecting it stops it from reconnecting and taking the session back
+	disconnect(current)
+	return nil
+}
+
 // DefaultClientCreator returns a default function for creating MQTT clients.
 func DefaultClientCreator() ClientCreator {
 	return func(config types.MessageBusConfig, handler pahoMqtt.OnConnectHandler) (pahoMqtt.Client, error) {
@@ -337,9 +415,10 @@ func createClientOptions(
 }
 
 func (mc *Client) PublishBinaryData(data []byte, topic string) error {
-	optionsReader := mc.mqttClient.OptionsReader()
+	mqttClient := mc.client()
+	optionsReader := mqttClient.OptionsReader()
 	return getTokenError(
-		mc.mqttClient.Publish(
+		mqttClient.Publish(
 			topic,
 			optionsReader.WillQos(),
 			optionsReader.WillRetained(),
@@ -366,16 +445,17 @@ func newBinaryDataMessageHandler(_ MessageUnmarshaller,
 }
 
 func (mc *Client) subscribe(topics []types.TopicChannel, messageErrors chan error, messageHandlerCreator MessageHandlerCreator) error {
-	optionsReader := mc.mqttClient.OptionsReader()
-
 	mc.subscriptionMutex.Lock()
 	defer mc.subscriptionMutex.Unlock()
 
+	mqttClient := mc.client()
+	optionsReader := mqttClient.OptionsReader()
+
 	for _, topic := range topics {
 		handler := messageHandlerCreator(mc.unmarshaller, topic.Messages, messageErrors)
 		qos := optionsReader.WillQos()
 
-		token := mc.mqttClient.Subscribe(topic.Topic, qos, handler)
+		token := mqttClient.Subscribe(topic.Topic, qos, handler)
 		err := getTokenError(token, optionsReader.ConnectTimeout(), SubscribeOperation, "Failed to create subscription")
 		if err != nil {
 			return err
@@ -62,6 +62,7 @@ const (
 	apiVersionHeader    = "ApiVersion"
 	errorCodeHeader     = "ErrorCode"
 	queryParamsHeader   = "QueryParams"
+	securityHeader      = "Security"
 )
 
 type natsMarshaller struct {
@@ -88,6 +89,13 @@ func (nm *natsMarshaller) Marshal(v types.MessageEnvelope, publishTopic string)
 			out.Header.Add(queryParamsHeader, query)
 		}
 	}
+	if v.Security != nil {
+		security, err := json.Marshal(v.Security)
+		if err != nil {
+			return nil, err
+		}
+		out.Header.Set(securityHeader, string(security))
+	}
 	if nm.opts.ExactlyOnce {
 		// the broker should only accept a message once per publishing service / correlation ID
 		out.Header.Set(nats.MsgIdHdr, fmt.Sprintf("%s-%s", nm.opts.ClientId, v.CorrelationID))
@@ -120,8 +128,16 @@ func (nm *natsMarshaller) Unmarshal(msg *nats.Msg, target *types.MessageEnvelope
 	query := msg.Header.Values(queryParamsHeader)
 	if len(query) > 0 {
 		for _, q := range query {
-			kv := strings.Split(q, ":")
-			target.QueryParams[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+			// values may contain colons themselves
+			key, value, _ := strings.Cut(q, ":")
+			target.QueryParams[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	if security := msg.Header.Get(securityHeader); security != "" {
+		target.Security = &types.EnvelopeSecurity{}
+		if err := json.Unmarshal([]byte(security), target.Security); err != nil {
+			return err
 		}
 	}
 
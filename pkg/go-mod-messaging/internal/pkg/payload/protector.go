@@ -0,0 +1,470 @@
+/********************************************************************************
+ *******************************************************************************/
+
+// Package payload signs MessageEnvelopes and encrypts their payloads before they are published, and verifies and
+// decrypts them as they are received, so that envelopes can not be read or forged by other clients of a shared broker.
+package payload
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"iiot-backend/pkg/go-mod-messaging/internal/pkg"
+	"iiot-backend/pkg/go-mod-messaging/pkg/types"
+)
+
+const (
+	// SigningHMAC signs envelopes with HMAC-SHA256 and a key shared by all services
+	SigningHMAC = "hmac"
+	// SigningEd25519 signs envelopes with the private key of the service, receivers verify them with its public key
+	SigningEd25519 = "ed25519"
+	// EncryptionAESGCM encrypts payloads with AES-256-GCM and a key shared by all services
+	EncryptionAESGCM = "aes-gcm"
+
+	algorithmHMAC   = "hmac-sha256"
+	algorithmEd     = "ed25519"
+	algorithmAESGCM = "aes-256-gcm"
+
+	aesKeySize = 32
+
+	// DefaultMaxAge is the maximum age of signed or encrypted envelopes when PayloadMaxAge is not set. Envelopes seen
+	// within it are remembered to reject replays, older ones are rejected as expired.
+	DefaultMaxAge = 5 * time.Minute
+)
+
+var (
+	// ErrUnprotected is returned for envelopes that are not signed or encrypted as the receiver requires
+	ErrUnprotected = errors.New("message envelope is not protected as required")
+	// ErrInvalidSignature is returned for envelopes whose signature does not match, such as forged envelopes
+	ErrInvalidSignature = errors.New("message envelope signature is invalid")
+	// ErrExpired is returned for envelopes protected longer ago than the configured maximum age, such as replays
+	ErrExpired = errors.New("message envelope is too old")
+	// ErrReplayed is returned for envelopes that were already received within the maximum age
+	ErrReplayed = errors.New("message envelope was already received")
+)
+
+// Protector signs and encrypts envelopes with the keys of the message bus configuration
+type Protector struct {
+	signing    string
+	encryption string
+	maxAge     time.Duration
+
+	signingKeyID    string
+	hmacKeys        map[string][]byte
+	privateKey      ed25519.PrivateKey
+	publicKeys      map[string]ed25519.PublicKey
+	encryptionKeyID string
+	encryptionKeys  map[string][]byte
+
+	seen *ReplayCache
+}
+
+// replayKey identifies a signed or encrypted envelope, the signature or the encryption authenticating each of its
+// fields
+type replayKey struct {
+	signatureKeyID  string
+	encryptionKeyID string
+	timestamp       int64
+	requestID       string
+}
+
+// ReplayCache remembers the envelopes received within the maximum age, older ones being rejected as expired anyway.
+// Each receiver of the envelopes of a topic needs a cache of its own, an envelope delivered to several subscriptions
+// being no replay.
+type ReplayCache struct {
+	mutex     sync.Mutex
+	received  map[replayKey]time.Time
+	lastPrune time.Time
+}
+
+func NewReplayCache() *ReplayCache {
+	return &ReplayCache{received: make(map[replayKey]time.Time)}
+}
+
+// add records key received at now and reports whether it was new. An envelope is accepted while its timestamp is
+// within maxAge of the clock either way, so entries are dropped once received more than twice maxAge ago.
+func (r *ReplayCache) add(key replayKey, now time.Time, maxAge time.Duration) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if now.Sub(r.lastPrune) > maxAge {
+		for k, received := range r.received {
+			if now.Sub(received) > 2*maxAge {
+				delete(r.received, k)
+			}
+		}
+		r.lastPrune = now
+	}
+	if _, ok := r.received[key]; ok {
+		return false
+	}
+	r.received[key] = now
+	return true
+}
+
+// IsConfigured reports whether the options of a message bus configuration ask for payload protection
+func IsConfigured(options map[string]string) bool {
+	return strings.TrimSpace(options[pkg.PayloadSigning]) != "" || strings.TrimSpace(options[pkg.PayloadEncryption]) != ""
+}
+
+// NewProtector creates a Protector from the PayloadSigning, PayloadEncryption and PayloadMaxAge options and the keys
+// given by the PayloadSigningKeys, PayloadVerifyKeys and PayloadEncryptionKeys options. The first key of each list
+// is the one envelopes are protected with, the others are only accepted from senders, so that keys can be rotated.
+// Signed or encrypted envelopes older than PayloadMaxAge, DefaultMaxAge unless set, are rejected, and so are those
+// received twice within it.
+func NewProtector(options map[string]string) (*Protector, error) {
+	p := &Protector{
+		signing:    strings.ToLower(strings.TrimSpace(options[pkg.PayloadSigning])),
+		encryption: strings.ToLower(strings.TrimSpace(options[pkg.PayloadEncryption])),
+		seen:       NewReplayCache(),
+	}
+
+	if value := strings.TrimSpace(options[pkg.PayloadMaxAge]); value != "" {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %w", pkg.PayloadMaxAge, value, err)
+		}
+		if maxAge < 0 {
+			return nil, fmt.Errorf("invalid %s '%s': must not be negative", pkg.PayloadMaxAge, value)
+		}
+		p.maxAge = maxAge
+	}
+	if p.maxAge == 0 && (p.signing != "" || p.encryption != "") {
+		p.maxAge = DefaultMaxAge
+	}
+
+	signingKeys, signingKeyID, err := parseKeys(options[pkg.PayloadSigningKeys])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", pkg.PayloadSigningKeys, err)
+	}
+	verifyKeys, _, err := parseKeys(options[pkg.PayloadVerifyKeys])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", pkg.PayloadVerifyKeys, err)
+	}
+
+	switch p.signing {
+	case "":
+	case SigningHMAC:
+		if signingKeyID == "" {
+			return nil, fmt.Errorf("%s signing requires %s", SigningHMAC, pkg.PayloadSigningKeys)
+		}
+		p.signingKeyID = signingKeyID
+		p.hmacKeys = signingKeys
+		for id, key := range verifyKeys {
+			p.hmacKeys[id] = key
+		}
+	case SigningEd25519:
+		p.publicKeys = make(map[string]ed25519.PublicKey)
+		for id, key := range verifyKeys {
+			if len(key) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("ed25519 verify key '%s' must be %d bytes, got %d", id, ed25519.PublicKeySize, len(key))
+			}
+			p.publicKeys[id] = key
+		}
+		// a service that only receives needs no private key
+		if signingKeyID != "" {
+			privateKey, err := ed25519PrivateKey(signingKeys[signingKeyID])
+			if err != nil {
+				return nil, fmt.Errorf("ed25519 signing key '%s': %w", signingKeyID, err)
+			}
+			p.signingKeyID = signingKeyID
+			p.privateKey = privateKey
+			p.publicKeys[signingKeyID] = privateKey.Public().(ed25519.PublicKey)
+		}
+	default:
+		return nil, fmt.Errorf("unknown %s '%s', expected %s or %s", pkg.PayloadSigning, p.signing, SigningHMAC, SigningEd25519)
+	}
+
+	switch p.encryption {
+	case "":
+	case EncryptionAESGCM:
+		encryptionKeys, encryptionKeyID, err := parseKeys(options[pkg.PayloadEncryptionKeys])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", pkg.PayloadEncryptionKeys, err)
+		}
+		if encryptionKeyID == "" {
+			return nil, fmt.Errorf("%s encryption requires %s", EncryptionAESGCM, pkg.PayloadEncryptionKeys)
+		}
+		for id, key := range encryptionKeys {
+			if len(key) != aesKeySize {
+				return nil, fmt.Errorf("encryption key '%s' must be %d bytes, got %d", id, aesKeySize, len(key))
+			}
+		}
+		p.encryptionKeyID = encryptionKeyID
+		p.encryptionKeys = encryptionKeys
+	default:
+		return nil, fmt.Errorf("unknown %s '%s', expected %s", pkg.PayloadEncryption, p.encryption, EncryptionAESGCM)
+	}
+
+	return p, nil
+}
+
+// KeepReplays lets p reject the replays of envelopes previous received, so that replacing the Protector to rotate keys
+// does not accept the envelopes received before once more
+func (p *Protector) KeepReplays(previous *Protector) {
+	if previous != nil {
+		p.seen = previous.seen
+	}
+}
+
+// Protect encrypts the payload of message and signs it for topic as configured. The payload of the returned envelope
+// is a byte array.
+func (p *Protector) Protect(message types.MessageEnvelope, topic string) (types.MessageEnvelope, error) {
+	payload, err := types.ConvertMsgPayloadToByteArray(message.ContentType, message.Payload)
+	if err != nil {
+		return types.MessageEnvelope{}, fmt.Errorf("unable to convert payload to protect it: %w", err)
+	}
+
+	message.Security = &types.EnvelopeSecurity{Timestamp: time.Now().UnixNano()}
+	if p.encryption != "" {
+		message.Security.EncryptionAlgorithm = algorithmAESGCM
+		message.Security.EncryptionKeyID = p.encryptionKeyID
+		payload, err = p.encrypt(payload, additionalData(message, topic))
+		if err != nil {
+			return types.MessageEnvelope{}, fmt.Errorf("unable to encrypt payload: %w", err)
+		}
+	}
+
+	switch p.signing {
+	case SigningHMAC:
+		message.Security.SignatureAlgorithm = algorithmHMAC
+		message.Security.SignatureKeyID = p.signingKeyID
+		mac := hmac.New(sha256.New, p.hmacKeys[p.signingKeyID])
+		mac.Write(content(message, topic, payload))
+		message.Security.Signature = mac.Sum(nil)
+	case SigningEd25519:
+		if p.privateKey == nil {
+			return types.MessageEnvelope{}, fmt.Errorf("unable to sign envelope: %s has no key", pkg.PayloadSigningKeys)
+		}
+		message.Security.SignatureAlgorithm = algorithmEd
+		message.Security.SignatureKeyID = p.signingKeyID
+		message.Security.Signature = ed25519.Sign(p.privateKey, content(message, topic, payload))
+	}
+
+	message.Payload = payload
+	return message, nil
+}
+
+// Open verifies the signature of a received envelope and decrypts its payload as configured, rejecting envelopes that
+// are not protected accordingly, expired or replayed. The payload of the returned envelope is a byte array.
+func (p *Protector) Open(message types.MessageEnvelope) (types.MessageEnvelope, error) {
+	return p.OpenOnce(message, p.seen)
+}
+
+// OpenOnce opens a received envelope like Open, rejecting the envelopes already recorded in seen rather than in the
+// cache of p, so that each subscription receiving the same envelope can open it once
+func (p *Protector) OpenOnce(message types.MessageEnvelope, seen *ReplayCache) (types.MessageEnvelope, error) {
+	security := message.Security
+	if security == nil {
+		return types.MessageEnvelope{}, ErrUnprotected
+	}
+	if p.maxAge > 0 {
+		if age := time.Since(time.Unix(0, security.Timestamp)); age > p.maxAge || age < -p.maxAge {
+			return types.MessageEnvelope{}, ErrExpired
+		}
+	}
+
+	payload, err := receivedPayload(message.Payload)
+	if err != nil {
+		return types.MessageEnvelope{}, err
+	}
+
+	switch p.signing {
+	case SigningHMAC:
+		key, ok := p.hmacKeys[security.SignatureKeyID]
+		if security.SignatureAlgorithm != algorithmHMAC || !ok {
+			return types.MessageEnvelope{}, ErrUnprotected
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write(content(message, message.ReceivedTopic, payload))
+		if !hmac.Equal(mac.Sum(nil), security.Signature) {
+			return types.MessageEnvelope{}, ErrInvalidSignature
+		}
+	case SigningEd25519:
+		key, ok := p.publicKeys[security.SignatureKeyID]
+		if security.SignatureAlgorithm != algorithmEd || !ok {
+			return types.MessageEnvelope{}, ErrUnprotected
+		}
+		if !ed25519.Verify(key, content(message, message.ReceivedTopic, payload), security.Signature) {
+			return types.MessageEnvelope{}, ErrInvalidSignature
+		}
+	}
+
+	if p.encryption != "" {
+		if security.EncryptionAlgorithm != algorithmAESGCM {
+			return types.MessageEnvelope{}, ErrUnprotected
+		}
+		payload, err = p.decrypt(security.EncryptionKeyID, payload, additionalData(message, message.ReceivedTopic))
+		if err != nil {
+			return types.MessageEnvelope{}, err
+		}
+	}
+
+	// only authenticated envelopes are remembered, so that forged ones can not block the genuine envelope
+	if p.maxAge > 0 {
+		key := replayKey{signatureKeyID: security.SignatureKeyID, encryptionKeyID: security.EncryptionKeyID,
+			timestamp: security.Timestamp, requestID: message.RequestID}
+		if !seen.add(key, time.Now(), p.maxAge) {
+			return types.MessageEnvelope{}, ErrReplayed
+		}
+	}
+
+	message.Payload = payload
+	return message, nil
+}
+
+// signedContent is what the signature covers
+type signedContent struct {
+	Topic         string                 `json:"topic"`
+	ApiVersion    string                 `json:"apiVersion"`
+	CorrelationID string                 `json:"correlationID"`
+	RequestID     string                 `json:"requestID"`
+	ErrorCode     int                    `json:"errorCode"`
+	ContentType   string                 `json:"contentType"`
+	QueryParams   map[string]string      `json:"queryParams"`
+	Security      types.EnvelopeSecurity `json:"security"`
+	Payload       []byte                 `json:"payload"`
+}
+
+// content returns the canonical encoding of the envelope published to topic with payload
+func content(message types.MessageEnvelope, topic string, payload []byte) []byte {
+	c := signedContent{
+		// NATS subjects separate levels with dots, which are received as slashes
+		Topic:         strings.ReplaceAll(topic, ".", "/"),
+		ApiVersion:    message.ApiVersion,
+		CorrelationID: message.CorrelationID,
+		RequestID:     message.RequestID,
+		ErrorCode:     message.ErrorCode,
+		ContentType:   message.ContentType,
+		Security:      *message.Security,
+	}
+	c.Security.Signature = nil
+	if len(message.QueryParams) > 0 {
+		c.QueryParams = message.QueryParams
+	}
+	if len(payload) > 0 {
+		c.Payload = payload
+	}
+
+	// struct fields and map keys are marshaled in a fixed order
+	data, _ := json.Marshal(c)
+	return data
+}
+
+// additionalData returns what the encryption authenticates besides the payload, the content without payload and
+// signature, which is only added once the payload is encrypted
+func additionalData(message types.MessageEnvelope, topic string) []byte {
+	security := *message.Security
+	security.SignatureAlgorithm, security.SignatureKeyID = "", ""
+	message.Security = &security
+	return content(message, topic, nil)
+}
+
+// receivedPayload returns the bytes of a received payload, which arrives as base64 string through JSON
+func receivedPayload(payload any) ([]byte, error) {
+	switch v := payload.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("protected payload is not base64: %w", err)
+		}
+		return decoded, nil
+	default:
+		return nil, ErrUnprotected
+	}
+}
+
+func (p *Protector) encrypt(plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(p.encryptionKeys[p.encryptionKeyID])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func (p *Protector) decrypt(keyID string, sealed []byte, additionalData []byte) ([]byte, error) {
+	key, ok := p.encryptionKeys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("payload encryption key '%s' is not available", keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidSignature
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		// a payload that fails authentication was forged or altered
+		return nil, ErrInvalidSignature
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ed25519PrivateKey accepts either the 32 byte seed or the 64 byte private key
+func ed25519PrivateKey(key []byte) (ed25519.PrivateKey, error) {
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	default:
+		return nil, fmt.Errorf("must be %d or %d bytes, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(key))
+	}
+}
+
+// parseKeys parses a comma separated list of id:base64-key pairs and returns the keys with the id of the first one
+func parseKeys(spec string) (map[string][]byte, string, error) {
+	keys := make(map[string][]byte)
+	first := ""
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(pair, ":")
+		if !ok || id == "" {
+			// the pair is not echoed, it may be a bare key
+			return nil, "", errors.New("keys must be given as id:base64-key pairs")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, "", fmt.Errorf("key '%s' is not valid base64: %w", id, err)
+		}
+		if first == "" {
+			first = id
+		}
+		keys[id] = key
+	}
+	return keys, first, nil
+}
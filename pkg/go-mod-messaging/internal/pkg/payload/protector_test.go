@@ -0,0 +1,228 @@
+/********************************************************************************
+ *******************************************************************************/
+
+package payload
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"iiot-backend/pkg/go-mod-messaging/internal/pkg"
+	"iiot-backend/pkg/go-mod-messaging/pkg/types"
+)
+
+const testTopic = "edgex/events/device/sensor-1"
+
+func testKey(t *testing.T, size int) string {
+	key := make([]byte, size)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newTestProtector(t *testing.T, options map[string]string) *Protector {
+	protector, err := NewProtector(options)
+	require.NoError(t, err)
+	return protector
+}
+
+// hmacOptions signs with HMAC and encrypts with AES-GCM
+func hmacOptions(t *testing.T) map[string]string {
+	return map[string]string{
+		pkg.PayloadSigning:        SigningHMAC,
+		pkg.PayloadSigningKeys:    "k1:" + testKey(t, 32),
+		pkg.PayloadEncryption:     EncryptionAESGCM,
+		pkg.PayloadEncryptionKeys: "e1:" + testKey(t, aesKeySize),
+	}
+}
+
+// received returns the envelope as a subscriber receives it, through JSON and with the topic it arrived on
+func received(t *testing.T, message types.MessageEnvelope, topic string) types.MessageEnvelope {
+	data, err := json.Marshal(message)
+	require.NoError(t, err)
+	var envelope types.MessageEnvelope
+	require.NoError(t, json.Unmarshal(data, &envelope))
+	envelope.ReceivedTopic = topic
+	return envelope
+}
+
+func testEnvelope() types.MessageEnvelope {
+	return types.MessageEnvelope{
+		RequestID:   "request-1",
+		ContentType: "application/json",
+		Payload:     []byte(`{"reading":21.5}`),
+	}
+}
+
+func TestProtectorOpensProtectedEnvelope(t *testing.T) {
+	options := hmacOptions(t)
+	sender, receiver := newTestProtector(t, options), newTestProtector(t, options)
+
+	protected, err := sender.Protect(testEnvelope(), testTopic)
+	require.NoError(t, err)
+	assert.NotContains(t, string(protected.Payload.([]byte)), "reading")
+
+	opened, err := receiver.Open(received(t, protected, testTopic))
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"reading":21.5}`), opened.Payload)
+}
+
+func TestProtectorRejectsForgedEnvelopes(t *testing.T) {
+	options := hmacOptions(t)
+	receiver := newTestProtector(t, options)
+
+	// the attacker knows the encryption key and the key id, but not the signing key
+	forgedOptions := hmacOptions(t)
+	forgedOptions[pkg.PayloadEncryptionKeys] = options[pkg.PayloadEncryptionKeys]
+	forged, err := newTestProtector(t, forgedOptions).Protect(testEnvelope(), testTopic)
+	require.NoError(t, err)
+	_, err = receiver.Open(received(t, forged, testTopic))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = receiver.Open(received(t, testEnvelope(), testTopic))
+	assert.ErrorIs(t, err, ErrUnprotected)
+
+	unknownKey, err := newTestProtector(t, options).Protect(testEnvelope(), testTopic)
+	require.NoError(t, err)
+	unknownKey.Security.SignatureKeyID = "k2"
+	_, err = receiver.Open(received(t, unknownKey, testTopic))
+	assert.ErrorIs(t, err, ErrUnprotected)
+}
+
+func TestProtectorRejectsTamperedEnvelopes(t *testing.T) {
+	options := hmacOptions(t)
+	sender := newTestProtector(t, options)
+
+	tests := []struct {
+		name   string
+		topic  string
+		modify func(message *types.MessageEnvelope)
+	}{
+		{"payload", testTopic, func(message *types.MessageEnvelope) {
+			payload := append([]byte(nil), message.Payload.([]byte)...)
+			payload[len(payload)-1] ^= 1
+			message.Payload = payload
+		}},
+		{"request id", testTopic, func(message *types.MessageEnvelope) { message.RequestID = "request-2" }},
+		{"content type", testTopic, func(message *types.MessageEnvelope) { message.ContentType = "application/cbor" }},
+		{"timestamp", testTopic, func(message *types.MessageEnvelope) { message.Security.Timestamp++ }},
+		{"encryption key", testTopic, func(message *types.MessageEnvelope) { message.Security.EncryptionKeyID = "e2" }},
+		{"topic", "edgex/events/device/sensor-2", func(message *types.MessageEnvelope) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			protected, err := sender.Protect(testEnvelope(), testTopic)
+			require.NoError(t, err)
+			tt.modify(&protected)
+
+			_, err = newTestProtector(t, options).Open(received(t, protected, tt.topic))
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+		})
+	}
+}
+
+func TestProtectorRejectsReplayedEnvelopes(t *testing.T) {
+	options := hmacOptions(t)
+	sender, receiver := newTestProtector(t, options), newTestProtector(t, options)
+
+	protected, err := sender.Protect(testEnvelope(), testTopic)
+	require.NoError(t, err)
+	_, err = receiver.Open(received(t, protected, testTopic))
+	require.NoError(t, err)
+	_, err = receiver.Open(received(t, protected, testTopic))
+	assert.ErrorIs(t, err, ErrReplayed)
+
+	// rotating the keys keeps what was received
+	rotated := newTestProtector(t, options)
+	rotated.KeepReplays(receiver)
+	_, err = rotated.Open(received(t, protected, testTopic))
+	assert.ErrorIs(t, err, ErrReplayed)
+
+	// a new envelope for the same request is no replay
+	again, err := sender.Protect(testEnvelope(), testTopic)
+	require.NoError(t, err)
+	_, err = receiver.Open(received(t, again, testTopic))
+	assert.NoError(t, err)
+
+	// receivers with a cache of their own each open the envelope once
+	subscription := NewReplayCache()
+	_, err = receiver.OpenOnce(received(t, protected, testTopic), subscription)
+	assert.NoError(t, err)
+	_, err = receiver.OpenOnce(received(t, protected, testTopic), subscription)
+	assert.ErrorIs(t, err, ErrReplayed)
+}
+
+func TestProtectorRejectsReplayedEncryptedEnvelopes(t *testing.T) {
+	// encryption alone authenticates the envelope, it expires and is remembered like signed ones
+	options := map[string]string{
+		pkg.PayloadEncryption:     EncryptionAESGCM,
+		pkg.PayloadEncryptionKeys: "e1:" + testKey(t, aesKeySize),
+	}
+	sender, receiver := newTestProtector(t, options), newTestProtector(t, options)
+	assert.Equal(t, DefaultMaxAge, receiver.maxAge)
+
+	protected, err := sender.Protect(testEnvelope(), testTopic)
+	require.NoError(t, err)
+	_, err = receiver.Open(received(t, protected, testTopic))
+	require.NoError(t, err)
+	_, err = receiver.Open(received(t, protected, testTopic))
+	assert.ErrorIs(t, err, ErrReplayed)
+}
+
+func TestProtectorRejectsExpiredEnvelopes(t *testing.T) {
+	// signed envelopes expire after DefaultMaxAge unless PayloadMaxAge is set
+	options := map[string]string{
+		pkg.PayloadSigning:     SigningHMAC,
+		pkg.PayloadSigningKeys: "k1:" + testKey(t, 32),
+	}
+	sender, receiver := newTestProtector(t, options), newTestProtector(t, options)
+	assert.Equal(t, DefaultMaxAge, receiver.maxAge)
+
+	for _, skew := range []time.Duration{-DefaultMaxAge - time.Minute, DefaultMaxAge + time.Minute} {
+		protected, err := sender.Protect(testEnvelope(), testTopic)
+		require.NoError(t, err)
+
+		// sign again with the shifted timestamp, so that only the age is wrong
+		protected.Security.Timestamp = time.Now().Add(skew).UnixNano()
+		mac := hmac.New(sha256.New, sender.hmacKeys[sender.signingKeyID])
+		mac.Write(content(protected, testTopic, protected.Payload.([]byte)))
+		protected.Security.Signature = mac.Sum(nil)
+		_, err = receiver.Open(received(t, protected, testTopic))
+		assert.ErrorIs(t, err, ErrExpired, skew)
+	}
+
+	_, err := NewProtector(map[string]string{pkg.PayloadSigning: SigningHMAC, pkg.PayloadSigningKeys: options[pkg.PayloadSigningKeys], pkg.PayloadMaxAge: "-1s"})
+	assert.Error(t, err)
+}
+
+func TestProtectorVerifiesEd25519Signatures(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sender := newTestProtector(t, map[string]string{
+		pkg.PayloadSigning:     SigningEd25519,
+		pkg.PayloadSigningKeys: "svc:" + base64.StdEncoding.EncodeToString(privateKey.Seed()),
+	})
+	receiver := newTestProtector(t, map[string]string{
+		pkg.PayloadSigning:    SigningEd25519,
+		pkg.PayloadVerifyKeys: "svc:" + base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey)),
+	})
+
+	protected, err := sender.Protect(testEnvelope(), testTopic)
+	require.NoError(t, err)
+	opened, err := receiver.Open(received(t, protected, testTopic))
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"reading":21.5}`), opened.Payload)
+
+	// a receiver holding only the public key can not sign
+	_, err = receiver.Protect(testEnvelope(), testTopic)
+	assert.Error(t, err)
+}
@@ -17,6 +17,7 @@ import (
 	"iiot-backend/pkg/go-mod-messaging/internal/pkg/mqtt"
 	"iiot-backend/pkg/go-mod-messaging/internal/pkg/nats"
 	"iiot-backend/pkg/go-mod-messaging/internal/pkg/nats/jetstream"
+	"iiot-backend/pkg/go-mod-messaging/internal/pkg/payload"
 	"iiot-backend/pkg/go-mod-messaging/pkg/types"
 )
 
@@ -39,14 +40,25 @@ func NewMessageClient(msgConfig types.MessageBusConfig) (MessageClient, error) {
 		return nil, fmt.Errorf("unable to create messageClient: Broker info not set")
 	}
 
+	var client MessageClient
+	var err error
 	switch lowerMsgType := strings.ToLower(msgConfig.Type); lowerMsgType {
 	case MQTT:
-		return mqtt.NewMQTTClient(msgConfig)
+		client, err = mqtt.NewMQTTClient(msgConfig)
 	case NatsCore:
-		return nats.NewClient(msgConfig)
+		client, err = nats.NewClient(msgConfig)
 	case NatsJetStream:
-		return jetstream.NewClient(msgConfig)
+		client, err = jetstream.NewClient(msgConfig)
 	default:
 		return nil, fmt.Errorf("unknown message type '%s' requested", msgConfig.Type)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	// sign and encrypt the payloads when the Optional properties ask for it
+	if payload.IsConfigured(msgConfig.Optional) {
+		return newProtectedClient(client, msgConfig.Optional)
+	}
+	return client, nil
 }
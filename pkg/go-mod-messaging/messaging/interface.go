@@ -55,3 +55,11 @@ type MessageClient interface {
 	// and TopicChannel will also be closed
 	Disconnect() error
 }
+
+// CredentialsUpdater is implemented by message clients that can replace their broker credentials without being
+// re-created, so that rotated secrets take effect without a restart
+type CredentialsUpdater interface {
+	// UpdateCredentials reconnects to the broker with the new username and password. Existing subscriptions are
+	// re-created on the new connection.
+	UpdateCredentials(username string, password string) error
+}
@@ -0,0 +1,227 @@
+//
+//
+//
+//
+// Unless required by applicable law or agreed to in writing, software
+//
+
+package messaging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"iiot-backend/pkg/go-mod-messaging/internal/pkg"
+	"iiot-backend/pkg/go-mod-messaging/internal/pkg/payload"
+	"iiot-backend/pkg/go-mod-messaging/pkg/types"
+)
+
+// PayloadKeysUpdater is implemented by message clients protecting their payloads, so that rotated payload keys take
+// effect without a restart
+type PayloadKeysUpdater interface {
+	// UpdatePayloadKeys replaces the PayloadSigningKeys, PayloadVerifyKeys and PayloadEncryptionKeys options the
+	// payloads are protected with.
+	UpdatePayloadKeys(signingKeys string, verifyKeys string, encryptionKeys string) error
+}
+
+// protectedClient signs and encrypts the envelopes published by the wrapped client and verifies and decrypts the
+// envelopes it receives, forwarding only those that are protected as configured. Binary data is passed through as is.
+type protectedClient struct {
+	MessageClient
+	options map[string]string
+
+	protectorMutex sync.RWMutex
+	protector      *payload.Protector
+
+	subscriptionMutex sync.Mutex
+	subscriptions     map[string][]*forwarder
+}
+
+// forwarder passes the envelopes of one subscribed topic that pass verification on, until done is closed. It keeps
+// the envelopes it received to reject replays, those received by other subscriptions to the same topic being no
+// replays.
+type forwarder struct {
+	done    chan struct{}
+	stopped chan struct{}
+	seen    *payload.ReplayCache
+}
+
+// stop makes the forwarder return and waits for it, so that none of its channels are used afterwards
+func (f *forwarder) stop() {
+	close(f.done)
+	<-f.stopped
+}
+
+// protectedCredentialsClient is a protectedClient whose wrapped client can update its broker credentials
+type protectedCredentialsClient struct {
+	*protectedClient
+	CredentialsUpdater
+}
+
+// newProtectedClient wraps client to protect its payloads as configured by options
+func newProtectedClient(client MessageClient, options map[string]string) (MessageClient, error) {
+	protector, err := payload.NewProtector(options)
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure message payload protection: %w", err)
+	}
+
+	protected := &protectedClient{
+		MessageClient: client,
+		options:       options,
+		protector:     protector,
+		subscriptions: make(map[string][]*forwarder),
+	}
+	if updater, ok := client.(CredentialsUpdater); ok {
+		return &protectedCredentialsClient{protectedClient: protected, CredentialsUpdater: updater}, nil
+	}
+	return protected, nil
+}
+
+func (c *protectedClient) currentProtector() *payload.Protector {
+	c.protectorMutex.RLock()
+	defer c.protectorMutex.RUnlock()
+	return c.protector
+}
+
+// Publish protects the message and publishes it to topic
+func (c *protectedClient) Publish(message types.MessageEnvelope, topic string) error {
+	protected, err := c.currentProtector().Protect(message, topic)
+	if err != nil {
+		return err
+	}
+	return c.MessageClient.Publish(protected, topic)
+}
+
+// PublishWithSizeLimit protects the message and publishes it to topic, the limit applying to the protected message
+func (c *protectedClient) PublishWithSizeLimit(message types.MessageEnvelope, topic string, limit int64) error {
+	protected, err := c.currentProtector().Protect(message, topic)
+	if err != nil {
+		return err
+	}
+	return c.MessageClient.PublishWithSizeLimit(protected, topic, limit)
+}
+
+// Subscribe subscribes to topics, forwarding the received messages that pass verification to the channels of topics
+// and reporting the others to messageErrors, unless it is not ready to receive them
+func (c *protectedClient) Subscribe(topics []types.TopicChannel, messageErrors chan error) error {
+	return c.subscribe(topics, messageErrors, messageErrors)
+}
+
+// subscribe subscribes to topics, reporting the errors of the wrapped client to messageErrors and the rejected
+// messages to rejections without blocking. Rejections are dropped when it is nil.
+func (c *protectedClient) subscribe(topics []types.TopicChannel, messageErrors chan error, rejections chan<- error) error {
+	received := make([]types.TopicChannel, len(topics))
+	for i, topic := range topics {
+		received[i] = types.TopicChannel{Topic: topic.Topic, Messages: make(chan types.MessageEnvelope)}
+	}
+
+	if err := c.MessageClient.Subscribe(received, messageErrors); err != nil {
+		return err
+	}
+
+	c.subscriptionMutex.Lock()
+	defer c.subscriptionMutex.Unlock()
+	for i, topic := range topics {
+		f := &forwarder{done: make(chan struct{}), stopped: make(chan struct{}), seen: payload.NewReplayCache()}
+		c.subscriptions[topic.Topic] = append(c.subscriptions[topic.Topic], f)
+		go c.forward(f, received[i].Messages, topic.Messages, rejections)
+	}
+	return nil
+}
+
+// forward passes the messages of received that pass verification on to messages until f is stopped. The wrapped
+// client owns received, so it is never closed here.
+func (c *protectedClient) forward(f *forwarder, received <-chan types.MessageEnvelope, messages chan<- types.MessageEnvelope, rejections chan<- error) {
+	defer close(f.stopped)
+	for {
+		var message types.MessageEnvelope
+		select {
+		case <-f.done:
+			return
+		case message = <-received:
+		}
+
+		opened, err := c.currentProtector().OpenOnce(message, f.seen)
+		if err != nil {
+			// a flood of forged messages must not stall the subscription
+			select {
+			case rejections <- fmt.Errorf("rejected message received on %s: %w", message.ReceivedTopic, err):
+			default:
+			}
+			continue
+		}
+
+		select {
+		case <-f.done:
+			return
+		case messages <- opened:
+		}
+	}
+}
+
+// Request publishes a protected request and waits for a response that passes verification. Rejected messages on the
+// response topic are dropped rather than failing the request, otherwise anyone able to publish there could abort it.
+func (c *protectedClient) Request(message types.MessageEnvelope, requestTopic string, responseTopicPrefix string, timeout time.Duration) (*types.MessageEnvelope, error) {
+	subscribe := func(topics []types.TopicChannel, messageErrors chan error) error {
+		return c.subscribe(topics, messageErrors, nil)
+	}
+	return pkg.DoRequest(subscribe, c.Unsubscribe, c.Publish, message, requestTopic, responseTopicPrefix, timeout)
+}
+
+// Unsubscribe unsubscribes from topics and stops forwarding their messages
+func (c *protectedClient) Unsubscribe(topics ...string) error {
+	if err := c.MessageClient.Unsubscribe(topics...); err != nil {
+		return err
+	}
+
+	c.subscriptionMutex.Lock()
+	defer c.subscriptionMutex.Unlock()
+	for _, topic := range topics {
+		for _, f := range c.subscriptions[topic] {
+			f.stop()
+		}
+		delete(c.subscriptions, topic)
+	}
+	return nil
+}
+
+// Disconnect closes the connection of the wrapped client and stops forwarding messages
+func (c *protectedClient) Disconnect() error {
+	if err := c.MessageClient.Disconnect(); err != nil {
+		return err
+	}
+
+	c.subscriptionMutex.Lock()
+	defer c.subscriptionMutex.Unlock()
+	for topic, forwarders := range c.subscriptions {
+		for _, f := range forwarders {
+			f.stop()
+		}
+		delete(c.subscriptions, topic)
+	}
+	return nil
+}
+
+// UpdatePayloadKeys protects the payloads with new keys, keeping the other options
+func (c *protectedClient) UpdatePayloadKeys(signingKeys string, verifyKeys string, encryptionKeys string) error {
+	c.protectorMutex.Lock()
+	defer c.protectorMutex.Unlock()
+
+	options := make(map[string]string, len(c.options))
+	for key, value := range c.options {
+		options[key] = value
+	}
+	options[pkg.PayloadSigningKeys] = signingKeys
+	options[pkg.PayloadVerifyKeys] = verifyKeys
+	options[pkg.PayloadEncryptionKeys] = encryptionKeys
+
+	protector, err := payload.NewProtector(options)
+	if err != nil {
+		return err
+	}
+	protector.KeepReplays(c.protector)
+	c.options = options
+	c.protector = protector
+	return nil
+}
@@ -0,0 +1,224 @@
+//
+//
+//
+//
+// Unless required by applicable law or agreed to in writing, software
+//
+
+package messaging
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"iiot-backend/pkg/go-mod-messaging/internal/pkg"
+	"iiot-backend/pkg/go-mod-messaging/internal/pkg/payload"
+	"iiot-backend/pkg/go-mod-messaging/pkg/types"
+)
+
+// fakeBus delivers the envelopes published to a topic to its subscription, calling onPublish first
+type fakeBus struct {
+	MessageClient
+
+	mutex         sync.Mutex
+	subscriptions map[string]chan<- types.MessageEnvelope
+	onPublish     func(message types.MessageEnvelope, topic string)
+}
+
+func newFakeBus() *fakeBus {
+	return &fakeBus{subscriptions: make(map[string]chan<- types.MessageEnvelope)}
+}
+
+func (b *fakeBus) Subscribe(topics []types.TopicChannel, _ chan error) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, topic := range topics {
+		b.subscriptions[topic.Topic] = topic.Messages
+	}
+	return nil
+}
+
+func (b *fakeBus) Unsubscribe(topics ...string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, topic := range topics {
+		delete(b.subscriptions, topic)
+	}
+	return nil
+}
+
+func (b *fakeBus) Publish(message types.MessageEnvelope, topic string) error {
+	if b.onPublish != nil {
+		b.onPublish(message, topic)
+	}
+	return nil
+}
+
+// deliver passes message to the subscription of topic as the broker would
+func (b *fakeBus) deliver(message types.MessageEnvelope, topic string) {
+	b.deliverMatching(message, topic, topic)
+}
+
+// deliverMatching passes message published to topic to the subscription of filter, such as a wildcard matching it
+func (b *fakeBus) deliverMatching(message types.MessageEnvelope, topic string, filter string) {
+	b.mutex.Lock()
+	subscription := b.subscriptions[filter]
+	b.mutex.Unlock()
+	message.ReceivedTopic = topic
+	subscription <- message
+}
+
+func protectionOptions(t *testing.T) map[string]string {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return map[string]string{
+		pkg.PayloadSigning:     payload.SigningHMAC,
+		pkg.PayloadSigningKeys: "k1:" + base64.StdEncoding.EncodeToString(key),
+	}
+}
+
+func protect(t *testing.T, options map[string]string, message types.MessageEnvelope, topic string) types.MessageEnvelope {
+	protector, err := payload.NewProtector(options)
+	require.NoError(t, err)
+	protected, err := protector.Protect(message, topic)
+	require.NoError(t, err)
+	return protected
+}
+
+func TestProtectedClientDropsRejectedMessagesWithoutBlocking(t *testing.T) {
+	options := protectionOptions(t)
+	bus := newFakeBus()
+	client, err := newProtectedClient(bus, options)
+	require.NoError(t, err)
+
+	// nobody reads the errors, a forged message must not stall the subscription
+	messages := make(chan types.MessageEnvelope)
+	require.NoError(t, client.Subscribe([]types.TopicChannel{{Topic: "events", Messages: messages}}, make(chan error)))
+
+	forged := protect(t, protectionOptions(t), types.MessageEnvelope{Payload: []byte("forged")}, "events")
+	genuine := protect(t, options, types.MessageEnvelope{Payload: []byte("genuine")}, "events")
+	go func() {
+		bus.deliver(forged, "events")
+		bus.deliver(forged, "events")
+		bus.deliver(genuine, "events")
+	}()
+
+	select {
+	case message := <-messages:
+		assert.Equal(t, []byte("genuine"), message.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("the genuine message was not forwarded")
+	}
+
+	// replaying the genuine message is rejected as well
+	go bus.deliver(genuine, "events")
+	select {
+	case message := <-messages:
+		t.Fatalf("replayed message was forwarded: %s", message.Payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestProtectedClientForwardsToOverlappingSubscriptions(t *testing.T) {
+	options := protectionOptions(t)
+	bus := newFakeBus()
+	client, err := newProtectedClient(bus, options)
+	require.NoError(t, err)
+
+	device, all := make(chan types.MessageEnvelope), make(chan types.MessageEnvelope)
+	require.NoError(t, client.Subscribe([]types.TopicChannel{{Topic: "events/device-1", Messages: device}}, make(chan error)))
+	require.NoError(t, client.Subscribe([]types.TopicChannel{{Topic: "events/#", Messages: all}}, make(chan error)))
+
+	// the broker delivers the envelope to both subscriptions, neither delivery is a replay
+	genuine := protect(t, options, types.MessageEnvelope{RequestID: "request-1", Payload: []byte("genuine")}, "events/device-1")
+	go func() {
+		bus.deliverMatching(genuine, "events/device-1", "events/device-1")
+		bus.deliverMatching(genuine, "events/device-1", "events/#")
+	}()
+	for _, messages := range []chan types.MessageEnvelope{device, all} {
+		select {
+		case message := <-messages:
+			assert.Equal(t, []byte("genuine"), message.Payload)
+		case <-time.After(time.Second):
+			t.Fatal("the message was not forwarded to every subscription")
+		}
+	}
+
+	// each subscription still rejects the replay
+	go bus.deliverMatching(genuine, "events/device-1", "events/#")
+	select {
+	case message := <-all:
+		t.Fatalf("replayed message was forwarded: %s", message.Payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestProtectedClientReportsRejectedMessages(t *testing.T) {
+	bus := newFakeBus()
+	client, err := newProtectedClient(bus, protectionOptions(t))
+	require.NoError(t, err)
+
+	errs := make(chan error, 1)
+	require.NoError(t, client.Subscribe([]types.TopicChannel{{Topic: "events", Messages: make(chan types.MessageEnvelope)}}, errs))
+	go bus.deliver(types.MessageEnvelope{Payload: []byte("unprotected")}, "events")
+
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, payload.ErrUnprotected)
+	case <-time.After(time.Second):
+		t.Fatal("the rejected message was not reported")
+	}
+}
+
+func TestProtectedClientRequestIgnoresRejectedResponses(t *testing.T) {
+	options := protectionOptions(t)
+	bus := newFakeBus()
+	client, err := newProtectedClient(bus, options)
+	require.NoError(t, err)
+
+	bus.onPublish = func(request types.MessageEnvelope, topic string) {
+		if topic != "commands" {
+			return
+		}
+		responseTopic := "responses/" + request.RequestID
+		response := types.MessageEnvelope{RequestID: request.RequestID, Payload: []byte("genuine")}
+		forged := protect(t, protectionOptions(t), types.MessageEnvelope{RequestID: request.RequestID, Payload: []byte("forged")}, responseTopic)
+		genuine := protect(t, options, response, responseTopic)
+		go func() {
+			bus.deliver(forged, responseTopic)
+			bus.deliver(genuine, responseTopic)
+		}()
+	}
+
+	response, err := client.Request(types.MessageEnvelope{RequestID: "request-1"}, "commands", "responses", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("genuine"), response.Payload)
+}
+
+func TestProtectedClientUnsubscribeStopsForwarding(t *testing.T) {
+	options := protectionOptions(t)
+	bus := newFakeBus()
+	client, err := newProtectedClient(bus, options)
+	require.NoError(t, err)
+
+	// the subscriber stopped reading while a message waits to be forwarded
+	messages := make(chan types.MessageEnvelope)
+	require.NoError(t, client.Subscribe([]types.TopicChannel{{Topic: "events", Messages: messages}}, nil))
+	bus.deliver(protect(t, options, types.MessageEnvelope{Payload: []byte("pending")}, "events"), "events")
+
+	unsubscribed := make(chan error)
+	go func() { unsubscribed <- client.Unsubscribe("events") }()
+	select {
+	case err := <-unsubscribed:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Unsubscribe did not stop the forwarding goroutine")
+	}
+}
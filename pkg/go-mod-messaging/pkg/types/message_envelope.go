@@ -45,6 +45,25 @@ type MessageEnvelope struct {
 	ContentType string `json:"contentType"`
 	// QueryParams is optionally provided key/value pairs.
 	QueryParams map[string]string `json:"queryParams,omitempty"`
+	// Security describes how the envelope is signed and its payload encrypted, nil when it is not protected.
+	Security *EnvelopeSecurity `json:"security,omitempty"`
+}
+
+// EnvelopeSecurity carries the signature of a MessageEnvelope and the keys and algorithms its payload was protected
+// with. The signature covers the topic the envelope was published to, its attributes and its payload.
+type EnvelopeSecurity struct {
+	// Timestamp is when the envelope was protected, in nanoseconds since the epoch.
+	Timestamp int64 `json:"timestamp"`
+	// SignatureAlgorithm is hmac-sha256 or ed25519, empty when the envelope is not signed.
+	SignatureAlgorithm string `json:"signatureAlgorithm,omitempty"`
+	// SignatureKeyID identifies the key the envelope was signed with.
+	SignatureKeyID string `json:"signatureKeyID,omitempty"`
+	// Signature is the signature of the envelope.
+	Signature []byte `json:"signature,omitempty"`
+	// EncryptionAlgorithm is aes-256-gcm, empty when the payload is not encrypted.
+	EncryptionAlgorithm string `json:"encryptionAlgorithm,omitempty"`
+	// EncryptionKeyID identifies the key the payload was encrypted with.
+	EncryptionKeyID string `json:"encryptionKeyID,omitempty"`
 }
 
 // NewMessageEnvelope creates a new MessageEnvelope for the specified payload with attributes from the specified context
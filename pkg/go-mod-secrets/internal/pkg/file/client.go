@@ -0,0 +1,404 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+// Package file implements a SecretClient storing secrets in a local file encrypted with AES-256-GCM, for single
+// node deployments that cannot run OpenBao. Every save adds a new version of the secret, and the services sharing
+// the file are told about secrets changed by the others through WatchSecrets.
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"iiot-backend/pkg/go-mod-secrets/pkg"
+	"iiot-backend/pkg/go-mod-secrets/pkg/types"
+
+	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
+)
+
+const defaultMaxVersions = 10
+
+// Client is the file secret store implementation of secrets.SecretClient and secrets.VersionedSecretClient
+type Client struct {
+	config      types.SecretConfig
+	lc          logger.LoggerClient
+	keys        keySource
+	maxVersions int
+
+	mutex sync.Mutex
+	// key and salt cache the last derived key, deriving it from a passphrase is deliberately slow
+	key  []byte
+	salt []byte
+	// jwtKey caches the JWT signing key derived from key, it is dropped when key changes
+	jwtKey []byte
+	// known holds the current version of each of this service's secrets, to tell which ones other processes changed
+	known   map[string]int
+	modTime time.Time
+}
+
+// NewSecretsClient opens the secrets file configured in config.FileStore, creating it on the first save
+func NewSecretsClient(config types.SecretConfig, lc logger.LoggerClient) (*Client, error) {
+	if config.FileStore.Path == "" {
+		return nil, pkg.NewErrSecretStore("FileStore.Path is required for the file secret store")
+	}
+
+	keys, err := newKeySource(config.FileStore.MasterKeyFile, config.FileStore.Passphrase)
+	if err != nil {
+		return nil, pkg.NewErrSecretStore(err.Error())
+	}
+
+	client := &Client{
+		config:      config,
+		lc:          lc,
+		keys:        keys,
+		maxVersions: config.FileStore.MaxVersions,
+		known:       map[string]int{},
+	}
+	if client.maxVersions <= 0 {
+		client.maxVersions = defaultMaxVersions
+	}
+
+	// Fail fast on a wrong key rather than on the first secret retrieved
+	doc, err := client.load()
+	if err != nil {
+		return nil, err
+	}
+	client.known = client.currentVersions(doc)
+
+	return client, nil
+}
+
+// RetrieveSecret retrieves the current version of the secret at the provided secretName that matches the
+// specified keys.
+func (c *Client) RetrieveSecret(secretName string, keys ...string) (map[string]string, error) {
+	current, err := c.retrieve(secretName, 0)
+	if err != nil {
+		return nil, err
+	}
+	return filterKeys(current.Data, keys)
+}
+
+// SaveSecret stores the data as a new version of the secret at the provided secretName.
+func (c *Client) SaveSecret(secretName string, data map[string]string) error {
+	return c.save(c.config.BasePath, secretName, data)
+}
+
+// SaveServiceSecret stores the data as a new version of the secret of another service sharing the file. That
+// service's WatchSecrets reports the change.
+func (c *Client) SaveServiceSecret(serviceKey string, secretName string, data map[string]string) error {
+	if c.config.BasePath == "" {
+		return pkg.NewErrSecretStore("secrets of other services can only be saved when a StoreName is configured")
+	}
+	return c.save(path.Join(path.Dir(c.config.BasePath), serviceKey), secretName, data)
+}
+
+// SetAuthToken does nothing, the file is opened with the master key or passphrase.
+func (c *Client) SetAuthToken(_ context.Context, _ string) error {
+	return nil
+}
+
+// RetrieveSecretNames retrieves the names of the secrets of this service.
+func (c *Client) RetrieveSecretNames() ([]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	doc, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for secretPath := range doc.Secrets {
+		if name, ok := c.secretName(secretPath); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SecretVersions returns the versions kept for the secret, oldest first.
+func (c *Client) SecretVersions(secretName string) ([]types.SecretVersion, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	doc, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	e, ok := doc.Secrets[c.secretPath(c.config.BasePath, secretName)]
+	if !ok {
+		return nil, pkg.NewErrSecretNameNotFound(secretName)
+	}
+
+	versions := make([]types.SecretVersion, 0, len(e.Versions))
+	for _, v := range e.Versions {
+		versions = append(versions, types.SecretVersion{Version: v.Version, Created: v.Created})
+	}
+	return versions, nil
+}
+
+// RetrieveSecretVersion retrieves the data of a kept version of the secret.
+func (c *Client) RetrieveSecretVersion(secretName string, number int) (map[string]string, error) {
+	v, err := c.retrieve(secretName, number)
+	if err != nil {
+		return nil, err
+	}
+	return copyData(v.Data), nil
+}
+
+// WatchSecrets checks the file every interval and calls onChange with the names of this service's secrets whose
+// current version was changed by another process, until ctx is done.
+func (c *Client) WatchSecrets(ctx context.Context, interval time.Duration, onChange func(secretNames []string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := c.changedSecrets()
+			if err != nil {
+				c.lc.Errorf("unable to check secrets file for changes: %v", err)
+				continue
+			}
+			if len(changed) > 0 {
+				onChange(changed)
+			}
+		}
+	}
+}
+
+// changedSecrets reloads the file when it was modified since the last check and returns the names of the
+// secrets whose current version differs from the one last seen
+func (c *Client) changedSecrets() ([]string, error) {
+	info, err := os.Stat(c.config.FileStore.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if info.ModTime().Equal(c.modTime) {
+		return nil, nil
+	}
+	doc, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	c.modTime = info.ModTime()
+
+	current := c.currentVersions(doc)
+	var changed []string
+	for name, v := range current {
+		if c.known[name] != v {
+			changed = append(changed, name)
+		}
+	}
+	for name := range c.known {
+		if _, ok := current[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	c.known = current
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// retrieve returns a version of the secret, the current one when number is 0
+func (c *Client) retrieve(secretName string, number int) (*version, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	doc, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	e := doc.Secrets[c.secretPath(c.config.BasePath, secretName)]
+	if number == 0 {
+		if current := e.current(); current != nil {
+			return current, nil
+		}
+		return nil, pkg.NewErrSecretNameNotFound(secretName)
+	}
+	if e != nil {
+		for i := range e.Versions {
+			if e.Versions[i].Version == number {
+				return &e.Versions[i], nil
+			}
+		}
+	}
+	return nil, pkg.NewErrSecretNameNotFound(fmt.Sprintf("%s version %d", secretName, number))
+}
+
+// save adds a new version of the secret under basePath, dropping the oldest versions beyond MaxVersions
+func (c *Client) save(basePath string, secretName string, data map[string]string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	unlock, err := lockFile(c.config.FileStore.Path)
+	if err != nil {
+		return pkg.NewErrSecretStore(err.Error())
+	}
+	defer unlock()
+
+	env, err := readEnvelope(c.config.FileStore.Path)
+	if err != nil {
+		return pkg.NewErrSecretStore(err.Error())
+	}
+	doc := newDocument()
+	var salt []byte
+	if env != nil {
+		doc, err = c.open(*env)
+		if err != nil {
+			return err
+		}
+		salt = env.Salt
+	} else if c.keys.kdf() == kdfScrypt {
+		salt = make([]byte, saltSize)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return pkg.NewErrSecretStore(err.Error())
+		}
+	}
+
+	secretPath := c.secretPath(basePath, secretName)
+	e, ok := doc.Secrets[secretPath]
+	if !ok {
+		e = &entry{}
+		doc.Secrets[secretPath] = e
+	}
+	next := 1
+	if current := e.current(); current != nil {
+		next = current.Version + 1
+	}
+	e.Versions = append(e.Versions, version{Version: next, Created: time.Now().UnixMilli(), Data: copyData(data)})
+	if len(e.Versions) > c.maxVersions {
+		e.Versions = e.Versions[len(e.Versions)-c.maxVersions:]
+	}
+
+	key, err := c.derive(c.keys.kdf(), salt)
+	if err != nil {
+		return pkg.NewErrSecretStore(err.Error())
+	}
+	contents, err := sealDocument(doc, key, c.keys.kdf(), salt)
+	if err != nil {
+		return pkg.NewErrSecretStore(fmt.Sprintf("unable to encrypt secrets file: %v", err))
+	}
+	if err := writeAtomic(c.config.FileStore.Path, contents); err != nil {
+		return pkg.NewErrSecretStore(err.Error())
+	}
+
+	// Our own saves are not changes for WatchSecrets to report
+	if name, ok := c.secretName(secretPath); ok {
+		c.known[name] = next
+	}
+	return nil
+}
+
+// load reads and decrypts the file, an absent file is an empty store. The caller must hold the mutex.
+func (c *Client) load() (*document, error) {
+	env, err := readEnvelope(c.config.FileStore.Path)
+	if err != nil {
+		return nil, pkg.NewErrSecretStore(err.Error())
+	}
+	if env == nil {
+		return newDocument(), nil
+	}
+	return c.open(*env)
+}
+
+func (c *Client) open(env envelope) (*document, error) {
+	key, err := c.derive(env.KDF, env.Salt)
+	if err != nil {
+		return nil, pkg.NewErrSecretStore(err.Error())
+	}
+	doc, err := openDocument(env, key)
+	if err != nil {
+		return nil, pkg.NewErrSecretStore(err.Error())
+	}
+	return doc, nil
+}
+
+// derive returns the key for the salt, reusing the last derived key when the salt did not change
+func (c *Client) derive(kdf string, salt []byte) ([]byte, error) {
+	if c.key != nil && bytes.Equal(c.salt, salt) && kdf == c.keys.kdf() {
+		return c.key, nil
+	}
+	key, err := c.keys.key(kdf, salt)
+	if err != nil {
+		return nil, err
+	}
+	c.key, c.salt, c.jwtKey = key, salt, nil
+	return key, nil
+}
+
+// currentVersions returns the current version of each of this service's secrets
+func (c *Client) currentVersions(doc *document) map[string]int {
+	versions := map[string]int{}
+	for secretPath, e := range doc.Secrets {
+		if name, ok := c.secretName(secretPath); ok {
+			if current := e.current(); current != nil {
+				versions[name] = current.Version
+			}
+		}
+	}
+	return versions
+}
+
+func (c *Client) secretPath(basePath string, secretName string) string {
+	return path.Join("/", basePath, secretName)
+}
+
+// secretName returns the name of a secret of this service from its path
+func (c *Client) secretName(secretPath string) (string, bool) {
+	prefix := strings.TrimSuffix(path.Join("/", c.config.BasePath), "/") + "/"
+	if !strings.HasPrefix(secretPath, prefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(secretPath, prefix)
+	return name, name != ""
+}
+
+func filterKeys(data map[string]string, keys []string) (map[string]string, error) {
+	// Do not filter any of the secrets
+	if len(keys) <= 0 {
+		return copyData(data), nil
+	}
+
+	values := make(map[string]string)
+	var notFound []string
+	for _, key := range keys {
+		value, ok := data[key]
+		if !ok {
+			notFound = append(notFound, key)
+			continue
+		}
+		values[key] = value
+	}
+
+	if len(notFound) > 0 {
+		return nil, pkg.NewErrSecretsNotFound(notFound)
+	}
+	return values, nil
+}
+
+func copyData(data map[string]string) map[string]string {
+	result := make(map[string]string, len(data))
+	for key, value := range data {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,159 @@
+package file
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
+	"iiot-backend/pkg/go-mod-secrets/pkg/types"
+)
+
+func writeMasterKey(t *testing.T, dir string) string {
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "master.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)), 0600))
+	return keyFile
+}
+
+func newTestClient(t *testing.T, storePath string, info types.FileStoreInfo, serviceKey string) *Client {
+	info.Path = storePath
+	client, err := NewSecretsClient(types.SecretConfig{BasePath: "/v1/secret/iiot/" + serviceKey, FileStore: info}, logger.NewMockClient())
+	require.NoError(t, err)
+	return client
+}
+
+func TestFileStoreEncryptsSecrets(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "secrets.json")
+	info := types.FileStoreInfo{MasterKeyFile: writeMasterKey(t, dir)}
+
+	client := newTestClient(t, storePath, info, "core-metadata")
+	require.NoError(t, client.SaveSecret("mqtt", map[string]string{"username": "edge", "password": "s3cr3t-value"}))
+
+	contents, err := os.ReadFile(storePath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(contents), "s3cr3t-value")
+	assert.NotContains(t, string(contents), "mqtt")
+
+	otherKey := types.FileStoreInfo{Path: storePath, MasterKeyFile: writeMasterKey(t, t.TempDir())}
+	_, err = NewSecretsClient(types.SecretConfig{BasePath: "/v1/secret/iiot/core-metadata", FileStore: otherKey}, logger.NewMockClient())
+	assert.Error(t, err, "a different master key must not open the file")
+
+	reopened := newTestClient(t, storePath, info, "core-metadata")
+	data, err := reopened.RetrieveSecret("mqtt", "password")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"password": "s3cr3t-value"}, data)
+}
+
+func TestFileStoreRejectsModifiedHeader(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "secrets.json")
+	client := newTestClient(t, storePath, types.FileStoreInfo{Passphrase: "correct horse"}, "core-data")
+	require.NoError(t, client.SaveSecret("db", map[string]string{"password": "pg"}))
+
+	contents, err := os.ReadFile(storePath)
+	require.NoError(t, err)
+	var env envelope
+	require.NoError(t, json.Unmarshal(contents, &env))
+	env.Salt[0] ^= 0xff
+	tampered, err := json.Marshal(env)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(storePath, tampered, 0600))
+
+	_, err = NewSecretsClient(types.SecretConfig{BasePath: "/v1/secret/iiot/core-data", FileStore: types.FileStoreInfo{Path: storePath, Passphrase: "correct horse"}}, logger.NewMockClient())
+	assert.Error(t, err)
+}
+
+func TestFileStoreRotatesVersions(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "secrets.json")
+	info := types.FileStoreInfo{MasterKeyFile: writeMasterKey(t, dir), MaxVersions: 2}
+	client := newTestClient(t, storePath, info, "core-metadata")
+
+	for _, password := range []string{"first", "second", "third"} {
+		require.NoError(t, client.SaveSecret("mqtt", map[string]string{"password": password}))
+	}
+
+	data, err := client.RetrieveSecret("mqtt")
+	require.NoError(t, err)
+	assert.Equal(t, "third", data["password"])
+
+	versions, err := client.SecretVersions("mqtt")
+	require.NoError(t, err)
+	require.Len(t, versions, 2, "versions beyond MaxVersions are dropped")
+	assert.Equal(t, 2, versions[0].Version)
+	assert.Equal(t, 3, versions[1].Version)
+
+	previous, err := client.RetrieveSecretVersion("mqtt", 2)
+	require.NoError(t, err)
+	assert.Equal(t, "second", previous["password"])
+	_, err = client.RetrieveSecretVersion("mqtt", 1)
+	assert.Error(t, err)
+}
+
+func TestFileStoreReportsRotationsByOtherServices(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "secrets.json")
+	info := types.FileStoreInfo{MasterKeyFile: writeMasterKey(t, dir)}
+
+	metadata := newTestClient(t, storePath, info, "core-metadata")
+	require.NoError(t, metadata.SaveSecret("mqtt", map[string]string{"password": "first"}))
+	changed, err := metadata.changedSecrets()
+	require.NoError(t, err)
+	assert.Empty(t, changed, "a service's own saves are not reported")
+
+	security := newTestClient(t, storePath, info, "security-secretstore-setup")
+	require.NoError(t, security.SaveServiceSecret("core-metadata", "mqtt", map[string]string{"password": "rotated"}))
+
+	changed, err = metadata.changedSecrets()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mqtt"}, changed)
+	data, err := metadata.RetrieveSecret("mqtt")
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", data["password"])
+}
+
+func TestFileStoreJWTs(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "secrets.json")
+	info := types.FileStoreInfo{Passphrase: "correct horse"}
+	client := newTestClient(t, storePath, info, "core-metadata")
+	require.NoError(t, client.SaveSecret("db", map[string]string{"password": "pg"}))
+
+	// every service sharing the file accepts the JWTs of the others
+	token, err := newTestClient(t, storePath, info, "device-opcua").GetSelfJWT("device-opcua")
+	require.NoError(t, err)
+	valid, err := client.IsJWTValid(token)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	// the file is recreated with a new salt by another process, the JWTs signed before no longer validate
+	require.NoError(t, os.Remove(storePath))
+	recreated := newTestClient(t, storePath, info, "device-opcua")
+	require.NoError(t, recreated.SaveSecret("db", map[string]string{"password": "pg"}))
+	valid, err = client.IsJWTValid(token)
+	require.NoError(t, err)
+	assert.False(t, valid, "a JWT signed with the key of the previous salt must be rejected")
+	token, err = recreated.GetSelfJWT("device-opcua")
+	require.NoError(t, err)
+	valid, err = client.IsJWTValid(token)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	other := newTestClient(t, filepath.Join(dir, "other.json"), info, "device-opcua")
+	require.NoError(t, other.SaveSecret("db", map[string]string{"password": "pg"}))
+	forged, err := other.GetSelfJWT("device-opcua")
+	require.NoError(t, err)
+	valid, err = client.IsJWTValid(forged)
+	require.NoError(t, err)
+	assert.False(t, valid, "a JWT signed for another secrets file must be rejected")
+}
@@ -0,0 +1,79 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+package file
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"iiot-backend/pkg/go-mod-secrets/pkg"
+)
+
+const (
+	jwtIssuer = "file-secret-store"
+	jwtTTL    = 15 * time.Minute
+)
+
+// GetSelfJWT returns a JWT identifying the service, signed with a key derived from the file key so that every
+// service sharing the secrets file accepts it.
+func (c *Client) GetSelfJWT(serviceKey string) (string, error) {
+	key, err := c.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    jwtIssuer,
+		Subject:   serviceKey,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(jwtTTL)),
+	})
+	return token.SignedString(key)
+}
+
+// IsJWTValid reports whether the JWT was issued by a service sharing the secrets file and has not expired.
+func (c *Client) IsJWTValid(token string) (bool, error) {
+	key, err := c.signingKey()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(jwtIssuer))
+	return err == nil, nil
+}
+
+// signingKey derives the JWT signing key from the file key, so the file key itself never signs anything. The file
+// is read on every call, as another process may have rewritten it with a new salt, but the key is only derived
+// again when the salt changed.
+func (c *Client) signingKey() ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	env, err := readEnvelope(c.config.FileStore.Path)
+	if err != nil {
+		return nil, pkg.NewErrSecretStore(err.Error())
+	}
+	if env == nil {
+		return nil, pkg.NewErrSecretStore("the secrets file does not exist yet, save a secret first")
+	}
+	// derive drops the cached signing key when the salt differs from the one it was derived with
+	key, err := c.derive(env.KDF, env.Salt)
+	if err != nil {
+		return nil, pkg.NewErrSecretStore(err.Error())
+	}
+	if c.jwtKey != nil {
+		return c.jwtKey, nil
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(jwtIssuer))
+	c.jwtKey = mac.Sum(nil)
+	return c.jwtKey, nil
+}
@@ -0,0 +1,292 @@
+/*******************************************************************************
+ *******************************************************************************/
+
+package file
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	formatVersion = 1
+	keySize       = 32
+	saltSize      = 16
+
+	kdfNone   = "none"
+	kdfScrypt = "scrypt"
+
+	// scrypt parameters recommended for interactive logins, deriving the key takes well under a second
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	lockRetryInterval = 50 * time.Millisecond
+	lockTimeout       = 10 * time.Second
+	// staleLockAge is the age after which a lock left behind by a crashed process is removed
+	staleLockAge = 30 * time.Second
+)
+
+// envelope is the on-disk format of the secrets file. Only the document is encrypted, the header says how to
+// derive the key.
+type envelope struct {
+	Format     int    `json:"format"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// document is the decrypted content of the secrets file, keyed by the full path of each secret
+type document struct {
+	Secrets map[string]*entry `json:"secrets"`
+}
+
+// entry holds the kept versions of a secret, oldest first
+type entry struct {
+	Versions []version `json:"versions"`
+}
+
+type version struct {
+	Version int               `json:"version"`
+	Created int64             `json:"created"`
+	Data    map[string]string `json:"data"`
+}
+
+func newDocument() *document {
+	return &document{Secrets: map[string]*entry{}}
+}
+
+// current returns the current version of the secret, or nil when the secret does not exist
+func (e *entry) current() *version {
+	if e == nil || len(e.Versions) == 0 {
+		return nil
+	}
+	return &e.Versions[len(e.Versions)-1]
+}
+
+// keySource derives the file key from either a master key or a passphrase
+type keySource struct {
+	masterKey  []byte
+	passphrase string
+}
+
+func newKeySource(masterKeyFile string, passphrase string) (keySource, error) {
+	if masterKeyFile != "" {
+		contents, err := os.ReadFile(masterKeyFile)
+		if err != nil {
+			return keySource{}, fmt.Errorf("unable to read master key file: %w", err)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(contents)))
+		if err != nil {
+			return keySource{}, fmt.Errorf("master key file must hold a base64 encoded key: %w", err)
+		}
+		if len(key) != keySize {
+			return keySource{}, fmt.Errorf("master key must be %d bytes, got %d", keySize, len(key))
+		}
+		return keySource{masterKey: key}, nil
+	}
+
+	if passphrase == "" {
+		return keySource{}, errors.New("the file secret store requires a MasterKeyFile or a Passphrase")
+	}
+	return keySource{passphrase: passphrase}, nil
+}
+
+func (k keySource) kdf() string {
+	if k.masterKey != nil {
+		return kdfNone
+	}
+	return kdfScrypt
+}
+
+// key returns the encryption key of a file written with the given key derivation and salt
+func (k keySource) key(kdf string, salt []byte) ([]byte, error) {
+	if kdf != k.kdf() {
+		return nil, fmt.Errorf("secrets file was written with key derivation '%s' but '%s' is configured", kdf, k.kdf())
+	}
+	if k.masterKey != nil {
+		return k.masterKey, nil
+	}
+	return scrypt.Key([]byte(k.passphrase), salt, scryptN, scryptR, scryptP, keySize)
+}
+
+// sealDocument encrypts the document with AES-256-GCM. The header is bound to the ciphertext as additional data
+// so it cannot be swapped.
+func sealDocument(doc *document, key []byte, kdf string, salt []byte) ([]byte, error) {
+	plaintext, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	env := envelope{Format: formatVersion, KDF: kdf, Salt: salt, Nonce: nonce}
+	env.Ciphertext = aead.Seal(nil, nonce, plaintext, additionalData(env))
+	return json.MarshalIndent(env, "", "  ")
+}
+
+// openDocument decrypts a secrets file
+func openDocument(env envelope, key []byte) (*document, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, additionalData(env))
+	if err != nil {
+		return nil, errors.New("unable to decrypt secrets file, the master key or passphrase is wrong or the file was modified")
+	}
+
+	doc := newDocument()
+	if err := json.Unmarshal(plaintext, doc); err != nil {
+		return nil, fmt.Errorf("unable to parse decrypted secrets file: %w", err)
+	}
+	if doc.Secrets == nil {
+		doc.Secrets = map[string]*entry{}
+	}
+	return doc, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func additionalData(env envelope) []byte {
+	return []byte(fmt.Sprintf("%d:%s:%s", env.Format, env.KDF, base64.StdEncoding.EncodeToString(env.Salt)))
+}
+
+func readEnvelope(path string) (*envelope, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read secrets file: %w", err)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(contents, &env); err != nil {
+		return nil, fmt.Errorf("unable to parse secrets file: %w", err)
+	}
+	if env.Format != formatVersion {
+		return nil, fmt.Errorf("unsupported secrets file format %d", env.Format)
+	}
+	return &env, nil
+}
+
+// writeAtomic replaces the file in a single rename so readers never see a partial write
+func writeAtomic(path string, contents []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("unable to create secrets directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to write secrets file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write secrets file: %w", err)
+	}
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write secrets file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write secrets file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write secrets file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// lockFile serializes writers across every process sharing the secrets file. The lock file holds a token naming
+// its owner, the returned function releases the lock unless another process removed it as stale.
+func lockFile(path string) (func(), error) {
+	lockPath := path + ".lock"
+	owner := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, owner); err != nil {
+		return nil, fmt.Errorf("unable to lock secrets file: %w", err)
+	}
+	token := fmt.Sprintf("%d:%x", os.Getpid(), owner)
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = lock.WriteString(token)
+			if closeErr := lock.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return nil, fmt.Errorf("unable to lock secrets file: %w", err)
+			}
+			return func() { unlockFile(lockPath, token) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("unable to lock secrets file: %w", err)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			if stale, err := os.ReadFile(lockPath); err == nil {
+				breakStaleLock(lockPath, token, stale)
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for secrets file lock %s", lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// breakStaleLock removes the stale lock left behind by a crashed process, whose contents were read as stale. The
+// lock is moved aside in a single rename, which only one of the processes waiting for it can do, and put back when
+// it turns out that another process took the lock after it was found stale.
+func breakStaleLock(lockPath string, token string, stale []byte) {
+	asidePath := lockPath + "." + strings.ReplaceAll(token, ":", "-") + ".stale"
+	if err := os.Rename(lockPath, asidePath); err != nil {
+		return
+	}
+	defer os.Remove(asidePath)
+
+	if moved, err := os.ReadFile(asidePath); err == nil && string(moved) != string(stale) {
+		// Link does not replace a lock taken in the meantime
+		_ = os.Link(asidePath, lockPath)
+	}
+}
+
+// unlockFile removes the lock file while it still holds the owner's token
+func unlockFile(lockPath string, token string) {
+	if current, err := os.ReadFile(lockPath); err == nil && string(current) == token {
+		os.Remove(lockPath)
+	}
+}
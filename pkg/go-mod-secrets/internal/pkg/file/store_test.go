@@ -0,0 +1,52 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockFileBreaksStaleLock(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "secrets.json")
+	lockPath := storePath + ".lock"
+	require.NoError(t, os.WriteFile(lockPath, []byte("crashed"), 0600))
+	stale := time.Now().Add(-2 * staleLockAge)
+	require.NoError(t, os.Chtimes(lockPath, stale, stale))
+
+	unlock, err := lockFile(storePath)
+	require.NoError(t, err)
+	owner, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	assert.NotEqual(t, "crashed", string(owner))
+
+	unlock()
+	_, err = os.Stat(lockPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBreakStaleLockRestoresLockTakenInTheMeantime(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "secrets.json.lock")
+	require.NoError(t, os.WriteFile(lockPath, []byte("fresh-owner"), 0600))
+
+	// the lock found stale was replaced by another process's lock before it was moved aside
+	breakStaleLock(lockPath, "my-token", []byte("crashed"))
+
+	owner, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	assert.Equal(t, "fresh-owner", string(owner))
+}
+
+func TestUnlockKeepsLockOfAnotherOwner(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "secrets.json.lock")
+	require.NoError(t, os.WriteFile(lockPath, []byte("other-owner"), 0600))
+
+	unlockFile(lockPath, "my-token")
+
+	owner, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	assert.Equal(t, "other-owner", string(owner))
+}
@@ -25,6 +25,8 @@ type SecretConfig struct {
 	Authentication AuthenticationInfo
 	// RuntimeTokenProvider could be optional if not using delayed start from a runtime token provider
 	RuntimeTokenProvider RuntimeTokenProviderInfo
+	// FileStore is only used by the encrypted local file secret store
+	FileStore FileStoreInfo
 }
 
 // BuildURL constructs a URL which can be used to identify a HTTP based secret provider
@@ -67,6 +69,22 @@ type RuntimeTokenProviderInfo struct {
 	RequiredSecrets string
 }
 
+// FileStoreInfo contains the settings of the encrypted local file secret store, used on single node deployments
+// that cannot run OpenBao
+type FileStoreInfo struct {
+	// Path is the location of the encrypted secrets file. Services sharing the file see each other's rotations.
+	Path string
+	// MasterKeyFile is the location of a file holding the base64 encoded 32 byte master key. It takes precedence
+	// over Passphrase.
+	MasterKeyFile string
+	// Passphrase the key is derived from when no MasterKeyFile is set
+	Passphrase string
+	// MaxVersions is the number of versions kept for each secret, including the current one
+	MaxVersions int
+	// WatchInterval is how often the file is checked for secrets changed by other services, i.e. "5s"
+	WatchInterval string
+}
+
 func (provider RuntimeTokenProviderInfo) BuildProviderURL(path string) (string, error) {
 	return buildURL(provider.Protocol, provider.Host, path, provider.Port)
 }
@@ -43,3 +43,9 @@ type EntityMetadata struct {
 	Name     string   `json:"name"`
 	Policies []string `json:"policies"`
 }
+
+// SecretVersion describes one stored version of a secret
+type SecretVersion struct {
+	Version int   `json:"version"`
+	Created int64 `json:"created"`
+}
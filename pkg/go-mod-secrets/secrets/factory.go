@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 
+	"iiot-backend/pkg/go-mod-secrets/internal/pkg/file"
 	"iiot-backend/pkg/go-mod-secrets/internal/pkg/openbao"
 	"iiot-backend/pkg/go-mod-secrets/pkg"
 	"iiot-backend/pkg/go-mod-secrets/pkg/types"
@@ -16,6 +17,9 @@ import (
 
 const DefaultSecretStore = "openbao"
 
+// FileSecretStore is the encrypted local file secret store, for single node deployments that cannot run OpenBao
+const FileSecretStore = "file"
+
 // NewSecretsClient creates a new instance of a SecretClient based on the passed in configuration.
 // The SecretClient allows access to secret(s) for the configured token.
 func NewSecretsClient(ctx context.Context, config types.SecretConfig, lc logger.LoggerClient, callback pkg.TokenExpiredCallback) (SecretClient, error) {
@@ -23,12 +27,12 @@ func NewSecretsClient(ctx context.Context, config types.SecretConfig, lc logger.
 		return nil, pkg.NewErrSecretStore("background ctx is required and cannot be nil")
 	}
 
-	// Currently only have one secret store type implementation, so no need to have/check type.
-
 	switch config.Type {
-	// Currently only have one secret store type implementation, so type isn't actual set in configuration
 	case DefaultSecretStore:
 		return openbao.NewSecretsClient(ctx, config, lc, callback)
+	case FileSecretStore:
+		// There is no token to expire, the file is opened with the master key or passphrase
+		return file.NewSecretsClient(config, lc)
 	default:
 		return nil, fmt.Errorf("invalid secrets client type of '%s'", config.Type)
 	}
@@ -5,6 +5,7 @@ package secrets
 
 import (
 	"context"
+	"time"
 
 	"iiot-backend/pkg/go-mod-secrets/pkg/types"
 )
@@ -37,6 +38,25 @@ type SecretClient interface {
 	IsJWTValid(jwt string) (bool, error)
 }
 
+// VersionedSecretClient is implemented by secret clients whose store keeps previous versions of every secret and is
+// shared by several services, such as the file secret store.
+type VersionedSecretClient interface {
+	SecretClient
+
+	// SecretVersions returns the versions kept for the secret, oldest first. The last one is the current version.
+	SecretVersions(secretName string) ([]types.SecretVersion, error)
+
+	// RetrieveSecretVersion retrieves the data of a kept version of the secret.
+	RetrieveSecretVersion(secretName string, version int) (map[string]string, error)
+
+	// SaveServiceSecret stores a new version of a secret belonging to another service sharing the store.
+	SaveServiceSecret(serviceKey string, secretName string, data map[string]string) error
+
+	// WatchSecrets calls onChange with the names of the secrets changed by other processes sharing the store,
+	// checking every interval until ctx is done.
+	WatchSecrets(ctx context.Context, interval time.Duration, onChange func(secretNames []string))
+}
+
 // SecretStoreClient provides a contract for managing a Secret Store from a secret store provider.
 type SecretStoreClient interface {
 	HealthCheck() (int, error)
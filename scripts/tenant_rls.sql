@@ -0,0 +1,43 @@
+-- Optional row-level security for tenant isolation
+-- The backend enforces tenants in its queries. Apply this script with psql to
+-- also confine other database clients, such as reporting or BI connections,
+-- to one tenant. Each such client logs in with its own role, which does not
+-- own the tables and is mapped to its tenant by the table owner:
+--
+--   CREATE ROLE plant_a_reporting LOGIN PASSWORD '...';
+--   GRANT SELECT ON ALL TABLES IN SCHEMA public TO plant_a_reporting;
+--   INSERT INTO tenant_roles (role_name, tenant_id) VALUES ('plant_a_reporting', 'plant-a');
+--
+-- The tenant follows from the role the session logged in with, which unlike a
+-- session setting cannot be changed by the client. Roles without a mapping see
+-- no rows. Table owners, and with them the backend, bypass these policies.
+
+CREATE TABLE IF NOT EXISTS tenant_roles (
+    role_name NAME PRIMARY KEY,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(name) ON DELETE CASCADE
+);
+REVOKE ALL ON tenant_roles FROM PUBLIC;
+
+-- session_tenant runs with the rights of its owner so that clients need no access to
+-- tenant_roles. It looks up session_user, the login role, which SET ROLE does not change.
+CREATE OR REPLACE FUNCTION session_tenant() RETURNS VARCHAR
+    LANGUAGE sql STABLE SECURITY DEFINER
+    SET search_path = pg_catalog, public
+AS $$
+    SELECT tenant_id FROM public.tenant_roles WHERE role_name = session_user
+$$;
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['device_services', 'device_profiles', 'devices', 'asset_nodes', 'events',
+        'readings', 'intervals', 'interval_actions', 'notifications', 'subscriptions', 'rules',
+        'pipelines', 'users']
+    LOOP
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %I', t);
+        EXECUTE format('CREATE POLICY tenant_isolation ON %I USING (tenant_id = session_tenant())', t);
+    END LOOP;
+END
+$$;
@@ -0,0 +1,86 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package application
+
+import (
+	"net/http"
+	"time"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	commandContainer "iiot-backend/services/core/command/container"
+	"iiot-backend/services/core/command/infrastructure/interfaces"
+)
+
+// NewCommandAudit starts the audit record of a command, measuring latency from now
+func NewCommandAudit(source models.CommandAuditSource, caller, deviceName, commandName, method, queryParams string, settings map[string]any, correlationId string) models.CommandAudit {
+	return models.CommandAudit{
+		Source:        source,
+		Caller:        caller,
+		DeviceName:    deviceName,
+		CommandName:   commandName,
+		Method:        method,
+		QueryParams:   queryParams,
+		Settings:      settings,
+		CorrelationId: correlationId,
+		Created:       time.Now().UnixMilli(),
+	}
+}
+
+// RecordCommandAudit completes the audit record with the outcome of the command and stores it. A failure to
+// store the record is logged and does not affect the command. Nothing is recorded when no database is configured.
+func RecordCommandAudit(audit models.CommandAudit, statusCode int, commandErr error, dic *di.Container) {
+	dbClient := commandContainer.DBClientFrom(dic.Get)
+	if dbClient == nil {
+		return
+	}
+
+	audit.Latency = time.Now().UnixMilli() - audit.Created
+	audit.StatusCode = statusCode
+	if commandErr != nil {
+		audit.ErrorMessage = commandErr.Error()
+		if statusCode == 0 {
+			audit.StatusCode = http.StatusInternalServerError
+			if iiotErr, ok := commandErr.(errors.IIOT); ok && iiotErr.Code() != 0 {
+				audit.StatusCode = iiotErr.Code()
+			}
+		}
+	}
+
+	if err := dbClient.AddCommandAudit(audit); err != nil {
+		lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+		lc.Errorf("Failed to record audit of command %s of device %s: %v", audit.CommandName, audit.DeviceName, err)
+	}
+}
+
+// CommandAudits query command audit records by filter, offset and limit
+func CommandAudits(filter interfaces.CommandAuditFilter, offset int, limit int, dic *di.Container) (audits []dtos.CommandAudit, totalCount uint32, err errors.IIOT) {
+	dbClient := commandContainer.DBClientFrom(dic.Get)
+	if dbClient == nil {
+		return audits, totalCount, errors.NewCommonIIOT(errors.KindServiceUnavailable, "command audit database is not configured", nil)
+	}
+
+	auditModels, totalCount, err := dbClient.CommandAudits(filter, offset, limit)
+	if err != nil {
+		return audits, totalCount, errors.NewCommonIIOTWrapper(err)
+	}
+	return dtos.FromCommandAuditModelsToDTOs(auditModels), totalCount, nil
+}
+
+// PurgeCommandAuditsByAge removes command audit records older than age milliseconds
+func PurgeCommandAuditsByAge(age int64, dic *di.Container) errors.IIOT {
+	dbClient := commandContainer.DBClientFrom(dic.Get)
+	if dbClient == nil {
+		return errors.NewCommonIIOT(errors.KindServiceUnavailable, "command audit database is not configured", nil)
+	}
+
+	if err := dbClient.DeleteCommandAuditsByAge(age); err != nil {
+		return errors.NewCommonIIOTWrapper(err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	commonDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/common"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/requests"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	commandContainer "iiot-backend/services/core/command/container"
+)
+
+// Defaults applied when the BatchCommand configuration leaves a value unset
+const (
+	defaultBatchConcurrency    = 8
+	defaultBatchMaxConcurrency = 64
+	defaultBatchTimeout        = 10 * time.Second
+	defaultBatchMaxDevices     = 1000
+)
+
+// IssueBatchCommand issues the get or set command of the request to every selected device, at most the requested
+// concurrency at a time, and returns one result per device in the order the devices were selected. Every device
+// command is audited with the given source, caller and correlation id.
+func IssueBatchCommand(req requests.BatchCommandRequest, source models.CommandAuditSource, caller string, correlationId string, dic *di.Container) ([]dtos.BatchCommandResult, errors.IIOT) {
+	batchConfig := commandContainer.ConfigurationFrom(dic.Get).Writable.BatchCommand
+
+	concurrency := batchConfig.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	maxConcurrency := batchConfig.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultBatchMaxConcurrency
+	}
+	if req.Concurrency > 0 {
+		concurrency = req.Concurrency
+	}
+	if concurrency > maxConcurrency {
+		return nil, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("concurrency %d exceeds the maximum of %d", concurrency, maxConcurrency), nil)
+	}
+
+	timeout := defaultBatchTimeout
+	if batchConfig.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(batchConfig.Timeout); err != nil {
+			return nil, errors.NewCommonIIOT(errors.KindServerError, "invalid BatchCommand.Timeout configuration value", err)
+		}
+	}
+	if req.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(req.Timeout); err != nil {
+			return nil, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("invalid timeout %s", req.Timeout), err)
+		}
+	}
+
+	deviceNames, err := batchDeviceNames(req, dic)
+	if err != nil {
+		return nil, err
+	}
+	maxDevices := batchConfig.MaxDevices
+	if maxDevices <= 0 {
+		maxDevices = defaultBatchMaxDevices
+	}
+	if len(deviceNames) > maxDevices {
+		return nil, errors.NewCommonIIOT(errors.KindLimitExceeded, fmt.Sprintf("batch selects %d devices, more than the maximum of %d", len(deviceNames), maxDevices), nil)
+	}
+
+	queryParams := url.Values{}
+	for key, value := range req.QueryParams {
+		queryParams.Set(key, value)
+	}
+	rawQuery := queryParams.Encode()
+
+	results := make([]dtos.BatchCommandResult, len(deviceNames))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, deviceName := range deviceNames {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, deviceName string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			results[i] = issueBatchDeviceCommand(ctx, req, deviceName, rawQuery, source, caller, correlationId, dic)
+		}(i, deviceName)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// batchDeviceNames resolves the device selector of the request, dropping duplicates
+func batchDeviceNames(req requests.BatchCommandRequest, dic *di.Container) ([]string, errors.IIOT) {
+	if len(req.DeviceNames) > 0 {
+		return uniqueNames(req.DeviceNames), nil
+	}
+
+	dc := bootstrapContainer.DeviceClientFrom(dic.Get)
+	if dc == nil {
+		return nil, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceClient returned", nil)
+	}
+
+	var names []string
+	if len(req.Labels) > 0 {
+		devicesResponse, err := dc.AllDevices(context.Background(), req.Labels, 0, -1)
+		if err != nil {
+			return nil, errors.NewCommonIIOTWrapper(err)
+		}
+		for _, device := range devicesResponse.Devices {
+			names = append(names, device.Name)
+		}
+	} else {
+		devicesResponse, err := dc.DevicesByProfileName(context.Background(), req.ProfileName, 0, -1)
+		if err != nil {
+			return nil, errors.NewCommonIIOTWrapper(err)
+		}
+		for _, device := range devicesResponse.Devices {
+			names = append(names, device.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.NewCommonIIOT(errors.KindEntityDoesNotExist, "no devices match the batch selector", nil)
+	}
+	return uniqueNames(names), nil
+}
+
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	unique := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		unique = append(unique, name)
+	}
+	return unique
+}
+
+// issueBatchDeviceCommand issues the batch command to one device and audits it
+func issueBatchDeviceCommand(ctx context.Context, req requests.BatchCommandRequest, deviceName string, queryParams string, source models.CommandAuditSource, caller string, correlationId string, dic *di.Container) dtos.BatchCommandResult {
+	result := dtos.BatchCommandResult{DeviceName: deviceName}
+
+	var statusCode int
+	var response any
+	var err errors.IIOT
+	if req.Method == "set" {
+		audit := NewCommandAudit(source, caller, deviceName, req.CommandName, http.MethodPut, queryParams, req.Settings, correlationId)
+		var setResponse commonDTO.BaseResponse
+		setResponse, err = issueSetCommandByName(ctx, deviceName, req.CommandName, queryParams, req.Settings, dic)
+		if err == nil {
+			statusCode, response = setResponse.StatusCode, setResponse
+		}
+		RecordCommandAudit(audit, statusCode, err, dic)
+	} else {
+		audit := NewCommandAudit(source, caller, deviceName, req.CommandName, http.MethodGet, queryParams, nil, correlationId)
+		var getResponse *responses.EventResponse
+		getResponse, err = issueGetCommandByName(ctx, deviceName, req.CommandName, queryParams, dic)
+		if err == nil {
+			// no event is returned when ds-returnevent is false
+			statusCode = http.StatusOK
+			if getResponse != nil {
+				statusCode, response = getResponse.StatusCode, getResponse
+			}
+		}
+		RecordCommandAudit(audit, statusCode, err, dic)
+	}
+
+	if err != nil {
+		result.StatusCode = err.Code()
+		result.Message = err.Error()
+		if ctx.Err() == context.DeadlineExceeded {
+			result.StatusCode = http.StatusGatewayTimeout
+			result.Message = fmt.Sprintf("device %s did not respond within the batch timeout: %s", deviceName, err.Error())
+		}
+		return result
+	}
+	result.StatusCode = statusCode
+	result.Response = response
+	return result
+}
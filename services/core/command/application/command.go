@@ -33,12 +33,6 @@ func AllCommands(offset int, limit int, dic *di.Container) (deviceCoreCommands [
                 return deviceCoreCommands, totalCount, errors.NewCommonIIOTWrapper(err)
         }
 
-        // retrieve device profile information through Metadata DeviceTemplateClient
-        dpc := bootstrapContainer.DeviceTemplateClientFrom(dic.Get)
-        if dpc == nil {
-                return deviceCoreCommands, totalCount, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceTemplateClient returned", nil)
-        }
-
         // Prepare the url for command
         configuration := commandContainer.ConfigurationFrom(dic.Get)
         serviceUrl := configuration.Service.Url()
@@ -48,9 +42,10 @@ func AllCommands(offset int, limit int, dic *di.Container) (deviceCoreCommands [
                         // if the profile is not set, skip the profile query
                         continue
                 }
-                deviceProfileResponse, err := dpc.DeviceTemplateByName(context.Background(), device.ProfileName)
+                // devices commonly share a profile, so the metadata cache saves most of these lookups
+                deviceProfileResponse, err := DeviceProfileByName(context.Background(), device.ProfileName, dic)
                 if err != nil {
-                        return deviceCoreCommands, totalCount, errors.NewCommonIIOTWrapper(err)
+                        return deviceCoreCommands, totalCount, err
                 }
                 commands, err := buildCoreCommands(device.Name, serviceUrl, deviceProfileResponse.Profile)
                 if err != nil {
@@ -71,24 +66,16 @@ func CommandsByDeviceName(name string, dic *di.Container) (deviceCoreCommand dto
                 return deviceCoreCommand, errors.NewCommonIIOT(errors.KindContractInvalid, "device name is empty", nil)
         }
 
-        // retrieve device information through Metadata DeviceClient
-        dc := bootstrapContainer.DeviceClientFrom(dic.Get)
-        if dc == nil {
-                return deviceCoreCommand, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceClient returned", nil)
-        }
-        deviceResponse, err := dc.DeviceByName(context.Background(), name)
+        // retrieve device information through the metadata cache
+        deviceResponse, err := DeviceByName(context.Background(), name, dic)
         if err != nil {
-                return deviceCoreCommand, errors.NewCommonIIOTWrapper(err)
+                return deviceCoreCommand, err
         }
 
-        // retrieve device profile information through Metadata DeviceTemplateClient
-        dpc := bootstrapContainer.DeviceTemplateClientFrom(dic.Get)
-        if dpc == nil {
-                return deviceCoreCommand, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceTemplateClient returned", nil)
-        }
-        deviceProfileResponse, err := dpc.DeviceTemplateByName(context.Background(), deviceResponse.Device.ProfileName)
+        // retrieve device profile information through the metadata cache
+        deviceProfileResponse, err := DeviceProfileByName(context.Background(), deviceResponse.Device.ProfileName, dic)
         if err != nil {
-                return deviceCoreCommand, errors.NewCommonIIOTWrapper(err)
+                return deviceCoreCommand, err
         }
 
         // Prepare the url for command
@@ -188,6 +175,10 @@ func buildCoreCommands(deviceName string, serviceUrl string, profile dtos.Device
 // IssueGetCommandByName issues the specified get(read) command referenced by the command name to the device/sensor, also
 // referenced by name.
 func IssueGetCommandByName(deviceName string, commandName string, queryParams string, dic *di.Container) (res *responses.EventResponse, err errors.IIOT) {
+        return cachedGetCommandByName(context.Background(), deviceName, commandName, queryParams, dic)
+}
+
+func issueGetCommandByName(ctx context.Context, deviceName string, commandName string, queryParams string, dic *di.Container) (res *responses.EventResponse, err errors.IIOT) {
         if deviceName == "" {
                 return res, errors.NewCommonIIOT(errors.KindContractInvalid, "device name cannot be empty", nil)
         }
@@ -196,24 +187,16 @@ func IssueGetCommandByName(deviceName string, commandName string, queryParams st
                 return res, errors.NewCommonIIOT(errors.KindContractInvalid, "command name cannot be empty", nil)
         }
 
-        // retrieve device information through Metadata DeviceClient
-        dc := bootstrapContainer.DeviceClientFrom(dic.Get)
-        if dc == nil {
-                return res, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceClient returned", nil)
-        }
-        deviceResponse, err := dc.DeviceByName(context.Background(), deviceName)
+        // retrieve device information through the metadata cache
+        deviceResponse, err := DeviceByName(ctx, deviceName, dic)
         if err != nil {
-                return res, errors.NewCommonIIOTWrapper(err)
+                return res, err
         }
 
-        // retrieve device service information through Metadata DeviceClient
-        dsc := bootstrapContainer.DeviceHandlerClientFrom(dic.Get)
-        if dsc == nil {
-                return res, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceHandlerClient returned", nil)
-        }
-        deviceServiceResponse, err := dsc.DeviceHandlerByName(context.Background(), deviceResponse.Device.ServiceName)
+        // retrieve device service information through the metadata cache
+        deviceServiceResponse, err := DeviceServiceByName(ctx, deviceResponse.Device.ServiceName, dic)
         if err != nil {
-                return res, errors.NewCommonIIOTWrapper(err)
+                return res, err
         }
 
         // Issue command by passing the base address of device service into DeviceHandlerCommandClient
@@ -221,7 +204,7 @@ func IssueGetCommandByName(deviceName string, commandName string, queryParams st
         if dscc == nil {
                 return res, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceHandlerCommandClient returned", nil)
         }
-        res, err = dscc.GetCommand(context.Background(), deviceServiceResponse.Service.BaseAddress, deviceName, commandName, queryParams)
+        res, err = dscc.GetCommand(ctx, deviceServiceResponse.Service.BaseAddress, deviceName, commandName, queryParams)
         if err != nil {
                 return res, errors.NewCommonIIOTWrapper(err)
         }
@@ -232,6 +215,10 @@ func IssueGetCommandByName(deviceName string, commandName string, queryParams st
 // IssueSetCommandByName issues the specified set(write) command referenced by the command name to the device/sensor, also
 // referenced by name.
 func IssueSetCommandByName(deviceName string, commandName string, queryParams string, settings map[string]interface{}, dic *di.Container) (response commonDTO.BaseResponse, err errors.IIOT) {
+        return issueSetCommandByName(context.Background(), deviceName, commandName, queryParams, settings, dic)
+}
+
+func issueSetCommandByName(ctx context.Context, deviceName string, commandName string, queryParams string, settings map[string]interface{}, dic *di.Container) (response commonDTO.BaseResponse, err errors.IIOT) {
         if deviceName == "" {
                 return response, errors.NewCommonIIOT(errors.KindContractInvalid, "device name cannot be empty", nil)
         }
@@ -240,24 +227,32 @@ func IssueSetCommandByName(deviceName string, commandName string, queryParams st
                 return response, errors.NewCommonIIOT(errors.KindContractInvalid, "command name cannot be empty", nil)
         }
 
-        // retrieve device information through Metadata DeviceClient
-        dc := bootstrapContainer.DeviceClientFrom(dic.Get)
-        if dc == nil {
-                return response, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceClient returned", nil)
-        }
-        deviceResponse, err := dc.DeviceByName(context.Background(), deviceName)
+        // retrieve device information through the metadata cache
+        deviceResponse, err := DeviceByName(ctx, deviceName, dic)
         if err != nil {
-                return response, errors.NewCommonIIOTWrapper(err)
+                return response, err
         }
 
-        // retrieve device service information through Metadata DeviceClient
-        dsc := bootstrapContainer.DeviceHandlerClientFrom(dic.Get)
-        if dsc == nil {
-                return response, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceHandlerClient returned", nil)
+        // validate the settings against the device profile before they reach the device
+        deviceProfileResponse, err := DeviceProfileByName(ctx, deviceResponse.Device.ProfileName, dic)
+        if err != nil {
+                return response, err
         }
-        deviceServiceResponse, err := dsc.DeviceHandlerByName(context.Background(), deviceResponse.Device.ServiceName)
+        if err = validateSetCommandSettings(commandName, settings, deviceProfileResponse.Profile); err != nil {
+                return response, err
+        }
+        devices := map[string]dtos.Device{deviceName: deviceResponse.Device}
+        if err = checkInterlocks(deviceName, commandName, devices, dic); err != nil {
+                return response, err
+        }
+        if err = CheckSetRateLimits(deviceName, commandName, dic); err != nil {
+                return response, err
+        }
+
+        // retrieve device service information through the metadata cache
+        deviceServiceResponse, err := DeviceServiceByName(ctx, deviceResponse.Device.ServiceName, dic)
         if err != nil {
-                return response, errors.NewCommonIIOTWrapper(err)
+                return response, err
         }
 
         // Issue command by passing the base address of device service into DeviceHandlerCommandClient
@@ -265,5 +260,14 @@ func IssueSetCommandByName(deviceName string, commandName string, queryParams st
         if dscc == nil {
                 return response, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceHandlerCommandClient returned", nil)
         }
-        return dscc.SetCommandWithObject(context.Background(), deviceServiceResponse.Service.BaseAddress, deviceName, commandName, queryParams, settings)
+        response, err = dscc.SetCommandWithObject(ctx, deviceServiceResponse.Service.BaseAddress, deviceName, commandName, queryParams, settings)
+        if err != nil {
+                return response, err
+        }
+
+        // cached reads of the device may no longer hold
+        if readCache := commandContainer.ReadCacheFrom(dic.Get); readCache != nil {
+                readCache.InvalidateDevice(deviceName)
+        }
+        return response, nil
 }
\ No newline at end of file
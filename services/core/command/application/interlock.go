@@ -0,0 +1,190 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+
+	"iiot-backend/services/core/command/config"
+	commandContainer "iiot-backend/services/core/command/container"
+)
+
+// Device states an interlock condition can check
+const (
+	InterlockStateAdmin     = "AdminState"
+	InterlockStateOperating = "OperatingState"
+)
+
+// defaultInterlockMaxAge is applied to reading conditions without a MaxAge, so a stale reading from a device that
+// stopped reporting cannot keep an interlock satisfied
+const defaultInterlockMaxAge = time.Minute
+
+// CheckInterlocks evaluates the interlock rules that apply to a set command of the device and returns a
+// KindStatusConflict error naming the first rule that blocks it. Conditions that cannot be evaluated block the
+// command.
+func CheckInterlocks(deviceName string, commandName string, dic *di.Container) errors.IIOT {
+	return checkInterlocks(deviceName, commandName, make(map[string]dtos.Device), dic)
+}
+
+// checkInterlocks is CheckInterlocks with devices already retrieved from core-metadata, keyed by name
+func checkInterlocks(deviceName string, commandName string, devices map[string]dtos.Device, dic *di.Container) errors.IIOT {
+	interlocks := commandContainer.ConfigurationFrom(dic.Get).Writable.Interlocks
+	if len(interlocks) == 0 {
+		return nil
+	}
+
+	// evaluate the rules in a stable order so the same rule is always reported
+	names := make([]string, 0, len(interlocks))
+	for name := range interlocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	for _, name := range names {
+		rule := interlocks[name]
+		if !interlockApplies(rule, deviceName, commandName) {
+			continue
+		}
+		for _, condition := range rule.Conditions {
+			reason := evaluateInterlockCondition(condition, deviceName, devices, dic)
+			if reason == "" {
+				continue
+			}
+			message := fmt.Sprintf("set command %s of device %s blocked by interlock %s: %s", commandName, deviceName, name, reason)
+			lc.Warn(message)
+			return errors.NewCommonIIOT(errors.KindStatusConflict, message, nil)
+		}
+	}
+	return nil
+}
+
+func interlockApplies(rule config.InterlockInfo, deviceName string, commandName string) bool {
+	if rule.DeviceName != "" && rule.DeviceName != "*" && rule.DeviceName != deviceName {
+		return false
+	}
+	return rule.CommandName == "" || rule.CommandName == commandName
+}
+
+// evaluateInterlockCondition returns why the condition does not hold, or an empty string when it does
+func evaluateInterlockCondition(condition config.InterlockCondition, targetDevice string, devices map[string]dtos.Device, dic *di.Container) string {
+	deviceName := condition.DeviceName
+	if deviceName == "" {
+		deviceName = targetDevice
+	}
+
+	var subject, actual string
+	if condition.ResourceName == "" {
+		device, err := interlockDevice(deviceName, devices, dic)
+		if err != nil {
+			return fmt.Sprintf("unable to retrieve device %s: %v", deviceName, err)
+		}
+		subject = fmt.Sprintf("%s of device %s", condition.State, deviceName)
+		switch condition.State {
+		case InterlockStateAdmin:
+			actual = device.ServiceState
+		case InterlockStateOperating:
+			actual = device.OperatingState
+		default:
+			return fmt.Sprintf("unknown device state %s", condition.State)
+		}
+	} else {
+		subject = fmt.Sprintf("reading %s of device %s", condition.ResourceName, deviceName)
+		mc := bootstrapContainer.MeasurementClientFrom(dic.Get)
+		if mc == nil {
+			return "nil MeasurementClient returned"
+		}
+		response, err := mc.MeasurementsByDeviceNameAndResourceName(context.Background(), deviceName, condition.ResourceName, 0, 1)
+		if err != nil {
+			return fmt.Sprintf("unable to retrieve %s: %v", subject, err)
+		}
+		if len(response.Measurements) == 0 {
+			return fmt.Sprintf("no %s available", subject)
+		}
+		reading := response.Measurements[0]
+		maxAge := defaultInterlockMaxAge
+		if condition.MaxAge != "" {
+			maxAge, err = time.ParseDuration(condition.MaxAge)
+			if err != nil || maxAge <= 0 {
+				return fmt.Sprintf("invalid MaxAge %s", condition.MaxAge)
+			}
+		}
+		if age := time.Since(time.Unix(0, reading.Origin)); age > maxAge {
+			return fmt.Sprintf("%s is %s old, older than %s", subject, age.Truncate(time.Second), maxAge)
+		}
+		actual = reading.Value
+	}
+
+	holds, err := compareInterlockValue(actual, condition.Operator, condition.Value)
+	if err != nil {
+		return err.Error()
+	}
+	if !holds {
+		return fmt.Sprintf("%s is %s, required %s %s", subject, actual, condition.Operator, condition.Value)
+	}
+	return ""
+}
+
+func interlockDevice(deviceName string, devices map[string]dtos.Device, dic *di.Container) (dtos.Device, error) {
+	if device, ok := devices[deviceName]; ok {
+		return device, nil
+	}
+	dc := bootstrapContainer.DeviceClientFrom(dic.Get)
+	if dc == nil {
+		return dtos.Device{}, fmt.Errorf("nil DeviceClient returned")
+	}
+	deviceResponse, err := dc.DeviceByName(context.Background(), deviceName)
+	if err != nil {
+		return dtos.Device{}, err
+	}
+	devices[deviceName] = deviceResponse.Device
+	return deviceResponse.Device, nil
+}
+
+// compareInterlockValue applies the operator of a condition. Equality compares numerically when both sides are
+// numbers and as strings otherwise; the ordering operators require numbers.
+func compareInterlockValue(actual string, operator string, expected string) (bool, error) {
+	actualNumber, actualErr := strconv.ParseFloat(actual, 64)
+	expectedNumber, expectedErr := strconv.ParseFloat(expected, 64)
+	numeric := actualErr == nil && expectedErr == nil
+
+	switch operator {
+	case "==", "":
+		if numeric {
+			return actualNumber == expectedNumber, nil
+		}
+		return actual == expected, nil
+	case "!=":
+		if numeric {
+			return actualNumber != expectedNumber, nil
+		}
+		return actual != expected, nil
+	case "<", "<=", ">", ">=":
+		if !numeric {
+			return false, fmt.Errorf("cannot compare %s %s %s numerically", actual, operator, expected)
+		}
+	default:
+		return false, fmt.Errorf("unknown interlock operator %s", operator)
+	}
+
+	switch operator {
+	case "<":
+		return actualNumber < expectedNumber, nil
+	case "<=":
+		return actualNumber <= expectedNumber, nil
+	case ">":
+		return actualNumber > expectedNumber, nil
+	default:
+		return actualNumber >= expectedNumber, nil
+	}
+}
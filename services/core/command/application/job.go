@@ -0,0 +1,487 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package application
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	commonDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/common"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/requests"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	"iiot-backend/services/core/command/config"
+	commandContainer "iiot-backend/services/core/command/container"
+
+	"github.com/google/uuid"
+)
+
+// Defaults applied when the CommandJob configuration leaves a value unset
+const (
+	defaultCommandJobTimeout   = 30 * time.Minute
+	defaultCommandJobRetention = 24 * time.Hour
+	defaultCommandJobMaxJobs   = 100
+	defaultMaxScheduleAhead    = 24 * time.Hour
+
+	commandJobCallbackTimeout = 10 * time.Second
+)
+
+// IsAsyncCommand reports whether the query parameters ask for the command to be run as a job, either with ds-async
+// or by scheduling it with ds-writeat
+func IsAsyncCommand(queryParams string) bool {
+	values, err := url.ParseQuery(queryParams)
+	return err == nil && (values.Get(common.Async) == common.ValueTrue || values.Get(common.WriteAt) != "")
+}
+
+// StartCommandJob runs the get or set command in the background and returns the job tracking it at once. A set
+// command with ds-writeat is only issued at that time, its settings being validated up front. The device service
+// is passed the job id with ds-jobid so it can publish progress, and the outcome is audited, posted to the
+// ds-callback URL when one is given and sent as a notification when configured.
+func StartCommandJob(method, deviceName, commandName, queryParams string, settings map[string]any, audit models.CommandAudit, dic *di.Container) (dtos.CommandJob, errors.IIOT) {
+	if deviceName == "" {
+		return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindContractInvalid, "device name cannot be empty", nil)
+	}
+	if commandName == "" {
+		return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindContractInvalid, "command name cannot be empty", nil)
+	}
+
+	jobConfig := commandContainer.ConfigurationFrom(dic.Get).Writable.CommandJob
+	limits, edgeErr := commandJobLimitsFrom(jobConfig)
+	if edgeErr != nil {
+		return dtos.CommandJob{}, edgeErr
+	}
+
+	params, err := url.ParseQuery(queryParams)
+	if err != nil {
+		return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindContractInvalid, "failed to parse query parameters", err)
+	}
+	callback := params.Get(common.Callback)
+	if callback != "" {
+		callbackUrl, err := url.Parse(callback)
+		if err != nil || (callbackUrl.Scheme != "http" && callbackUrl.Scheme != "https") || callbackUrl.Host == "" {
+			return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("invalid %s URL %s, an absolute http or https URL is expected", common.Callback, callback), err)
+		}
+		if !callbackHostAllowed(callbackUrl, jobConfig.CallbackHosts) {
+			return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("%s host %s is not one of the configured CommandJob.CallbackHosts", common.Callback, callbackUrl.Hostname()), nil)
+		}
+	}
+	var scheduled int64
+	if writeAt := params.Get(common.WriteAt); writeAt != "" {
+		if strings.EqualFold(method, http.MethodGet) {
+			return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("%s is only supported for set commands", common.WriteAt), nil)
+		}
+		scheduled, err = strconv.ParseInt(writeAt, 10, 64)
+		if err != nil || scheduled <= time.Now().UnixMilli() {
+			return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("invalid %s %s, a future timestamp in milliseconds is expected", common.WriteAt, writeAt), err)
+		}
+		if time.UnixMilli(scheduled).After(time.Now().Add(limits.maxScheduleAhead)) {
+			return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("%s %s is more than %s ahead", common.WriteAt, writeAt, limits.maxScheduleAhead), nil)
+		}
+	}
+	params.Del(common.Async)
+	params.Del(common.Callback)
+	params.Del(common.WriteAt)
+
+	// fail fast on unknown devices rather than with a job that can never succeed
+	deviceResponse, edgeErr := DeviceByName(context.Background(), deviceName, dic)
+	if edgeErr != nil {
+		return dtos.CommandJob{}, edgeErr
+	}
+	if scheduled > 0 {
+		// settings that can't be written are better reported now than when the time comes
+		deviceProfileResponse, edgeErr := DeviceProfileByName(context.Background(), deviceResponse.Device.ProfileName, dic)
+		if edgeErr != nil {
+			return dtos.CommandJob{}, edgeErr
+		}
+		if edgeErr = validateSetCommandSettings(commandName, settings, deviceProfileResponse.Profile); edgeErr != nil {
+			return dtos.CommandJob{}, edgeErr
+		}
+	}
+
+	jobStore := commandContainer.CommandJobStoreFrom(dic.Get)
+	if jobStore == nil {
+		return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindServerError, "nil command job store returned", nil)
+	}
+	now := time.Now().UnixMilli()
+	commandJob := dtos.CommandJob{
+		Id:          uuid.NewString(),
+		DeviceName:  deviceName,
+		CommandName: commandName,
+		Method:      method,
+		Status:      models.Pending,
+		Callback:    callback,
+		Scheduled:   scheduled,
+		Created:     now,
+		Modified:    now,
+	}
+	params.Set(common.JobId, commandJob.Id)
+
+	// scheduled commands are persisted so that they are still issued after a restart
+	dbClient := commandContainer.DBClientFrom(dic.Get)
+	if scheduled > 0 && dbClient != nil {
+		edgeErr = dbClient.AddScheduledCommand(models.ScheduledCommand{
+			JobId:         commandJob.Id,
+			DeviceName:    deviceName,
+			CommandName:   commandName,
+			QueryParams:   params.Encode(),
+			Settings:      settings,
+			Callback:      callback,
+			Source:        audit.Source,
+			Caller:        audit.Caller,
+			CorrelationId: audit.CorrelationId,
+			Scheduled:     scheduled,
+			Created:       now,
+		})
+		if edgeErr != nil {
+			return dtos.CommandJob{}, edgeErr
+		}
+	}
+	if !jobStore.Add(commandJob, limits.maxJobs, limits.retention) {
+		if scheduled > 0 && dbClient != nil {
+			_ = dbClient.DeleteScheduledCommand(commandJob.Id)
+		}
+		return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindServiceUnavailable, fmt.Sprintf("%d command jobs are already pending or running", limits.maxJobs), nil)
+	}
+
+	go runCommandJob(commandJob, params.Encode(), settings, limits.timeout, audit, dic)
+
+	return commandJob, nil
+}
+
+// commandJobLimits is the CommandJob configuration with the defaults applied
+type commandJobLimits struct {
+	timeout          time.Duration
+	retention        time.Duration
+	maxJobs          int
+	maxScheduleAhead time.Duration
+}
+
+func commandJobLimitsFrom(jobConfig config.CommandJobInfo) (commandJobLimits, errors.IIOT) {
+	limits := commandJobLimits{
+		timeout:          defaultCommandJobTimeout,
+		retention:        defaultCommandJobRetention,
+		maxJobs:          jobConfig.MaxJobs,
+		maxScheduleAhead: defaultMaxScheduleAhead,
+	}
+	var err error
+	if jobConfig.Timeout != "" {
+		if limits.timeout, err = time.ParseDuration(jobConfig.Timeout); err != nil {
+			return limits, errors.NewCommonIIOT(errors.KindServerError, "invalid CommandJob.Timeout configuration value", err)
+		}
+	}
+	if jobConfig.Retention != "" {
+		if limits.retention, err = time.ParseDuration(jobConfig.Retention); err != nil {
+			return limits, errors.NewCommonIIOT(errors.KindServerError, "invalid CommandJob.Retention configuration value", err)
+		}
+	}
+	if limits.maxJobs <= 0 {
+		limits.maxJobs = defaultCommandJobMaxJobs
+	}
+	if jobConfig.MaxScheduleAhead != "" {
+		if limits.maxScheduleAhead, err = time.ParseDuration(jobConfig.MaxScheduleAhead); err != nil {
+			return limits, errors.NewCommonIIOT(errors.KindServerError, "invalid CommandJob.MaxScheduleAhead configuration value", err)
+		}
+	}
+	return limits, nil
+}
+
+// ResumeScheduledCommandJobs restarts the jobs of the set commands scheduled with ds-writeat that had not been
+// issued when the service stopped. Commands whose time passed longer than the job timeout ago are not issued any
+// more, their jobs fail.
+func ResumeScheduledCommandJobs(dic *di.Container) errors.IIOT {
+	dbClient := commandContainer.DBClientFrom(dic.Get)
+	if dbClient == nil {
+		return nil
+	}
+	limits, edgeErr := commandJobLimitsFrom(commandContainer.ConfigurationFrom(dic.Get).Writable.CommandJob)
+	if edgeErr != nil {
+		return edgeErr
+	}
+	commands, edgeErr := dbClient.ScheduledCommands()
+	if edgeErr != nil {
+		return edgeErr
+	}
+
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	jobStore := commandContainer.CommandJobStoreFrom(dic.Get)
+	now := time.Now()
+	for _, command := range commands {
+		commandJob := dtos.CommandJob{
+			Id:          command.JobId,
+			DeviceName:  command.DeviceName,
+			CommandName: command.CommandName,
+			Method:      http.MethodPut,
+			Status:      models.Pending,
+			Callback:    command.Callback,
+			Scheduled:   command.Scheduled,
+			Created:     command.Created,
+			Modified:    now.UnixMilli(),
+		}
+
+		if now.Sub(time.UnixMilli(command.Scheduled)) > limits.timeout {
+			lc.Warnf("Command job %s missed its scheduled time for command %s of device %s while the service was down", command.JobId, command.CommandName, command.DeviceName)
+			commandJob.Status = models.Failed
+			commandJob.StatusCode = http.StatusGatewayTimeout
+			commandJob.Message = fmt.Sprintf("the command was not issued at %s as the service was down", time.UnixMilli(command.Scheduled).UTC().Format(time.RFC3339))
+			jobStore.Add(commandJob, 0, limits.retention)
+			if edgeErr := dbClient.DeleteScheduledCommand(command.JobId); edgeErr != nil {
+				lc.Errorf("Failed to delete scheduled command of job %s: %v", command.JobId, edgeErr)
+			}
+			continue
+		}
+
+		audit := NewCommandAudit(command.Source, command.Caller, command.DeviceName, command.CommandName, http.MethodPut,
+			command.QueryParams, command.Settings, command.CorrelationId)
+		jobStore.Add(commandJob, 0, limits.retention)
+		go runCommandJob(commandJob, command.QueryParams, command.Settings, limits.timeout, audit, dic)
+	}
+	if len(commands) > 0 {
+		lc.Infof("Resumed %d scheduled command jobs", len(commands))
+	}
+	return nil
+}
+
+func runCommandJob(commandJob dtos.CommandJob, queryParams string, settings map[string]any, timeout time.Duration, audit models.CommandAudit, dic *di.Container) {
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	jobStore := commandContainer.CommandJobStoreFrom(dic.Get)
+	if commandJob.Scheduled > 0 {
+		timer := time.NewTimer(time.Until(time.UnixMilli(commandJob.Scheduled)))
+		select {
+		case <-timer.C:
+		case <-jobStore.Context().Done():
+			// the service is stopping, the persisted command is resumed when it starts again
+			timer.Stop()
+			return
+		}
+		// the command is issued at most once, even if the service stops while it runs
+		if dbClient := commandContainer.DBClientFrom(dic.Get); dbClient != nil {
+			if err := dbClient.DeleteScheduledCommand(commandJob.Id); err != nil {
+				lc.Errorf("Failed to delete scheduled command of job %s: %v", commandJob.Id, err)
+			}
+		}
+	}
+	jobStore.Update(commandJob.Id, func(job *dtos.CommandJob) {
+		job.Status = models.Running
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var statusCode int
+	var result any
+	var err errors.IIOT
+	if strings.EqualFold(commandJob.Method, http.MethodGet) {
+		var getResponse *responses.EventResponse
+		getResponse, err = issueGetCommandByName(ctx, commandJob.DeviceName, commandJob.CommandName, queryParams, dic)
+		if err == nil {
+			// no event is returned when ds-returnevent is false
+			statusCode = http.StatusOK
+			if getResponse != nil {
+				statusCode, result = getResponse.StatusCode, getResponse
+			}
+		}
+	} else {
+		var setResponse commonDTO.BaseResponse
+		setResponse, err = issueSetCommandByName(ctx, commandJob.DeviceName, commandJob.CommandName, queryParams, settings, dic)
+		if err == nil {
+			statusCode, result = setResponse.StatusCode, setResponse
+		}
+	}
+	RecordCommandAudit(audit, statusCode, err, dic)
+
+	finished, ok := jobStore.Update(commandJob.Id, func(job *dtos.CommandJob) {
+		if err != nil {
+			job.Status = models.Failed
+			job.StatusCode = err.Code()
+			job.Message = err.Error()
+			if ctx.Err() == context.DeadlineExceeded {
+				job.StatusCode = http.StatusGatewayTimeout
+				job.Message = fmt.Sprintf("device %s did not complete the command within %s: %s", job.DeviceName, timeout, err.Error())
+			}
+			return
+		}
+		job.Status = models.Succeeded
+		job.Progress = 100
+		job.StatusCode = statusCode
+		job.Result = result
+	})
+	if !ok {
+		lc.Errorf("Command job %s disappeared before it finished", commandJob.Id)
+		return
+	}
+	lc.Debugf("Command job %s for command %s of device %s finished with status %s", finished.Id, finished.CommandName, finished.DeviceName, finished.Status)
+
+	if finished.Callback != "" {
+		jobConfig := commandContainer.ConfigurationFrom(dic.Get).Writable.CommandJob
+		if err := postCommandJobCallback(finished, jobConfig); err != nil {
+			lc.Errorf("Failed to post command job %s to callback %s: %v", finished.Id, finished.Callback, err)
+		}
+	}
+	notifyCommandJob(finished, dic)
+}
+
+// callbackClient posts command job callbacks to the configured hosts, connecting without a proxy and not following
+// redirects. publicCallbackClient, used with CommandJob.CallbackPublicOnly, also refuses to connect to loopback,
+// private, link-local and other non-public addresses, checked on the address each host name resolves to.
+var (
+	callbackClient       = newCallbackClient(false)
+	publicCallbackClient = newCallbackClient(true)
+)
+
+func newCallbackClient(publicOnly bool) *http.Client {
+	dialer := &net.Dialer{Timeout: commandJobCallbackTimeout}
+	if publicOnly {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicCallbackIP(ip) {
+				return fmt.Errorf("callback address %s is not a public address", host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: commandJobCallbackTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: commandJobCallbackTimeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicCallbackIP reports whether ip may receive command job callbacks
+func publicCallbackIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// callbackHostAllowed reports whether the host of the callback URL is one of hosts, compared case-insensitively
+func callbackHostAllowed(callbackUrl *url.URL, hosts []string) bool {
+	for _, host := range hosts {
+		if strings.EqualFold(callbackUrl.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// postCommandJobCallback posts the finished job to its callback URL, once more checking its host against the
+// CallbackHosts of jobConfig as the configuration may have changed since the job was started
+func postCommandJobCallback(commandJob dtos.CommandJob, jobConfig config.CommandJobInfo) error {
+	callbackUrl, err := url.Parse(commandJob.Callback)
+	if err != nil {
+		return err
+	}
+	if !callbackHostAllowed(callbackUrl, jobConfig.CallbackHosts) {
+		return fmt.Errorf("callback host %s is not one of the configured CommandJob.CallbackHosts", callbackUrl.Hostname())
+	}
+
+	body, err := json.Marshal(responses.NewCommandJobResponse("", "", http.StatusOK, commandJob))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandJobCallbackTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, commandJob.Callback, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(common.ContentType, common.ContentTypeJSON)
+
+	client := callbackClient
+	if jobConfig.CallbackPublicOnly {
+		client = publicCallbackClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback responded with status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// notifyCommandJob sends a notification of the finished job when a notification category is configured
+func notifyCommandJob(commandJob dtos.CommandJob, dic *di.Container) {
+	category := commandContainer.ConfigurationFrom(dic.Get).Writable.CommandJob.NotificationCategory
+	if category == "" {
+		return
+	}
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	alertClient := bootstrapContainer.AlertClientFrom(dic.Get)
+	if alertClient == nil {
+		lc.Warnf("Command job %s finished but no support-notifications client is configured to notify about it", commandJob.Id)
+		return
+	}
+
+	severity := models.Normal
+	content := fmt.Sprintf("Command job %s: %s command %s of device %s succeeded", commandJob.Id, commandJob.Method, commandJob.CommandName, commandJob.DeviceName)
+	if commandJob.Status == models.Failed {
+		severity = models.Minor
+		content = fmt.Sprintf("Command job %s: %s command %s of device %s failed: %s", commandJob.Id, commandJob.Method, commandJob.CommandName, commandJob.DeviceName, commandJob.Message)
+	}
+	alert := dtos.NewAlert([]string{commandJob.DeviceName}, category, content, common.CoreCommandServiceName, severity)
+	if _, err := alertClient.SendAlert(context.Background(), []requests.AddAlertRequest{requests.NewAddAlertRequest(alert)}); err != nil {
+		lc.Errorf("Failed to send notification of command job %s: %v", commandJob.Id, err)
+	}
+}
+
+// CommandJobById returns the command job with the given id
+func CommandJobById(id string, dic *di.Container) (dtos.CommandJob, errors.IIOT) {
+	jobStore := commandContainer.CommandJobStoreFrom(dic.Get)
+	if jobStore == nil {
+		return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindServerError, "nil command job store returned", nil)
+	}
+	commandJob, ok := jobStore.Get(id)
+	if !ok {
+		return dtos.CommandJob{}, errors.NewCommonIIOT(errors.KindEntityDoesNotExist, fmt.Sprintf("command job %s does not exist", id), nil)
+	}
+	return commandJob, nil
+}
+
+// UpdateCommandJobProgress records the progress a device service reported for a running job. Progress is
+// clamped to 0-100 and reports for unknown or finished jobs are ignored.
+func UpdateCommandJobProgress(id string, progress dtos.CommandJobProgress, dic *di.Container) errors.IIOT {
+	jobStore := commandContainer.CommandJobStoreFrom(dic.Get)
+	if jobStore == nil {
+		return errors.NewCommonIIOT(errors.KindServerError, "nil command job store returned", nil)
+	}
+	percentage := progress.Progress
+	if percentage < 0 {
+		percentage = 0
+	} else if percentage > 100 {
+		percentage = 100
+	}
+	if _, ok := jobStore.Update(id, func(job *dtos.CommandJob) {
+		job.Status = models.Running
+		job.Progress = percentage
+		job.Message = progress.Message
+	}); !ok {
+		return errors.NewCommonIIOT(errors.KindEntityDoesNotExist, fmt.Sprintf("command job %s does not exist or has finished", id), nil)
+	}
+	return nil
+}
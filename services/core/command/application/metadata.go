@@ -0,0 +1,110 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package application
+
+import (
+	"context"
+	"time"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+
+	"iiot-backend/services/core/command/cache"
+	commandContainer "iiot-backend/services/core/command/container"
+)
+
+const defaultMetadataCacheTTL = 5 * time.Minute
+
+// metadataCache returns the metadata cache and the TTL of new entries, or nil when caching is disabled
+func metadataCache(dic *di.Container) (*cache.MetadataCache, time.Duration) {
+	cacheConfig := commandContainer.ConfigurationFrom(dic.Get).Writable.MetadataCache
+	if !cacheConfig.Enabled {
+		return nil, 0
+	}
+	ttl := defaultMetadataCacheTTL
+	if cacheConfig.TTL != "" {
+		parsed, err := time.ParseDuration(cacheConfig.TTL)
+		if err != nil {
+			lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+			lc.Warnf("Invalid MetadataCache.TTL configuration value %s, using %s: %v", cacheConfig.TTL, ttl, err)
+		} else {
+			ttl = parsed
+		}
+	}
+	return commandContainer.MetadataCacheFrom(dic.Get), ttl
+}
+
+// DeviceByName retrieves the device from core-metadata through the metadata cache
+func DeviceByName(ctx context.Context, name string, dic *di.Container) (responses.DeviceResponse, errors.IIOT) {
+	metadataCache, ttl := metadataCache(dic)
+	if metadataCache != nil {
+		if deviceResponse, ok := metadataCache.Device(name); ok {
+			return deviceResponse, nil
+		}
+	}
+
+	dc := bootstrapContainer.DeviceClientFrom(dic.Get)
+	if dc == nil {
+		return responses.DeviceResponse{}, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceClient returned", nil)
+	}
+	deviceResponse, err := dc.DeviceByName(ctx, name)
+	if err != nil {
+		return deviceResponse, errors.NewCommonIIOTWrapper(err)
+	}
+
+	if metadataCache != nil {
+		metadataCache.SetDevice(name, deviceResponse, ttl)
+	}
+	return deviceResponse, nil
+}
+
+// DeviceServiceByName retrieves the device service from core-metadata through the metadata cache
+func DeviceServiceByName(ctx context.Context, name string, dic *di.Container) (responses.DeviceHandlerResponse, errors.IIOT) {
+	metadataCache, ttl := metadataCache(dic)
+	if metadataCache != nil {
+		if deviceServiceResponse, ok := metadataCache.DeviceService(name); ok {
+			return deviceServiceResponse, nil
+		}
+	}
+
+	dsc := bootstrapContainer.DeviceHandlerClientFrom(dic.Get)
+	if dsc == nil {
+		return responses.DeviceHandlerResponse{}, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceHandlerClient returned", nil)
+	}
+	deviceServiceResponse, err := dsc.DeviceHandlerByName(ctx, name)
+	if err != nil {
+		return deviceServiceResponse, errors.NewCommonIIOTWrapper(err)
+	}
+
+	if metadataCache != nil {
+		metadataCache.SetDeviceService(name, deviceServiceResponse, ttl)
+	}
+	return deviceServiceResponse, nil
+}
+
+// DeviceProfileByName retrieves the device profile from core-metadata through the metadata cache
+func DeviceProfileByName(ctx context.Context, name string, dic *di.Container) (responses.DeviceTemplateResponse, errors.IIOT) {
+	metadataCache, ttl := metadataCache(dic)
+	if metadataCache != nil {
+		if deviceProfileResponse, ok := metadataCache.DeviceProfile(name); ok {
+			return deviceProfileResponse, nil
+		}
+	}
+
+	dpc := bootstrapContainer.DeviceTemplateClientFrom(dic.Get)
+	if dpc == nil {
+		return responses.DeviceTemplateResponse{}, errors.NewCommonIIOT(errors.KindServerError, "nil DeviceTemplateClient returned", nil)
+	}
+	deviceProfileResponse, err := dpc.DeviceTemplateByName(ctx, name)
+	if err != nil {
+		return deviceProfileResponse, errors.NewCommonIIOTWrapper(err)
+	}
+
+	if metadataCache != nil {
+		metadataCache.SetDeviceProfile(name, deviceProfileResponse, ttl)
+	}
+	return deviceProfileResponse, nil
+}
@@ -0,0 +1,303 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	commonDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/common"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	"iiot-backend/services/core/command/config"
+	commandContainer "iiot-backend/services/core/command/container"
+)
+
+// Defaults applied when the CommandQueue configuration leaves a value unset
+const (
+	defaultQueueExpiry        = 24 * time.Hour
+	defaultQueueMaxExpiry     = 7 * 24 * time.Hour
+	defaultQueueMaxPerDevice  = 100
+	defaultQueueRetryInterval = time.Minute
+
+	queuedCommandDeliveryTimeout = 30 * time.Second
+)
+
+// queueDeliveries holds the names of the devices whose queued commands are being delivered, so that a device
+// only ever has one delivery running and receives its commands in order
+var queueDeliveries sync.Map
+
+// IsQueueRequested reports whether the command queue is enabled and the set command opted in to it
+func IsQueueRequested(queryParams string, dic *di.Container) bool {
+	if !commandContainer.ConfigurationFrom(dic.Get).Writable.CommandQueue.Enabled {
+		return false
+	}
+	values, err := url.ParseQuery(queryParams)
+	return err == nil && values.Get(common.QueueCommand) == common.ValueTrue
+}
+
+// CommandQueueRetryInterval returns how often the delivery of queued commands is retried
+func CommandQueueRetryInterval(dic *di.Container) time.Duration {
+	queueConfig := commandContainer.ConfigurationFrom(dic.Get).Writable.CommandQueue
+	if queueConfig.RetryInterval != "" {
+		if interval, err := time.ParseDuration(queueConfig.RetryInterval); err == nil && interval > 0 {
+			return interval
+		}
+		lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+		lc.Warnf("Invalid CommandQueue.RetryInterval configuration value %s, using %s", queueConfig.RetryInterval, defaultQueueRetryInterval)
+	}
+	return defaultQueueRetryInterval
+}
+
+// IssueSetCommandOrQueue issues the set command, or queues it when the device is down or its device service can't
+// be reached. A command is also queued when earlier commands are still waiting for the device, so that the device
+// receives them in order. The queued command is returned when the command was queued rather than issued; it is
+// audited once it is delivered or dropped.
+func IssueSetCommandOrQueue(deviceName string, commandName string, queryParams string, settings map[string]any, audit models.CommandAudit, dic *di.Container) (commonDTO.BaseResponse, *dtos.QueuedCommand, errors.IIOT) {
+	var response commonDTO.BaseResponse
+	dbClient := commandContainer.DBClientFrom(dic.Get)
+	if dbClient == nil {
+		return response, nil, errors.NewCommonIIOT(errors.KindServiceUnavailable, "command queue database is not configured", nil)
+	}
+	queueConfig := commandContainer.ConfigurationFrom(dic.Get).Writable.CommandQueue
+
+	params, parseErr := url.ParseQuery(queryParams)
+	if parseErr != nil {
+		return response, nil, errors.NewCommonIIOT(errors.KindContractInvalid, "failed to parse query parameters", parseErr)
+	}
+	expiry, err := queuedCommandExpiry(params.Get(common.QueueExpiry), queueConfig)
+	if err != nil {
+		return response, nil, err
+	}
+	params.Del(common.QueueCommand)
+	params.Del(common.QueueExpiry)
+	queryParams = params.Encode()
+
+	deviceResponse, err := DeviceByName(context.Background(), deviceName, dic)
+	if err != nil {
+		return response, nil, err
+	}
+	_, waiting, err := dbClient.QueuedCommands(deviceName, 0, 0)
+	if err != nil {
+		return response, nil, errors.NewCommonIIOTWrapper(err)
+	}
+
+	var reason string
+	switch {
+	case waiting > 0:
+		reason = fmt.Sprintf("%d earlier commands are queued for the device", waiting)
+	case deviceResponse.Device.OperatingState == models.Down:
+		reason = "device is down"
+	default:
+		response, err = issueSetCommandByName(context.Background(), deviceName, commandName, queryParams, settings, dic)
+		if err == nil || errors.Kind(err) != errors.KindServiceUnavailable {
+			return response, nil, err
+		}
+		reason = err.Error()
+	}
+
+	// a command that can never succeed is rejected now rather than when the device is back
+	deviceProfileResponse, err := DeviceProfileByName(context.Background(), deviceResponse.Device.ProfileName, dic)
+	if err != nil {
+		return response, nil, err
+	}
+	if err = validateSetCommandSettings(commandName, settings, deviceProfileResponse.Profile); err != nil {
+		return response, nil, err
+	}
+
+	maxPerDevice := queueConfig.MaxPerDevice
+	if maxPerDevice <= 0 {
+		maxPerDevice = defaultQueueMaxPerDevice
+	}
+	if waiting >= uint32(maxPerDevice) {
+		return response, nil, errors.NewCommonIIOT(errors.KindLimitExceeded, fmt.Sprintf("device %s already has %d queued commands", deviceName, waiting), nil)
+	}
+
+	now := time.Now()
+	queuedCommand, err := dbClient.AddQueuedCommand(models.QueuedCommand{
+		DeviceName:    deviceName,
+		CommandName:   commandName,
+		QueryParams:   queryParams,
+		Settings:      settings,
+		Source:        audit.Source,
+		Caller:        audit.Caller,
+		CorrelationId: audit.CorrelationId,
+		Reason:        reason,
+		Expires:       now.Add(expiry).UnixMilli(),
+		Created:       now.UnixMilli(),
+	})
+	if err != nil {
+		return response, nil, errors.NewCommonIIOTWrapper(err)
+	}
+
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	lc.Infof("Queued command %s of device %s as %s: %s", commandName, deviceName, queuedCommand.Id, reason)
+	if waiting > 0 && deviceResponse.Device.OperatingState != models.Down {
+		// the device may well be reachable again, in which case there is no need to wait for the next retry
+		go DeliverQueuedCommands(deviceName, dic)
+	}
+
+	queuedCommandDTO := dtos.FromQueuedCommandModelToDTO(queuedCommand)
+	return response, &queuedCommandDTO, nil
+}
+
+func queuedCommandExpiry(requested string, queueConfig config.CommandQueueInfo) (time.Duration, errors.IIOT) {
+	maxExpiry := defaultQueueMaxExpiry
+	if queueConfig.MaxExpiry != "" {
+		var err error
+		if maxExpiry, err = time.ParseDuration(queueConfig.MaxExpiry); err != nil {
+			return 0, errors.NewCommonIIOT(errors.KindServerError, "invalid CommandQueue.MaxExpiry configuration value", err)
+		}
+	}
+	expiry := defaultQueueExpiry
+	if queueConfig.DefaultExpiry != "" {
+		var err error
+		if expiry, err = time.ParseDuration(queueConfig.DefaultExpiry); err != nil {
+			return 0, errors.NewCommonIIOT(errors.KindServerError, "invalid CommandQueue.DefaultExpiry configuration value", err)
+		}
+	}
+	if requested != "" {
+		var err error
+		if expiry, err = time.ParseDuration(requested); err != nil || expiry <= 0 {
+			return 0, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("invalid %s %s, a positive duration is expected", common.QueueExpiry, requested), err)
+		}
+	}
+	if expiry > maxExpiry {
+		return 0, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("queue expiry %s exceeds the maximum of %s", expiry, maxExpiry), nil)
+	}
+	return expiry, nil
+}
+
+// DeliverQueuedCommands delivers the queued commands of the device in the order they were queued, stopping while
+// the device is down or its device service can't be reached. Commands that have expired or that the device
+// rejects are dropped. Every command delivered or dropped is audited.
+func DeliverQueuedCommands(deviceName string, dic *di.Container) {
+	if _, delivering := queueDeliveries.LoadOrStore(deviceName, struct{}{}); delivering {
+		return
+	}
+	defer queueDeliveries.Delete(deviceName)
+
+	dbClient := commandContainer.DBClientFrom(dic.Get)
+	if dbClient == nil {
+		return
+	}
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+
+	for {
+		queuedCommands, _, err := dbClient.QueuedCommands(deviceName, 0, 1)
+		if err != nil {
+			lc.Errorf("Failed to query queued commands of device %s: %v", deviceName, err)
+			return
+		}
+		if len(queuedCommands) == 0 {
+			return
+		}
+		queuedCommand := queuedCommands[0]
+		audit := NewCommandAudit(queuedCommand.Source, queuedCommand.Caller, deviceName, queuedCommand.CommandName,
+			http.MethodPut, queuedCommand.QueryParams, queuedCommand.Settings, queuedCommand.CorrelationId)
+
+		if queuedCommand.Expires < time.Now().UnixMilli() {
+			lc.Warnf("Dropping queued command %s of device %s, it expired before the device was reachable", queuedCommand.Id, deviceName)
+			RecordCommandAudit(audit, http.StatusGone, fmt.Errorf("queued command %s expired before delivery", queuedCommand.Id), dic)
+		} else {
+			deviceResponse, err := DeviceByName(context.Background(), deviceName, dic)
+			if err != nil {
+				lc.Errorf("Failed to retrieve device %s to deliver its queued commands: %v", deviceName, err)
+				return
+			}
+			if deviceResponse.Device.OperatingState == models.Down {
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), queuedCommandDeliveryTimeout)
+			response, err := issueSetCommandByName(ctx, deviceName, queuedCommand.CommandName, queuedCommand.QueryParams, queuedCommand.Settings, dic)
+			cancel()
+			if err != nil && errors.Kind(err) == errors.KindServiceUnavailable {
+				lc.Debugf("Device %s is still unreachable, its queued commands wait for the next attempt", deviceName)
+				return
+			}
+			if err != nil && errors.Kind(err) == errors.KindLimitExceeded {
+				lc.Debugf("Set rate limit of device %s reached, its queued commands wait for the next attempt", deviceName)
+				return
+			}
+			RecordCommandAudit(audit, response.StatusCode, err, dic)
+			if err != nil {
+				lc.Errorf("Dropping queued command %s of device %s, the device rejected it: %v", queuedCommand.Id, deviceName, err)
+			} else {
+				lc.Infof("Delivered queued command %s of device %s", queuedCommand.Id, deviceName)
+			}
+		}
+
+		if err := dbClient.DeleteQueuedCommandById(queuedCommand.Id); err != nil {
+			// stop rather than deliver the same command again
+			lc.Errorf("Failed to remove queued command %s of device %s: %v", queuedCommand.Id, deviceName, err)
+			return
+		}
+	}
+}
+
+// DeliverAllQueuedCommands attempts the delivery of the queued commands of every device that has any
+func DeliverAllQueuedCommands(dic *di.Container) {
+	if !commandContainer.ConfigurationFrom(dic.Get).Writable.CommandQueue.Enabled {
+		return
+	}
+	dbClient := commandContainer.DBClientFrom(dic.Get)
+	if dbClient == nil {
+		return
+	}
+
+	deviceNames, err := dbClient.QueuedCommandDeviceNames()
+	if err != nil {
+		lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+		lc.Errorf("Failed to query devices with queued commands: %v", err)
+		return
+	}
+	for _, deviceName := range deviceNames {
+		go DeliverQueuedCommands(deviceName, dic)
+	}
+}
+
+// QueuedCommands returns the queued commands of the device, or of every device when deviceName is empty, in the
+// order they are delivered
+func QueuedCommands(deviceName string, offset int, limit int, dic *di.Container) (queuedCommands []dtos.QueuedCommand, totalCount uint32, err errors.IIOT) {
+	dbClient := commandContainer.DBClientFrom(dic.Get)
+	if dbClient == nil {
+		return queuedCommands, totalCount, errors.NewCommonIIOT(errors.KindServiceUnavailable, "command queue database is not configured", nil)
+	}
+
+	queuedCommandModels, totalCount, err := dbClient.QueuedCommands(deviceName, offset, limit)
+	if err != nil {
+		return queuedCommands, totalCount, errors.NewCommonIIOTWrapper(err)
+	}
+	return dtos.FromQueuedCommandModelsToDTOs(queuedCommandModels), totalCount, nil
+}
+
+// CancelQueuedCommand removes the queued command with the given id before it is delivered
+func CancelQueuedCommand(id string, dic *di.Container) errors.IIOT {
+	dbClient := commandContainer.DBClientFrom(dic.Get)
+	if dbClient == nil {
+		return errors.NewCommonIIOT(errors.KindServiceUnavailable, "command queue database is not configured", nil)
+	}
+
+	queuedCommand, err := dbClient.QueuedCommandById(id)
+	if err != nil {
+		return errors.NewCommonIIOTWrapper(err)
+	}
+	if err := dbClient.DeleteQueuedCommandById(id); err != nil {
+		return errors.NewCommonIIOTWrapper(err)
+	}
+
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	lc.Infof("Cancelled queued command %s of device %s", id, queuedCommand.DeviceName)
+	return nil
+}
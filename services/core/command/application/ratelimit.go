@@ -0,0 +1,83 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package application
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+
+	"iiot-backend/services/core/command/config"
+	commandContainer "iiot-backend/services/core/command/container"
+	"iiot-backend/services/core/command/ratelimit"
+)
+
+// CheckSetRateLimits counts the set command against every rate limit that applies to it and returns a
+// KindLimitExceeded error naming the first exhausted limit instead, in which case nothing is counted
+func CheckSetRateLimits(deviceName string, commandName string, dic *di.Container) errors.IIOT {
+	rules := commandContainer.ConfigurationFrom(dic.Get).Writable.SetRateLimits
+	if len(rules) == 0 {
+		return nil
+	}
+	limiter := commandContainer.SetRateLimiterFrom(dic.Get)
+	if limiter == nil {
+		return errors.NewCommonIIOT(errors.KindServerError, "nil set command rate limiter returned", nil)
+	}
+
+	// collect the rules in a stable order so the same rule is always reported
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var limits []ratelimit.Limit
+	var limitNames []string
+	for _, name := range names {
+		rule := rules[name]
+		if !setRateLimitApplies(rule, deviceName, commandName) {
+			continue
+		}
+		period, err := time.ParseDuration(rule.Period)
+		if err != nil || period <= 0 {
+			return errors.NewCommonIIOT(errors.KindServerError, fmt.Sprintf("invalid Period %s of set rate limit %s", rule.Period, name), err)
+		}
+		maxWrites := rule.MaxWrites
+		if maxWrites <= 0 {
+			maxWrites = 1
+		}
+		// writes are counted per device, and per command when the rule names one
+		key := name + "/" + deviceName
+		if rule.CommandName != "" {
+			key += "/" + commandName
+		}
+		limits = append(limits, ratelimit.Limit{Key: key, MaxEvents: maxWrites, Period: period})
+		limitNames = append(limitNames, name)
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+
+	exhausted, retryAfter := limiter.Allow(limits)
+	if exhausted < 0 {
+		return nil
+	}
+	limit := limits[exhausted]
+	message := fmt.Sprintf("set command %s of device %s rejected by rate limit %s: at most %d writes per %s, retry in %s",
+		commandName, deviceName, limitNames[exhausted], limit.MaxEvents, limit.Period, retryAfter.Round(time.Millisecond))
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	lc.Warn(message)
+	return errors.NewCommonIIOT(errors.KindLimitExceeded, message, nil)
+}
+
+func setRateLimitApplies(rule config.SetRateLimitInfo, deviceName string, commandName string) bool {
+	if rule.DeviceName != "" && rule.DeviceName != "*" && rule.DeviceName != deviceName {
+		return false
+	}
+	return rule.CommandName == "" || rule.CommandName == commandName
+}
@@ -0,0 +1,70 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+
+	"iiot-backend/services/core/command/cache"
+	commandContainer "iiot-backend/services/core/command/container"
+)
+
+// Defaults applied when the ReadCache configuration leaves a value unset
+const (
+	defaultReadCacheTTL        = time.Second
+	defaultReadCacheMaxEntries = 10000
+)
+
+// cachedGetCommandByName issues a get command through the read cache when the cache is enabled, by configuration
+// or by the ds-usecache query parameter. Reads that push an event or return none always reach the device.
+func cachedGetCommandByName(ctx context.Context, deviceName string, commandName string, queryParams string, dic *di.Container) (*responses.EventResponse, errors.IIOT) {
+	params, parseErr := url.ParseQuery(queryParams)
+	if parseErr != nil {
+		return nil, errors.NewCommonIIOT(errors.KindContractInvalid, "invalid query parameters", parseErr)
+	}
+
+	cacheConfig := commandContainer.ConfigurationFrom(dic.Get).Writable.ReadCache
+	useCache := cacheConfig.Enabled
+	if value := params.Get(common.UseCache); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("invalid query parameter, %s has to be %s or %s", common.UseCache, common.ValueTrue, common.ValueFalse), err)
+		}
+		useCache = parsed
+	}
+	// ds-usecache is for core-command only
+	params.Del(common.UseCache)
+	forwardedParams := params.Encode()
+
+	readCache := commandContainer.ReadCacheFrom(dic.Get)
+	if !useCache || readCache == nil ||
+		params.Get(common.PushDataEvent) == common.ValueTrue || params.Get(common.ReturnDataEvent) == common.ValueFalse {
+		return issueGetCommandByName(ctx, deviceName, commandName, forwardedParams, dic)
+	}
+
+	ttl := defaultReadCacheTTL
+	if cacheConfig.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(cacheConfig.TTL); err != nil {
+			return nil, errors.NewCommonIIOT(errors.KindServerError, "invalid ReadCache.TTL configuration value", err)
+		}
+	}
+	maxEntries := cacheConfig.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultReadCacheMaxEntries
+	}
+
+	return readCache.Read(cache.ReadKey(deviceName, commandName, forwardedParams), ttl, maxEntries, func() (*responses.EventResponse, errors.IIOT) {
+		return issueGetCommandByName(ctx, deviceName, commandName, forwardedParams, dic)
+	})
+}
@@ -0,0 +1,201 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+)
+
+// ValidateSetCommand checks the settings of a set command against the profile of the device, for commands that
+// reach the device service without going through IssueSetCommandByName
+func ValidateSetCommand(deviceName string, commandName string, settings map[string]interface{}, dic *di.Container) errors.IIOT {
+	deviceResponse, err := DeviceByName(context.Background(), deviceName, dic)
+	if err != nil {
+		return err
+	}
+	deviceProfileResponse, err := DeviceProfileByName(context.Background(), deviceResponse.Device.ProfileName, dic)
+	if err != nil {
+		return err
+	}
+	return validateSetCommandSettings(commandName, settings, deviceProfileResponse.Profile)
+}
+
+// validateSetCommandSettings checks the settings of a set command against the device profile before they are
+// forwarded to the device service: the command and every resource it writes must allow writes, and each value
+// must parse as the resource value type, stay within its minimum and maximum and match its mappings when defined.
+func validateSetCommandSettings(commandName string, settings map[string]interface{}, profile dtos.DeviceProfile) errors.IIOT {
+	operations, err := writableResourceOperations(commandName, profile)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range settings {
+		operation, ok := resourceOperationByName(operations, name)
+		if !ok {
+			return errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("setting %s is not a resource of command %s", name, commandName), nil)
+		}
+		resource, ok := deviceResourcesByName(profile.DeviceResources, operation.DeviceResource)
+		if !ok {
+			return errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("resource %s of command %s is not defined in profile %s", operation.DeviceResource, commandName, profile.Name), nil)
+		}
+		if !strings.Contains(resource.Properties.ReadWrite, common.ReadWrite_W) {
+			return errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("resource %s is read-only", resource.Name), nil)
+		}
+		if err := validateSettingValue(resource, operation.Mappings, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writableResourceOperations returns the resource operations the set command writes, either those of the device
+// command or the device resource of the same name
+func writableResourceOperations(commandName string, profile dtos.DeviceProfile) ([]dtos.ResourceOperation, errors.IIOT) {
+	for _, command := range profile.DeviceCommands {
+		if command.Name != commandName {
+			continue
+		}
+		if !strings.Contains(command.ReadWrite, common.ReadWrite_W) {
+			return nil, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("command %s is read-only", commandName), nil)
+		}
+		return command.ResourceOperations, nil
+	}
+	if _, ok := deviceResourcesByName(profile.DeviceResources, commandName); ok {
+		return []dtos.ResourceOperation{{DeviceResource: commandName}}, nil
+	}
+	return nil, errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("command %s is not defined in profile %s", commandName, profile.Name), nil)
+}
+
+func resourceOperationByName(operations []dtos.ResourceOperation, name string) (dtos.ResourceOperation, bool) {
+	for _, operation := range operations {
+		if operation.DeviceResource == name {
+			return operation, true
+		}
+	}
+	return dtos.ResourceOperation{}, false
+}
+
+// validateSettingValue checks one setting value against the properties of its resource. Mapped values are
+// translated back by the device service, so only the mapping is checked for them.
+func validateSettingValue(resource dtos.DeviceResource, mappings map[string]string, value interface{}) errors.IIOT {
+	valueType := resource.Properties.ValueType
+
+	if len(mappings) > 0 {
+		str := settingString(value)
+		for _, mapped := range mappings {
+			if str == mapped {
+				return nil
+			}
+		}
+		return errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("value %s of resource %s does not match any of its mappings", str, resource.Name), nil)
+	}
+
+	switch valueType {
+	case common.ValueTypeBinary, common.ValueTypeObject, common.ValueTypeObjectArray, common.ValueTypeString, common.ValueTypeStringArray:
+		return nil
+	}
+
+	if elementType, isArray := strings.CutSuffix(valueType, "Array"); isArray {
+		elements, err := settingArray(value)
+		if err != nil {
+			return errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("value of resource %s is not a valid %s", resource.Name, valueType), err)
+		}
+		for _, element := range elements {
+			if err := validateSettingScalar(resource, elementType, element); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return validateSettingScalar(resource, valueType, value)
+}
+
+// validateSettingScalar parses a single value as valueType and checks it against the resource limits
+func validateSettingScalar(resource dtos.DeviceResource, valueType string, value interface{}) errors.IIOT {
+	str := settingString(value)
+
+	var number float64
+	var err error
+	switch valueType {
+	case common.ValueTypeBool:
+		_, err = strconv.ParseBool(str)
+		if err != nil {
+			return errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("value %s of resource %s is not a valid %s", str, resource.Name, valueType), err)
+		}
+		return nil
+	case common.ValueTypeInt8, common.ValueTypeInt16, common.ValueTypeInt32, common.ValueTypeInt64:
+		var i int64
+		i, err = strconv.ParseInt(str, 10, valueTypeBits(valueType))
+		number = float64(i)
+	case common.ValueTypeUint8, common.ValueTypeUint16, common.ValueTypeUint32, common.ValueTypeUint64:
+		var u uint64
+		u, err = strconv.ParseUint(str, 10, valueTypeBits(valueType))
+		number = float64(u)
+	case common.ValueTypeFloat32, common.ValueTypeFloat64:
+		number, err = strconv.ParseFloat(str, valueTypeBits(valueType))
+	default:
+		return nil
+	}
+	if err != nil {
+		return errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("value %s of resource %s is not a valid %s", str, resource.Name, valueType), err)
+	}
+
+	if minimum := resource.Properties.Minimum; minimum != nil && number < *minimum {
+		return errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("value %s of resource %s is below minimum %v", str, resource.Name, *minimum), nil)
+	}
+	if maximum := resource.Properties.Maximum; maximum != nil && number > *maximum {
+		return errors.NewCommonIIOT(errors.KindContractInvalid, fmt.Sprintf("value %s of resource %s is above maximum %v", str, resource.Name, *maximum), nil)
+	}
+	return nil
+}
+
+// valueTypeBits returns the bit size of a numeric value type, e.g. 16 for Int16
+func valueTypeBits(valueType string) int {
+	bits, err := strconv.Atoi(strings.TrimLeftFunc(valueType, unicode.IsLetter))
+	if err != nil {
+		return 64
+	}
+	return bits
+}
+
+// settingString returns the string form of a setting value, which is usually a string but may be a JSON number
+// or boolean
+func settingString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// settingArray returns the elements of an array setting, given either as a JSON array or its string encoding
+func settingArray(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case string:
+		var elements []interface{}
+		if err := json.Unmarshal([]byte(v), &elements); err != nil {
+			return nil, err
+		}
+		return elements, nil
+	default:
+		return nil, fmt.Errorf("unexpected %T", value)
+	}
+}
@@ -0,0 +1,99 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+)
+
+func limit(value float64) *float64 {
+	return &value
+}
+
+// vfdProfile describes a variable frequency drive with a bounded setpoint, a read-only status, a mapped run mode and
+// a command writing the setpoint together with the run mode
+func vfdProfile() dtos.DeviceProfile {
+	profile := dtos.DeviceProfile{
+		DeviceResources: []dtos.DeviceResource{
+			{Name: "Setpoint", Properties: dtos.ResourceProperties{ValueType: common.ValueTypeFloat32, ReadWrite: common.ReadWrite_RW, Minimum: limit(0), Maximum: limit(50)}},
+			{Name: "Ramp", Properties: dtos.ResourceProperties{ValueType: common.ValueTypeUint8, ReadWrite: common.ReadWrite_W, Maximum: limit(200)}},
+			{Name: "Status", Properties: dtos.ResourceProperties{ValueType: common.ValueTypeInt16, ReadWrite: common.ReadWrite_R}},
+			{Name: "Enabled", Properties: dtos.ResourceProperties{ValueType: common.ValueTypeBool, ReadWrite: common.ReadWrite_RW}},
+			{Name: "Presets", Properties: dtos.ResourceProperties{ValueType: common.ValueTypeInt32Array, ReadWrite: common.ReadWrite_RW, Minimum: limit(-10), Maximum: limit(10)}},
+			{Name: "Label", Properties: dtos.ResourceProperties{ValueType: common.ValueTypeString, ReadWrite: common.ReadWrite_RW}},
+			{Name: "Mode", Properties: dtos.ResourceProperties{ValueType: common.ValueTypeInt16, ReadWrite: common.ReadWrite_RW}},
+		},
+		DeviceCommands: []dtos.DeviceCommand{
+			{Name: "Run", ReadWrite: common.ReadWrite_RW, ResourceOperations: []dtos.ResourceOperation{
+				{DeviceResource: "Setpoint"},
+				{DeviceResource: "Mode", Mappings: map[string]string{"0": "STOP", "1": "FORWARD", "2": "REVERSE"}},
+			}},
+			{Name: "Diagnostics", ReadWrite: common.ReadWrite_R, ResourceOperations: []dtos.ResourceOperation{{DeviceResource: "Setpoint"}}},
+		},
+	}
+	profile.Name = "vfd"
+	return profile
+}
+
+func TestValidateSetCommandSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		settings map[string]interface{}
+		valid    bool
+	}{
+		{"setpoint within limits", "Setpoint", map[string]interface{}{"Setpoint": "42.5"}, true},
+		{"setpoint as JSON number", "Setpoint", map[string]interface{}{"Setpoint": 42.5}, true},
+		{"setpoint at minimum", "Setpoint", map[string]interface{}{"Setpoint": "0"}, true},
+		{"setpoint at maximum", "Setpoint", map[string]interface{}{"Setpoint": "50"}, true},
+		{"setpoint above maximum", "Setpoint", map[string]interface{}{"Setpoint": "500"}, false},
+		{"setpoint below minimum", "Setpoint", map[string]interface{}{"Setpoint": "-0.1"}, false},
+		{"setpoint not a number", "Setpoint", map[string]interface{}{"Setpoint": "fast"}, false},
+		{"float32 overflow", "Setpoint", map[string]interface{}{"Setpoint": "1e39"}, false},
+		{"unsigned within limits", "Ramp", map[string]interface{}{"Ramp": "200"}, true},
+		{"unsigned above maximum", "Ramp", map[string]interface{}{"Ramp": "201"}, false},
+		{"unsigned overflows its bits", "Ramp", map[string]interface{}{"Ramp": "256"}, false},
+		{"unsigned negative", "Ramp", map[string]interface{}{"Ramp": "-1"}, false},
+		{"integer not integral", "Mode", map[string]interface{}{"Mode": "1.5"}, false},
+		{"bool", "Enabled", map[string]interface{}{"Enabled": "true"}, true},
+		{"bool invalid", "Enabled", map[string]interface{}{"Enabled": "yes"}, false},
+		{"array within limits", "Presets", map[string]interface{}{"Presets": "[1, -2, 10]"}, true},
+		{"array as JSON array", "Presets", map[string]interface{}{"Presets": []interface{}{1.0, 2.0}}, true},
+		{"array element above maximum", "Presets", map[string]interface{}{"Presets": "[1, 11]"}, false},
+		{"array malformed", "Presets", map[string]interface{}{"Presets": "1, 2"}, false},
+		{"string is not parsed", "Label", map[string]interface{}{"Label": "line 3"}, true},
+		{"read-only resource", "Status", map[string]interface{}{"Status": "1"}, false},
+		{"read-only command", "Diagnostics", map[string]interface{}{"Setpoint": "10"}, false},
+		{"unknown command", "Reset", map[string]interface{}{"Reset": "1"}, false},
+		{"command with mapped value", "Run", map[string]interface{}{"Setpoint": "10", "Mode": "FORWARD"}, true},
+		{"command with unmapped value", "Run", map[string]interface{}{"Mode": "1"}, false},
+		{"command with out of range value", "Run", map[string]interface{}{"Setpoint": "60", "Mode": "STOP"}, false},
+		{"setting outside the command", "Run", map[string]interface{}{"Ramp": "10"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSetCommandSettings(tt.command, tt.settings, vfdProfile())
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, errors.KindContractInvalid, errors.Kind(err))
+		})
+	}
+}
+
+func TestValueTypeBits(t *testing.T) {
+	assert.Equal(t, 8, valueTypeBits(common.ValueTypeUint8))
+	assert.Equal(t, 32, valueTypeBits(common.ValueTypeFloat32))
+	assert.Equal(t, 64, valueTypeBits(common.ValueTypeInt64))
+	assert.Equal(t, 64, valueTypeBits(common.ValueTypeBool))
+}
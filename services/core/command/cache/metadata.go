@@ -0,0 +1,123 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+)
+
+// MetadataCache keeps the devices, device services and device profiles core-command looks up in core-metadata.
+// Entries are dropped when core-metadata announces a change through a system event, and expire after the TTL
+// given when they were stored in case an event is missed.
+type MetadataCache struct {
+	mutex    sync.Mutex
+	devices  map[string]expiring[responses.DeviceResponse]
+	services map[string]expiring[responses.DeviceHandlerResponse]
+	profiles map[string]expiring[responses.DeviceTemplateResponse]
+}
+
+type expiring[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// NewMetadataCache creates an empty MetadataCache
+func NewMetadataCache() *MetadataCache {
+	return &MetadataCache{
+		devices:  make(map[string]expiring[responses.DeviceResponse]),
+		services: make(map[string]expiring[responses.DeviceHandlerResponse]),
+		profiles: make(map[string]expiring[responses.DeviceTemplateResponse]),
+	}
+}
+
+func lookup[T any](entries map[string]expiring[T], name string) (T, bool) {
+	entry, ok := entries[name]
+	if !ok || !time.Now().Before(entry.expires) {
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func store[T any](entries map[string]expiring[T], name string, value T, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	entries[name] = expiring[T]{value: value, expires: time.Now().Add(ttl)}
+}
+
+// Device returns the cached device, if any
+func (c *MetadataCache) Device(name string) (responses.DeviceResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return lookup(c.devices, name)
+}
+
+// SetDevice caches the device for ttl
+func (c *MetadataCache) SetDevice(name string, device responses.DeviceResponse, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	store(c.devices, name, device, ttl)
+}
+
+// DeviceService returns the cached device service, if any
+func (c *MetadataCache) DeviceService(name string) (responses.DeviceHandlerResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return lookup(c.services, name)
+}
+
+// SetDeviceService caches the device service for ttl
+func (c *MetadataCache) SetDeviceService(name string, service responses.DeviceHandlerResponse, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	store(c.services, name, service, ttl)
+}
+
+// DeviceProfile returns the cached device profile, if any
+func (c *MetadataCache) DeviceProfile(name string) (responses.DeviceTemplateResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return lookup(c.profiles, name)
+}
+
+// SetDeviceProfile caches the device profile for ttl
+func (c *MetadataCache) SetDeviceProfile(name string, profile responses.DeviceTemplateResponse, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	store(c.profiles, name, profile, ttl)
+}
+
+// InvalidateDevice drops the cached device
+func (c *MetadataCache) InvalidateDevice(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.devices, name)
+}
+
+// InvalidateDeviceService drops the cached device service
+func (c *MetadataCache) InvalidateDeviceService(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.services, name)
+}
+
+// InvalidateDeviceProfile drops the cached device profile
+func (c *MetadataCache) InvalidateDeviceProfile(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.profiles, name)
+}
+
+// Clear drops every cached entry
+func (c *MetadataCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.devices = make(map[string]expiring[responses.DeviceResponse])
+	c.services = make(map[string]expiring[responses.DeviceHandlerResponse])
+	c.profiles = make(map[string]expiring[responses.DeviceTemplateResponse])
+}
@@ -0,0 +1,131 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+)
+
+// ReadFunc issues a get command to the device service
+type ReadFunc func() (*responses.EventResponse, errors.IIOT)
+
+// ReadCache keeps the responses of get commands for a short time and coalesces concurrent identical reads, so
+// clients polling the same command share one request to the device.
+type ReadCache struct {
+	mutex   sync.Mutex
+	entries map[string]readEntry
+	calls   map[string]*readCall
+	// generation is incremented by InvalidateDevice; a read started in an earlier generation is not cached
+	generation uint64
+}
+
+type readEntry struct {
+	response *responses.EventResponse
+	expires  time.Time
+}
+
+// readCall is a read in flight, shared by every caller asking for the same key until it completes
+type readCall struct {
+	done       chan struct{}
+	generation uint64
+	response   *responses.EventResponse
+	err        errors.IIOT
+}
+
+// NewReadCache creates an empty ReadCache
+func NewReadCache() *ReadCache {
+	return &ReadCache{
+		entries: make(map[string]readEntry),
+		calls:   make(map[string]*readCall),
+	}
+}
+
+// ReadKey identifies a get command by device, command and its normalized query parameters
+func ReadKey(deviceName string, commandName string, queryParams string) string {
+	return deviceName + "\x00" + commandName + "\x00" + queryParams
+}
+
+// Read returns the cached response for key when it is younger than ttl, waits for an identical read already in
+// flight, or otherwise issues read and caches a successful response for ttl. At most maxEntries responses are
+// kept; a response that does not fit once expired entries are dropped is returned without being cached, and so is
+// the response of a read that was in flight when its device was invalidated.
+func (c *ReadCache) Read(key string, ttl time.Duration, maxEntries int, read ReadFunc) (*responses.EventResponse, errors.IIOT) {
+	c.mutex.Lock()
+	if entry, ok := c.entries[key]; ok {
+		if time.Now().Before(entry.expires) {
+			c.mutex.Unlock()
+			return entry.response, nil
+		}
+		delete(c.entries, key)
+	}
+	if call, ok := c.calls[key]; ok {
+		c.mutex.Unlock()
+		<-call.done
+		return call.response, call.err
+	}
+	call := &readCall{done: make(chan struct{}), generation: c.generation}
+	c.calls[key] = call
+	c.mutex.Unlock()
+
+	// release the waiting callers even when read panics, they then see this error
+	call.err = errors.NewCommonIIOT(errors.KindServerError, "read did not complete", nil)
+	defer func() {
+		c.mutex.Lock()
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+		c.mutex.Unlock()
+		close(call.done)
+	}()
+
+	call.response, call.err = read()
+
+	c.mutex.Lock()
+	if call.err == nil && call.response != nil && ttl > 0 && call.generation == c.generation {
+		if len(c.entries) >= maxEntries {
+			c.dropExpired()
+		}
+		if len(c.entries) < maxEntries {
+			c.entries[key] = readEntry{response: call.response, expires: time.Now().Add(ttl)}
+		}
+	}
+	c.mutex.Unlock()
+
+	return call.response, call.err
+}
+
+// InvalidateDevice drops the cached responses of the device, e.g. after a set command changed its values. Reads
+// of the device already in flight are not cached and later callers do not wait for them.
+func (c *ReadCache) InvalidateDevice(deviceName string) {
+	prefix := deviceName + "\x00"
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+	for key := range c.calls {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.calls, key)
+		}
+	}
+}
+
+// dropExpired removes the expired entries; the caller holds the mutex
+func (c *ReadCache) dropExpired() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
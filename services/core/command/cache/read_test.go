@@ -0,0 +1,99 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+)
+
+func TestReadCacheReleasesWaitersWhenReadPanics(t *testing.T) {
+	c := NewReadCache()
+	key := ReadKey("vfd-1", "Speed", "")
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { _ = recover() }()
+		_, _ = c.Read(key, time.Minute, 10, func() (*responses.EventResponse, errors.IIOT) {
+			close(started)
+			<-release
+			panic("device service client failed")
+		})
+	}()
+	<-started
+
+	waited := make(chan errors.IIOT)
+	go func() {
+		_, err := c.Read(key, time.Minute, 10, func() (*responses.EventResponse, errors.IIOT) {
+			t.Error("the waiting caller issued its own read")
+			return nil, nil
+		})
+		waited <- err
+	}()
+	// give the second caller time to join the read in flight
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-waited:
+		require.Error(t, err)
+		assert.Equal(t, errors.KindServerError, errors.Kind(err))
+	case <-time.After(time.Second):
+		t.Fatal("the waiting caller was not released")
+	}
+
+	// the failed read is no longer in flight
+	response := &responses.EventResponse{}
+	got, err := c.Read(key, time.Minute, 10, func() (*responses.EventResponse, errors.IIOT) { return response, nil })
+	require.NoError(t, err)
+	assert.Same(t, response, got)
+}
+
+func TestReadCacheDoesNotCacheReadsStartedBeforeInvalidation(t *testing.T) {
+	c := NewReadCache()
+	key := ReadKey("vfd-1", "Speed", "")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	stale := &responses.EventResponse{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		got, err := c.Read(key, time.Minute, 10, func() (*responses.EventResponse, errors.IIOT) {
+			close(started)
+			<-release
+			return stale, nil
+		})
+		assert.NoError(t, err)
+		assert.Same(t, stale, got)
+	}()
+	<-started
+
+	// a set command changes the device while the read is in flight
+	c.InvalidateDevice("vfd-1")
+
+	// later callers issue a new read instead of waiting for the stale one
+	fresh := &responses.EventResponse{}
+	got, err := c.Read(key, time.Minute, 10, func() (*responses.EventResponse, errors.IIOT) { return fresh, nil })
+	require.NoError(t, err)
+	assert.Same(t, fresh, got)
+
+	close(release)
+	<-done
+
+	// the stale response did not replace the fresh one
+	got, err = c.Read(key, time.Minute, 10, func() (*responses.EventResponse, errors.IIOT) {
+		t.Error("the fresh response was not cached")
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Same(t, fresh, got)
+}
@@ -36,6 +36,102 @@ type WritableInfo struct {
         LogLevel        string
         InsecureSecrets bootstrapConfig.InsecureSecrets
         Telemetry       bootstrapConfig.TelemetryInfo
+        Interlocks      map[string]InterlockInfo
+        SetRateLimits   map[string]SetRateLimitInfo
+        RateLimits      bootstrapConfig.RateLimitInfo
+        BatchCommand    BatchCommandInfo
+        ReadCache       ReadCacheInfo
+        MetadataCache   MetadataCacheInfo
+        CommandJob      CommandJobInfo
+        CommandQueue    CommandQueueInfo
+}
+
+// SetRateLimitInfo limits how often set commands reach the matching devices: at most MaxWrites, 1 when unset,
+// within any window of Period. An empty or "*" DeviceName matches every device, each device being limited on its
+// own. With a CommandName the limit applies to that command, otherwise to all set commands of the device together.
+type SetRateLimitInfo struct {
+        DeviceName  string
+        CommandName string
+        MaxWrites   int
+        Period      string
+}
+
+// CommandQueueInfo configures the queue of set commands for devices that are down or whose device service is
+// unreachable. A set command is only queued when Enabled and the request opts in with ds-queue. DefaultExpiry
+// applies when the request gives no ds-queueexpiry and MaxExpiry caps it. MaxPerDevice bounds the commands
+// queued for one device. Delivery is attempted when core-metadata reports the device up and every RetryInterval.
+// Zero values fall back to the built-in defaults.
+type CommandQueueInfo struct {
+        Enabled       bool
+        DefaultExpiry string
+        MaxExpiry     string
+        MaxPerDevice  int
+        RetryInterval string
+}
+
+// CommandJobInfo configures commands run as jobs with ds-async, or scheduled with ds-writeat. Timeout bounds how
+// long a job may wait for the device service and MaxJobs how many jobs may be pending or running at once.
+// MaxScheduleAhead is the furthest in the future a set command may be scheduled. Finished jobs are kept for
+// Retention. When NotificationCategory is set, a notification of that category is sent as each job finishes.
+// ds-callback URLs must name one of CallbackHosts, callbacks are refused when it is empty. The hosts are trusted
+// wherever they resolve to, unless CallbackPublicOnly also refuses loopback, private and link-local addresses.
+// Zero values fall back to the built-in defaults.
+type CommandJobInfo struct {
+        Timeout              string
+        Retention            string
+        MaxJobs              int
+        MaxScheduleAhead     string
+        NotificationCategory string
+        CallbackHosts        []string
+        CallbackPublicOnly   bool
+}
+
+// MetadataCacheInfo configures the cache of devices, device services and device profiles looked up in
+// core-metadata. Entries are dropped on the matching metadata system event and at the latest after TTL.
+type MetadataCacheInfo struct {
+        Enabled bool
+        TTL     string
+}
+
+// ReadCacheInfo configures the cache of get command responses. When Enabled, identical reads within TTL are
+// served from the cache and concurrent identical reads share one request to the device; a request can opt in or
+// out with the ds-usecache query parameter. MaxEntries bounds the number of cached responses.
+type ReadCacheInfo struct {
+        Enabled    bool
+        TTL        string
+        MaxEntries int
+}
+
+// BatchCommandInfo bounds batch commands. Concurrency is the default number of devices commanded at once and
+// MaxConcurrency the most a request may ask for. Timeout is the default time allowed per device and MaxDevices
+// the most devices one batch may address. Zero values fall back to the built-in defaults.
+type BatchCommandInfo struct {
+        Concurrency    int
+        MaxConcurrency int
+        Timeout        string
+        MaxDevices     int
+}
+
+// InterlockInfo is a safety rule that blocks set commands to the matching device and command unless all of its
+// conditions hold. An empty or "*" DeviceName matches every device and an empty CommandName every command.
+type InterlockInfo struct {
+        DeviceName  string
+        CommandName string
+        Description string
+        Conditions  []InterlockCondition
+}
+
+// InterlockCondition compares either the latest reading of a resource from core-data or, when ResourceName is
+// empty, the AdminState or OperatingState of a device from core-metadata against Value. DeviceName defaults to
+// the device the command targets. Operator is one of ==, !=, <, <=, > and >=, the ordering operators comparing
+// numerically. A reading older than MaxAge fails the condition; MaxAge defaults to one minute.
+type InterlockCondition struct {
+        DeviceName   string
+        ResourceName string
+        State        string
+        Operator     string
+        Value        string
+        MaxAge       string
 }
 
 // UpdateFromRaw converts configuration received from the registry to a service-specific configuration struct which is
@@ -106,4 +202,9 @@ func (c *ConfigurationStruct) GetInsecureSecrets() bootstrapConfig.InsecureSecre
 // GetTelemetryInfo returns the service's Telemetry settings.
 func (c *ConfigurationStruct) GetTelemetryInfo() *bootstrapConfig.TelemetryInfo {
         return &c.Writable.Telemetry
-}
\ No newline at end of file
+}
+
+// GetRateLimitInfo returns the service's per-client RateLimits settings.
+func (c *ConfigurationStruct) GetRateLimitInfo() *bootstrapConfig.RateLimitInfo {
+        return &c.Writable.RateLimits
+}
@@ -18,7 +18,10 @@ package container
 
 import (
 	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/services/core/command/cache"
 	"iiot-backend/services/core/command/config"
+	"iiot-backend/services/core/command/job"
+	"iiot-backend/services/core/command/ratelimit"
 )
 
 // ConfigurationName contains the name of command service's config.ConfigurationStruct implementation in the DIC.
@@ -27,4 +30,52 @@ var ConfigurationName = di.TypeInstanceToName((*config.ConfigurationStruct)(nil)
 // ConfigurationFrom helper function queries the DIC and returns command service's config.ConfigurationStruct implementation.
 func ConfigurationFrom(get di.Get) *config.ConfigurationStruct {
 	return get(ConfigurationName).(*config.ConfigurationStruct)
-}
\ No newline at end of file
+}
+
+// ReadCacheName contains the name of command service's cache.ReadCache implementation in the DIC.
+var ReadCacheName = di.TypeInstanceToName((*cache.ReadCache)(nil))
+
+// ReadCacheFrom helper function queries the DIC and returns command service's cache.ReadCache implementation.
+func ReadCacheFrom(get di.Get) *cache.ReadCache {
+	readCache, ok := get(ReadCacheName).(*cache.ReadCache)
+	if !ok {
+		return nil
+	}
+	return readCache
+}
+
+// MetadataCacheName contains the name of command service's cache.MetadataCache implementation in the DIC.
+var MetadataCacheName = di.TypeInstanceToName((*cache.MetadataCache)(nil))
+
+// MetadataCacheFrom helper function queries the DIC and returns command service's cache.MetadataCache implementation.
+func MetadataCacheFrom(get di.Get) *cache.MetadataCache {
+	metadataCache, ok := get(MetadataCacheName).(*cache.MetadataCache)
+	if !ok {
+		return nil
+	}
+	return metadataCache
+}
+
+// CommandJobStoreName contains the name of command service's job.Store implementation in the DIC.
+var CommandJobStoreName = di.TypeInstanceToName((*job.Store)(nil))
+
+// CommandJobStoreFrom helper function queries the DIC and returns command service's job.Store implementation.
+func CommandJobStoreFrom(get di.Get) *job.Store {
+	jobStore, ok := get(CommandJobStoreName).(*job.Store)
+	if !ok {
+		return nil
+	}
+	return jobStore
+}
+
+// SetRateLimiterName contains the name of command service's ratelimit.Limiter implementation in the DIC.
+var SetRateLimiterName = di.TypeInstanceToName((*ratelimit.Limiter)(nil))
+
+// SetRateLimiterFrom helper function queries the DIC and returns command service's ratelimit.Limiter implementation.
+func SetRateLimiterFrom(get di.Get) *ratelimit.Limiter {
+	limiter, ok := get(SetRateLimiterName).(*ratelimit.Limiter)
+	if !ok {
+		return nil
+	}
+	return limiter
+}
@@ -0,0 +1,22 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/services/core/command/infrastructure/interfaces"
+)
+
+// DBClientInterfaceName contains the name of the interfaces.DBClient implementation in the DIC.
+var DBClientInterfaceName = di.TypeInstanceToName((*interfaces.DBClient)(nil))
+
+// DBClientFrom helper function queries the DIC and returns the interfaces.DBClient implementation,
+// or nil when no database is configured.
+func DBClientFrom(get di.Get) interfaces.DBClient {
+	client, ok := get(DBClientInterfaceName).(interfaces.DBClient)
+	if !ok {
+		return nil
+	}
+	return client
+}
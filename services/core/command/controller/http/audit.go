@@ -0,0 +1,122 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"iiot-backend/middleware/auth"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/caller"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/mtls/peer"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	commonDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/common"
+	responseDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+
+	"iiot-backend/pkg/utils"
+	"iiot-backend/services/core/command/application"
+	commandContainer "iiot-backend/services/core/command/container"
+	"iiot-backend/services/core/command/infrastructure/interfaces"
+
+	"github.com/labstack/echo/v4"
+)
+
+// callerFromRequest identifies the caller of a command by the principal auth.Authenticate verified or the JWT
+// subject the bootstrap authentication hook verified, falling back to the identity of a verified client
+// certificate and then to the remote address.
+func callerFromRequest(c echo.Context) string {
+	if principal := auth.PrincipalFrom(c); principal != nil && principal.Username != "" {
+		return principal.Username
+	}
+	if subject := caller.Subject(c.Request().Context()); subject != "" {
+		return subject
+	}
+	if certificate := peer.Certificate(c.Request()); certificate != nil {
+		if identities := peer.Identities(certificate); len(identities) > 0 {
+			return identities[0]
+		}
+	}
+	return c.Request().RemoteAddr
+}
+
+func parseTimestampQuery(r *http.Request, key string) (int64, errors.IIOT) {
+	raw := utils.ParseQueryStringToString(r, key, "0")
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value < 0 {
+		return 0, errors.NewCommonIIOT(errors.KindContractInvalid, "invalid query parameter "+key+", it must be a non-negative timestamp in milliseconds", err)
+	}
+	return value, nil
+}
+
+func (cc *CommandController) CommandAudits(c echo.Context) error {
+	lc := container.LoggerClientFrom(cc.dic.Get)
+	r := c.Request()
+	w := c.Response()
+	ctx := r.Context()
+	config := commandContainer.ConfigurationFrom(cc.dic.Get)
+
+	// parse URL query string for offset, limit
+	offset, limit, _, err := utils.ParseGetAllObjectsRequestQueryString(c, 0, math.MaxInt32, -1, config.Service.MaxResultCount)
+	if err != nil {
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+	start, err := parseTimestampQuery(r, common.Start)
+	if err != nil {
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+	end, err := parseTimestampQuery(r, common.End)
+	if err != nil {
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+	if end != 0 && start > end {
+		err = errors.NewCommonIIOT(errors.KindContractInvalid, "end must be greater than or equal to start", nil)
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+
+	filter := interfaces.CommandAuditFilter{
+		DeviceName:  utils.ParseQueryStringToString(r, common.Device, ""),
+		CommandName: utils.ParseQueryStringToString(r, common.Command, ""),
+		Method:      utils.ParseQueryStringToString(r, common.Method, ""),
+		Source:      strings.ToUpper(utils.ParseQueryStringToString(r, common.Source, "")),
+		Start:       start,
+		End:         end,
+	}
+	audits, totalCount, err := application.CommandAudits(filter, offset, limit, cc.dic)
+	if err != nil {
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+
+	response := responseDTO.NewMultiCommandAuditsResponse("", "", http.StatusOK, totalCount, audits)
+	utils.WriteHttpHeader(w, ctx, http.StatusOK)
+	// encode and send out the response
+	return utils.EncodeAndWriteResponse(response, w, lc)
+}
+
+func (cc *CommandController) PurgeCommandAuditsByAge(c echo.Context) error {
+	lc := container.LoggerClientFrom(cc.dic.Get)
+	r := c.Request()
+	w := c.Response()
+	ctx := r.Context()
+
+	// URL parameters
+	age, parseErr := strconv.ParseInt(c.Param(common.Age), 10, 64)
+	if parseErr != nil || age < 0 {
+		err := errors.NewCommonIIOT(errors.KindContractInvalid, "age must be a non-negative number of milliseconds", parseErr)
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+
+	err := application.PurgeCommandAuditsByAge(age, cc.dic)
+	if err != nil {
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+
+	response := commonDTO.NewBaseResponse("", "", http.StatusAccepted)
+	utils.WriteHttpHeader(w, ctx, http.StatusAccepted)
+	// encode and send out the response
+	return utils.EncodeAndWriteResponse(response, w, lc)
+}
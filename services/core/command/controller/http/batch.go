@@ -0,0 +1,57 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/handlers"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/requests"
+	responseDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	"iiot-backend/pkg/utils"
+	"iiot-backend/services/core/command/application"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (cc *CommandController) IssueBatchCommand(c echo.Context) error {
+	lc := container.LoggerClientFrom(cc.dic.Get)
+	r := c.Request()
+	w := c.Response()
+	ctx := r.Context()
+
+	// Request body
+	var req requests.BatchCommandRequest
+	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+		err := errors.NewCommonIIOTWrapper(decodeErr)
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+
+	results, err := application.IssueBatchCommand(req, models.CommandAuditSourceREST, callerFromRequest(c), handlers.FromContext(ctx), cc.dic)
+	if err != nil {
+		return utils.WriteErrorResponse(w, ctx, lc, err, req.RequestId)
+	}
+
+	statusCode := batchStatusCode(results)
+	response := responseDTO.NewBatchCommandResponse(req.RequestId, "", statusCode, results)
+	utils.WriteHttpHeader(w, ctx, statusCode)
+	// encode and send out the response
+	return utils.EncodeAndWriteResponse(response, w, lc)
+}
+
+// batchStatusCode is 200 when the command succeeded on every device and 207 otherwise
+func batchStatusCode(results []dtos.BatchCommandResult) int {
+	for _, result := range results {
+		if result.StatusCode < 200 || result.StatusCode > 299 {
+			return http.StatusMultiStatus
+		}
+	}
+	return http.StatusOK
+}
@@ -11,10 +11,12 @@ import (
 	"net/http"
 
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/handlers"
 	"iiot-backend/pkg/go-mod-bootstrap/di"
 	"iiot-backend/pkg/go-mod-core-contracts/common"
 	responseDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
 	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
 
 	"iiot-backend/pkg/utils"
 	"iiot-backend/services/core/command/application"
@@ -106,15 +108,23 @@ func (cc *CommandController) IssueGetCommandByName(c echo.Context) error {
 		return utils.WriteErrorResponse(w, ctx, lc, err, "")
 	}
 
+	audit := application.NewCommandAudit(models.CommandAuditSourceREST, callerFromRequest(c), deviceName, commandName,
+		http.MethodGet, queryParams, nil, handlers.FromContext(ctx))
+	if application.IsAsyncCommand(queryParams) {
+		return cc.issueAsyncCommand(c, http.MethodGet, nil, audit)
+	}
 	response, err := application.IssueGetCommandByName(deviceName, commandName, queryParams, cc.dic)
 	if err != nil {
+		application.RecordCommandAudit(audit, 0, err, cc.dic)
 		return utils.WriteErrorResponse(w, ctx, lc, err, "")
 	}
 	// encode and send out the response
 	if response != nil {
+		application.RecordCommandAudit(audit, response.StatusCode, nil, cc.dic)
 		utils.WriteHttpHeader(w, ctx, response.StatusCode)
 		return utils.EncodeAndWriteResponse(response, w, lc)
 	}
+	application.RecordCommandAudit(audit, http.StatusOK, nil, cc.dic)
 	// If dsReturnEvent is no, there will be no content returned in the http response
 	utils.WriteHttpHeader(w, ctx, http.StatusOK)
 	return nil
@@ -137,10 +147,20 @@ func (cc *CommandController) IssueSetCommandByName(c echo.Context) error {
 	if err != nil {
 		return utils.WriteErrorResponse(w, ctx, lc, err, "")
 	}
+	audit := application.NewCommandAudit(models.CommandAuditSourceREST, callerFromRequest(c), deviceName, commandName,
+		http.MethodPut, queryParams, settings, handlers.FromContext(ctx))
+	if application.IsAsyncCommand(queryParams) {
+		return cc.issueAsyncCommand(c, http.MethodPut, settings, audit)
+	}
+	if application.IsQueueRequested(queryParams, cc.dic) {
+		return cc.issueQueueableSetCommand(c, settings, audit)
+	}
 	response, err := application.IssueSetCommandByName(deviceName, commandName, queryParams, settings, cc.dic)
 	if err != nil {
+		application.RecordCommandAudit(audit, 0, err, cc.dic)
 		return utils.WriteErrorResponse(w, ctx, lc, err, "")
 	}
+	application.RecordCommandAudit(audit, response.StatusCode, nil, cc.dic)
 
 	utils.WriteHttpHeader(w, ctx, response.StatusCode)
 	// encode and send out the response
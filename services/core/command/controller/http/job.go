@@ -0,0 +1,56 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"net/http"
+
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	responseDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	"iiot-backend/pkg/utils"
+	"iiot-backend/services/core/command/application"
+
+	"github.com/labstack/echo/v4"
+)
+
+// issueAsyncCommand starts the command as a job and responds with 202 and the job
+func (cc *CommandController) issueAsyncCommand(c echo.Context, method string, settings map[string]any, audit models.CommandAudit) error {
+	lc := container.LoggerClientFrom(cc.dic.Get)
+	r := c.Request()
+	w := c.Response()
+	ctx := r.Context()
+
+	commandJob, err := application.StartCommandJob(method, c.Param(common.Name), c.Param(common.Command), r.URL.RawQuery, settings, audit, cc.dic)
+	if err != nil {
+		application.RecordCommandAudit(audit, 0, err, cc.dic)
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+
+	response := responseDTO.NewCommandJobResponse("", "", http.StatusAccepted, commandJob)
+	utils.WriteHttpHeader(w, ctx, http.StatusAccepted)
+	// encode and send out the response
+	return utils.EncodeAndWriteResponse(response, w, lc)
+}
+
+func (cc *CommandController) CommandJobById(c echo.Context) error {
+	lc := container.LoggerClientFrom(cc.dic.Get)
+	r := c.Request()
+	w := c.Response()
+	ctx := r.Context()
+
+	// URL parameters
+	id := c.Param(common.Id)
+	commandJob, err := application.CommandJobById(id, cc.dic)
+	if err != nil {
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+
+	response := responseDTO.NewCommandJobResponse("", "", http.StatusOK, commandJob)
+	utils.WriteHttpHeader(w, ctx, http.StatusOK)
+	// encode and send out the response
+	return utils.EncodeAndWriteResponse(response, w, lc)
+}
@@ -0,0 +1,97 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"math"
+	"net/http"
+
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	commonDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/common"
+	responseDTO "iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	"iiot-backend/pkg/utils"
+	"iiot-backend/services/core/command/application"
+	commandContainer "iiot-backend/services/core/command/container"
+
+	"github.com/labstack/echo/v4"
+)
+
+// issueQueueableSetCommand issues the set command, responding with 202 and the queued command when the device
+// can't be reached and the command is queued instead
+func (cc *CommandController) issueQueueableSetCommand(c echo.Context, settings map[string]any, audit models.CommandAudit) error {
+	lc := container.LoggerClientFrom(cc.dic.Get)
+	r := c.Request()
+	w := c.Response()
+	ctx := r.Context()
+
+	response, queuedCommand, err := application.IssueSetCommandOrQueue(c.Param(common.Name), c.Param(common.Command), r.URL.RawQuery, settings, audit, cc.dic)
+	if err != nil {
+		application.RecordCommandAudit(audit, 0, err, cc.dic)
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+	if queuedCommand != nil {
+		// audited once delivered
+		queuedResponse := responseDTO.NewQueuedCommandResponse("", "", http.StatusAccepted, *queuedCommand)
+		utils.WriteHttpHeader(w, ctx, http.StatusAccepted)
+		return utils.EncodeAndWriteResponse(queuedResponse, w, lc)
+	}
+	application.RecordCommandAudit(audit, response.StatusCode, nil, cc.dic)
+
+	utils.WriteHttpHeader(w, ctx, response.StatusCode)
+	// encode and send out the response
+	return utils.EncodeAndWriteResponse(response, w, lc)
+}
+
+func (cc *CommandController) QueuedCommands(c echo.Context) error {
+	return cc.queuedCommands(c, "")
+}
+
+func (cc *CommandController) QueuedCommandsByDeviceName(c echo.Context) error {
+	return cc.queuedCommands(c, c.Param(common.Name))
+}
+
+func (cc *CommandController) queuedCommands(c echo.Context, deviceName string) error {
+	lc := container.LoggerClientFrom(cc.dic.Get)
+	r := c.Request()
+	w := c.Response()
+	ctx := r.Context()
+	config := commandContainer.ConfigurationFrom(cc.dic.Get)
+
+	// parse URL query string for offset, limit
+	offset, limit, _, err := utils.ParseGetAllObjectsRequestQueryString(c, 0, math.MaxInt32, -1, config.Service.MaxResultCount)
+	if err != nil {
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+	queuedCommands, totalCount, err := application.QueuedCommands(deviceName, offset, limit, cc.dic)
+	if err != nil {
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+
+	response := responseDTO.NewMultiQueuedCommandsResponse("", "", http.StatusOK, totalCount, queuedCommands)
+	utils.WriteHttpHeader(w, ctx, http.StatusOK)
+	// encode and send out the response
+	return utils.EncodeAndWriteResponse(response, w, lc)
+}
+
+func (cc *CommandController) CancelQueuedCommand(c echo.Context) error {
+	lc := container.LoggerClientFrom(cc.dic.Get)
+	r := c.Request()
+	w := c.Response()
+	ctx := r.Context()
+
+	// URL parameters
+	id := c.Param(common.Id)
+	err := application.CancelQueuedCommand(id, cc.dic)
+	if err != nil {
+		return utils.WriteErrorResponse(w, ctx, lc, err, "")
+	}
+
+	response := commonDTO.NewBaseResponse("", "", http.StatusOK)
+	utils.WriteHttpHeader(w, ctx, http.StatusOK)
+	// encode and send out the response
+	return utils.EncodeAndWriteResponse(response, w, lc)
+}
@@ -0,0 +1,71 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	"iiot-backend/pkg/go-mod-messaging/pkg/types"
+
+	"iiot-backend/services/core/command/application"
+)
+
+// newBusCommandAudit starts the audit record of a command received over a message bus. Bus requests carry no
+// caller identity, so the topic the request arrived on is recorded in its place.
+func newBusCommandAudit(source models.CommandAuditSource, requestEnvelope types.MessageEnvelope, receivedTopic, deviceName, commandName, method string) models.CommandAudit {
+	httpMethod := http.MethodGet
+	var settings map[string]any
+	if strings.EqualFold(method, "set") {
+		httpMethod = http.MethodPut
+		settings = settingsFromPayload(requestEnvelope.Payload)
+	}
+
+	queryParams := url.Values{}
+	for key, value := range requestEnvelope.QueryParams {
+		queryParams.Set(key, value)
+	}
+
+	return application.NewCommandAudit(source, receivedTopic, deviceName, commandName, httpMethod,
+		queryParams.Encode(), settings, requestEnvelope.CorrelationID)
+}
+
+// settingsFromPayload decodes the settings of a set command, which arrive either decoded or as raw JSON
+func settingsFromPayload(payload any) map[string]any {
+	switch value := payload.(type) {
+	case map[string]any:
+		return value
+	case []byte:
+		var settings map[string]any
+		if json.Unmarshal(value, &settings) == nil {
+			return settings
+		}
+	case string:
+		var settings map[string]any
+		if json.Unmarshal([]byte(value), &settings) == nil {
+			return settings
+		}
+	}
+	return nil
+}
+
+// recordBusCommandAudit stores the audit record with the outcome of a command forwarded over the message bus
+func recordBusCommandAudit(audit models.CommandAudit, response *types.MessageEnvelope, statusCode int, requestErr error, dic *di.Container) {
+	if requestErr == nil && response != nil && response.ErrorCode != 0 {
+		requestErr = fmt.Errorf("%v", response.Payload)
+		if payload, ok := response.Payload.([]byte); ok {
+			requestErr = fmt.Errorf("%s", payload)
+		}
+	}
+	if statusCode == 0 && requestErr == nil {
+		statusCode = http.StatusOK
+	}
+	application.RecordCommandAudit(audit, statusCode, requestErr, dic)
+}
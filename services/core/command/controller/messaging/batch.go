@@ -0,0 +1,123 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package messaging
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/requests"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+	"iiot-backend/pkg/go-mod-messaging/messaging"
+
+	"iiot-backend/pkg/go-mod-messaging/pkg/types"
+
+	"iiot-backend/services/core/command/application"
+	"iiot-backend/services/core/command/container"
+)
+
+// SubscribeBatchCommandRequests subscribes batch command requests from EdgeX service (e.g., Application Service)
+// via internal MessageBus
+func SubscribeBatchCommandRequests(ctx context.Context, dic *di.Container) errors.IIOT {
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	baseTopic := container.ConfigurationFrom(dic.Get).MessageBus.GetBaseTopicPrefix()
+	batchRequestTopic := common.BuildTopic(baseTopic, common.CoreCommandBatchRequestSubscribeTopic)
+
+	messages := make(chan types.MessageEnvelope)
+	messageErrors := make(chan error)
+	topics := []types.TopicChannel{
+		{
+			Topic:    batchRequestTopic,
+			Messages: messages,
+		},
+	}
+
+	messageBus := bootstrapContainer.MessagingClientFrom(dic.Get)
+
+	lc.Infof("Subscribing to internal batch command requests on topic: %s", batchRequestTopic)
+
+	err := messageBus.Subscribe(topics, messageErrors)
+	if err != nil {
+		return errors.NewCommonIIOTWrapper(err)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				lc.Infof("Exiting waiting for MessageBus '%s' topic messages", batchRequestTopic)
+				return
+			case err = <-messageErrors:
+				lc.Error(err.Error())
+			case requestEnvelope := <-messages:
+				// a batch waits on many devices, so it must not hold up the requests that follow it
+				go processBatchCommandRequest(messageBus, requestEnvelope, baseTopic, lc, dic)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func processBatchCommandRequest(
+	messageBus messaging.MessageClient,
+	requestEnvelope types.MessageEnvelope,
+	baseTopic string,
+	lc logger.LoggerClient,
+	dic *di.Container,
+) {
+	lc.Debugf("Batch command request received on internal MessageBus. Topic: %s, Request-id: %s, Correlation-id: %s", requestEnvelope.ReceivedTopic, requestEnvelope.RequestID, requestEnvelope.CorrelationID)
+
+	if len(strings.TrimSpace(requestEnvelope.RequestID)) == 0 {
+		lc.Errorf("RequestId not set in batch command request received on internal MessageBus")
+		lc.Warn("Not publishing error message back due to insufficient information to publish on response topic")
+		return
+	}
+
+	// internal response topic scheme: <ResponseTopicPrefix>/<service-name>/<request-id>
+	internalResponseTopic := common.BuildTopic(baseTopic, common.ResponseTopic, common.CoreCommandServiceName, requestEnvelope.RequestID)
+
+	responseEnvelope, err := getBatchCommandResponseEnvelope(requestEnvelope, dic)
+	if err != nil {
+		lc.Error(err.Error())
+		responseEnvelope = types.NewMessageEnvelopeWithError(requestEnvelope.RequestID, err.Error())
+	}
+
+	err = messageBus.Publish(responseEnvelope, internalResponseTopic)
+	if err != nil {
+		lc.Errorf("Could not publish to topic '%s': %s", internalResponseTopic, err.Error())
+		return
+	}
+
+	lc.Debugf("Batch command response sent to internal MessageBus. Topic: %s, Correlation-id: %s", internalResponseTopic, requestEnvelope.CorrelationID)
+}
+
+func getBatchCommandResponseEnvelope(requestEnvelope types.MessageEnvelope, dic *di.Container) (types.MessageEnvelope, error) {
+	req, err := types.GetMsgPayload[requests.BatchCommandRequest](requestEnvelope)
+	if err != nil {
+		return types.MessageEnvelope{}, err
+	}
+
+	results, batchErr := application.IssueBatchCommand(req, models.CommandAuditSourceMessageBus, requestEnvelope.ReceivedTopic, requestEnvelope.CorrelationID, dic)
+	if batchErr != nil {
+		return types.MessageEnvelope{}, batchErr
+	}
+
+	statusCode := http.StatusOK
+	for _, result := range results {
+		if result.StatusCode < 200 || result.StatusCode > 299 {
+			statusCode = http.StatusMultiStatus
+			break
+		}
+	}
+	batchResponse := responses.NewBatchCommandResponse(req.RequestId, "", statusCode, results)
+	return types.NewMessageEnvelopeForResponse(batchResponse, requestEnvelope.RequestID, requestEnvelope.CorrelationID, common.ContentTypeJSON)
+}
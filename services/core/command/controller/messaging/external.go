@@ -9,6 +9,7 @@ package messaging
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -18,9 +19,11 @@ import (
 	"iiot-backend/pkg/go-mod-bootstrap/di"
 	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
 	"iiot-backend/pkg/go-mod-core-contracts/common"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
 
 	"iiot-backend/pkg/go-mod-messaging/pkg/types"
 
+	"iiot-backend/services/core/command/application"
 	"iiot-backend/services/core/command/container"
 )
 
@@ -142,8 +145,11 @@ func commandRequestHandler(requestTimeout time.Duration, dic *di.Container) mqtt
 		internalBaseTopic := config.MessageBus.GetBaseTopicPrefix()
 		topicPrefix := common.BuildTopic(internalBaseTopic, common.CoreCommandDeviceRequestPublishTopic)
 
+		audit := newBusCommandAudit(models.CommandAuditSourceExternalMQTT, requestEnvelope, message.Topic(), deviceName, commandName, method)
+
 		deviceServiceName, err := retrieveServiceNameByDevice(deviceName, dic)
 		if err != nil {
+			recordBusCommandAudit(audit, nil, http.StatusNotFound, err, dic)
 			responseEnvelope := types.NewMessageEnvelopeWithError(requestEnvelope.RequestID, err.Error())
 			publishMessage(client, externalResponseTopic, qos, retain, responseEnvelope, lc)
 			return
@@ -151,11 +157,21 @@ func commandRequestHandler(requestTimeout time.Duration, dic *di.Container) mqtt
 
 		err = validateGetCommandQueryParameters(requestEnvelope.QueryParams)
 		if err != nil {
+			recordBusCommandAudit(audit, nil, http.StatusBadRequest, err, dic)
 			responseEnvelope := types.NewMessageEnvelopeWithError(requestEnvelope.RequestID, err.Error())
 			publishMessage(client, externalResponseTopic, qos, retain, responseEnvelope, lc)
 			return
 		}
 
+		if strings.EqualFold(method, "set") {
+			if err := checkSetCommand(deviceName, commandName, settingsFromPayload(requestEnvelope.Payload), dic); err != nil {
+				recordBusCommandAudit(audit, nil, err.Code(), err, dic)
+				responseEnvelope := types.NewMessageEnvelopeWithError(requestEnvelope.RequestID, err.Error())
+				publishMessage(client, externalResponseTopic, qos, retain, responseEnvelope, lc)
+				return
+			}
+		}
+
 		deviceRequestTopic := common.NewPathBuilder().EnableNameFieldEscape(config.Service.EnableNameFieldEscape).
 			SetPath(topicPrefix).SetNameFieldPath(deviceServiceName).SetNameFieldPath(deviceName).SetNameFieldPath(commandName).SetPath(method).BuildPath()
 		deviceResponseTopicPrefix := common.NewPathBuilder().EnableNameFieldEscape(config.Service.EnableNameFieldEscape).
@@ -169,12 +185,15 @@ func commandRequestHandler(requestTimeout time.Duration, dic *di.Container) mqtt
 		// Request waits for the response and returns it.
 		response, err := internalMessageBus.Request(requestEnvelope, deviceRequestTopic, deviceResponseTopicPrefix, requestTimeout)
 		if err != nil {
+			recordBusCommandAudit(audit, nil, http.StatusServiceUnavailable, err, dic)
 			errorMessage := fmt.Sprintf("Failed to send DeviceCommand request with internal MessageBus: %v", err)
 			responseEnvelope := types.NewMessageEnvelopeWithError(requestEnvelope.RequestID, errorMessage)
 			publishMessage(client, externalResponseTopic, qos, retain, responseEnvelope, lc)
 			return
 		}
 
+		recordBusCommandAudit(audit, response, 0, nil, dic)
+
 		lc.Debugf("Command response received from internal MessageBus. Topic: %s, Request-id: %s Correlation-id: %s", response.ReceivedTopic, response.RequestID, response.CorrelationID)
 
 		response.ReceivedTopic = externalResponseTopic
@@ -9,6 +9,7 @@ package messaging
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
 	"iiot-backend/pkg/go-mod-core-contracts/common"
 	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
 	"iiot-backend/pkg/go-mod-messaging/messaging"
 
 	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
@@ -23,6 +25,7 @@ import (
 
 	"iiot-backend/pkg/go-mod-messaging/pkg/types"
 
+	"iiot-backend/services/core/command/application"
 	"iiot-backend/services/core/command/container"
 )
 
@@ -128,10 +131,13 @@ func processDeviceCommandRequest(
 		return
 	}
 
+	audit := newBusCommandAudit(models.CommandAuditSourceMessageBus, requestEnvelope, requestEnvelope.ReceivedTopic, deviceName, commandName, method)
+
 	topicPrefix := common.BuildTopic(baseTopic, common.CoreCommandDeviceRequestPublishTopic)
 	// internal command request topic scheme: <DeviceRequestTopicPrefix>/<device-service>/<device>/<command-name>/<method>
 	deviceServiceName, err := retrieveServiceNameByDevice(deviceName, dic)
 	if err != nil {
+		recordBusCommandAudit(audit, nil, http.StatusNotFound, err, dic)
 		err = fmt.Errorf("invalid request topic: %s", err.Error())
 		lc.Error(err.Error())
 		responseEnvelope := types.NewMessageEnvelopeWithError(requestEnvelope.RequestID, err.Error())
@@ -144,6 +150,7 @@ func processDeviceCommandRequest(
 
 	err = validateGetCommandQueryParameters(requestEnvelope.QueryParams)
 	if err != nil {
+		recordBusCommandAudit(audit, nil, http.StatusBadRequest, err, dic)
 		lc.Errorf(err.Error())
 		responseEnvelope := types.NewMessageEnvelopeWithError(requestEnvelope.RequestID, err.Error())
 		err = messageBus.Publish(responseEnvelope, internalResponseTopic)
@@ -153,6 +160,41 @@ func processDeviceCommandRequest(
 		return
 	}
 
+	if application.IsAsyncCommand(queryStringFromEnvelope(requestEnvelope)) {
+		responseEnvelope, err := getCommandJobResponseEnvelope(requestEnvelope, deviceName, commandName, method, audit, dic)
+		if err != nil {
+			lc.Error(err.Error())
+			responseEnvelope = types.NewMessageEnvelopeWithError(requestEnvelope.RequestID, err.Error())
+		}
+		if err = messageBus.Publish(responseEnvelope, internalResponseTopic); err != nil {
+			lc.Errorf("Could not publish to topic '%s': %s", internalResponseTopic, err.Error())
+		}
+		return
+	}
+
+	if strings.EqualFold(method, "set") && application.IsQueueRequested(queryStringFromEnvelope(requestEnvelope), dic) {
+		responseEnvelope, err := getQueueableSetCommandResponseEnvelope(requestEnvelope, deviceName, commandName, audit, dic)
+		if err != nil {
+			lc.Error(err.Error())
+			responseEnvelope = types.NewMessageEnvelopeWithError(requestEnvelope.RequestID, err.Error())
+		}
+		if err = messageBus.Publish(responseEnvelope, internalResponseTopic); err != nil {
+			lc.Errorf("Could not publish to topic '%s': %s", internalResponseTopic, err.Error())
+		}
+		return
+	}
+
+	if strings.EqualFold(method, "set") {
+		if err := checkSetCommand(deviceName, commandName, settingsFromPayload(requestEnvelope.Payload), dic); err != nil {
+			recordBusCommandAudit(audit, nil, err.Code(), err, dic)
+			responseEnvelope := types.NewMessageEnvelopeWithError(requestEnvelope.RequestID, err.Error())
+			if err := messageBus.Publish(responseEnvelope, internalResponseTopic); err != nil {
+				lc.Errorf("Could not publish to topic '%s': %s", internalResponseTopic, err.Error())
+			}
+			return
+		}
+	}
+
 	deviceRequestTopic := common.NewPathBuilder().EnableNameFieldEscape(config.Service.EnableNameFieldEscape).
 		SetPath(topicPrefix).SetNameFieldPath(deviceServiceName).SetNameFieldPath(deviceName).SetNameFieldPath(commandName).SetPath(method).BuildPath()
 	deviceResponseTopicPrefix := common.NewPathBuilder().EnableNameFieldEscape(config.Service.EnableNameFieldEscape).
@@ -163,9 +205,11 @@ func processDeviceCommandRequest(
 
 	response, err := messageBus.Request(requestEnvelope, deviceRequestTopic, deviceResponseTopicPrefix, requestTimeout)
 	if err != nil {
+		recordBusCommandAudit(audit, nil, http.StatusServiceUnavailable, err, dic)
 		lc.Errorf("Request to topic '%s' failed: %s", deviceRequestTopic, err.Error())
 		return
 	}
+	recordBusCommandAudit(audit, response, 0, nil, dic)
 
 	// original request is from internal MessageBus
 	err = messageBus.Publish(*response, internalResponseTopic)
@@ -0,0 +1,98 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package messaging
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	"iiot-backend/pkg/go-mod-messaging/pkg/types"
+
+	"iiot-backend/services/core/command/application"
+	"iiot-backend/services/core/command/container"
+)
+
+// SubscribeCommandJobProgress subscribes the progress that device services publish for the command jobs they run
+func SubscribeCommandJobProgress(ctx context.Context, dic *di.Container) errors.IIOT {
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	baseTopic := container.ConfigurationFrom(dic.Get).MessageBus.GetBaseTopicPrefix()
+	progressTopic := common.BuildTopic(baseTopic, common.CoreCommandJobProgressSubscribeTopic)
+
+	messages := make(chan types.MessageEnvelope)
+	messageErrors := make(chan error)
+	topics := []types.TopicChannel{
+		{
+			Topic:    progressTopic,
+			Messages: messages,
+		},
+	}
+
+	messageBus := bootstrapContainer.MessagingClientFrom(dic.Get)
+
+	lc.Infof("Subscribing to command job progress on topic: %s", progressTopic)
+
+	err := messageBus.Subscribe(topics, messageErrors)
+	if err != nil {
+		return errors.NewCommonIIOTWrapper(err)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				lc.Infof("Exiting waiting for MessageBus '%s' topic messages", progressTopic)
+				return
+			case err = <-messageErrors:
+				lc.Error(err.Error())
+			case envelope := <-messages:
+				processCommandJobProgress(envelope, dic)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func processCommandJobProgress(envelope types.MessageEnvelope, dic *di.Container) {
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+
+	// topic scheme: <CommandJobProgressTopicPrefix>/<job-id>
+	topicLevels := strings.Split(envelope.ReceivedTopic, "/")
+	jobId := topicLevels[len(topicLevels)-1]
+
+	progress, err := types.GetMsgPayload[dtos.CommandJobProgress](envelope)
+	if err != nil {
+		lc.Errorf("Failed to decode progress of command job %s: %v", jobId, err)
+		return
+	}
+	if err := application.UpdateCommandJobProgress(jobId, progress, dic); err != nil {
+		lc.Debugf("Ignoring progress of command job %s: %v", jobId, err)
+	}
+}
+
+// getCommandJobResponseEnvelope starts the command of the request as a job and returns the response carrying the job
+func getCommandJobResponseEnvelope(requestEnvelope types.MessageEnvelope, deviceName, commandName, method string, audit models.CommandAudit, dic *di.Container) (types.MessageEnvelope, error) {
+	var settings map[string]any
+	if strings.EqualFold(method, "set") {
+		settings = settingsFromPayload(requestEnvelope.Payload)
+	}
+
+	commandJob, err := application.StartCommandJob(method, deviceName, commandName, queryStringFromEnvelope(requestEnvelope), settings, audit, dic)
+	if err != nil {
+		recordBusCommandAudit(audit, nil, err.Code(), err, dic)
+		return types.MessageEnvelope{}, err
+	}
+
+	jobResponse := responses.NewCommandJobResponse("", "", http.StatusAccepted, commandJob)
+	return types.NewMessageEnvelopeForResponse(jobResponse, requestEnvelope.RequestID, requestEnvelope.CorrelationID, common.ContentTypeJSON)
+}
@@ -0,0 +1,36 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package messaging
+
+import (
+	"net/http"
+
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	"iiot-backend/pkg/go-mod-messaging/pkg/types"
+
+	"iiot-backend/services/core/command/application"
+)
+
+// getQueueableSetCommandResponseEnvelope issues the set command of the request, or queues it when the device
+// can't be reached, and returns the response of the device service or the queued command
+func getQueueableSetCommandResponseEnvelope(requestEnvelope types.MessageEnvelope, deviceName, commandName string, audit models.CommandAudit, dic *di.Container) (types.MessageEnvelope, error) {
+	settings := settingsFromPayload(requestEnvelope.Payload)
+	response, queuedCommand, err := application.IssueSetCommandOrQueue(deviceName, commandName, queryStringFromEnvelope(requestEnvelope), settings, audit, dic)
+	if err != nil {
+		recordBusCommandAudit(audit, nil, err.Code(), err, dic)
+		return types.MessageEnvelope{}, err
+	}
+	if queuedCommand != nil {
+		// audited once delivered
+		queuedResponse := responses.NewQueuedCommandResponse("", "", http.StatusAccepted, *queuedCommand)
+		return types.NewMessageEnvelopeForResponse(queuedResponse, requestEnvelope.RequestID, requestEnvelope.CorrelationID, common.ContentTypeJSON)
+	}
+
+	application.RecordCommandAudit(audit, response.StatusCode, nil, dic)
+	return types.NewMessageEnvelopeForResponse(response, requestEnvelope.RequestID, requestEnvelope.CorrelationID, common.ContentTypeJSON)
+}
@@ -0,0 +1,109 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package messaging
+
+import (
+	"context"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+	"iiot-backend/pkg/go-mod-core-contracts/common"
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	"iiot-backend/pkg/go-mod-messaging/pkg/types"
+
+	"iiot-backend/services/core/command/application"
+	"iiot-backend/services/core/command/container"
+)
+
+// SubscribeMetadataSystemEvents subscribes the system events published by core-metadata, dropping the metadata cache
+// entries of the devices, device services and device profiles they report and delivering the queued commands of
+// devices reported up
+func SubscribeMetadataSystemEvents(ctx context.Context, dic *di.Container) errors.IIOT {
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	baseTopic := container.ConfigurationFrom(dic.Get).MessageBus.GetBaseTopicPrefix()
+	systemEventTopic := common.BuildTopic(baseTopic, common.SystemDataEventPublishTopic, common.CoreMetaDataServiceName, "#")
+
+	messages := make(chan types.MessageEnvelope)
+	messageErrors := make(chan error)
+	topics := []types.TopicChannel{
+		{
+			Topic:    systemEventTopic,
+			Messages: messages,
+		},
+	}
+
+	messageBus := bootstrapContainer.MessagingClientFrom(dic.Get)
+
+	lc.Infof("Subscribing to metadata system events on topic: %s", systemEventTopic)
+
+	err := messageBus.Subscribe(topics, messageErrors)
+	if err != nil {
+		return errors.NewCommonIIOTWrapper(err)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				lc.Infof("Exiting waiting for MessageBus '%s' topic messages", systemEventTopic)
+				return
+			case err = <-messageErrors:
+				lc.Error(err.Error())
+			case envelope := <-messages:
+				processMetadataSystemEvent(envelope, dic)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func processMetadataSystemEvent(envelope types.MessageEnvelope, dic *di.Container) {
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	metadataCache := container.MetadataCacheFrom(dic.Get)
+
+	systemEvent, err := types.GetMsgPayload[dtos.SystemDataEvent](envelope)
+	if err != nil {
+		// the event can't tell what changed, so nothing cached can be trusted
+		lc.Warnf("Failed to decode metadata system event received on topic %s, clearing the metadata cache: %v", envelope.ReceivedTopic, err)
+		if metadataCache != nil {
+			metadataCache.Clear()
+		}
+		return
+	}
+
+	// devices, device services and device profiles all carry their name in the details
+	var details struct {
+		Name           string
+		OperatingState string
+	}
+	if err = systemEvent.DecodeDetails(&details); err != nil || details.Name == "" {
+		lc.Warnf("Failed to decode details of metadata system event %s/%s, clearing the metadata cache: %v", systemEvent.Type, systemEvent.Action, err)
+		if metadataCache != nil {
+			metadataCache.Clear()
+		}
+		return
+	}
+
+	if metadataCache != nil {
+		switch systemEvent.Type {
+		case common.DeviceSystemDataEventType:
+			metadataCache.InvalidateDevice(details.Name)
+		case common.DeviceHandlerSystemDataEventType:
+			metadataCache.InvalidateDeviceService(details.Name)
+		case common.DeviceTemplateSystemDataEventType:
+			metadataCache.InvalidateDeviceProfile(details.Name)
+		}
+		lc.Debugf("Metadata cache entry of %s %s dropped on %s system event", systemEvent.Type, details.Name, systemEvent.Action)
+	}
+
+	// commands queued while the device was down can be delivered now
+	if systemEvent.Type == common.DeviceSystemDataEventType && details.OperatingState == models.Up &&
+		container.ConfigurationFrom(dic.Get).Writable.CommandQueue.Enabled {
+		go application.DeliverQueuedCommands(details.Name, dic)
+	}
+}
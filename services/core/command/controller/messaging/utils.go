@@ -8,15 +8,15 @@ package messaging
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 
-	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
 	"iiot-backend/pkg/go-mod-bootstrap/di"
 	"iiot-backend/pkg/go-mod-core-contracts/common"
 	"iiot-backend/pkg/go-mod-core-contracts/dtos/responses"
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
 
 	"iiot-backend/pkg/go-mod-messaging/pkg/types"
 
@@ -26,28 +26,41 @@ import (
 // retrieveServiceNameByDevice validates the existence of device and device service,
 // returns the service name to which the command request will be sent.
 func retrieveServiceNameByDevice(deviceName string, dic *di.Container) (string, error) {
-	// retrieve device information through Metadata DeviceClient
-	dc := bootstrapContainer.DeviceClientFrom(dic.Get)
-	if dc == nil {
-		return "", errors.New("nil Device Client")
-	}
-	deviceResponse, err := dc.DeviceByName(context.Background(), deviceName)
+	// retrieve device information through the metadata cache
+	deviceResponse, err := application.DeviceByName(context.Background(), deviceName, dic)
 	if err != nil {
 		return "", fmt.Errorf("failed to get Device by name %s: %v", deviceName, err)
 	}
 
-	// retrieve device service information through Metadata DeviceClient
-	dsc := bootstrapContainer.DeviceHandlerClientFrom(dic.Get)
-	if dsc == nil {
-		return "", errors.New("nil DeviceService Client")
-	}
-	deviceServiceResponse, err := dsc.DeviceServiceByName(context.Background(), deviceResponse.Device.ServiceName)
+	// retrieve device service information through the metadata cache
+	deviceServiceResponse, err := application.DeviceServiceByName(context.Background(), deviceResponse.Device.ServiceName, dic)
 	if err != nil {
 		return "", fmt.Errorf("failed to get DeviceService by name %s: %v", deviceResponse.Device.ServiceName, err)
 	}
 	return deviceServiceResponse.Service.Name, nil
 }
 
+// checkSetCommand validates the settings of set commands against the device profile and applies their interlocks
+// and rate limits before a command is forwarded over the bus
+func checkSetCommand(deviceName string, commandName string, settings map[string]any, dic *di.Container) errors.IIOT {
+	if err := application.ValidateSetCommand(deviceName, commandName, settings, dic); err != nil {
+		return err
+	}
+	if err := application.CheckInterlocks(deviceName, commandName, dic); err != nil {
+		return err
+	}
+	return application.CheckSetRateLimits(deviceName, commandName, dic)
+}
+
+// queryStringFromEnvelope encodes the query parameters of the request as a URL query string
+func queryStringFromEnvelope(requestEnvelope types.MessageEnvelope) string {
+	queryParams := url.Values{}
+	for key, value := range requestEnvelope.QueryParams {
+		queryParams.Set(key, value)
+	}
+	return queryParams.Encode()
+}
+
 // validateGetCommandQueryParameters validates the value is valid for device service's reserved query parameters
 func validateGetCommandQueryParameters(queryParams map[string]string) error {
 	if dsReturnEvent, ok := queryParams[common.ReturnDataEvent]; ok {
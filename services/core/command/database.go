@@ -0,0 +1,98 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/startup"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+
+	"iiot-backend/services/core/command/container"
+	"iiot-backend/services/core/command/infrastructure/postgres"
+)
+
+const (
+	primaryDatabaseKey = "Primary"
+	databaseSecretName = "postgres"
+	defaultDBTimeout   = 5 * time.Second
+	secretUsernameKey  = "username"
+	secretPasswordKey  = "password"
+)
+
+// DatabaseBootstrapHandler connects to the database that stores the command audit log and the command queue. The
+// database is optional: when it is not configured or cannot be reached, commands are still served but neither
+// audited nor queued.
+func DatabaseBootstrapHandler(ctx context.Context, wg *sync.WaitGroup, _ startup.Timer, dic *di.Container) bool {
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	configuration := container.ConfigurationFrom(dic.Get)
+
+	dbInfo, ok := configuration.Databases[primaryDatabaseKey]
+	if !ok || dbInfo.Host == "" {
+		lc.Warn("Databases.Primary not configured, command audit log and command queue disabled")
+		return true
+	}
+
+	timeout := defaultDBTimeout
+	if dbInfo.Timeout != "" {
+		parsed, err := time.ParseDuration(dbInfo.Timeout)
+		if err != nil {
+			lc.Errorf("Failed to parse Databases.Primary.Timeout configuration value: %v", err)
+			return false
+		}
+		timeout = parsed
+	}
+
+	secretProvider := bootstrapContainer.SecretProviderFrom(dic.Get)
+	credentials, err := secretProvider.RetrieveSecret(databaseSecretName, secretUsernameKey, secretPasswordKey)
+	if err != nil {
+		lc.Warnf("Unable to retrieve database credentials, command audit log and command queue disabled: %v", err)
+		return true
+	}
+
+	dbClient, edgeErr := postgres.NewClient(dbInfo.Host, dbInfo.Port, dbInfo.Name,
+		credentials[secretUsernameKey], credentials[secretPasswordKey], timeout)
+	if edgeErr != nil {
+		lc.Warnf("Unable to connect to database, command audit log and command queue disabled: %v", edgeErr)
+		return true
+	}
+
+	dic.Update(di.ServiceConstructorMap{
+		container.DBClientInterfaceName: func(get di.Get) interface{} {
+			return dbClient
+		},
+	})
+	lc.Infof("Command audit log and command queue stored in database %s on %s:%d", dbInfo.Name, dbInfo.Host, dbInfo.Port)
+
+	// Reconnect with the new credentials when the database secret is rotated
+	err = secretProvider.RegisterSecretUpdatedCallback(databaseSecretName, func(secretName string) {
+		credentials, err := secretProvider.RetrieveSecret(secretName, secretUsernameKey, secretPasswordKey)
+		if err != nil {
+			lc.Errorf("Unable to retrieve updated database credentials: %v", err)
+			return
+		}
+		if edgeErr := dbClient.UpdateCredentials(credentials[secretUsernameKey], credentials[secretPasswordKey]); edgeErr != nil {
+			lc.Errorf("Unable to reconnect to database with the updated credentials: %v", edgeErr)
+			return
+		}
+		lc.Info("Reconnected to database with the updated credentials")
+	})
+	if err != nil {
+		lc.Warnf("Database credentials will not follow updates of secret '%s': %v", databaseSecretName, err)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		<-ctx.Done()
+		dbClient.CloseSession()
+		lc.Info("Database disconnected")
+	}()
+
+	return true
+}
@@ -0,0 +1,39 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package interfaces
+
+import (
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+)
+
+// CommandAuditFilter narrows a command audit query. Empty fields and zero
+// timestamps are not applied.
+type CommandAuditFilter struct {
+	DeviceName  string
+	CommandName string
+	Method      string
+	Source      string
+	Start       int64
+	End         int64
+}
+
+// DBClient defines the interface for the core-command persistence
+type DBClient interface {
+	CloseSession()
+
+	AddCommandAudit(audit models.CommandAudit) errors.IIOT
+	CommandAudits(filter CommandAuditFilter, offset int, limit int) ([]models.CommandAudit, uint32, errors.IIOT)
+	DeleteCommandAuditsByAge(age int64) errors.IIOT
+
+	AddQueuedCommand(command models.QueuedCommand) (models.QueuedCommand, errors.IIOT)
+	QueuedCommands(deviceName string, offset int, limit int) ([]models.QueuedCommand, uint32, errors.IIOT)
+	QueuedCommandById(id string) (models.QueuedCommand, errors.IIOT)
+	QueuedCommandDeviceNames() ([]string, errors.IIOT)
+	DeleteQueuedCommandById(id string) errors.IIOT
+
+	AddScheduledCommand(command models.ScheduledCommand) errors.IIOT
+	ScheduledCommands() ([]models.ScheduledCommand, errors.IIOT)
+	DeleteScheduledCommand(jobId string) errors.IIOT
+}
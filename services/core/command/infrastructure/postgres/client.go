@@ -0,0 +1,200 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+	"iiot-backend/services/core/command/infrastructure/interfaces"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+const commandAuditColumns = `id, source, COALESCE(caller, ''), device_name, command_name, method,
+	COALESCE(query_params, ''), settings, status_code, COALESCE(error_message, ''), latency,
+	COALESCE(correlation_id, ''), created`
+
+// Client is the PostgreSQL implementation of interfaces.DBClient
+type Client struct {
+	mutex   sync.RWMutex
+	db      *sql.DB
+	host    string
+	port    int
+	name    string
+	timeout time.Duration
+}
+
+// NewClient opens the connection pool and checks the database is reachable
+func NewClient(host string, port int, name, username, password string, timeout time.Duration) (*Client, errors.IIOT) {
+	db, edgeErr := openPool(host, port, name, username, password, timeout)
+	if edgeErr != nil {
+		return nil, edgeErr
+	}
+	return &Client{db: db, host: host, port: port, name: name, timeout: timeout}, nil
+}
+
+func openPool(host string, port int, name, username, password string, timeout time.Duration) (*sql.DB, errors.IIOT) {
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable connect_timeout=%d",
+		host, port, username, password, name, int(timeout.Seconds()))
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to open database connection", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to ping database", err)
+	}
+
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(2)
+
+	return db, nil
+}
+
+// UpdateCredentials connects with the new credentials and replaces the connection pool, so that a rotated
+// database password needs no restart. The previous pool is closed once its in-flight queries are done.
+func (c *Client) UpdateCredentials(username, password string) errors.IIOT {
+	db, edgeErr := openPool(c.host, c.port, c.name, username, password, c.timeout)
+	if edgeErr != nil {
+		return edgeErr
+	}
+
+	c.mutex.Lock()
+	previous := c.db
+	c.db = db
+	c.mutex.Unlock()
+
+	previous.Close()
+	return nil
+}
+
+// conn returns the current connection pool
+func (c *Client) conn() *sql.DB {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.db
+}
+
+// BeginTx starts a transaction on the current connection pool, so that the audit log can be stored
+// in the command database
+func (c *Client) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return c.conn().BeginTx(ctx, opts)
+}
+
+// QueryContext runs a query on the current connection pool
+func (c *Client) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.conn().QueryContext(ctx, query, args...)
+}
+
+// CloseSession closes the connection pool
+func (c *Client) CloseSession() {
+	c.conn().Close()
+}
+
+// AddCommandAudit stores a command audit record, assigning its id and creation time when unset
+func (c *Client) AddCommandAudit(audit models.CommandAudit) errors.IIOT {
+	if audit.Id == "" {
+		audit.Id = uuid.NewString()
+	}
+	if audit.Created == 0 {
+		audit.Created = time.Now().UnixMilli()
+	}
+
+	var settings []byte
+	if audit.Settings != nil {
+		var err error
+		settings, err = json.Marshal(audit.Settings)
+		if err != nil {
+			return errors.NewCommonIIOT(errors.KindContractInvalid, "failed to encode command settings", err)
+		}
+	}
+
+	query := `
+		INSERT INTO command_audits (id, source, caller, device_name, command_name, method, query_params,
+			settings, status_code, error_message, latency, correlation_id, created)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, NULLIF($7, ''), $8, $9, NULLIF($10, ''), $11, NULLIF($12, ''), $13)`
+
+	_, err := c.conn().Exec(query, audit.Id, string(audit.Source), audit.Caller, audit.DeviceName, audit.CommandName,
+		audit.Method, audit.QueryParams, settings, audit.StatusCode, audit.ErrorMessage, audit.Latency,
+		audit.CorrelationId, audit.Created)
+	if err != nil {
+		return errors.NewCommonIIOT(errors.KindDatabaseError, "failed to add command audit", err)
+	}
+	return nil
+}
+
+// CommandAudits returns the matching command audit records, newest first, with the total count of matches
+func (c *Client) CommandAudits(filter interfaces.CommandAuditFilter, offset int, limit int) ([]models.CommandAudit, uint32, errors.IIOT) {
+	where := `
+		WHERE ($1 = '' OR device_name = $1)
+		AND ($2 = '' OR command_name = $2)
+		AND ($3 = '' OR UPPER(method) = UPPER($3))
+		AND ($4 = '' OR source = $4)
+		AND ($5 = 0 OR created >= $5)
+		AND ($6 = 0 OR created <= $6)`
+	args := []interface{}{filter.DeviceName, filter.CommandName, filter.Method, filter.Source, filter.Start, filter.End}
+
+	var totalCount uint32
+	if err := c.conn().QueryRow(`SELECT COUNT(*) FROM command_audits`+where, args...).Scan(&totalCount); err != nil {
+		return nil, 0, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to count command audits", err)
+	}
+
+	// a negative limit means no limit
+	var limitArg interface{}
+	if limit >= 0 {
+		limitArg = limit
+	}
+	query := `SELECT ` + commandAuditColumns + ` FROM command_audits` + where + `
+		ORDER BY created DESC
+		OFFSET $7 LIMIT $8`
+
+	rows, err := c.conn().Query(query, append(args, offset, limitArg)...)
+	if err != nil {
+		return nil, 0, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to query command audits", err)
+	}
+	defer rows.Close()
+
+	audits := make([]models.CommandAudit, 0)
+	for rows.Next() {
+		var audit models.CommandAudit
+		var source string
+		var settings []byte
+		err := rows.Scan(&audit.Id, &source, &audit.Caller, &audit.DeviceName, &audit.CommandName, &audit.Method,
+			&audit.QueryParams, &settings, &audit.StatusCode, &audit.ErrorMessage, &audit.Latency,
+			&audit.CorrelationId, &audit.Created)
+		if err != nil {
+			return nil, 0, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to scan command audit", err)
+		}
+		audit.Source = models.CommandAuditSource(source)
+		if len(settings) > 0 {
+			if err := json.Unmarshal(settings, &audit.Settings); err != nil {
+				return nil, 0, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to decode command settings", err)
+			}
+		}
+		audits = append(audits, audit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to read command audits", err)
+	}
+
+	return audits, totalCount, nil
+}
+
+// DeleteCommandAuditsByAge removes the command audit records older than age milliseconds
+func (c *Client) DeleteCommandAuditsByAge(age int64) errors.IIOT {
+	expireTimestamp := time.Now().UnixMilli() - age
+	if _, err := c.conn().Exec(`DELETE FROM command_audits WHERE created < $1`, expireTimestamp); err != nil {
+		return errors.NewCommonIIOT(errors.KindDatabaseError, "failed to delete command audits", err)
+	}
+	return nil
+}
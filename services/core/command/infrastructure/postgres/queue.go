@@ -0,0 +1,153 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+
+	"github.com/google/uuid"
+)
+
+const queuedCommandColumns = `id, device_name, command_name, COALESCE(query_params, ''), settings, source,
+	COALESCE(caller, ''), COALESCE(correlation_id, ''), COALESCE(reason, ''), expires, created`
+
+// AddQueuedCommand appends a set command to the queue of its device, assigning its id and creation time when unset
+func (c *Client) AddQueuedCommand(command models.QueuedCommand) (models.QueuedCommand, errors.IIOT) {
+	if command.Id == "" {
+		command.Id = uuid.NewString()
+	}
+	if command.Created == 0 {
+		command.Created = time.Now().UnixMilli()
+	}
+
+	settings, err := json.Marshal(command.Settings)
+	if err != nil {
+		return command, errors.NewCommonIIOT(errors.KindContractInvalid, "failed to encode command settings", err)
+	}
+
+	query := `
+		INSERT INTO command_queue (id, device_name, command_name, query_params, settings, source, caller,
+			correlation_id, reason, expires, created)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10, $11)`
+
+	_, err = c.conn().Exec(query, command.Id, command.DeviceName, command.CommandName, command.QueryParams, settings,
+		string(command.Source), command.Caller, command.CorrelationId, command.Reason, command.Expires, command.Created)
+	if err != nil {
+		return command, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to queue command", err)
+	}
+	return command, nil
+}
+
+// QueuedCommands returns the queued commands of the device, or of every device when deviceName is empty, in the
+// order they are delivered, with the total count of matches
+func (c *Client) QueuedCommands(deviceName string, offset int, limit int) ([]models.QueuedCommand, uint32, errors.IIOT) {
+	where := ` WHERE ($1 = '' OR device_name = $1)`
+
+	var totalCount uint32
+	if err := c.conn().QueryRow(`SELECT COUNT(*) FROM command_queue`+where, deviceName).Scan(&totalCount); err != nil {
+		return nil, 0, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to count queued commands", err)
+	}
+
+	// a negative limit means no limit
+	var limitArg interface{}
+	if limit >= 0 {
+		limitArg = limit
+	}
+	query := `SELECT ` + queuedCommandColumns + ` FROM command_queue` + where + `
+		ORDER BY seq
+		OFFSET $2 LIMIT $3`
+
+	rows, err := c.conn().Query(query, deviceName, offset, limitArg)
+	if err != nil {
+		return nil, 0, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to query queued commands", err)
+	}
+	defer rows.Close()
+
+	commands := make([]models.QueuedCommand, 0)
+	for rows.Next() {
+		command, err := scanQueuedCommand(rows)
+		if err != nil {
+			return nil, 0, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to scan queued command", err)
+		}
+		commands = append(commands, command)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to read queued commands", err)
+	}
+
+	return commands, totalCount, nil
+}
+
+// QueuedCommandById returns the queued command with the given id
+func (c *Client) QueuedCommandById(id string) (models.QueuedCommand, errors.IIOT) {
+	row := c.conn().QueryRow(`SELECT `+queuedCommandColumns+` FROM command_queue WHERE id = $1`, id)
+	command, err := scanQueuedCommand(row)
+	if err == sql.ErrNoRows {
+		return command, errors.NewCommonIIOT(errors.KindEntityDoesNotExist, fmt.Sprintf("queued command %s does not exist", id), nil)
+	} else if err != nil {
+		return command, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to scan queued command", err)
+	}
+	return command, nil
+}
+
+// QueuedCommandDeviceNames returns the names of the devices that have queued commands
+func (c *Client) QueuedCommandDeviceNames() ([]string, errors.IIOT) {
+	rows, err := c.conn().Query(`SELECT DISTINCT device_name FROM command_queue`)
+	if err != nil {
+		return nil, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to query devices with queued commands", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to scan device name", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to read devices with queued commands", err)
+	}
+	return names, nil
+}
+
+// DeleteQueuedCommandById removes the queued command with the given id
+func (c *Client) DeleteQueuedCommandById(id string) errors.IIOT {
+	result, err := c.conn().Exec(`DELETE FROM command_queue WHERE id = $1`, id)
+	if err != nil {
+		return errors.NewCommonIIOT(errors.KindDatabaseError, "failed to delete queued command", err)
+	}
+	if count, err := result.RowsAffected(); err == nil && count == 0 {
+		return errors.NewCommonIIOT(errors.KindEntityDoesNotExist, fmt.Sprintf("queued command %s does not exist", id), nil)
+	}
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanQueuedCommand(row rowScanner) (models.QueuedCommand, error) {
+	var command models.QueuedCommand
+	var source string
+	var settings []byte
+	err := row.Scan(&command.Id, &command.DeviceName, &command.CommandName, &command.QueryParams, &settings, &source,
+		&command.Caller, &command.CorrelationId, &command.Reason, &command.Expires, &command.Created)
+	if err != nil {
+		return command, err
+	}
+	command.Source = models.CommandAuditSource(source)
+	if err := json.Unmarshal(settings, &command.Settings); err != nil {
+		return command, fmt.Errorf("failed to decode command settings: %w", err)
+	}
+	return command, nil
+}
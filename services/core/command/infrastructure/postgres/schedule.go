@@ -0,0 +1,73 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"iiot-backend/pkg/go-mod-core-contracts/errors"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+)
+
+const scheduledCommandColumns = `job_id, device_name, command_name, COALESCE(query_params, ''), settings,
+	COALESCE(callback, ''), source, COALESCE(caller, ''), COALESCE(correlation_id, ''), scheduled, created`
+
+// AddScheduledCommand keeps a set command scheduled with ds-writeat until it is issued
+func (c *Client) AddScheduledCommand(command models.ScheduledCommand) errors.IIOT {
+	settings, err := json.Marshal(command.Settings)
+	if err != nil {
+		return errors.NewCommonIIOT(errors.KindContractInvalid, "failed to encode command settings", err)
+	}
+
+	query := `
+		INSERT INTO scheduled_commands (job_id, device_name, command_name, query_params, settings, callback, source,
+			caller, correlation_id, scheduled, created)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, ''), $7, NULLIF($8, ''), NULLIF($9, ''), $10, $11)`
+
+	_, err = c.conn().Exec(query, command.JobId, command.DeviceName, command.CommandName, command.QueryParams, settings,
+		command.Callback, string(command.Source), command.Caller, command.CorrelationId, command.Scheduled, command.Created)
+	if err != nil {
+		return errors.NewCommonIIOT(errors.KindDatabaseError, "failed to store scheduled command", err)
+	}
+	return nil
+}
+
+// ScheduledCommands returns the scheduled commands that have not been issued yet, the earliest first
+func (c *Client) ScheduledCommands() ([]models.ScheduledCommand, errors.IIOT) {
+	rows, err := c.conn().Query(`SELECT ` + scheduledCommandColumns + ` FROM scheduled_commands ORDER BY scheduled`)
+	if err != nil {
+		return nil, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to query scheduled commands", err)
+	}
+	defer rows.Close()
+
+	commands := make([]models.ScheduledCommand, 0)
+	for rows.Next() {
+		var command models.ScheduledCommand
+		var source string
+		var settings []byte
+		err := rows.Scan(&command.JobId, &command.DeviceName, &command.CommandName, &command.QueryParams, &settings,
+			&command.Callback, &source, &command.Caller, &command.CorrelationId, &command.Scheduled, &command.Created)
+		if err != nil {
+			return nil, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to scan scheduled command", err)
+		}
+		command.Source = models.CommandAuditSource(source)
+		if err := json.Unmarshal(settings, &command.Settings); err != nil {
+			return nil, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to scan scheduled command", fmt.Errorf("failed to decode command settings: %w", err))
+		}
+		commands = append(commands, command)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewCommonIIOT(errors.KindDatabaseError, "failed to read scheduled commands", err)
+	}
+	return commands, nil
+}
+
+// DeleteScheduledCommand removes the scheduled command of the command job, once it is issued or abandoned
+func (c *Client) DeleteScheduledCommand(jobId string) errors.IIOT {
+	if _, err := c.conn().Exec(`DELETE FROM scheduled_commands WHERE job_id = $1`, jobId); err != nil {
+		return errors.NewCommonIIOT(errors.KindDatabaseError, "failed to delete scheduled command", err)
+	}
+	return nil
+}
@@ -20,6 +20,9 @@ import (
         "context"
         "sync"
 
+        "iiot-backend/services/core/command/cache"
+        "iiot-backend/services/core/command/job"
+        "iiot-backend/services/core/command/ratelimit"
         "iiot-backend/services/core/command/container"
         bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
         "iiot-backend/pkg/go-mod-bootstrap/bootstrap/secret"
@@ -51,6 +54,21 @@ func (b *Bootstrap) BootstrapHandler(ctx context.Context, wg *sync.WaitGroup, _
         config := container.ConfigurationFrom(dic.Get)
         lc := bootstrapContainer.LoggingClientFrom(dic.Get) //this would fail when inside the func below?
 
+        dic.Update(di.ServiceConstructorMap{
+                container.ReadCacheName: func(get di.Get) interface{} {
+                        return cache.NewReadCache()
+                },
+                container.MetadataCacheName: func(get di.Get) interface{} {
+                        return cache.NewMetadataCache()
+                },
+                container.CommandJobStoreName: func(get di.Get) interface{} {
+                        return job.NewStore(ctx)
+                },
+                container.SetRateLimiterName: func(get di.Get) interface{} {
+                        return ratelimit.NewLimiter()
+                },
+        })
+
         // DeviceServiceCommandClient is not part of the common clients handled by the NewClientsBootstrap handler
         dic.Update(di.ServiceConstructorMap{
                 bootstrapContainer.DeviceHandlerCommandClientName: func(get di.Get) interface{} { // add API DeviceServiceCommandClient
@@ -0,0 +1,85 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"iiot-backend/pkg/go-mod-core-contracts/dtos"
+	"iiot-backend/pkg/go-mod-core-contracts/models"
+)
+
+// Store keeps the command jobs of core-command in memory. Jobs do not outlive the service, as the commands they
+// track are run by it, except for scheduled set commands which core-command persists until they are issued.
+type Store struct {
+	mutex sync.Mutex
+	jobs  map[string]dtos.CommandJob
+	ctx   context.Context
+}
+
+// NewStore creates an empty Store for the jobs of the service running until ctx is done
+func NewStore(ctx context.Context) *Store {
+	return &Store{jobs: make(map[string]dtos.CommandJob), ctx: ctx}
+}
+
+// Context returns the context of the service the jobs run in, jobs waiting for their scheduled time stop waiting
+// once it is done
+func (s *Store) Context() context.Context {
+	return s.ctx
+}
+
+// Finished reports whether the job has completed, successfully or not
+func Finished(job dtos.CommandJob) bool {
+	return job.Status == models.Succeeded || job.Status == models.Failed
+}
+
+// Add stores the job unless maxUnfinished jobs are already pending or running. Finished jobs last modified
+// longer than retention ago are dropped first.
+func (s *Store) Add(job dtos.CommandJob, maxUnfinished int, retention time.Duration) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	expired := time.Now().Add(-retention).UnixMilli()
+	unfinished := 0
+	for id, stored := range s.jobs {
+		if !Finished(stored) {
+			unfinished++
+		} else if stored.Modified < expired {
+			delete(s.jobs, id)
+		}
+	}
+	if maxUnfinished > 0 && unfinished >= maxUnfinished {
+		return false
+	}
+
+	s.jobs[job.Id] = job
+	return true
+}
+
+// Get returns the job with the given id
+func (s *Store) Get(id string) (dtos.CommandJob, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// Update applies update to the job with the given id, unless the job has already finished, and returns the
+// updated job. The modified timestamp is set on every update.
+func (s *Store) Update(id string, update func(job *dtos.CommandJob)) (dtos.CommandJob, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || Finished(job) {
+		return job, false
+	}
+	update(&job)
+	job.Modified = time.Now().UnixMilli()
+	s.jobs[id] = job
+	return job, true
+}
@@ -75,9 +75,12 @@ func Main(ctx context.Context, cancel context.CancelFunc, router *echo.Echo, arg
 		bootstrapConfig.ServiceTypeOther,
 		[]interfaces.BootstrapHandler{
 			handlers.NewClientsBootstrap().BootstrapHandler,
+			DatabaseBootstrapHandler,
 			MessagingBootstrapHandler,
+			CommandQueueBootstrapHandler,
 			handlers.NewServiceMetrics(common.CoreCommandServiceName).BootstrapHandler, // Must be after Messaging
 			NewBootstrap(router, common.CoreCommandServiceName).BootstrapHandler,
+			ScheduledCommandsBootstrapHandler, // Must be after the job store is created
 			httpServer.BootstrapHandler,
 			handlers.NewStartMessage(common.CoreCommandServiceName, version.CoreCommandVersion).BootstrapHandler,
 		})
@@ -120,5 +123,20 @@ func MessagingBootstrapHandler(ctx context.Context, wg *sync.WaitGroup, startupT
 		return false
 	}
 
+	if err := messaging.SubscribeBatchCommandRequests(ctx, dic); err != nil {
+		lc.Errorf("Failed to subscribe batch command requests from internal message bus, %v", err)
+		return false
+	}
+
+	if err := messaging.SubscribeMetadataSystemEvents(ctx, dic); err != nil {
+		lc.Errorf("Failed to subscribe metadata system events from internal message bus, %v", err)
+		return false
+	}
+
+	if err := messaging.SubscribeCommandJobProgress(ctx, dic); err != nil {
+		lc.Errorf("Failed to subscribe command job progress from internal message bus, %v", err)
+		return false
+	}
+
 	return true
 }
\ No newline at end of file
@@ -0,0 +1,36 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/startup"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+
+	"iiot-backend/services/core/command/application"
+)
+
+// CommandQueueBootstrapHandler retries the delivery of queued set commands periodically. This covers device
+// services that come back without the device being updated in core-metadata, as well as missed system events.
+func CommandQueueBootstrapHandler(ctx context.Context, wg *sync.WaitGroup, _ startup.Timer, dic *di.Container) bool {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for {
+			// read on every round so that a change to the writable configuration applies
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(application.CommandQueueRetryInterval(dic)):
+				application.DeliverAllQueuedCommands(dic)
+			}
+		}
+	}()
+
+	return true
+}
@@ -0,0 +1,65 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limit allows at most MaxEvents events for Key within any window of Period
+type Limit struct {
+	Key       string
+	MaxEvents int
+	Period    time.Duration
+}
+
+// Limiter tracks recent events per key in sliding windows
+type Limiter struct {
+	mutex  sync.Mutex
+	events map[string][]time.Time
+}
+
+// NewLimiter creates a Limiter without any recorded events
+func NewLimiter() *Limiter {
+	return &Limiter{events: make(map[string][]time.Time)}
+}
+
+// Allow records an event against every limit when none of them is exhausted. Otherwise nothing is recorded and
+// the index of the first exhausted limit is returned with how long until it allows an event again; the index is
+// -1 when the event was allowed.
+func (l *Limiter) Allow(limits []Limit) (int, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	for i, limit := range limits {
+		recent := l.recent(limit.Key, limit.Period, now)
+		if len(recent) >= limit.MaxEvents {
+			// the oldest event in the window is the next to leave it
+			return i, recent[len(recent)-limit.MaxEvents].Add(limit.Period).Sub(now)
+		}
+	}
+	for _, limit := range limits {
+		l.events[limit.Key] = append(l.events[limit.Key], now)
+	}
+	return -1, 0
+}
+
+// recent drops the events of key that are older than period and returns the remaining ones
+func (l *Limiter) recent(key string, period time.Duration, now time.Time) []time.Time {
+	events := l.events[key]
+	start := now.Add(-period)
+	i := 0
+	for i < len(events) && !events[i].After(start) {
+		i++
+	}
+	events = events[i:]
+	if len(events) == 0 {
+		delete(l.events, key)
+		return nil
+	}
+	l.events[key] = events
+	return events
+}
@@ -6,18 +6,22 @@
 package command
 
 import (
+	"iiot-backend/middleware/audit"
 	"iiot-backend/pkg/go-mod-core-contracts/version"
 	commandController "iiot-backend/services/core/command/controller/http"
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/controller"
 	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/handlers"
 	"iiot-backend/pkg/go-mod-bootstrap/di"
 	"iiot-backend/pkg/go-mod-core-contracts/common"
+	"iiot-backend/services/core/command/container"
 
 	"github.com/labstack/echo/v4"
 )
 
 func LoadRestRoutes(r *echo.Echo, dic *di.Container, serviceName string) {
 	authenticationHook := handlers.AutoConfigAuthenticationFunc(dic)
+	record := auditRecorder(dic)
 
 	// Common
 	_ = controller.NewCommonController(dic, r, serviceName, version.CoreCommandVersion)
@@ -27,5 +31,45 @@ func LoadRestRoutes(r *echo.Echo, dic *di.Container, serviceName string) {
 	r.GET(common.ApiAllDeviceRoute, cmd.AllCommands, authenticationHook)
 	r.GET(common.ApiDeviceByNameRoute, cmd.CommandsByDeviceName, authenticationHook)
 	r.GET(common.ApiDeviceNameCommandNameRoute, cmd.IssueGetCommandByName, authenticationHook)
-	r.PUT(common.ApiDeviceNameCommandNameRoute, cmd.IssueSetCommandByName, authenticationHook)
-}
\ No newline at end of file
+	r.PUT(common.ApiDeviceNameCommandNameRoute, cmd.IssueSetCommandByName, record, authenticationHook)
+	r.POST(common.ApiBatchCommandRoute, cmd.IssueBatchCommand, record, authenticationHook)
+
+	// Command audit
+	r.GET(common.ApiCommandAuditRoute, cmd.CommandAudits, authenticationHook)
+	r.DELETE(common.ApiCommandAuditByAgeRoute, cmd.PurgeCommandAuditsByAge, record, authenticationHook)
+
+	// Command job
+	r.GET(common.ApiCommandJobByIdRoute, cmd.CommandJobById, authenticationHook)
+
+	// Command queue
+	r.GET(common.ApiCommandQueueRoute, cmd.QueuedCommands, authenticationHook)
+	r.GET(common.ApiCommandQueueByDeviceNameRoute, cmd.QueuedCommandsByDeviceName, authenticationHook)
+	r.DELETE(common.ApiCommandQueueByIdRoute, cmd.CancelQueuedCommand, record, authenticationHook)
+}
+
+// auditRecorder returns the middleware recording mutating calls in the audit log shared with the
+// backend, stored in the command database and chained with the key of the audit-log secret or
+// AUDIT_LOG_KEY. Without the database or the key, calls are not recorded.
+func auditRecorder(dic *di.Container) echo.MiddlewareFunc {
+	lc := bootstrapContainer.LoggerClientFrom(dic.Get)
+	db, ok := container.DBClientFrom(dic.Get).(audit.Conn)
+	if !ok {
+		lc.Warn("No database connected, set commands are not recorded in the audit log")
+		return passThrough
+	}
+
+	var retrieve func(secretName string, keys ...string) (map[string]string, error)
+	if secretProvider := bootstrapContainer.SecretProviderFrom(dic.Get); secretProvider != nil {
+		retrieve = secretProvider.RetrieveSecret
+	}
+	key, err := audit.LogKey(retrieve)
+	if err != nil {
+		lc.Warnf("Set commands are not recorded in the audit log: %v", err)
+		return passThrough
+	}
+	return audit.Record(audit.NewLog(db, key))
+}
+
+func passThrough(next echo.HandlerFunc) echo.HandlerFunc {
+	return next
+}
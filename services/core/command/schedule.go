@@ -0,0 +1,24 @@
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"context"
+	"sync"
+
+	bootstrapContainer "iiot-backend/pkg/go-mod-bootstrap/bootstrap/container"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/startup"
+	"iiot-backend/pkg/go-mod-bootstrap/di"
+
+	"iiot-backend/services/core/command/application"
+)
+
+// ScheduledCommandsBootstrapHandler resumes the jobs of the set commands scheduled with ds-writeat before the
+// service last stopped. It must run after the command job store is created.
+func ScheduledCommandsBootstrapHandler(_ context.Context, _ *sync.WaitGroup, _ startup.Timer, dic *di.Container) bool {
+	if err := application.ResumeScheduledCommandJobs(dic); err != nil {
+		bootstrapContainer.LoggerClientFrom(dic.Get).Errorf("Failed to resume scheduled command jobs: %v", err)
+	}
+	return true
+}
@@ -0,0 +1,219 @@
+package data
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/models"
+	"iiot-backend/utils"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// scoped returns the service restricted to the tenant of the request
+func (h *Handler) scoped(c echo.Context) *Service {
+	return h.service.WithTenant(tenant.FromContext(c.Request().Context()))
+}
+
+// timeRange parses the RFC 3339 start and end query parameters, ignoring malformed ones
+func timeRange(c echo.Context) (start, end time.Time) {
+	if startStr := c.QueryParam("start"); startStr != "" {
+		start, _ = time.Parse(time.RFC3339, startStr)
+	}
+	if endStr := c.QueryParam("end"); endStr != "" {
+		end, _ = time.Parse(time.RFC3339, endStr)
+	}
+	return start, end
+}
+
+// Event handlers
+func (h *Handler) GetEvents(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+
+	if limit == 0 {
+		limit = 50
+	}
+
+	filter := models.EventFilter{
+		DeviceName:  c.QueryParam("device"),
+		ProfileName: c.QueryParam("profile"),
+		SourceName:  c.QueryParam("source"),
+		AssetNode:   c.QueryParam("assetNode"),
+		Limit:       limit,
+		Offset:      offset,
+	}
+	filter.Start, filter.End = timeRange(c)
+
+	events, err := h.scoped(c).GetEvents(filter)
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve events", err)
+	}
+
+	return utils.SuccessResponse(c, events)
+}
+
+func (h *Handler) GetEvent(c echo.Context) error {
+	id := c.Param("id")
+	event, err := h.scoped(c).GetEventByID(id)
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusNotFound, "Event not found", err)
+	}
+	return utils.SuccessResponse(c, event)
+}
+
+// CreateEvent stores an event sent by a device service, running its readings through the resource
+// transforms when they are enabled
+func (h *Handler) CreateEvent(c echo.Context) error {
+	var req models.EventRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+	}
+
+	id, err := h.scoped(c).CreateEvent(&req)
+	if errors.Is(err, ErrDeviceNotFound) {
+		return utils.ErrorResponse(c, http.StatusNotFound, "Device not found", err)
+	} else if errors.Is(err, ErrReadingDeviceMismatch) {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Reading of another device", err)
+	} else if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create event", err)
+	}
+
+	return utils.SuccessResponseWithStatus(c, http.StatusCreated, map[string]string{"id": id})
+}
+
+func (h *Handler) DeleteEvent(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.scoped(c).DeleteEvent(id); err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete event", err)
+	}
+	return utils.SuccessResponse(c, map[string]string{"message": "Event deleted successfully"})
+}
+
+func (h *Handler) DeleteEventsByDevice(c echo.Context) error {
+	deviceName := c.Param("device")
+	if err := h.scoped(c).DeleteEventsByDevice(deviceName); err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete events", err)
+	}
+	return utils.SuccessResponse(c, map[string]string{"message": "Events deleted successfully"})
+}
+
+func (h *Handler) DeleteEventsByAge(c echo.Context) error {
+	age, err := strconv.ParseInt(c.Param("age"), 10, 64)
+	if err != nil || age < 0 {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid age", err)
+	}
+	if err := h.scoped(c).DeleteEventsByAge(age); err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete events", err)
+	}
+	return utils.SuccessResponse(c, map[string]string{"message": "Events deleted successfully"})
+}
+
+// Reading handlers
+func (h *Handler) GetReadings(c echo.Context) error {
+	return h.getReadings(c, c.QueryParam("device"), c.QueryParam("resourceName"))
+}
+
+// GetReadingsByDevice serves the EdgeX route naming the device in the path
+func (h *Handler) GetReadingsByDevice(c echo.Context) error {
+	return h.getReadings(c, c.Param("name"), c.QueryParam("resourceName"))
+}
+
+// GetReadingsByResource serves the EdgeX route naming the resource in the path
+func (h *Handler) GetReadingsByResource(c echo.Context) error {
+	return h.getReadings(c, c.QueryParam("device"), c.Param("name"))
+}
+
+func (h *Handler) getReadings(c echo.Context, deviceName, resourceName string) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+
+	if limit == 0 {
+		limit = 50
+	}
+
+	filter := models.ReadingFilter{
+		DeviceName:   deviceName,
+		ResourceName: resourceName,
+		ProfileName:  c.QueryParam("profile"),
+		ValueType:    c.QueryParam("valueType"),
+		AssetNode:    c.QueryParam("assetNode"),
+		Unit:         c.QueryParam("unit"),
+		Limit:        limit,
+		Offset:       offset,
+	}
+	filter.Start, filter.End = timeRange(c)
+
+	readings, err := h.scoped(c).GetReadings(filter)
+	if errors.Is(err, ErrUnknownUnit) {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Unknown unit", err)
+	} else if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve readings", err)
+	}
+
+	return utils.SuccessResponse(c, readings)
+}
+
+func (h *Handler) GetReading(c echo.Context) error {
+	id := c.Param("id")
+	reading, err := h.scoped(c).GetReadingByID(id)
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusNotFound, "Reading not found", err)
+	}
+	return utils.SuccessResponse(c, reading)
+}
+
+func (h *Handler) DeleteReading(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.scoped(c).DeleteReading(id); err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete reading", err)
+	}
+	return utils.SuccessResponse(c, map[string]string{"message": "Reading deleted successfully"})
+}
+
+// Count handlers
+func (h *Handler) GetEventCount(c echo.Context) error {
+	count, err := h.scoped(c).GetEventCount()
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to count events", err)
+	}
+	return utils.SuccessResponse(c, map[string]int64{"count": count})
+}
+
+func (h *Handler) GetEventCountByDevice(c echo.Context) error {
+	count, err := h.scoped(c).GetEventCountByDevice(c.Param("device"))
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to count events", err)
+	}
+	return utils.SuccessResponse(c, map[string]int64{"count": count})
+}
+
+func (h *Handler) GetReadingCount(c echo.Context) error {
+	count, err := h.scoped(c).GetReadingCount()
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to count readings", err)
+	}
+	return utils.SuccessResponse(c, map[string]int64{"count": count})
+}
+
+func (h *Handler) GetReadingCountByDevice(c echo.Context) error {
+	count, err := h.scoped(c).GetReadingCountByDevice(c.Param("device"))
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to count readings", err)
+	}
+	return utils.SuccessResponse(c, map[string]int64{"count": count})
+}
@@ -0,0 +1,29 @@
+package data
+
+import (
+	"net/http"
+
+	"iiot-backend/pkg/go-mod-bootstrap/config"
+)
+
+// RateLimits returns the default per-client limits of the event and reading routes, generous enough
+// for device services and dashboards while keeping a single client from starving the others
+func RateLimits() config.RateLimitInfo {
+	return config.RateLimitInfo{
+		Enabled: true,
+		Groups: map[string]config.RateLimitGroupInfo{
+			"ingest": {
+				Routes:  []string{"/api/v3/event"},
+				Methods: []string{http.MethodPost},
+				Limit:   100,
+				Burst:   200,
+			},
+			"query": {
+				Routes:  []string{"/api/v3/event", "/api/v3/reading"},
+				Methods: []string{http.MethodGet},
+				Limit:   10,
+				Burst:   20,
+			},
+		},
+	}
+}
@@ -2,29 +2,63 @@ package data
 
 import (
 	"github.com/labstack/echo/v4"
+
+	"iiot-backend/middleware/auth"
+)
+
+const (
+	permissionDataRead  = "data:read"
+	permissionDataWrite = "data:write"
 )
 
-func RegisterRoutes(g *echo.Group, service *Service) {
+// RegisterRoutes registers the event and reading routes and returns the permission each of them
+// requires, for use with auth.RequirePermissions
+func RegisterRoutes(g *echo.Group, service *Service) auth.RoutePermissions {
 	handler := NewHandler(service)
+	permissions := auth.RoutePermissions{}
+	permit := func(route *echo.Route, required ...string) {
+		permissions[auth.Route(route.Method, route.Path)] = required
+	}
 
 	// Events routes
 	events := g.Group("/event")
-	events.GET("", handler.GetEvents)
-	events.GET("/:id", handler.GetEvent)
-	events.POST("", handler.CreateEvent)
-	events.DELETE("/:id", handler.DeleteEvent)
-	events.DELETE("/device/:device", handler.DeleteEventsByDevice)
-	events.DELETE("/age/:age", handler.DeleteEventsByAge)
+	permit(events.GET("", handler.GetEvents), permissionDataRead)
+	permit(events.GET("/:id", handler.GetEvent), permissionDataRead)
+	permit(events.POST("", handler.CreateEvent), permissionDataWrite)
+	permit(events.DELETE("/:id", handler.DeleteEvent), permissionDataWrite)
+	permit(events.DELETE("/device/:device", handler.DeleteEventsByDevice), permissionDataWrite)
+	permit(events.DELETE("/age/:age", handler.DeleteEventsByAge), permissionDataWrite)
 
 	// Readings routes
 	readings := g.Group("/reading")
-	readings.GET("", handler.GetReadings)
-	readings.GET("/:id", handler.GetReading)
-	readings.DELETE("/:id", handler.DeleteReading)
+	permit(readings.GET("", handler.GetReadings), permissionDataRead)
+	permit(readings.GET("/:id", handler.GetReading), permissionDataRead)
+	permit(readings.DELETE("/:id", handler.DeleteReading), permissionDataWrite)
 
 	// Count routes
-	g.GET("/event/count", handler.GetEventCount)
-	g.GET("/event/count/device/:device", handler.GetEventCountByDevice)
-	g.GET("/reading/count", handler.GetReadingCount)
-	g.GET("/reading/count/device/:device", handler.GetReadingCountByDevice)
+	permit(g.GET("/event/count", handler.GetEventCount), permissionDataRead)
+	permit(g.GET("/event/count/device/:device", handler.GetEventCountByDevice), permissionDataRead)
+	permit(g.GET("/reading/count", handler.GetReadingCount), permissionDataRead)
+	permit(g.GET("/reading/count/device/:device", handler.GetReadingCountByDevice), permissionDataRead)
+
+	return permissions
+}
+
+// RegisterEdgeXRoutes registers the EdgeX v3 event and reading routes served by core-data and
+// returns the permission each of them requires, for use with auth.RequirePermissions
+func RegisterEdgeXRoutes(g *echo.Group, service *Service) auth.RoutePermissions {
+	handler := NewHandler(service)
+	permissions := auth.RoutePermissions{}
+	permit := func(route *echo.Route, required ...string) {
+		permissions[auth.Route(route.Method, route.Path)] = required
+	}
+
+	permit(g.POST("/event", handler.CreateEvent), permissionDataWrite)
+	permit(g.GET("/event/all", handler.GetEvents), permissionDataRead)
+	permit(g.GET("/event/id/:id", handler.GetEvent), permissionDataRead)
+	permit(g.DELETE("/event/id/:id", handler.DeleteEvent), permissionDataWrite)
+	permit(g.GET("/reading/device/name/:name", handler.GetReadingsByDevice), permissionDataRead)
+	permit(g.GET("/reading/resource/name/:name", handler.GetReadingsByResource), permissionDataRead)
+
+	return permissions
 }
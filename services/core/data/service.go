@@ -3,31 +3,54 @@ package data
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"iiot-backend/middleware/tenant"
 	"iiot-backend/models"
+	"iiot-backend/services/core/metadata"
+)
+
+var (
+	// ErrDeviceNotFound is returned for events of devices unknown to core-metadata or outside the
+	// tenant scope of the service
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrReadingDeviceMismatch is returned for events carrying a reading of another device
+	ErrReadingDeviceMismatch = errors.New("reading device differs from the event device")
 )
 
 type Service struct {
-	db *sql.DB
+	db                *sql.DB
+	transformReadings bool
+	notifier          TenantNotifier
+	scope             tenant.Scope
 }
 
 func NewService(db *sql.DB) *Service {
-	return &Service{db: db}
+	return &Service{db: db, scope: tenant.Unrestricted()}
+}
+
+// WithTenant returns a copy of the service restricted to the events and readings visible in scope
+func (s *Service) WithTenant(scope tenant.Scope) *Service {
+	scoped := *s
+	scoped.scope = scope
+	return &scoped
 }
 
 // Event methods
 func (s *Service) GetEvents(filter models.EventFilter) ([]models.Event, error) {
 	query := `
-		SELECT id, device_name, profile_name, source_name, origin, tags, created, modified
+		SELECT id, device_name, profile_name, source_name, COALESCE(profile_version, 0), origin, tags, created, modified
 		FROM events
 		WHERE ($1 = '' OR device_name = $1)
 		  AND ($2 = '' OR profile_name = $2)
 		  AND ($3 = '' OR source_name = $3)
 		  AND ($4::timestamp IS NULL OR created >= $4)
 		  AND ($5::timestamp IS NULL OR created <= $5)
+		  AND ($8 = '' OR device_name IN (` + metadata.AssetDevicesSQL("$8") + `))
+		  AND %s
 		ORDER BY created DESC
 		LIMIT $6 OFFSET $7
 	`
@@ -40,8 +63,11 @@ func (s *Service) GetEvents(filter models.EventFilter) ([]models.Event, error) {
 		end = filter.End
 	}
 
-	rows, err := s.db.Query(query, filter.DeviceName, filter.ProfileName, filter.SourceName,
-		start, end, filter.Limit, filter.Offset)
+	args := []interface{}{filter.DeviceName, filter.ProfileName, filter.SourceName,
+		start, end, filter.Limit, filter.Offset, filter.AssetNode}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query events: %w", err)
 	}
@@ -54,7 +80,7 @@ func (s *Service) GetEvents(filter models.EventFilter) ([]models.Event, error) {
 
 		err := rows.Scan(
 			&event.ID, &event.DeviceName, &event.ProfileName, &event.SourceName,
-			&event.Origin, &tagsJSON, &event.Created, &event.Modified,
+			&event.ProfileVersion, &event.Origin, &tagsJSON, &event.Created, &event.Modified,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan event: %w", err)
@@ -78,18 +104,19 @@ func (s *Service) GetEvents(filter models.EventFilter) ([]models.Event, error) {
 }
 
 func (s *Service) GetEventByID(id string) (*models.Event, error) {
+	args := []interface{}{id}
 	query := `
-		SELECT id, device_name, profile_name, source_name, origin, tags, created, modified
+		SELECT id, device_name, profile_name, source_name, COALESCE(profile_version, 0), origin, tags, created, modified
 		FROM events
-		WHERE id = $1
+		WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 
 	var event models.Event
 	var tagsJSON []byte
 
-	err := s.db.QueryRow(query, id).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&event.ID, &event.DeviceName, &event.ProfileName, &event.SourceName,
-		&event.Origin, &tagsJSON, &event.Created, &event.Modified,
+		&event.ProfileVersion, &event.Origin, &tagsJSON, &event.Created, &event.Modified,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get event: %w", err)
@@ -109,7 +136,17 @@ func (s *Service) GetEventByID(id string) (*models.Event, error) {
 	return &event, nil
 }
 
+// CreateEvent stores an event and its readings for the tenant of the device, which must be known to
+// core-metadata and visible in the tenant scope of the service. Every reading must belong to the
+// device of the event.
 func (s *Service) CreateEvent(req *models.EventRequest) (string, error) {
+	for _, reading := range req.Readings {
+		if reading.DeviceName != req.DeviceName {
+			return "", fmt.Errorf("%w: reading %s of device %s in event of device %s", ErrReadingDeviceMismatch,
+				reading.ResourceName, reading.DeviceName, req.DeviceName)
+		}
+	}
+
 	tx, err := s.db.Begin()
 	if err != nil {
 		return "", fmt.Errorf("failed to begin transaction: %w", err)
@@ -126,13 +163,29 @@ func (s *Service) CreateEvent(req *models.EventRequest) (string, error) {
 
 	tagsJSON, _ := json.Marshal(req.Tags)
 
+	// events belong to the tenant of their device
+	eventTenant, err := deviceTenant(tx, req.DeviceName)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !s.scope.Allows(eventTenant)) {
+		return "", fmt.Errorf("%w: %s", ErrDeviceNotFound, req.DeviceName)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to resolve device tenant: %w", err)
+	}
+
+	profileVersion := req.ProfileVersion
+	if profileVersion == 0 {
+		profileVersion, err = resolveProfileVersion(tx, req.DeviceName, req.ProfileName)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve profile version: %w", err)
+		}
+	}
+
 	eventQuery := `
-		INSERT INTO events (id, device_name, profile_name, source_name, origin, tags, created, modified)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO events (id, device_name, profile_name, source_name, profile_version, origin, tags, created, modified, tenant_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7, $8, $9, $10)
 	`
 
 	_, err = tx.Exec(eventQuery, eventID, req.DeviceName, req.ProfileName, req.SourceName,
-		origin, tagsJSON, now, now)
+		profileVersion, origin, tagsJSON, now, now, eventTenant)
 	if err != nil {
 		return "", fmt.Errorf("failed to create event: %w", err)
 	}
@@ -140,11 +193,45 @@ func (s *Service) CreateEvent(req *models.EventRequest) (string, error) {
 	// Create readings
 	readingQuery := `
 		INSERT INTO readings (id, event_id, device_name, resource_name, profile_name, value_type,
-		                     value, binary_value, media_type, units, tags, origin, created, modified)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		                     value, binary_value, media_type, units, tags, origin, created, modified, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 	`
 
+	var violations []readingViolation
+	resourceProperties := make(map[string]map[string]models.ResourceProperties)
+
 	for _, readingReq := range req.Readings {
+		if s.transformReadings {
+			props, ok := resourceProperties[readingReq.ProfileName]
+			if !ok {
+				version := 0
+				if readingReq.ProfileName == req.ProfileName {
+					version = profileVersion
+				}
+				props, err = loadResourceProperties(tx, readingReq.ProfileName, version)
+				if err != nil {
+					return "", fmt.Errorf("failed to load resource properties: %w", err)
+				}
+				resourceProperties[readingReq.ProfileName] = props
+			}
+
+			if resource, ok := props[readingReq.ResourceName]; ok {
+				if reasons := transformReading(&readingReq, resource); len(reasons) > 0 {
+					severity := "MINOR"
+					if resource.Assertion != "" && readingReq.Value != resource.Assertion {
+						severity = "CRITICAL"
+					}
+					violations = append(violations, readingViolation{
+						deviceName:   readingReq.DeviceName,
+						resourceName: readingReq.ResourceName,
+						value:        readingReq.Value,
+						reasons:      reasons,
+						severity:     severity,
+					})
+				}
+			}
+		}
+
 		readingID := uuid.New().String()
 		readingOrigin := readingReq.Origin
 		if readingOrigin == 0 {
@@ -156,7 +243,7 @@ func (s *Service) CreateEvent(req *models.EventRequest) (string, error) {
 		_, err = tx.Exec(readingQuery, readingID, eventID, readingReq.DeviceName,
 			readingReq.ResourceName, readingReq.ProfileName, readingReq.ValueType,
 			readingReq.Value, readingReq.BinaryValue, readingReq.MediaType,
-			readingReq.Units, readingTagsJSON, readingOrigin, now, now)
+			readingReq.Units, readingTagsJSON, readingOrigin, now, now, eventTenant)
 		if err != nil {
 			return "", fmt.Errorf("failed to create reading: %w", err)
 		}
@@ -166,9 +253,37 @@ func (s *Service) CreateEvent(req *models.EventRequest) (string, error) {
 		return "", fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	s.notifyViolations(eventTenant, violations)
+
 	return eventID, nil
 }
 
+// resolveProfileVersion returns the profile version the device is pinned to,
+// or the current version of the profile when it is not pinned. It returns 0
+// when the profile is unknown to core-metadata.
+func resolveProfileVersion(tx *sql.Tx, deviceName, profileName string) (int, error) {
+	query := `
+		SELECT COALESCE(
+			(SELECT profile_version FROM devices WHERE name = $1 AND profile_name = $2),
+			(SELECT version FROM device_profiles WHERE name = $2),
+			0)
+	`
+
+	var version int
+	if err := tx.QueryRow(query, deviceName, profileName).Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// deviceTenant returns the tenant of the named device, sql.ErrNoRows for devices unknown to
+// core-metadata
+func deviceTenant(tx *sql.Tx, deviceName string) (string, error) {
+	var tenantID string
+	err := tx.QueryRow(`SELECT tenant_id FROM devices WHERE name = $1`, deviceName).Scan(&tenantID)
+	return tenantID, err
+}
+
 func (s *Service) DeleteEvent(id string) error {
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -176,14 +291,17 @@ func (s *Service) DeleteEvent(id string) error {
 	}
 	defer tx.Rollback()
 
+	args := []interface{}{id}
+	condition := s.scope.Condition("tenant_id", &args)
+
 	// Delete readings first
-	_, err = tx.Exec("DELETE FROM readings WHERE event_id = $1", id)
+	_, err = tx.Exec("DELETE FROM readings WHERE event_id = $1 AND "+condition, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete readings: %w", err)
 	}
 
 	// Delete event
-	_, err = tx.Exec("DELETE FROM events WHERE id = $1", id)
+	_, err = tx.Exec("DELETE FROM events WHERE id = $1 AND "+condition, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete event: %w", err)
 	}
@@ -198,14 +316,17 @@ func (s *Service) DeleteEventsByDevice(deviceName string) error {
 	}
 	defer tx.Rollback()
 
+	args := []interface{}{deviceName}
+	condition := s.scope.Condition("tenant_id", &args)
+
 	// Delete readings first
-	_, err = tx.Exec("DELETE FROM readings WHERE device_name = $1", deviceName)
+	_, err = tx.Exec("DELETE FROM readings WHERE device_name = $1 AND "+condition, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete readings: %w", err)
 	}
 
 	// Delete events
-	_, err = tx.Exec("DELETE FROM events WHERE device_name = $1", deviceName)
+	_, err = tx.Exec("DELETE FROM events WHERE device_name = $1 AND "+condition, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete events: %w", err)
 	}
@@ -222,14 +343,17 @@ func (s *Service) DeleteEventsByAge(ageInMilliseconds int64) error {
 	}
 	defer tx.Rollback()
 
+	args := []interface{}{cutoffTime}
+	condition := s.scope.Condition("tenant_id", &args)
+
 	// Delete readings first
-	_, err = tx.Exec("DELETE FROM readings WHERE created < $1", cutoffTime)
+	_, err = tx.Exec("DELETE FROM readings WHERE created < $1 AND "+condition, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete readings: %w", err)
 	}
 
 	// Delete events
-	_, err = tx.Exec("DELETE FROM events WHERE created < $1", cutoffTime)
+	_, err = tx.Exec("DELETE FROM events WHERE created < $1 AND "+condition, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete events: %w", err)
 	}
@@ -249,6 +373,8 @@ func (s *Service) GetReadings(filter models.ReadingFilter) ([]models.Reading, er
 		  AND ($4 = '' OR value_type = $4)
 		  AND ($5::timestamp IS NULL OR created >= $5)
 		  AND ($6::timestamp IS NULL OR created <= $6)
+		  AND ($9 = '' OR device_name IN (` + metadata.AssetDevicesSQL("$9") + `))
+		  AND %s
 		ORDER BY created DESC
 		LIMIT $7 OFFSET $8
 	`
@@ -261,8 +387,11 @@ func (s *Service) GetReadings(filter models.ReadingFilter) ([]models.Reading, er
 		end = filter.End
 	}
 
-	rows, err := s.db.Query(query, filter.DeviceName, filter.ResourceName, filter.ProfileName,
-		filter.ValueType, start, end, filter.Limit, filter.Offset)
+	args := []interface{}{filter.DeviceName, filter.ResourceName, filter.ProfileName,
+		filter.ValueType, start, end, filter.Limit, filter.Offset, filter.AssetNode}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query readings: %w", err)
 	}
@@ -290,21 +419,28 @@ func (s *Service) GetReadings(filter models.ReadingFilter) ([]models.Reading, er
 		readings = append(readings, reading)
 	}
 
+	if filter.Unit != "" {
+		if err := s.ConvertReadings(readings, filter.Unit); err != nil {
+			return nil, err
+		}
+	}
+
 	return readings, nil
 }
 
 func (s *Service) GetReadingByID(id string) (*models.Reading, error) {
+	args := []interface{}{id}
 	query := `
 		SELECT id, event_id, device_name, resource_name, profile_name, value_type,
 		       value, binary_value, media_type, units, tags, origin, created, modified
 		FROM readings
-		WHERE id = $1
+		WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 
 	var reading models.Reading
 	var tagsJSON []byte
 
-	err := s.db.QueryRow(query, id).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&reading.ID, &reading.EventID, &reading.DeviceName, &reading.ResourceName,
 		&reading.ProfileName, &reading.ValueType, &reading.Value, &reading.BinaryValue,
 		&reading.MediaType, &reading.Units, &tagsJSON, &reading.Origin,
@@ -322,8 +458,9 @@ func (s *Service) GetReadingByID(id string) (*models.Reading, error) {
 }
 
 func (s *Service) DeleteReading(id string) error {
-	query := `DELETE FROM readings WHERE id = $1`
-	_, err := s.db.Exec(query, id)
+	args := []interface{}{id}
+	query := `DELETE FROM readings WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args)
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete reading: %w", err)
 	}
@@ -374,7 +511,8 @@ func (s *Service) getReadingsByEventID(eventID string) ([]models.Reading, error)
 // Count methods
 func (s *Service) GetEventCount() (int64, error) {
 	var count int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM events").Scan(&count)
+	var args []interface{}
+	err := s.db.QueryRow("SELECT COUNT(*) FROM events WHERE "+s.scope.Condition("tenant_id", &args), args...).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get event count: %w", err)
 	}
@@ -383,7 +521,9 @@ func (s *Service) GetEventCount() (int64, error) {
 
 func (s *Service) GetEventCountByDevice(deviceName string) (int64, error) {
 	var count int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM events WHERE device_name = $1", deviceName).Scan(&count)
+	args := []interface{}{deviceName}
+	err := s.db.QueryRow("SELECT COUNT(*) FROM events WHERE device_name = $1 AND "+s.scope.Condition("tenant_id", &args), args...).
+		Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get event count by device: %w", err)
 	}
@@ -392,7 +532,8 @@ func (s *Service) GetEventCountByDevice(deviceName string) (int64, error) {
 
 func (s *Service) GetReadingCount() (int64, error) {
 	var count int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM readings").Scan(&count)
+	var args []interface{}
+	err := s.db.QueryRow("SELECT COUNT(*) FROM readings WHERE "+s.scope.Condition("tenant_id", &args), args...).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get reading count: %w", err)
 	}
@@ -401,7 +542,9 @@ func (s *Service) GetReadingCount() (int64, error) {
 
 func (s *Service) GetReadingCountByDevice(deviceName string) (int64, error) {
 	var count int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM readings WHERE device_name = $1", deviceName).Scan(&count)
+	args := []interface{}{deviceName}
+	err := s.db.QueryRow("SELECT COUNT(*) FROM readings WHERE device_name = $1 AND "+s.scope.Condition("tenant_id", &args), args...).
+		Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get reading count by device: %w", err)
 	}
@@ -0,0 +1,25 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"iiot-backend/models"
+)
+
+func TestCreateEventRejectsReadingsOfAnotherDevice(t *testing.T) {
+	req := &models.EventRequest{
+		DeviceName:  "press-1",
+		ProfileName: "press",
+		SourceName:  "temperature",
+		Readings: []models.ReadingRequest{
+			{DeviceName: "press-1", ResourceName: "temperature", ProfileName: "press", ValueType: "Float64", Value: "20"},
+			{DeviceName: "press-2", ResourceName: "temperature", ProfileName: "press", ValueType: "Float64", Value: "21"},
+		},
+	}
+
+	// rejected before the database is reached
+	_, err := NewService(nil).CreateEvent(req)
+	assert.ErrorIs(t, err, ErrReadingDeviceMismatch)
+}
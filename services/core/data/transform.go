@@ -0,0 +1,270 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/models"
+)
+
+// Reading tags written by the resource transform stage
+const (
+	TagRawValue       = "rawValue"
+	TagViolation      = "violation"
+	TagTransformError = "transformError"
+)
+
+var integerValueTypes = map[string]bool{
+	"Int8": true, "Int16": true, "Int32": true, "Int64": true,
+	"Uint8": true, "Uint16": true, "Uint32": true, "Uint64": true,
+}
+
+var floatValueTypes = map[string]bool{
+	"Float32": true, "Float64": true,
+}
+
+// ReadingNotifier raises notifications for readings that violate their
+// resource properties. The support-notifications Service satisfies it.
+type ReadingNotifier interface {
+	CreateNotification(req *models.NotificationRequest) (string, error)
+}
+
+// TenantNotifier returns the ReadingNotifier raising the notifications of the
+// tenant of scope, such as the WithTenant of the support-notifications Service
+type TenantNotifier func(scope tenant.Scope) ReadingNotifier
+
+// readingViolation is a reading flagged by the transform stage, notified once
+// the event has been stored
+type readingViolation struct {
+	deviceName   string
+	resourceName string
+	value        string
+	reasons      []string
+	severity     string
+}
+
+// EnableResourceTransforms turns on the ingest stage that applies the mask,
+// shift, base, scale and offset of the profile resource to raw readings and
+// checks them against minimum, maximum and assertion. Violating readings are
+// tagged, and also notified to the tenant of their event when notifier is not
+// nil.
+func (s *Service) EnableResourceTransforms(notifier TenantNotifier) {
+	s.transformReadings = true
+	s.notifier = notifier
+}
+
+// loadResourceProperties returns the properties of every resource of the
+// profile, taken from the given revision when it is known.
+func loadResourceProperties(tx *sql.Tx, profileName string, profileVersion int) (map[string]models.ResourceProperties, error) {
+	query := `
+		SELECT COALESCE(
+			(SELECT profile -> 'deviceResources' FROM device_profile_versions WHERE profile_name = $1 AND version = $2),
+			(SELECT device_resources FROM device_profiles WHERE name = $1),
+			'[]'::jsonb)
+	`
+
+	var resourcesJSON []byte
+	if err := tx.QueryRow(query, profileName, profileVersion).Scan(&resourcesJSON); err != nil {
+		return nil, err
+	}
+
+	var resources []map[string]interface{}
+	if err := json.Unmarshal(resourcesJSON, &resources); err != nil {
+		return nil, err
+	}
+
+	properties := make(map[string]models.ResourceProperties, len(resources))
+	for _, resource := range resources {
+		name, _ := resource["name"].(string)
+		props, _ := resource["properties"].(map[string]interface{})
+		if name == "" || props == nil {
+			continue
+		}
+		properties[name] = models.ResourceProperties{
+			ValueType: propertyString(props["valueType"]),
+			Units:     propertyString(props["units"]),
+			Minimum:   propertyString(props["minimum"]),
+			Maximum:   propertyString(props["maximum"]),
+			Mask:      propertyString(props["mask"]),
+			Shift:     propertyString(props["shift"]),
+			Scale:     propertyString(props["scale"]),
+			Offset:    propertyString(props["offset"]),
+			Base:      propertyString(props["base"]),
+			Assertion: propertyString(props["assertion"]),
+		}
+	}
+	return properties, nil
+}
+
+// propertyString accepts both the string and the numeric JSON form of a
+// resource property
+func propertyString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// transformReading applies the resource properties to the reading in place
+// and returns the reasons it violates them, if any.
+func transformReading(reading *models.ReadingRequest, props models.ResourceProperties) []string {
+	if reading.Value == "" {
+		return nil
+	}
+	if reading.Tags == nil {
+		reading.Tags = make(map[string]string)
+	}
+
+	raw := reading.Value
+	if integerValueTypes[reading.ValueType] || floatValueTypes[reading.ValueType] {
+		value, err := applyNumericTransforms(raw, reading.ValueType, props)
+		if err != nil {
+			reading.Tags[TagTransformError] = err.Error()
+			return nil
+		}
+		if value != raw {
+			reading.Value = value
+			reading.Tags[TagRawValue] = raw
+		}
+		if reading.Units == "" {
+			reading.Units = props.Units
+		}
+	}
+
+	violations := checkReadingLimits(reading.Value, reading.ValueType, props)
+	if len(violations) > 0 {
+		reading.Tags[TagViolation] = strings.Join(violations, "; ")
+	}
+	return violations
+}
+
+// applyNumericTransforms follows the device SDK order: mask, shift, base,
+// scale and offset. Mask and shift only apply to integer value types.
+func applyNumericTransforms(raw, valueType string, props models.ResourceProperties) (string, error) {
+	isInteger := integerValueTypes[valueType]
+	if props.Base == "" && props.Scale == "" && props.Offset == "" &&
+		(!isInteger || (props.Mask == "" && props.Shift == "")) {
+		return raw, nil
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return "", fmt.Errorf("value %s is not numeric", raw)
+	}
+
+	if props.Mask != "" && isInteger {
+		mask, err := strconv.ParseUint(props.Mask, 0, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid mask %s", props.Mask)
+		}
+		value = float64(uint64(int64(value)) & mask)
+	}
+	if props.Shift != "" && isInteger {
+		shift, err := strconv.ParseInt(props.Shift, 0, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid shift %s", props.Shift)
+		}
+		bits := uint64(int64(value))
+		if shift >= 0 {
+			bits <<= uint(shift)
+		} else {
+			bits >>= uint(-shift)
+		}
+		value = float64(bits)
+	}
+	if props.Base != "" {
+		base, err := strconv.ParseFloat(props.Base, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid base %s", props.Base)
+		}
+		value = math.Pow(base, value)
+	}
+	if props.Scale != "" {
+		scale, err := strconv.ParseFloat(props.Scale, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid scale %s", props.Scale)
+		}
+		value *= scale
+	}
+	if props.Offset != "" {
+		offset, err := strconv.ParseFloat(props.Offset, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid offset %s", props.Offset)
+		}
+		value += offset
+	}
+
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", fmt.Errorf("transformed value of %s is not finite", raw)
+	}
+	if isInteger {
+		if value != math.Trunc(value) {
+			return "", fmt.Errorf("transformed value %v is not valid for %s", value, valueType)
+		}
+		return strconv.FormatInt(int64(value), 10), nil
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64), nil
+}
+
+// checkReadingLimits compares the transformed value against the minimum,
+// maximum and assertion of the resource
+func checkReadingLimits(value, valueType string, props models.ResourceProperties) []string {
+	var violations []string
+
+	if integerValueTypes[valueType] || floatValueTypes[valueType] {
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			if props.Minimum != "" {
+				if min, err := strconv.ParseFloat(props.Minimum, 64); err == nil && v < min {
+					violations = append(violations, fmt.Sprintf("value %s is below minimum %s", value, props.Minimum))
+				}
+			}
+			if props.Maximum != "" {
+				if max, err := strconv.ParseFloat(props.Maximum, 64); err == nil && v > max {
+					violations = append(violations, fmt.Sprintf("value %s is above maximum %s", value, props.Maximum))
+				}
+			}
+		}
+	}
+	if props.Assertion != "" && value != props.Assertion {
+		violations = append(violations, fmt.Sprintf("value %s does not match assertion %s", value, props.Assertion))
+	}
+
+	return violations
+}
+
+// notifyViolations raises one notification per violating reading, for the
+// tenant the event belongs to
+func (s *Service) notifyViolations(tenantID string, violations []readingViolation) {
+	if s.notifier == nil || len(violations) == 0 {
+		return
+	}
+
+	notifier := s.notifier(tenant.Scope{Tenant: tenantID})
+	for _, v := range violations {
+		req := &models.NotificationRequest{
+			Slug:        "reading-violation-" + uuid.New().String(),
+			Sender:      "core-data",
+			Category:    "reading-violation",
+			Severity:    v.severity,
+			Content:     fmt.Sprintf("reading %s of device %s violates its resource properties: %s", v.resourceName, v.deviceName, strings.Join(v.reasons, "; ")),
+			Description: fmt.Sprintf("value %s", v.value),
+			Labels:      []string{v.deviceName, v.resourceName},
+		}
+		if _, err := notifier.CreateNotification(req); err != nil {
+			log.Errorf("failed to notify violation of reading %s of device %s: %v", v.resourceName, v.deviceName, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/models"
+)
+
+// recordingNotifier keeps the notifications raised for each tenant
+type recordingNotifier struct {
+	notifications map[string][]*models.NotificationRequest
+	err           error
+}
+
+func (n *recordingNotifier) forTenant(scope tenant.Scope) ReadingNotifier {
+	return tenantNotifier{recorder: n, tenant: scope.Tenant}
+}
+
+type tenantNotifier struct {
+	recorder *recordingNotifier
+	tenant   string
+}
+
+func (n tenantNotifier) CreateNotification(req *models.NotificationRequest) (string, error) {
+	n.recorder.notifications[n.tenant] = append(n.recorder.notifications[n.tenant], req)
+	return "", n.recorder.err
+}
+
+func TestTransformReading(t *testing.T) {
+	reading := &models.ReadingRequest{ValueType: "Float64", Value: "20"}
+	violations := transformReading(reading, models.ResourceProperties{Scale: "0.1", Offset: "-5", Maximum: "-4"})
+	assert.Equal(t, "-3", reading.Value)
+	assert.Equal(t, "20", reading.Tags[TagRawValue])
+	assert.Equal(t, []string{"value -3 is above maximum -4"}, violations)
+
+	reading = &models.ReadingRequest{ValueType: "String", Value: "OPEN"}
+	violations = transformReading(reading, models.ResourceProperties{Assertion: "CLOSED"})
+	assert.Len(t, violations, 1)
+}
+
+func TestNotifyViolationsUsesTheEventTenant(t *testing.T) {
+	notifier := &recordingNotifier{notifications: map[string][]*models.NotificationRequest{}}
+	service := NewService(nil)
+	service.EnableResourceTransforms(notifier.forTenant)
+
+	violation := readingViolation{deviceName: "press-1", resourceName: "temperature", value: "120", reasons: []string{"too hot"}, severity: "MINOR"}
+	service.notifyViolations("plant-b", []readingViolation{violation})
+	require.Len(t, notifier.notifications["plant-b"], 1)
+	assert.Empty(t, notifier.notifications[tenant.DefaultTenant])
+	assert.Equal(t, []string{"press-1", "temperature"}, notifier.notifications["plant-b"][0].Labels)
+
+	// a failed notification does not stop the others
+	notifier.err = errors.New("database unavailable")
+	service.notifyViolations("plant-b", []readingViolation{violation, violation})
+	assert.Len(t, notifier.notifications["plant-b"], 3)
+}
@@ -0,0 +1,93 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"iiot-backend/models"
+)
+
+// TagUnitConversionError is set on readings that could not be converted to
+// the unit requested by a reading query
+const TagUnitConversionError = "unitConversionError"
+
+// ErrUnknownUnit is returned for conversions to a unit missing from the unit catalogue
+var ErrUnknownUnit = errors.New("unit is not in the unit catalogue")
+
+// catalogueUnit holds the conversion of a unit from the core-metadata
+// catalogue to the base unit of its quantity: base = value*factor + offset
+type catalogueUnit struct {
+	quantity string
+	factor   float64
+	offset   float64
+}
+
+func (s *Service) loadUnitCatalogue() (map[string]catalogueUnit, error) {
+	rows, err := s.db.Query(`SELECT name, quantity, factor, "offset" FROM units`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unit catalogue: %w", err)
+	}
+	defer rows.Close()
+
+	units := make(map[string]catalogueUnit)
+	for rows.Next() {
+		var name string
+		var unit catalogueUnit
+		if err := rows.Scan(&name, &unit.quantity, &unit.factor, &unit.offset); err != nil {
+			return nil, fmt.Errorf("failed to scan unit: %w", err)
+		}
+		units[name] = unit
+	}
+	return units, rows.Err()
+}
+
+// ConvertReadings converts numeric readings to the target unit in place.
+// Readings whose unit is unknown or measures another quantity are left as
+// they are and tagged with the reason.
+func (s *Service) ConvertReadings(readings []models.Reading, target string) error {
+	units, err := s.loadUnitCatalogue()
+	if err != nil {
+		return err
+	}
+	to, ok := units[target]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownUnit, target)
+	}
+
+	for i := range readings {
+		reading := &readings[i]
+		if reading.Units == target || reading.Value == "" {
+			continue
+		}
+		if !integerValueTypes[reading.ValueType] && !floatValueTypes[reading.ValueType] {
+			continue
+		}
+
+		reason := ""
+		from, known := units[reading.Units]
+		value, parseErr := strconv.ParseFloat(reading.Value, 64)
+		switch {
+		case !known:
+			reason = fmt.Sprintf("unit %q is not in the unit catalogue", reading.Units)
+		case from.quantity != to.quantity:
+			reason = fmt.Sprintf("cannot convert %s (%s) to %s (%s)", reading.Units, from.quantity, target, to.quantity)
+		case parseErr != nil:
+			reason = fmt.Sprintf("value %s is not numeric", reading.Value)
+		}
+		if reason != "" {
+			if reading.Tags == nil {
+				reading.Tags = make(map[string]string)
+			}
+			reading.Tags[TagUnitConversionError] = reason
+			continue
+		}
+
+		converted := (value*from.factor + from.offset - to.offset) / to.factor
+		reading.Value = strconv.FormatFloat(converted, 'f', -1, 64)
+		reading.ValueType = "Float64"
+		reading.Units = target
+	}
+
+	return nil
+}
@@ -0,0 +1,670 @@
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/tenant"
+)
+
+// Asset hierarchy levels, ordered from the top of the ISA-95 hierarchy down
+const (
+	AssetLevelSite = "site"
+	AssetLevelArea = "area"
+	AssetLevelLine = "line"
+	AssetLevelCell = "cell"
+)
+
+var assetLevelRank = map[string]int{
+	AssetLevelSite: 0,
+	AssetLevelArea: 1,
+	AssetLevelLine: 2,
+	AssetLevelCell: 3,
+}
+
+// assetSubtreeSQL selects the names of the node given as $1 and all its descendants
+var assetSubtreeSQL = AssetSubtreeSQL("$1")
+
+// AssetSubtreeSQL returns the query selecting the names of the asset node given as placeholder and
+// all its descendants
+func AssetSubtreeSQL(placeholder string) string {
+	return `
+	WITH RECURSIVE subtree AS (
+		SELECT name FROM asset_nodes WHERE name = ` + placeholder + `
+		UNION ALL
+		SELECT a.name FROM asset_nodes a JOIN subtree s ON a.parent_name = s.name
+	)
+	SELECT name FROM subtree`
+}
+
+// AssetDevicesSQL returns the query selecting the names of the devices attached to the asset node
+// given as placeholder or any of its descendants, for the services filtering by asset node
+func AssetDevicesSQL(placeholder string) string {
+	return `SELECT name FROM devices WHERE asset_node IN (` + AssetSubtreeSQL(placeholder) + `)`
+}
+
+// AssetNode is a node of the asset hierarchy devices are attached to
+type AssetNode struct {
+	Id          string      `json:"id,omitempty"`
+	Name        string      `json:"name" validate:"required"`
+	Description string      `json:"description,omitempty"`
+	Level       string      `json:"level" validate:"required"`
+	ParentName  string      `json:"parentName,omitempty"`
+	Labels      []string    `json:"labels,omitempty"`
+	Children    []AssetNode `json:"children,omitempty"`
+	Created     int64       `json:"created,omitempty"`
+	Modified    int64       `json:"modified,omitempty"`
+}
+
+type AddAssetNodeRequest struct {
+	RequestId string    `json:"requestId,omitempty"`
+	AssetNode AssetNode `json:"assetNode"`
+}
+
+func (r *AddAssetNodeRequest) Validate() error {
+	if r.AssetNode.Name == "" {
+		return &ValidationError{Message: "assetNode name is required"}
+	}
+	if _, ok := assetLevelRank[r.AssetNode.Level]; !ok {
+		return &ValidationError{Message: fmt.Sprintf("assetNode level %s is invalid", r.AssetNode.Level)}
+	}
+	return nil
+}
+
+type UpdateAssetNodeRequest struct {
+	RequestId string          `json:"requestId,omitempty"`
+	AssetNode UpdateAssetNode `json:"assetNode"`
+}
+
+type UpdateAssetNode struct {
+	Description *string   `json:"description,omitempty"`
+	ParentName  *string   `json:"parentName,omitempty"`
+	Labels      *[]string `json:"labels,omitempty"`
+}
+
+// Asset node operations
+func (s *WorkingMetadataService) AddAssetNode(ctx context.Context, req AssetNode) (string, EdgeXError) {
+	if req.Name == "" {
+		return "", EdgeXError{Code: http.StatusBadRequest, Message: "asset node name is required"}
+	}
+	if _, ok := assetLevelRank[req.Level]; !ok {
+		return "", EdgeXError{Code: http.StatusBadRequest, Message: fmt.Sprintf("asset node level %s is invalid", req.Level)}
+	}
+	if edgeErr := s.validateAssetParent(ctx, req.Name, req.Level, req.ParentName); edgeErr.Code != 0 {
+		return "", edgeErr
+	}
+
+	id := uuid.New().String()
+	now := time.Now().Unix()
+
+	query := `
+		INSERT INTO asset_nodes (id, name, description, level, parent_name, labels, created, modified, tenant_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9)`
+
+	_, err := s.db.ExecContext(ctx, query, id, req.Name, req.Description, req.Level, req.ParentName,
+		marshalOrDefault(req.Labels, "[]"), now, now, tenant.FromContext(ctx).Tenant)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return "", EdgeXError{Code: http.StatusConflict, Message: fmt.Sprintf("asset node %s already exists", req.Name)}
+		}
+		return "", EdgeXError{Code: http.StatusInternalServerError, Message: "failed to add asset node"}
+	}
+
+	req.Id, req.Created, req.Modified = id, now, now
+	audit.Change(ctx, "asset", req.Name, nil, req)
+	return id, EdgeXError{}
+}
+
+// validateAssetParent checks that the parent exists, sits higher in the
+// hierarchy than the node and is not the node itself or one of its descendants.
+func (s *WorkingMetadataService) validateAssetParent(ctx context.Context, name, level, parentName string) EdgeXError {
+	if parentName == "" {
+		return EdgeXError{}
+	}
+	if parentName == name {
+		return EdgeXError{Code: http.StatusBadRequest, Message: "asset node cannot be its own parent"}
+	}
+
+	parent, edgeErr := s.GetAssetNodeByName(ctx, parentName)
+	if edgeErr.Code != 0 {
+		if edgeErr.Code == http.StatusNotFound {
+			return EdgeXError{Code: http.StatusBadRequest, Message: fmt.Sprintf("parent asset node %s not found", parentName)}
+		}
+		return edgeErr
+	}
+	if assetLevelRank[parent.Level] >= assetLevelRank[level] {
+		return EdgeXError{Code: http.StatusBadRequest, Message: fmt.Sprintf("asset node of level %s cannot be placed under level %s", level, parent.Level)}
+	}
+
+	subtree, err := s.assetSubtreeNames(ctx, name)
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to query asset subtree"}
+	}
+	for _, descendant := range subtree {
+		if descendant == parentName {
+			return EdgeXError{Code: http.StatusBadRequest, Message: fmt.Sprintf("asset node %s is a descendant of %s", parentName, name)}
+		}
+	}
+
+	return EdgeXError{}
+}
+
+func (s *WorkingMetadataService) assetSubtreeNames(ctx context.Context, name string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, assetSubtreeSQL, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	return names, rows.Err()
+}
+
+func scanAssetNode(scan func(dest ...interface{}) error) (AssetNode, error) {
+	var node AssetNode
+	var labelsJSON []byte
+
+	err := scan(&node.Id, &node.Name, &node.Description, &node.Level, &node.ParentName,
+		&labelsJSON, &node.Created, &node.Modified)
+	if err != nil {
+		return node, err
+	}
+	json.Unmarshal(labelsJSON, &node.Labels)
+	return node, nil
+}
+
+func (s *WorkingMetadataService) GetAssetNodeByName(ctx context.Context, name string) (AssetNode, EdgeXError) {
+	if name == "" {
+		return AssetNode{}, EdgeXError{Code: http.StatusBadRequest, Message: "asset node name is required"}
+	}
+
+	args := []interface{}{name}
+	query := `
+		SELECT id, name, COALESCE(description, ''), level, COALESCE(parent_name, ''), labels, created, modified
+		FROM asset_nodes
+		WHERE name = $1 AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args)
+
+	node, err := scanAssetNode(s.db.QueryRowContext(ctx, query, args...).Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return AssetNode{}, EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("asset node %s not found", name)}
+		}
+		return AssetNode{}, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get asset node"}
+	}
+
+	return node, EdgeXError{}
+}
+
+func (s *WorkingMetadataService) GetAllAssetNodes(ctx context.Context, level string, offset, limit int) ([]AssetNode, uint32, EdgeXError) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	scope := tenant.FromContext(ctx)
+
+	var totalCount uint32
+	countArgs := []interface{}{level}
+	countQuery := `SELECT COUNT(*) FROM asset_nodes WHERE ($1 = '' OR level = $1) AND ` + scope.Condition("tenant_id", &countArgs)
+	if err := s.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&totalCount); err != nil {
+		return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get total count"}
+	}
+
+	args := []interface{}{level, limit, offset}
+	query := `
+		SELECT id, name, COALESCE(description, ''), level, COALESCE(parent_name, ''), labels, created, modified
+		FROM asset_nodes
+		WHERE ($1 = '' OR level = $1) AND ` + scope.Condition("tenant_id", &args) + `
+		ORDER BY name LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to query asset nodes"}
+	}
+	defer rows.Close()
+
+	var nodes []AssetNode
+	for rows.Next() {
+		node, err := scanAssetNode(rows.Scan)
+		if err != nil {
+			return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to scan asset node"}
+		}
+		nodes = append(nodes, node)
+	}
+
+	return nodes, totalCount, EdgeXError{}
+}
+
+// GetAssetTree returns the named node with all its descendants nested as children
+func (s *WorkingMetadataService) GetAssetTree(ctx context.Context, name string) (AssetNode, EdgeXError) {
+	root, edgeErr := s.GetAssetNodeByName(ctx, name)
+	if edgeErr.Code != 0 {
+		return AssetNode{}, edgeErr
+	}
+
+	args := []interface{}{name}
+	query := `
+		SELECT id, name, COALESCE(description, ''), level, COALESCE(parent_name, ''), labels, created, modified
+		FROM asset_nodes
+		WHERE name IN (` + assetSubtreeSQL + `) AND name <> $1 AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args) + `
+		ORDER BY name`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return AssetNode{}, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to query asset subtree"}
+	}
+	defer rows.Close()
+
+	childrenOf := make(map[string][]AssetNode)
+	for rows.Next() {
+		node, err := scanAssetNode(rows.Scan)
+		if err != nil {
+			return AssetNode{}, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to scan asset node"}
+		}
+		childrenOf[node.ParentName] = append(childrenOf[node.ParentName], node)
+	}
+
+	var attach func(node *AssetNode)
+	attach = func(node *AssetNode) {
+		node.Children = childrenOf[node.Name]
+		for i := range node.Children {
+			attach(&node.Children[i])
+		}
+	}
+	attach(&root)
+
+	return root, EdgeXError{}
+}
+
+func (s *WorkingMetadataService) UpdateAssetNode(ctx context.Context, name string, req UpdateAssetNode) EdgeXError {
+	node, edgeErr := s.GetAssetNodeByName(ctx, name)
+	if edgeErr.Code != 0 {
+		return edgeErr
+	}
+	before := node
+
+	if req.Description != nil {
+		node.Description = *req.Description
+	}
+	if req.Labels != nil {
+		node.Labels = *req.Labels
+	}
+	if req.ParentName != nil {
+		if edgeErr := s.validateAssetParent(ctx, node.Name, node.Level, *req.ParentName); edgeErr.Code != 0 {
+			return edgeErr
+		}
+		node.ParentName = *req.ParentName
+	}
+
+	node.Modified = time.Now().Unix()
+	args := []interface{}{name, node.Description, node.ParentName, marshalOrDefault(node.Labels, "[]"), node.Modified}
+	query := `
+		UPDATE asset_nodes
+		SET description = $2, parent_name = NULLIF($3, ''), labels = $4, modified = $5
+		WHERE name = $1 AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args)
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to update asset node"}
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get rows affected"}
+	} else if rowsAffected == 0 {
+		return EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("asset node %s not found", name)}
+	}
+
+	audit.Change(ctx, "asset", name, before, node)
+	return EdgeXError{}
+}
+
+func (s *WorkingMetadataService) DeleteAssetNodeByName(ctx context.Context, name string) EdgeXError {
+	if name == "" {
+		return EdgeXError{Code: http.StatusBadRequest, Message: "asset node name is required"}
+	}
+
+	before, edgeErr := s.GetAssetNodeByName(ctx, name)
+	if edgeErr.Code != 0 {
+		return edgeErr
+	}
+
+	scope := tenant.FromContext(ctx)
+	var children int
+	countArgs := []interface{}{name}
+	countQuery := `SELECT COUNT(*) FROM asset_nodes WHERE parent_name = $1 AND ` + scope.Condition("tenant_id", &countArgs)
+	err := s.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&children)
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to count child asset nodes"}
+	}
+	if children > 0 {
+		return EdgeXError{Code: http.StatusConflict, Message: fmt.Sprintf("asset node %s still has %d child nodes", name, children)}
+	}
+
+	deleteArgs := []interface{}{name}
+	deleteQuery := `DELETE FROM asset_nodes WHERE name = $1 AND ` + scope.Condition("tenant_id", &deleteArgs)
+	result, err := s.db.ExecContext(ctx, deleteQuery, deleteArgs...)
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to delete asset node"}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get rows affected"}
+	}
+	if rowsAffected == 0 {
+		return EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("asset node %s not found", name)}
+	}
+
+	audit.Change(ctx, "asset", name, before, nil)
+	return EdgeXError{}
+}
+
+// SetDeviceAssetNode attaches the device to the named asset node, or detaches
+// it from any node when nodeName is empty.
+func (s *WorkingMetadataService) SetDeviceAssetNode(ctx context.Context, deviceName, nodeName string) EdgeXError {
+	if deviceName == "" {
+		return EdgeXError{Code: http.StatusBadRequest, Message: "device name is required"}
+	}
+	if nodeName != "" {
+		if _, edgeErr := s.GetAssetNodeByName(ctx, nodeName); edgeErr.Code != 0 {
+			return edgeErr
+		}
+	}
+	before, _ := s.GetDeviceByName(ctx, deviceName)
+
+	args := []interface{}{deviceName, nodeName, time.Now().Unix()}
+	query := `UPDATE devices SET asset_node = NULLIF($2, ''), modified = $3 WHERE name = $1 AND ` +
+		tenant.FromContext(ctx).Condition("tenant_id", &args)
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to update device asset node"}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get rows affected"}
+	}
+	if rowsAffected == 0 {
+		return EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("device %s not found", deviceName)}
+	}
+
+	after, _ := s.GetDeviceByName(ctx, deviceName)
+	audit.Change(ctx, "device", deviceName, maskedDevice(before), maskedDevice(after))
+	return EdgeXError{}
+}
+
+// DetachDeviceFromAssetNode detaches the device from the named asset node, failing when the device
+// is attached to another node or to none
+func (s *WorkingMetadataService) DetachDeviceFromAssetNode(ctx context.Context, nodeName, deviceName string) EdgeXError {
+	if deviceName == "" {
+		return EdgeXError{Code: http.StatusBadRequest, Message: "device name is required"}
+	}
+	if _, edgeErr := s.GetAssetNodeByName(ctx, nodeName); edgeErr.Code != 0 {
+		return edgeErr
+	}
+	before, edgeErr := s.GetDeviceByName(ctx, deviceName)
+	if edgeErr.Code != 0 {
+		return edgeErr
+	}
+	if before.AssetNode != nodeName {
+		return EdgeXError{Code: http.StatusConflict, Message: fmt.Sprintf("device %s is not attached to asset node %s", deviceName, nodeName)}
+	}
+
+	// the node is checked again by the update, so that a concurrent re-attachment is not undone
+	args := []interface{}{deviceName, nodeName, time.Now().Unix()}
+	query := `UPDATE devices SET asset_node = NULL, modified = $3 WHERE name = $1 AND asset_node = $2 AND ` +
+		tenant.FromContext(ctx).Condition("tenant_id", &args)
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to update device asset node"}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get rows affected"}
+	}
+	if rowsAffected == 0 {
+		return EdgeXError{Code: http.StatusConflict, Message: fmt.Sprintf("device %s is not attached to asset node %s", deviceName, nodeName)}
+	}
+
+	after, _ := s.GetDeviceByName(ctx, deviceName)
+	audit.Change(ctx, "device", deviceName, maskedDevice(before), maskedDevice(after))
+	return EdgeXError{}
+}
+
+// GetDevicesUnderAssetNode returns the devices attached to the named node or any of its descendants
+func (s *WorkingMetadataService) GetDevicesUnderAssetNode(ctx context.Context, name string, offset, limit int) ([]Device, uint32, EdgeXError) {
+	if _, edgeErr := s.GetAssetNodeByName(ctx, name); edgeErr.Code != 0 {
+		return nil, 0, edgeErr
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	scope := tenant.FromContext(ctx)
+
+	var totalCount uint32
+	countArgs := []interface{}{name}
+	countQuery := `SELECT COUNT(*) FROM devices WHERE asset_node IN (` + assetSubtreeSQL + `) AND ` +
+		scope.Condition("tenant_id", &countArgs)
+	if err := s.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&totalCount); err != nil {
+		return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get total count"}
+	}
+
+	args := []interface{}{name, limit, offset}
+	query := `
+		SELECT id, name, description, admin_state, operating_state, protocols, labels,
+			location, service_name, profile_name, auto_events, COALESCE(asset_node, ''), COALESCE(profile_version, 0), created, modified
+		FROM devices
+		WHERE asset_node IN (` + assetSubtreeSQL + `) AND ` + scope.Condition("tenant_id", &args) + `
+		ORDER BY name LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to query devices"}
+	}
+	defer rows.Close()
+
+	var devices []Device
+	for rows.Next() {
+		var device Device
+		var labelsJSON, protocolsJSON, autoEventsJSON, locationJSON []byte
+
+		err := rows.Scan(&device.Id, &device.Name, &device.Description, &device.AdminState,
+			&device.OperatingState, &protocolsJSON, &labelsJSON, &locationJSON,
+			&device.ServiceName, &device.ProfileName, &autoEventsJSON, &device.AssetNode,
+			&device.ProfileVersion, &device.Created, &device.Modified)
+		if err != nil {
+			return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to scan device"}
+		}
+
+		json.Unmarshal(labelsJSON, &device.Labels)
+		json.Unmarshal(autoEventsJSON, &device.AutoEvents)
+		json.Unmarshal(locationJSON, &device.Location)
+		protocols, edgeErr := s.decryptProtocols(ctx, device.Id, protocolsJSON)
+		if edgeErr.Code != 0 {
+			return nil, 0, edgeErr
+		}
+		device.Protocols = protocols
+
+		devices = append(devices, device)
+	}
+
+	return devices, totalCount, EdgeXError{}
+}
+
+// Asset node endpoints
+func (h *WorkingHandler) AddAssetNode(c echo.Context) error {
+	var req AddAssetNodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	id, edgeErr := h.service.AddAssetNode(c.Request().Context(), req.AssetNode)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusCreated,
+		"id":         id,
+	}
+	return c.JSON(http.StatusCreated, response)
+}
+
+func (h *WorkingHandler) GetAssetNodeByName(c echo.Context) error {
+	node, edgeErr := h.service.GetAssetNodeByName(c.Request().Context(), c.Param("name"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"assetNode":  node,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) GetAllAssetNodes(c echo.Context) error {
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	nodes, totalCount, edgeErr := h.service.GetAllAssetNodes(c.Request().Context(), c.QueryParam("level"), offset, limit)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"totalCount": totalCount,
+		"assetNodes": nodes,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) GetAssetTree(c echo.Context) error {
+	tree, edgeErr := h.service.GetAssetTree(c.Request().Context(), c.Param("name"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"assetNode":  tree,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) UpdateAssetNode(c echo.Context) error {
+	var req UpdateAssetNodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+	}
+
+	edgeErr := h.service.UpdateAssetNode(c.Request().Context(), c.Param("name"), req.AssetNode)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"message":    "Asset node updated successfully",
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) DeleteAssetNodeByName(c echo.Context) error {
+	edgeErr := h.service.DeleteAssetNodeByName(c.Request().Context(), c.Param("name"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"message":    "Asset node deleted successfully",
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) AttachDeviceToAssetNode(c echo.Context) error {
+	edgeErr := h.service.SetDeviceAssetNode(c.Request().Context(), c.Param("device"), c.Param("name"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"message":    "Device attached to asset node",
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) DetachDeviceFromAssetNode(c echo.Context) error {
+	edgeErr := h.service.DetachDeviceFromAssetNode(c.Request().Context(), c.Param("name"), c.Param("device"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"message":    "Device detached from asset node",
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) GetDevicesUnderAssetNode(c echo.Context) error {
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	devices, totalCount, edgeErr := h.service.GetDevicesUnderAssetNode(c.Request().Context(), c.Param("name"), offset, limit)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"totalCount": totalCount,
+		"devices":    devices,
+	}
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,553 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gopkg.in/yaml.v3"
+
+	"iiot-backend/middleware/sensitive"
+	"iiot-backend/middleware/tenant"
+)
+
+// Supported bulk import/export formats
+const (
+	BulkFormatJSON = "json"
+	BulkFormatYAML = "yaml"
+	BulkFormatCSV  = "csv"
+)
+
+// deviceCSVHeader is the column layout used for CSV device import and export.
+// Labels are separated by ';', location and protocols are JSON objects.
+var deviceCSVHeader = []string{
+	"name", "description", "serviceName", "profileName", "adminState",
+	"operatingState", "labels", "location", "protocols", "assetNode",
+}
+
+// BulkImportRequest carries the entities of a bulk import. Device services and
+// profiles are optional and are created before the devices referencing them.
+type BulkImportRequest struct {
+	DeviceServices []DeviceService `json:"deviceServices,omitempty"`
+	DeviceProfiles []DeviceProfile `json:"deviceProfiles,omitempty"`
+	Devices        []Device        `json:"devices"`
+}
+
+// BulkRowError describes a validation or persistence failure for a single row
+// of a bulk import. Row is 1-based within its entity list.
+type BulkRowError struct {
+	Entity  string `json:"entity"`
+	Row     int    `json:"row"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+// BulkImportResult reports the outcome of a bulk import
+type BulkImportResult struct {
+	DryRun                bool           `json:"dryRun"`
+	DeviceServicesCreated int            `json:"deviceServicesCreated"`
+	DeviceProfilesCreated int            `json:"deviceProfilesCreated"`
+	DevicesCreated        int            `json:"devicesCreated"`
+	Errors                []BulkRowError `json:"errors,omitempty"`
+}
+
+// DeviceExportFilter selects the devices included in an export
+type DeviceExportFilter struct {
+	ServiceName string
+	ProfileName string
+	Label       string
+}
+
+// ParseBulkImport decodes a bulk import payload in the given format. CSV
+// payloads only carry devices, one per row, using deviceCSVHeader columns.
+func ParseBulkImport(format string, body io.Reader) (BulkImportRequest, error) {
+	var req BulkImportRequest
+
+	switch strings.ToLower(format) {
+	case BulkFormatJSON, "":
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			return req, fmt.Errorf("failed to decode JSON payload: %w", err)
+		}
+	case BulkFormatYAML, "yml":
+		// Round-trip through JSON so YAML keys follow the same camelCase
+		// field names as the JSON API
+		var raw interface{}
+		if err := yaml.NewDecoder(body).Decode(&raw); err != nil {
+			return req, fmt.Errorf("failed to decode YAML payload: %w", err)
+		}
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return req, fmt.Errorf("failed to decode YAML payload: %w", err)
+		}
+		if err := json.Unmarshal(data, &req); err != nil {
+			return req, fmt.Errorf("failed to decode YAML payload: %w", err)
+		}
+	case BulkFormatCSV:
+		devices, err := parseDeviceCSV(body)
+		if err != nil {
+			return req, err
+		}
+		req.Devices = devices
+	default:
+		return req, fmt.Errorf("unsupported import format %s", format)
+	}
+
+	return req, nil
+}
+
+func parseDeviceCSV(body io.Reader) ([]Device, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.TrimSpace(column)] = i
+	}
+	for _, required := range []string{"name", "serviceName", "profileName"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing required column %s", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var devices []Device
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV row %d: %w", row, err)
+		}
+
+		device := Device{
+			Name:           field(record, "name"),
+			Description:    field(record, "description"),
+			ServiceName:    field(record, "serviceName"),
+			ProfileName:    field(record, "profileName"),
+			AdminState:     field(record, "adminState"),
+			OperatingState: field(record, "operatingState"),
+			AssetNode:      field(record, "assetNode"),
+		}
+		if labels := field(record, "labels"); labels != "" {
+			for _, label := range strings.Split(labels, ";") {
+				if label = strings.TrimSpace(label); label != "" {
+					device.Labels = append(device.Labels, label)
+				}
+			}
+		}
+		if location := field(record, "location"); location != "" {
+			if err := json.Unmarshal([]byte(location), &device.Location); err != nil {
+				return nil, fmt.Errorf("CSV row %d: location must be a JSON object: %w", row, err)
+			}
+		}
+		if protocols := field(record, "protocols"); protocols != "" {
+			if err := json.Unmarshal([]byte(protocols), &device.Protocols); err != nil {
+				return nil, fmt.Errorf("CSV row %d: protocols must be a JSON object: %w", row, err)
+			}
+		}
+
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
+
+// ImportBulk validates every entity of the request and, unless dryRun is set or
+// validation failed, creates all of them in a single transaction.
+func (s *WorkingMetadataService) ImportBulk(ctx context.Context, req BulkImportRequest, dryRun bool) (BulkImportResult, EdgeXError) {
+	result := BulkImportResult{DryRun: dryRun}
+
+	if len(req.DeviceServices) == 0 && len(req.DeviceProfiles) == 0 && len(req.Devices) == 0 {
+		return result, EdgeXError{Code: http.StatusBadRequest, Message: "bulk import request is empty"}
+	}
+
+	rowErrors, edgeErr := s.validateBulkImport(ctx, req)
+	if edgeErr.Code != 0 {
+		return result, edgeErr
+	}
+	result.Errors = rowErrors
+
+	if dryRun || len(rowErrors) > 0 {
+		return result, EdgeXError{}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to begin transaction"}
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+
+	for i, ds := range req.DeviceServices {
+		if err := insertDeviceService(ctx, tx, ds, now); err != nil {
+			result.Errors = append(result.Errors, bulkPersistError("deviceService", i+1, ds.Name, err))
+			return result, EdgeXError{Code: http.StatusConflict, Message: "bulk import rolled back"}
+		}
+	}
+	for i, dp := range req.DeviceProfiles {
+		if err := insertDeviceProfile(ctx, tx, dp, "bulk import", now); err != nil {
+			result.Errors = append(result.Errors, bulkPersistError("deviceProfile", i+1, dp.Name, err))
+			return result, EdgeXError{Code: http.StatusConflict, Message: "bulk import rolled back"}
+		}
+	}
+	for i, device := range req.Devices {
+		device.Id = uuid.New().String()
+		protocols, err := s.keys.EncryptProperties(ProtocolsField(device.Id), device.Protocols)
+		if err != nil {
+			return result, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to encrypt device protocols"}
+		}
+		device.Protocols = protocols
+		if err := insertDevice(ctx, tx, device, now); err != nil {
+			result.Errors = append(result.Errors, bulkPersistError("device", i+1, device.Name, err))
+			return result, EdgeXError{Code: http.StatusConflict, Message: "bulk import rolled back"}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to commit bulk import"}
+	}
+
+	result.DeviceServicesCreated = len(req.DeviceServices)
+	result.DeviceProfilesCreated = len(req.DeviceProfiles)
+	result.DevicesCreated = len(req.Devices)
+
+	return result, EdgeXError{}
+}
+
+// validateBulkImport checks required fields, duplicate names within the batch,
+// name clashes with existing entities and that every device references a
+// service and profile that exists either in the database or in the batch, an
+// existing asset node if one is given, and that profile units are catalogued.
+// Names clash across tenants, while references must stay within the tenant.
+func (s *WorkingMetadataService) validateBulkImport(ctx context.Context, req BulkImportRequest) ([]BulkRowError, EdgeXError) {
+	var rowErrors []BulkRowError
+	everyTenant := tenant.Scope{All: true}
+	scope := tenant.FromContext(ctx)
+
+	existingServices, err := s.existingNames(ctx, "device_services", everyTenant)
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to load device service names"}
+	}
+	visibleServices, err := s.existingNames(ctx, "device_services", scope)
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to load device service names"}
+	}
+	existingProfiles, err := s.existingNames(ctx, "device_profiles", everyTenant)
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to load device profile names"}
+	}
+	visibleProfiles, err := s.existingNames(ctx, "device_profiles", scope)
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to load device profile names"}
+	}
+	existingDevices, err := s.existingNames(ctx, "devices", everyTenant)
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to load device names"}
+	}
+	visibleAssets, err := s.existingNames(ctx, "asset_nodes", scope)
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to load asset node names"}
+	}
+	knownUnits, err := s.existingNames(ctx, "units", everyTenant)
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to load unit catalogue"}
+	}
+
+	batchServices := make(map[string]bool)
+	for i, ds := range req.DeviceServices {
+		row := BulkRowError{Entity: "deviceService", Row: i + 1, Name: ds.Name}
+		switch {
+		case ds.Name == "":
+			row.Message = "device service name is required"
+		case ds.BaseAddress == "":
+			row.Message = "device service base address is required"
+		case batchServices[ds.Name]:
+			row.Message = fmt.Sprintf("device service %s is duplicated in the import", ds.Name)
+		case existingServices[ds.Name]:
+			row.Message = fmt.Sprintf("device service %s already exists", ds.Name)
+		}
+		if row.Message != "" {
+			rowErrors = append(rowErrors, row)
+		}
+		batchServices[ds.Name] = true
+	}
+
+	batchProfiles := make(map[string]bool)
+	for i, dp := range req.DeviceProfiles {
+		row := BulkRowError{Entity: "deviceProfile", Row: i + 1, Name: dp.Name}
+		switch {
+		case dp.Name == "":
+			row.Message = "device profile name is required"
+		case batchProfiles[dp.Name]:
+			row.Message = fmt.Sprintf("device profile %s is duplicated in the import", dp.Name)
+		case existingProfiles[dp.Name]:
+			row.Message = fmt.Sprintf("device profile %s already exists", dp.Name)
+		case unknownProfileUnit(dp, knownUnits) != "":
+			row.Message = fmt.Sprintf("unit %s is not in the unit catalogue", unknownProfileUnit(dp, knownUnits))
+		}
+		if row.Message != "" {
+			rowErrors = append(rowErrors, row)
+		}
+		batchProfiles[dp.Name] = true
+	}
+
+	batchDevices := make(map[string]bool)
+	for i, device := range req.Devices {
+		row := BulkRowError{Entity: "device", Row: i + 1, Name: device.Name}
+		switch {
+		case device.Name == "":
+			row.Message = "device name is required"
+		case device.ServiceName == "":
+			row.Message = "device service name is required"
+		case device.ProfileName == "":
+			row.Message = "device profile name is required"
+		case batchDevices[device.Name]:
+			row.Message = fmt.Sprintf("device %s is duplicated in the import", device.Name)
+		case existingDevices[device.Name]:
+			row.Message = fmt.Sprintf("device %s already exists", device.Name)
+		case !visibleServices[device.ServiceName] && !batchServices[device.ServiceName]:
+			row.Message = fmt.Sprintf("device service %s not found", device.ServiceName)
+		case !visibleProfiles[device.ProfileName] && !batchProfiles[device.ProfileName]:
+			row.Message = fmt.Sprintf("device profile %s not found", device.ProfileName)
+		case device.AssetNode != "" && !visibleAssets[device.AssetNode]:
+			row.Message = fmt.Sprintf("asset node %s not found", device.AssetNode)
+		case sensitive.HasMask(device.Protocols):
+			row.Message = "device protocols carry masked credentials, export with the " + sensitive.PermissionReveal + " permission to import them"
+		case sensitive.HasEncrypted(device.Protocols):
+			row.Message = "device protocols carry encrypted credentials, export with the " + sensitive.PermissionReveal + " permission to import them"
+		default:
+			row.Message = s.checkSecretReferences(ctx, device.Protocols).Message
+		}
+		if row.Message != "" {
+			rowErrors = append(rowErrors, row)
+		}
+		batchDevices[device.Name] = true
+	}
+
+	return rowErrors, EdgeXError{}
+}
+
+// existingNames loads the names of the entities in table visible in scope, tables without a tenant
+// column can only be read with an unrestricted scope
+func (s *WorkingMetadataService) existingNames(ctx context.Context, table string, scope tenant.Scope) (map[string]bool, error) {
+	var args []interface{}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT name FROM %s WHERE %s", table, scope.Condition("tenant_id", &args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+
+	return names, rows.Err()
+}
+
+func bulkPersistError(entity string, row int, name string, err error) BulkRowError {
+	message := err.Error()
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		message = fmt.Sprintf("%s %s already exists", entity, name)
+	}
+	return BulkRowError{Entity: entity, Row: row, Name: name, Message: message}
+}
+
+func insertDeviceService(ctx context.Context, tx *sql.Tx, ds DeviceService, now int64) error {
+	if ds.AdminState == "" {
+		ds.AdminState = "UNLOCKED"
+	}
+	labelsJSON := marshalOrDefault(ds.Labels, "[]")
+
+	query := `
+		INSERT INTO device_services (id, name, description, base_address, admin_state, labels, created, modified, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := tx.ExecContext(ctx, query, uuid.New().String(), ds.Name, ds.Description, ds.BaseAddress,
+		ds.AdminState, labelsJSON, now, now, tenant.FromContext(ctx).Tenant)
+	return err
+}
+
+// insertDeviceProfile adds the profile as version 1 and records that revision
+func insertDeviceProfile(ctx context.Context, tx *sql.Tx, dp DeviceProfile, changeDescription string, now int64) error {
+	if dp.Id == "" {
+		dp.Id = uuid.New().String()
+	}
+	dp.Version = 1
+	dp.Created = now
+	dp.Modified = now
+
+	query := `
+		INSERT INTO device_profiles (id, name, description, manufacturer, model, labels,
+			device_resources, device_commands, core_commands, version, created, modified, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+
+	_, err := tx.ExecContext(ctx, query, dp.Id, dp.Name, dp.Description, dp.Manufacturer,
+		dp.Model, marshalOrDefault(dp.Labels, "[]"), marshalOrDefault(dp.DeviceResources, "[]"),
+		marshalOrDefault(dp.DeviceCommands, "[]"), marshalOrDefault(dp.CoreCommands, "[]"),
+		dp.Version, now, now, tenant.FromContext(ctx).Tenant)
+	if err != nil {
+		return err
+	}
+
+	return recordProfileVersion(ctx, tx, dp, changeDescription, now)
+}
+
+func insertDevice(ctx context.Context, tx *sql.Tx, device Device, now int64) error {
+	if device.AdminState == "" {
+		device.AdminState = "UNLOCKED"
+	}
+	if device.OperatingState == "" {
+		device.OperatingState = "UP"
+	}
+
+	query := `
+		INSERT INTO devices (id, name, description, admin_state, operating_state, protocols,
+			labels, location, service_name, profile_name, auto_events, tags, properties, created, modified, asset_node, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NULLIF($16, ''), $17)`
+
+	_, err := tx.ExecContext(ctx, query, device.Id, device.Name, device.Description,
+		device.AdminState, device.OperatingState, marshalOrDefault(device.Protocols, "{}"),
+		marshalOrDefault(device.Labels, "[]"), marshalOrDefault(device.Location, "{}"),
+		device.ServiceName, device.ProfileName, marshalOrDefault(device.AutoEvents, "[]"),
+		[]byte("{}"), []byte("{}"), now, now, device.AssetNode, tenant.FromContext(ctx).Tenant)
+	return err
+}
+
+// marshalOrDefault marshals v to JSON, falling back to def for nil values
+func marshalOrDefault(v interface{}, def string) []byte {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return []byte(def)
+	}
+	return data
+}
+
+// ExportDevices returns every device matching the filter, ordered by name
+func (s *WorkingMetadataService) ExportDevices(ctx context.Context, filter DeviceExportFilter) ([]Device, EdgeXError) {
+	var labelJSON interface{}
+	if filter.Label != "" {
+		labelJSON = marshalOrDefault([]string{filter.Label}, "[]")
+	}
+
+	args := []interface{}{filter.ServiceName, filter.ProfileName, labelJSON}
+	query := `
+		SELECT id, name, description, admin_state, operating_state, protocols, labels,
+			location, service_name, profile_name, auto_events, COALESCE(asset_node, ''), created, modified
+		FROM devices
+		WHERE ($1 = '' OR service_name = $1)
+		  AND ($2 = '' OR profile_name = $2)
+		  AND ($3::jsonb IS NULL OR labels @> $3::jsonb)
+		  AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args) + `
+		ORDER BY name`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to query devices"}
+	}
+	defer rows.Close()
+
+	devices := []Device{}
+	for rows.Next() {
+		var device Device
+		var labelsJSON, protocolsJSON, autoEventsJSON, locationJSON []byte
+
+		err := rows.Scan(&device.Id, &device.Name, &device.Description, &device.AdminState,
+			&device.OperatingState, &protocolsJSON, &labelsJSON, &locationJSON,
+			&device.ServiceName, &device.ProfileName, &autoEventsJSON, &device.AssetNode,
+			&device.Created, &device.Modified)
+		if err != nil {
+			return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to scan device"}
+		}
+
+		json.Unmarshal(labelsJSON, &device.Labels)
+		json.Unmarshal(autoEventsJSON, &device.AutoEvents)
+		json.Unmarshal(locationJSON, &device.Location)
+		protocols, edgeErr := s.decryptProtocols(ctx, device.Id, protocolsJSON)
+		if edgeErr.Code != 0 {
+			return nil, edgeErr
+		}
+		device.Protocols = protocols
+
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to query devices"}
+	}
+
+	return devices, EdgeXError{}
+}
+
+// EncodeDeviceExport renders devices in the requested format and returns the
+// encoded payload with its content type.
+func EncodeDeviceExport(format string, devices []Device) ([]byte, string, error) {
+	switch strings.ToLower(format) {
+	case BulkFormatJSON, "":
+		data, err := json.Marshal(BulkImportRequest{Devices: devices})
+		return data, "application/json", err
+	case BulkFormatYAML, "yml":
+		var raw interface{}
+		data, err := json.Marshal(BulkImportRequest{Devices: devices})
+		if err != nil {
+			return nil, "", err
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, "", err
+		}
+		data, err = yaml.Marshal(raw)
+		return data, "application/x-yaml", err
+	case BulkFormatCSV:
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		if err := writer.Write(deviceCSVHeader); err != nil {
+			return nil, "", err
+		}
+		for _, device := range devices {
+			location, protocols := "", ""
+			if len(device.Location) > 0 {
+				location = string(marshalOrDefault(device.Location, ""))
+			}
+			if len(device.Protocols) > 0 {
+				protocols = string(marshalOrDefault(device.Protocols, ""))
+			}
+			record := []string{
+				device.Name, device.Description, device.ServiceName, device.ProfileName,
+				device.AdminState, device.OperatingState, strings.Join(device.Labels, ";"),
+				location, protocols, device.AssetNode,
+			}
+			if err := writer.Write(record); err != nil {
+				return nil, "", err
+			}
+		}
+		writer.Flush()
+		return buf.Bytes(), "text/csv", writer.Error()
+	default:
+		return nil, "", fmt.Errorf("unsupported export format %s", format)
+	}
+}
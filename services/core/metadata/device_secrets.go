@@ -0,0 +1,391 @@
+package metadata
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/sensitive"
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/pkg/go-mod-bootstrap/bootstrap/mtls/peer"
+	secretsPkg "iiot-backend/pkg/go-mod-secrets/pkg"
+)
+
+// deviceSecretPrefix keeps the device secrets apart from the other secrets of the service in the
+// secret store, each tenant's secrets under a prefix of their own
+const deviceSecretPrefix = "device-credentials/"
+
+// deviceServiceContextKey holds the device service a service token was issued to
+const deviceServiceContextKey = "deviceService"
+
+// deviceSecretName restricts device secret names and tenants so that they cannot leave the prefix of
+// their tenant
+var deviceSecretName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// DeviceSecretStore keeps the credentials devices reference by name from their protocols, such as
+// the SecretProvider of the service. It also validates the JWTs services obtain from the store,
+// authenticating the device services fetching resolved credentials.
+type DeviceSecretStore interface {
+	RetrieveSecret(secretName string, keys ...string) (map[string]string, error)
+	SaveSecret(secretName string, data map[string]string) error
+	RetrieveSecretNames() ([]string, error)
+	IsJWTValid(jwt string) (bool, error)
+}
+
+// DeviceSecret describes a device secret without its values, which are only handed to device
+// services as part of the resolved device
+type DeviceSecret struct {
+	Name string   `json:"name"`
+	Keys []string `json:"keys"`
+}
+
+type UpdateDeviceSecretRequest struct {
+	RequestId  string            `json:"requestId,omitempty"`
+	SecretData map[string]string `json:"secretData"`
+}
+
+func (r *UpdateDeviceSecretRequest) Validate() error {
+	if len(r.SecretData) == 0 {
+		return &ValidationError{Message: "secret data is required"}
+	}
+	for key, value := range r.SecretData {
+		if key == "" {
+			return &ValidationError{Message: "secret data keys must not be empty"}
+		}
+		if key == sensitive.SecretReference {
+			return &ValidationError{Message: fmt.Sprintf("secret data must not contain %s", sensitive.SecretReference)}
+		}
+		if value == sensitive.Mask {
+			return &ValidationError{Message: "secret data carries masked values, provide the actual values"}
+		}
+	}
+	return nil
+}
+
+var errDeviceSecretsDisabled = EdgeXError{Code: http.StatusServiceUnavailable, Message: "device secrets are not configured, no secret store is available"}
+
+func validateDeviceSecretName(name string) EdgeXError {
+	if name == "" {
+		return EdgeXError{Code: http.StatusBadRequest, Message: "device secret name is required"}
+	}
+	if !deviceSecretName.MatchString(name) {
+		return EdgeXError{Code: http.StatusBadRequest, Message: fmt.Sprintf("device secret name %s may only contain letters, digits, '.', '_' and '-'", name)}
+	}
+	return EdgeXError{}
+}
+
+// tenantSecretPrefix returns the prefix of the device secrets of tenantID
+func tenantSecretPrefix(tenantID string) (string, EdgeXError) {
+	if !deviceSecretName.MatchString(tenantID) {
+		return "", EdgeXError{Code: http.StatusBadRequest, Message: fmt.Sprintf("tenant '%s' cannot hold device secrets", tenantID)}
+	}
+	return deviceSecretPrefix + tenantID + "/", EdgeXError{}
+}
+
+// deviceSecretPath returns where the device secret name of tenantID is kept
+func deviceSecretPath(tenantID, name string) (string, EdgeXError) {
+	if edgeErr := validateDeviceSecretName(name); edgeErr.Code != 0 {
+		return "", edgeErr
+	}
+	prefix, edgeErr := tenantSecretPrefix(tenantID)
+	return prefix + name, edgeErr
+}
+
+// retrieveDeviceSecret reads the data of the device secret name of tenantID
+func (s *WorkingMetadataService) retrieveDeviceSecret(tenantID, name string) (map[string]string, EdgeXError) {
+	if s.secrets == nil {
+		return nil, errDeviceSecretsDisabled
+	}
+	path, edgeErr := deviceSecretPath(tenantID, name)
+	if edgeErr.Code != 0 {
+		return nil, edgeErr
+	}
+
+	data, err := s.secrets.RetrieveSecret(path)
+	var notFound secretsPkg.ErrSecretNameNotFound
+	if errors.As(err, &notFound) {
+		return nil, EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("device secret %s not found", name)}
+	} else if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to read device secret"}
+	}
+	return data, EdgeXError{}
+}
+
+// SaveDeviceSecret creates the device secret name of the tenant of ctx or replaces its data with a
+// new version
+func (s *WorkingMetadataService) SaveDeviceSecret(ctx context.Context, name string, data map[string]string) EdgeXError {
+	if s.secrets == nil {
+		return errDeviceSecretsDisabled
+	}
+	tenantID := tenant.FromContext(ctx).Tenant
+	path, edgeErr := deviceSecretPath(tenantID, name)
+	if edgeErr.Code != 0 {
+		return edgeErr
+	}
+
+	// the audit log records which keys changed, never their values
+	var before interface{}
+	if previous, edgeErr := s.retrieveDeviceSecret(tenantID, name); edgeErr.Code == 0 {
+		before = DeviceSecret{Name: name, Keys: sortedKeys(previous)}
+	}
+
+	if err := s.secrets.SaveSecret(path, data); err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to save device secret"}
+	}
+
+	audit.Change(ctx, "devicesecret", name, before, DeviceSecret{Name: name, Keys: sortedKeys(data)})
+	return EdgeXError{}
+}
+
+func (s *WorkingMetadataService) GetDeviceSecretByName(ctx context.Context, name string) (DeviceSecret, EdgeXError) {
+	data, edgeErr := s.retrieveDeviceSecret(tenant.FromContext(ctx).Tenant, name)
+	if edgeErr.Code != 0 {
+		return DeviceSecret{}, edgeErr
+	}
+	return DeviceSecret{Name: name, Keys: sortedKeys(data)}, EdgeXError{}
+}
+
+// GetAllDeviceSecrets lists the device secrets of the tenant of ctx by name, without reading their
+// data
+func (s *WorkingMetadataService) GetAllDeviceSecrets(ctx context.Context) ([]string, EdgeXError) {
+	if s.secrets == nil {
+		return nil, errDeviceSecretsDisabled
+	}
+	prefix, edgeErr := tenantSecretPrefix(tenant.FromContext(ctx).Tenant)
+	if edgeErr.Code != 0 {
+		return nil, edgeErr
+	}
+
+	secretNames, err := s.secrets.RetrieveSecretNames()
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to list device secrets"}
+	}
+	names := []string{}
+	for _, secretName := range secretNames {
+		if strings.HasPrefix(secretName, prefix) {
+			names = append(names, strings.TrimPrefix(secretName, prefix))
+		}
+	}
+	sort.Strings(names)
+	return names, EdgeXError{}
+}
+
+// secretReferences returns the names of the device secrets referenced by the protocols
+func secretReferences(protocols map[string]interface{}) []string {
+	var names []string
+	for _, properties := range protocols {
+		if p, ok := properties.(map[string]interface{}); ok {
+			if name, ok := p[sensitive.SecretReference].(string); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkSecretReferences verifies that the device secrets referenced by the protocols exist in the
+// tenant of ctx, the tenant the device is created in
+func (s *WorkingMetadataService) checkSecretReferences(ctx context.Context, protocols map[string]interface{}) EdgeXError {
+	for _, name := range secretReferences(protocols) {
+		if _, edgeErr := s.retrieveDeviceSecret(tenant.FromContext(ctx).Tenant, name); edgeErr.Code != 0 {
+			if edgeErr.Code != http.StatusInternalServerError {
+				edgeErr.Code = http.StatusBadRequest
+			}
+			return edgeErr
+		}
+	}
+	return EdgeXError{}
+}
+
+// resolveProtocols returns a copy of the protocols in which the data of each referenced device
+// secret of tenantID is merged into the properties of the protocol referencing it
+func (s *WorkingMetadataService) resolveProtocols(tenantID string, protocols map[string]interface{}) (map[string]interface{}, EdgeXError) {
+	resolved := make(map[string]interface{}, len(protocols))
+	for protocol, properties := range protocols {
+		p, ok := properties.(map[string]interface{})
+		name, _ := p[sensitive.SecretReference].(string)
+		if !ok || name == "" {
+			resolved[protocol] = properties
+			continue
+		}
+
+		data, edgeErr := s.retrieveDeviceSecret(tenantID, name)
+		if edgeErr.Code != 0 {
+			return nil, edgeErr
+		}
+		merged := make(map[string]interface{}, len(p)+len(data))
+		for key, value := range p {
+			merged[key] = value
+		}
+		for key, value := range data {
+			merged[key] = value
+		}
+		resolved[protocol] = merged
+	}
+	return resolved, EdgeXError{}
+}
+
+// GetResolvedDeviceByName returns the device with its protocol credentials in plaintext, including
+// those of the device secrets its protocols reference in the tenant of the device. It is meant for
+// device services only, and only the device service the device belongs to may resolve it.
+func (s *WorkingMetadataService) GetResolvedDeviceByName(ctx context.Context, name, serviceName string) (Device, EdgeXError) {
+	device, edgeErr := s.GetDeviceByName(sensitive.WithReveal(ctx, true), name)
+	if edgeErr.Code != 0 {
+		return Device{}, edgeErr
+	}
+	if device.ServiceName != serviceName {
+		return Device{}, EdgeXError{Code: http.StatusForbidden, Message: fmt.Sprintf("device %s does not belong to device service %s", name, serviceName)}
+	}
+	var tenantID string
+	if err := s.db.QueryRowContext(ctx, `SELECT tenant_id FROM devices WHERE name = $1`, name).Scan(&tenantID); err != nil {
+		return Device{}, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get device tenant"}
+	}
+	device.Protocols, edgeErr = s.resolveProtocols(tenantID, device.Protocols)
+	if edgeErr.Code != 0 {
+		return Device{}, edgeErr
+	}
+	return device, EdgeXError{}
+}
+
+func sortedKeys(data map[string]string) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// requireServiceToken only lets through requests authenticated with a JWT the secret store issued
+// to a device service registered in metadata, as device services send it in the Authorization
+// header, over a connection with a verified client certificate identifying that same service. Any
+// service sharing the secret store can obtain a token naming any other service, so the token alone
+// does not identify the caller; the certificate does. Device services act for every tenant.
+func (h *WorkingHandler) requireServiceToken(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if h.service.secrets == nil {
+			return c.JSON(errDeviceSecretsDisabled.Code, map[string]string{"error": errDeviceSecretsDisabled.Message})
+		}
+		certificate := peer.Certificate(c.Request())
+		if certificate == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "client certificate required"})
+		}
+
+		scheme, token, _ := strings.Cut(c.Request().Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "service token required"})
+		}
+		valid, err := h.service.secrets.IsJWTValid(token)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to validate service token"})
+		}
+		if !valid {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid service token"})
+		}
+
+		// the secret store verified the token, its subject names the service it was issued to
+		var claims jwt.RegisteredClaims
+		if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil || claims.Subject == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "service token names no service"})
+		}
+		if !certifies(certificate, claims.Subject) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": fmt.Sprintf("client certificate does not identify service %s", claims.Subject)})
+		}
+
+		ctx := tenant.WithScope(c.Request().Context(), tenant.Unrestricted())
+		if _, edgeErr := h.service.GetDeviceServiceByName(ctx, claims.Subject); edgeErr.Code == http.StatusNotFound {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": fmt.Sprintf("service %s is not a device service", claims.Subject)})
+		} else if edgeErr.Code != 0 {
+			return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+		}
+		c.Set(deviceServiceContextKey, claims.Subject)
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
+
+// certifies reports whether the certificate identifies the service
+func certifies(certificate *x509.Certificate, service string) bool {
+	for _, identity := range peer.Identities(certificate) {
+		if identity == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Device secret endpoints
+func (h *WorkingHandler) UpdateDeviceSecret(c echo.Context) error {
+	var req UpdateDeviceSecretRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if edgeErr := h.service.SaveDeviceSecret(c.Request().Context(), c.Param("name"), req.SecretData); edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) GetDeviceSecretByName(c echo.Context) error {
+	secret, edgeErr := h.service.GetDeviceSecretByName(c.Request().Context(), c.Param("name"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"secret":     secret,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) GetAllDeviceSecrets(c echo.Context) error {
+	names, edgeErr := h.service.GetAllDeviceSecrets(c.Request().Context())
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion":  "v3",
+		"statusCode":  http.StatusOK,
+		"totalCount":  len(names),
+		"secretNames": names,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) GetResolvedDeviceByName(c echo.Context) error {
+	serviceName, _ := c.Get(deviceServiceContextKey).(string)
+	device, edgeErr := h.service.GetResolvedDeviceByName(c.Request().Context(), c.Param("name"), serviceName)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"device":     device,
+	}
+	return c.JSON(http.StatusOK, response)
+}
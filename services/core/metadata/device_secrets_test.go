@@ -0,0 +1,194 @@
+package metadata
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"iiot-backend/middleware/sensitive"
+	"iiot-backend/middleware/tenant"
+	secretsPkg "iiot-backend/pkg/go-mod-secrets/pkg"
+)
+
+// fakeSecretStore keeps device secrets in memory and accepts the JWTs listed in tokens
+type fakeSecretStore struct {
+	secrets map[string]map[string]string
+	tokens  map[string]bool
+}
+
+func (s *fakeSecretStore) RetrieveSecret(secretName string, _ ...string) (map[string]string, error) {
+	data, ok := s.secrets[secretName]
+	if !ok {
+		return nil, secretsPkg.NewErrSecretNameNotFound(secretName)
+	}
+	return data, nil
+}
+
+func (s *fakeSecretStore) SaveSecret(secretName string, data map[string]string) error {
+	s.secrets[secretName] = data
+	return nil
+}
+
+func (s *fakeSecretStore) RetrieveSecretNames() ([]string, error) {
+	var names []string
+	for name := range s.secrets {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (s *fakeSecretStore) IsJWTValid(token string) (bool, error) {
+	return s.tokens[token], nil
+}
+
+func newTestService(t *testing.T) (*WorkingMetadataService, *fakeSecretStore) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	keys, err := sensitive.NewKeyring(sensitive.StaticSource{"primary": "k1", "k1": base64.StdEncoding.EncodeToString(key)}, sensitive.SecretName)
+	require.NoError(t, err)
+
+	store := &fakeSecretStore{secrets: map[string]map[string]string{}, tokens: map[string]bool{}}
+	return NewWorkingMetadataService(nil, keys, store), store
+}
+
+func TestResolvedDeviceSecretsAreMasked(t *testing.T) {
+	service, store := newTestService(t)
+	ctx := tenant.WithScope(context.Background(), tenant.Scope{Tenant: "plant-a"})
+	require.Equal(t, 0, service.SaveDeviceSecret(ctx, "plc7-opcua", map[string]string{"username": "plc", "password": "opcua-s3cret"}).Code)
+
+	protocols := map[string]interface{}{
+		"opcua": map[string]interface{}{sensitive.SecretReference: "plc7-opcua", "endpoint": "opc.tcp://plc7:4840"},
+		"snmp":  map[string]interface{}{"authPassword": "snmp-s3cret"},
+	}
+	stored, edgeErr := service.encryptProtocols("device-1", protocols)
+	require.Equal(t, 0, edgeErr.Code)
+	assert.NotContains(t, string(stored), "snmp-s3cret")
+
+	// public responses mask the inline credentials and never carry those of the referenced secret
+	public, edgeErr := service.decryptProtocols(ctx, "device-1", stored)
+	require.Equal(t, 0, edgeErr.Code)
+	publicJSON, err := json.Marshal(public)
+	require.NoError(t, err)
+	assert.Equal(t, sensitive.Mask, public["snmp"].(map[string]interface{})["authPassword"])
+	assert.Equal(t, "plc7-opcua", public["opcua"].(map[string]interface{})[sensitive.SecretReference])
+	assert.NotContains(t, string(publicJSON), "opcua-s3cret")
+	assert.NotContains(t, string(publicJSON), "snmp-s3cret")
+
+	// device services get the credentials in plaintext, resolved from the secret store
+	revealed, edgeErr := service.decryptProtocols(sensitive.WithReveal(ctx, true), "device-1", stored)
+	require.Equal(t, 0, edgeErr.Code)
+	resolved, edgeErr := service.resolveProtocols("plant-a", revealed)
+	require.Equal(t, 0, edgeErr.Code)
+	assert.Equal(t, "opcua-s3cret", resolved["opcua"].(map[string]interface{})["password"])
+	assert.Equal(t, "snmp-s3cret", resolved["snmp"].(map[string]interface{})["authPassword"])
+	assert.NotContains(t, revealed["opcua"], "password", "resolving must not modify the stored protocols")
+
+	// masking the resolved device, as the audit log does, masks the credentials of the secret too
+	masked := maskedDevice(Device{Protocols: resolved})
+	assert.Equal(t, sensitive.Mask, masked.Protocols["opcua"].(map[string]interface{})["password"])
+	assert.Equal(t, "plc7-opcua", masked.Protocols["opcua"].(map[string]interface{})[sensitive.SecretReference])
+
+	// the secret API lists the keys of a secret, never its values
+	secret, edgeErr := service.GetDeviceSecretByName(ctx, "plc7-opcua")
+	require.Equal(t, 0, edgeErr.Code)
+	assert.Equal(t, DeviceSecret{Name: "plc7-opcua", Keys: []string{"password", "username"}}, secret)
+	assert.Contains(t, store.secrets, deviceSecretPrefix+"plant-a/plc7-opcua")
+}
+
+func TestDeviceSecretNamesStayInTheirPrefix(t *testing.T) {
+	service, _ := newTestService(t)
+	ctx := tenant.WithScope(context.Background(), tenant.Scope{Tenant: "plant-a"})
+
+	edgeErr := service.SaveDeviceSecret(ctx, "../core-metadata/db", map[string]string{"password": "pg"})
+	assert.Equal(t, http.StatusBadRequest, edgeErr.Code)
+	_, edgeErr = service.GetDeviceSecretByName(ctx, "missing")
+	assert.Equal(t, http.StatusNotFound, edgeErr.Code)
+	// secrets belong to a tenant, there is no prefix for requests of none
+	edgeErr = service.SaveDeviceSecret(context.Background(), "plc7-opcua", map[string]string{"password": "pg"})
+	assert.Equal(t, http.StatusBadRequest, edgeErr.Code)
+}
+
+func TestDeviceSecretsStayInTheirTenant(t *testing.T) {
+	service, _ := newTestService(t)
+	plantA := tenant.WithScope(context.Background(), tenant.Scope{Tenant: "plant-a"})
+	plantB := tenant.WithScope(context.Background(), tenant.Scope{Tenant: "plant-b"})
+	require.Equal(t, 0, service.SaveDeviceSecret(plantB, "plc7-opcua", map[string]string{"password": "opcua-s3cret"}).Code)
+	protocols := map[string]interface{}{
+		"opcua": map[string]interface{}{sensitive.SecretReference: "plc7-opcua"},
+	}
+
+	// devices of another tenant can neither reference the secret nor have it resolved
+	assert.Equal(t, http.StatusBadRequest, service.checkSecretReferences(plantA, protocols).Code)
+	_, edgeErr := service.resolveProtocols("plant-a", protocols)
+	assert.Equal(t, http.StatusNotFound, edgeErr.Code)
+	_, edgeErr = service.GetDeviceSecretByName(plantA, "plc7-opcua")
+	assert.Equal(t, http.StatusNotFound, edgeErr.Code)
+	names, edgeErr := service.GetAllDeviceSecrets(plantA)
+	require.Equal(t, 0, edgeErr.Code)
+	assert.Empty(t, names)
+
+	assert.Equal(t, 0, service.checkSecretReferences(plantB, protocols).Code)
+	names, edgeErr = service.GetAllDeviceSecrets(plantB)
+	require.Equal(t, 0, edgeErr.Code)
+	assert.Equal(t, []string{"plc7-opcua"}, names)
+}
+
+func TestRequireServiceToken(t *testing.T) {
+	service, store := newTestService(t)
+	db, err := sql.Open("metadata-empty", "")
+	require.NoError(t, err)
+	service.db = db
+	handler := NewWorkingHandler(service)
+	unnamed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Issuer: "file-secret-store"}).SignedString([]byte("key"))
+	require.NoError(t, err)
+	store.tokens[unnamed] = true
+	modbus, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Issuer: "file-secret-store", Subject: "device-modbus"}).SignedString([]byte("key"))
+	require.NoError(t, err)
+	store.tokens[modbus] = true
+
+	tests := []struct {
+		name          string
+		authorization string
+		certificate   string
+		expected      int
+		message       string
+	}{
+		{"no token", "", "device-modbus", http.StatusUnauthorized, "service token required"},
+		{"user credentials", "Basic YWRtaW46YWRtaW4=", "device-modbus", http.StatusUnauthorized, "service token required"},
+		{"invalid token", "Bearer forged", "device-modbus", http.StatusUnauthorized, "invalid service token"},
+		{"token without service", "Bearer " + unnamed, "device-modbus", http.StatusUnauthorized, "names no service"},
+		// any service sharing the secret store can obtain the token, the certificate identifies the caller
+		{"token without certificate", "Bearer " + modbus, "", http.StatusUnauthorized, "client certificate required"},
+		{"certificate of another service", "Bearer " + modbus, "core-command", http.StatusForbidden, "does not identify service device-modbus"},
+		{"unregistered device service", "Bearer " + modbus, "device-modbus", http.StatusForbidden, "not a device service"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v3/device/name/plc7/resolved", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			if tt.certificate != "" {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: tt.certificate}}}}}
+			}
+			rec := httptest.NewRecorder()
+			next := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+			require.NoError(t, handler.requireServiceToken(next)(echo.New().NewContext(req, rec)))
+			assert.Equal(t, tt.expected, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.message)
+		})
+	}
+}
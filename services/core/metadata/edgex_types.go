@@ -24,6 +24,8 @@ type Device struct {
         ProfileName    string                 `json:"profileName" validate:"required"`
         Labels         []string               `json:"labels,omitempty"`
         Location       map[string]interface{} `json:"location,omitempty"`
+        AssetNode      string                 `json:"assetNode,omitempty"`
+        ProfileVersion int                    `json:"profileVersion,omitempty"`
         Protocols      map[string]interface{} `json:"protocols,omitempty"`
         AutoEvents     []interface{}          `json:"autoEvents,omitempty"`
         Notify         bool                   `json:"notify"`
@@ -41,6 +43,7 @@ type DeviceProfile struct {
         DeviceResources  []map[string]interface{} `json:"deviceResources,omitempty"`
         DeviceCommands   []map[string]interface{} `json:"deviceCommands,omitempty"`
         CoreCommands     []map[string]interface{} `json:"coreCommands,omitempty"`
+        Version          int                      `json:"version,omitempty"`
         Created          int64                    `json:"created,omitempty"`
         Modified         int64                    `json:"modified,omitempty"`
 }
@@ -0,0 +1,757 @@
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/tenant"
+)
+
+// Kinds of change reported by a profile diff
+const (
+	ProfileChangeAdded    = "added"
+	ProfileChangeRemoved  = "removed"
+	ProfileChangeModified = "modified"
+)
+
+// profileDiffIgnored lists bookkeeping fields left out of profile diffs
+var profileDiffIgnored = map[string]bool{
+	"id":       true,
+	"version":  true,
+	"created":  true,
+	"modified": true,
+}
+
+// ProfileRevision is a stored snapshot of a device profile
+type ProfileRevision struct {
+	ProfileName       string        `json:"profileName"`
+	Version           int           `json:"version"`
+	ChangeDescription string        `json:"changeDescription,omitempty"`
+	Profile           DeviceProfile `json:"profile"`
+	Created           int64         `json:"created"`
+}
+
+// ProfileChange describes a single difference between two profile revisions.
+// Path addresses the changed field, with named list entries written as
+// deviceResources[temperature].properties.scale.
+type ProfileChange struct {
+	Path   string      `json:"path"`
+	Change string      `json:"change"`
+	From   interface{} `json:"from,omitempty"`
+	To     interface{} `json:"to,omitempty"`
+}
+
+type ProfileDiff struct {
+	ProfileName string          `json:"profileName"`
+	FromVersion int             `json:"fromVersion"`
+	ToVersion   int             `json:"toVersion"`
+	Changes     []ProfileChange `json:"changes"`
+}
+
+type AddDeviceProfileRequest struct {
+	RequestId         string        `json:"requestId,omitempty"`
+	ChangeDescription string        `json:"changeDescription,omitempty"`
+	Profile           DeviceProfile `json:"profile"`
+}
+
+func (r *AddDeviceProfileRequest) Validate() error {
+	if r.Profile.Name == "" {
+		return &ValidationError{Message: "profile name is required"}
+	}
+	return nil
+}
+
+type RollbackDeviceProfileRequest struct {
+	RequestId string `json:"requestId,omitempty"`
+	Version   int    `json:"version"`
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordProfileVersion stores a snapshot of the profile under its current version
+func recordProfileVersion(ctx context.Context, ex execer, dp DeviceProfile, changeDescription string, now int64) error {
+	snapshot, err := json.Marshal(dp)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO device_profile_versions (id, profile_name, version, profile, change_description, created)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err = ex.ExecContext(ctx, query, uuid.New().String(), dp.Name, dp.Version, snapshot, changeDescription, now)
+	return err
+}
+
+func scanDeviceProfile(scan func(dest ...interface{}) error) (DeviceProfile, error) {
+	var dp DeviceProfile
+	var labelsJSON, resourcesJSON, commandsJSON, coreCommandsJSON []byte
+
+	err := scan(&dp.Id, &dp.Name, &dp.Description, &dp.Manufacturer, &dp.Model, &labelsJSON,
+		&resourcesJSON, &commandsJSON, &coreCommandsJSON, &dp.Version, &dp.Created, &dp.Modified)
+	if err != nil {
+		return dp, err
+	}
+
+	json.Unmarshal(labelsJSON, &dp.Labels)
+	json.Unmarshal(resourcesJSON, &dp.DeviceResources)
+	json.Unmarshal(commandsJSON, &dp.DeviceCommands)
+	json.Unmarshal(coreCommandsJSON, &dp.CoreCommands)
+	return dp, nil
+}
+
+const deviceProfileColumns = `
+	id, name, COALESCE(description, ''), COALESCE(manufacturer, ''), COALESCE(model, ''), labels,
+	device_resources, device_commands, core_commands, version, created, modified`
+
+// Device profile operations
+func (s *WorkingMetadataService) AddDeviceProfile(ctx context.Context, req DeviceProfile, changeDescription string) (string, EdgeXError) {
+	if req.Name == "" {
+		return "", EdgeXError{Code: http.StatusBadRequest, Message: "device profile name is required"}
+	}
+	if edgeErr := s.validateProfileUnits(ctx, req); edgeErr.Code != 0 {
+		return "", edgeErr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", EdgeXError{Code: http.StatusInternalServerError, Message: "failed to begin transaction"}
+	}
+	defer tx.Rollback()
+
+	req.Id = uuid.New().String()
+	if err := insertDeviceProfile(ctx, tx, req, changeDescription, time.Now().Unix()); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return "", EdgeXError{Code: http.StatusConflict, Message: fmt.Sprintf("device profile %s already exists", req.Name)}
+		}
+		return "", EdgeXError{Code: http.StatusInternalServerError, Message: "failed to add device profile"}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", EdgeXError{Code: http.StatusInternalServerError, Message: "failed to commit device profile"}
+	}
+
+	audit.Change(ctx, "deviceprofile", req.Name, nil, req)
+	return req.Id, EdgeXError{}
+}
+
+func (s *WorkingMetadataService) GetDeviceProfileByName(ctx context.Context, name string) (DeviceProfile, EdgeXError) {
+	if name == "" {
+		return DeviceProfile{}, EdgeXError{Code: http.StatusBadRequest, Message: "device profile name is required"}
+	}
+
+	args := []interface{}{name}
+	query := `SELECT ` + deviceProfileColumns + ` FROM device_profiles WHERE name = $1 AND ` +
+		tenant.FromContext(ctx).Condition("tenant_id", &args)
+
+	dp, err := scanDeviceProfile(s.db.QueryRowContext(ctx, query, args...).Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return DeviceProfile{}, EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("device profile %s not found", name)}
+		}
+		return DeviceProfile{}, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get device profile"}
+	}
+
+	return dp, EdgeXError{}
+}
+
+func (s *WorkingMetadataService) GetAllDeviceProfiles(ctx context.Context, offset, limit int) ([]DeviceProfile, uint32, EdgeXError) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	scope := tenant.FromContext(ctx)
+
+	var totalCount uint32
+	var countArgs []interface{}
+	countQuery := `SELECT COUNT(*) FROM device_profiles WHERE ` + scope.Condition("tenant_id", &countArgs)
+	if err := s.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&totalCount); err != nil {
+		return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get total count"}
+	}
+
+	args := []interface{}{limit, offset}
+	query := `SELECT ` + deviceProfileColumns + ` FROM device_profiles WHERE ` + scope.Condition("tenant_id", &args) +
+		` ORDER BY name LIMIT $1 OFFSET $2`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to query device profiles"}
+	}
+	defer rows.Close()
+
+	var profiles []DeviceProfile
+	for rows.Next() {
+		dp, err := scanDeviceProfile(rows.Scan)
+		if err != nil {
+			return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to scan device profile"}
+		}
+		profiles = append(profiles, dp)
+	}
+
+	return profiles, totalCount, EdgeXError{}
+}
+
+// UpdateDeviceProfile replaces the profile content, bumps its version and
+// records the new revision. It returns the new version number.
+func (s *WorkingMetadataService) UpdateDeviceProfile(ctx context.Context, req DeviceProfile, changeDescription string) (int, EdgeXError) {
+	if req.Name == "" {
+		return 0, EdgeXError{Code: http.StatusBadRequest, Message: "device profile name is required"}
+	}
+	if edgeErr := s.validateProfileUnits(ctx, req); edgeErr.Code != 0 {
+		return 0, edgeErr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to begin transaction"}
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{req.Name}
+	query := `SELECT ` + deviceProfileColumns + ` FROM device_profiles WHERE name = $1 AND ` +
+		tenant.FromContext(ctx).Condition("tenant_id", &args) + ` FOR UPDATE`
+	current, err := scanDeviceProfile(tx.QueryRowContext(ctx, query, args...).Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("device profile %s not found", req.Name)}
+		}
+		return 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get device profile"}
+	}
+
+	now := time.Now().Unix()
+	req.Id = current.Id
+	req.Version = current.Version + 1
+	req.Created = current.Created
+	req.Modified = now
+
+	update := `
+		UPDATE device_profiles
+		SET description = $2, manufacturer = $3, model = $4, labels = $5, device_resources = $6,
+			device_commands = $7, core_commands = $8, version = $9, modified = $10
+		WHERE name = $1`
+
+	_, err = tx.ExecContext(ctx, update, req.Name, req.Description, req.Manufacturer, req.Model,
+		marshalOrDefault(req.Labels, "[]"), marshalOrDefault(req.DeviceResources, "[]"),
+		marshalOrDefault(req.DeviceCommands, "[]"), marshalOrDefault(req.CoreCommands, "[]"),
+		req.Version, now)
+	if err != nil {
+		return 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to update device profile"}
+	}
+
+	if err := recordProfileVersion(ctx, tx, req, changeDescription, now); err != nil {
+		return 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to record device profile version"}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to commit device profile"}
+	}
+
+	audit.Change(ctx, "deviceprofile", req.Name, current, req)
+	return req.Version, EdgeXError{}
+}
+
+// DeleteDeviceProfileByName removes the profile and its history. Profiles
+// still used by devices are refused, since the foreign key would cascade.
+func (s *WorkingMetadataService) DeleteDeviceProfileByName(ctx context.Context, name string) EdgeXError {
+	if name == "" {
+		return EdgeXError{Code: http.StatusBadRequest, Message: "device profile name is required"}
+	}
+
+	before, edgeErr := s.GetDeviceProfileByName(ctx, name)
+	if edgeErr.Code != 0 {
+		return edgeErr
+	}
+
+	// devices of every tenant are counted, the profile cannot be removed from under any of them
+	var devices int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM devices WHERE profile_name = $1`, name).Scan(&devices)
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to count devices using profile"}
+	}
+	if devices > 0 {
+		return EdgeXError{Code: http.StatusConflict, Message: fmt.Sprintf("device profile %s is still used by %d devices", name, devices)}
+	}
+
+	args := []interface{}{name}
+	query := `DELETE FROM device_profiles WHERE name = $1 AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args)
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to delete device profile"}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get rows affected"}
+	}
+	if rowsAffected == 0 {
+		return EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("device profile %s not found", name)}
+	}
+
+	audit.Change(ctx, "deviceprofile", name, before, nil)
+	return EdgeXError{}
+}
+
+func scanProfileRevision(scan func(dest ...interface{}) error) (ProfileRevision, error) {
+	var rev ProfileRevision
+	var snapshot []byte
+
+	if err := scan(&rev.ProfileName, &rev.Version, &snapshot, &rev.ChangeDescription, &rev.Created); err != nil {
+		return rev, err
+	}
+	if err := json.Unmarshal(snapshot, &rev.Profile); err != nil {
+		return rev, err
+	}
+	return rev, nil
+}
+
+func (s *WorkingMetadataService) GetDeviceProfileVersion(ctx context.Context, name string, version int) (ProfileRevision, EdgeXError) {
+	args := []interface{}{name, version}
+	query := `
+		SELECT profile_name, version, profile, COALESCE(change_description, ''), created
+		FROM device_profile_versions
+		WHERE profile_name = $1 AND version = $2
+			AND profile_name IN (SELECT name FROM device_profiles WHERE ` + tenant.FromContext(ctx).Condition("tenant_id", &args) + `)`
+
+	rev, err := scanProfileRevision(s.db.QueryRowContext(ctx, query, args...).Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ProfileRevision{}, EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("version %d of device profile %s not found", version, name)}
+		}
+		return ProfileRevision{}, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get device profile version"}
+	}
+
+	return rev, EdgeXError{}
+}
+
+// GetDeviceProfileHistory returns every stored revision of the profile, newest first
+func (s *WorkingMetadataService) GetDeviceProfileHistory(ctx context.Context, name string) ([]ProfileRevision, EdgeXError) {
+	if _, edgeErr := s.GetDeviceProfileByName(ctx, name); edgeErr.Code != 0 {
+		return nil, edgeErr
+	}
+
+	query := `
+		SELECT profile_name, version, profile, COALESCE(change_description, ''), created
+		FROM device_profile_versions
+		WHERE profile_name = $1
+		ORDER BY version DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, name)
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to query device profile history"}
+	}
+	defer rows.Close()
+
+	var revisions []ProfileRevision
+	for rows.Next() {
+		rev, err := scanProfileRevision(rows.Scan)
+		if err != nil {
+			return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to scan device profile version"}
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, EdgeXError{}
+}
+
+// DiffDeviceProfileVersions lists the changes needed to go from one revision to another
+func (s *WorkingMetadataService) DiffDeviceProfileVersions(ctx context.Context, name string, fromVersion, toVersion int) (ProfileDiff, EdgeXError) {
+	from, edgeErr := s.GetDeviceProfileVersion(ctx, name, fromVersion)
+	if edgeErr.Code != 0 {
+		return ProfileDiff{}, edgeErr
+	}
+	to, edgeErr := s.GetDeviceProfileVersion(ctx, name, toVersion)
+	if edgeErr.Code != 0 {
+		return ProfileDiff{}, edgeErr
+	}
+
+	fromDoc, err := profileDocument(from.Profile)
+	if err != nil {
+		return ProfileDiff{}, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to decode device profile version"}
+	}
+	toDoc, err := profileDocument(to.Profile)
+	if err != nil {
+		return ProfileDiff{}, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to decode device profile version"}
+	}
+	for field := range profileDiffIgnored {
+		delete(fromDoc, field)
+		delete(toDoc, field)
+	}
+
+	diff := ProfileDiff{ProfileName: name, FromVersion: fromVersion, ToVersion: toVersion, Changes: []ProfileChange{}}
+	diffValues("", fromDoc, toDoc, &diff.Changes)
+	return diff, EdgeXError{}
+}
+
+// RollbackDeviceProfile restores the content of an earlier revision as a new
+// revision, so the history itself is never rewritten.
+func (s *WorkingMetadataService) RollbackDeviceProfile(ctx context.Context, name string, version int) (int, EdgeXError) {
+	rev, edgeErr := s.GetDeviceProfileVersion(ctx, name, version)
+	if edgeErr.Code != 0 {
+		return 0, edgeErr
+	}
+
+	rev.Profile.Name = name
+	return s.UpdateDeviceProfile(ctx, rev.Profile, fmt.Sprintf("rollback to version %d", version))
+}
+
+// SetDeviceProfileVersion pins the device to a revision of its profile, or
+// unpins it so it follows the latest revision when version is 0.
+func (s *WorkingMetadataService) SetDeviceProfileVersion(ctx context.Context, deviceName string, version int) EdgeXError {
+	device, edgeErr := s.GetDeviceByName(ctx, deviceName)
+	if edgeErr.Code != 0 {
+		return edgeErr
+	}
+	if version < 0 {
+		return EdgeXError{Code: http.StatusBadRequest, Message: "profile version must not be negative"}
+	}
+	if version > 0 {
+		if _, edgeErr := s.GetDeviceProfileVersion(ctx, device.ProfileName, version); edgeErr.Code != 0 {
+			return edgeErr
+		}
+	}
+
+	query := `UPDATE devices SET profile_version = NULLIF($2, 0), modified = $3 WHERE name = $1`
+	if _, err := s.db.ExecContext(ctx, query, deviceName, version, time.Now().Unix()); err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to update device profile version"}
+	}
+
+	after, _ := s.GetDeviceByName(ctx, deviceName)
+	audit.Change(ctx, "device", deviceName, maskedDevice(device), maskedDevice(after))
+	return EdgeXError{}
+}
+
+// profileDocument converts a profile to its generic JSON form for diffing
+func profileDocument(dp DeviceProfile) (map[string]interface{}, error) {
+	data, err := json.Marshal(dp)
+	if err != nil {
+		return nil, err
+	}
+	doc := make(map[string]interface{})
+	err = json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+func diffValues(path string, from, to interface{}, changes *[]ProfileChange) {
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		diffMaps(path, fromMap, toMap, changes)
+		return
+	}
+
+	fromNamed, fromIsNamed := namedEntries(from)
+	toNamed, toIsNamed := namedEntries(to)
+	if fromIsNamed && toIsNamed {
+		diffNamedEntries(path, fromNamed, toNamed, changes)
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, ProfileChange{Path: path, Change: ProfileChangeModified, From: from, To: to})
+	}
+}
+
+func diffMaps(path string, from, to map[string]interface{}, changes *[]ProfileChange) {
+	for _, key := range unionKeys(from, to) {
+		fromValue, inFrom := from[key]
+		toValue, inTo := to[key]
+		keyPath := joinPath(path, key)
+
+		switch {
+		case !inFrom:
+			*changes = append(*changes, ProfileChange{Path: keyPath, Change: ProfileChangeAdded, To: toValue})
+		case !inTo:
+			*changes = append(*changes, ProfileChange{Path: keyPath, Change: ProfileChangeRemoved, From: fromValue})
+		default:
+			diffValues(keyPath, fromValue, toValue, changes)
+		}
+	}
+}
+
+func diffNamedEntries(path string, from, to map[string]interface{}, changes *[]ProfileChange) {
+	for _, name := range unionKeys(from, to) {
+		fromValue, inFrom := from[name]
+		toValue, inTo := to[name]
+		entryPath := fmt.Sprintf("%s[%s]", path, name)
+
+		switch {
+		case !inFrom:
+			*changes = append(*changes, ProfileChange{Path: entryPath, Change: ProfileChangeAdded, To: toValue})
+		case !inTo:
+			*changes = append(*changes, ProfileChange{Path: entryPath, Change: ProfileChangeRemoved, From: fromValue})
+		default:
+			diffValues(entryPath, fromValue, toValue, changes)
+		}
+	}
+}
+
+// namedEntries indexes a list of objects by their name field. It reports
+// false when v is not such a list, in which case it is compared as a whole.
+func namedEntries(v interface{}) (map[string]interface{}, bool) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	entries := make(map[string]interface{}, len(list))
+	for _, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := obj["name"].(string)
+		if !ok || name == "" {
+			return nil, false
+		}
+		if _, dup := entries[name]; dup {
+			return nil, false
+		}
+		entries[name] = obj
+	}
+	return entries, true
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Device profile endpoints
+func (h *WorkingHandler) AddDeviceProfile(c echo.Context) error {
+	var req AddDeviceProfileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	id, edgeErr := h.service.AddDeviceProfile(c.Request().Context(), req.Profile, req.ChangeDescription)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusCreated,
+		"id":         id,
+		"version":    1,
+	}
+	return c.JSON(http.StatusCreated, response)
+}
+
+func (h *WorkingHandler) UpdateDeviceProfile(c echo.Context) error {
+	var req AddDeviceProfileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	version, edgeErr := h.service.UpdateDeviceProfile(c.Request().Context(), req.Profile, req.ChangeDescription)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"version":    version,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) GetDeviceProfileByName(c echo.Context) error {
+	profile, edgeErr := h.service.GetDeviceProfileByName(c.Request().Context(), c.Param("name"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"profile":    profile,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) GetAllDeviceProfiles(c echo.Context) error {
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	profiles, totalCount, edgeErr := h.service.GetAllDeviceProfiles(c.Request().Context(), offset, limit)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"totalCount": totalCount,
+		"profiles":   profiles,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) DeleteDeviceProfileByName(c echo.Context) error {
+	edgeErr := h.service.DeleteDeviceProfileByName(c.Request().Context(), c.Param("name"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"message":    "Device profile deleted successfully",
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) GetDeviceProfileHistory(c echo.Context) error {
+	revisions, edgeErr := h.service.GetDeviceProfileHistory(c.Request().Context(), c.Param("name"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"totalCount": len(revisions),
+		"revisions":  revisions,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) GetDeviceProfileVersion(c echo.Context) error {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid profile version"})
+	}
+
+	rev, edgeErr := h.service.GetDeviceProfileVersion(c.Request().Context(), c.Param("name"), version)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"revision":   rev,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) DiffDeviceProfileVersions(c echo.Context) error {
+	fromVersion, err := strconv.Atoi(c.QueryParam("from"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid from version"})
+	}
+	toVersion, err := strconv.Atoi(c.QueryParam("to"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid to version"})
+	}
+
+	diff, edgeErr := h.service.DiffDeviceProfileVersions(c.Request().Context(), c.Param("name"), fromVersion, toVersion)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"diff":       diff,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) RollbackDeviceProfile(c echo.Context) error {
+	var req RollbackDeviceProfileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+	}
+
+	version, edgeErr := h.service.RollbackDeviceProfile(c.Request().Context(), c.Param("name"), req.Version)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"version":    version,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) PinDeviceProfileVersion(c echo.Context) error {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid profile version"})
+	}
+
+	edgeErr := h.service.SetDeviceProfileVersion(c.Request().Context(), c.Param("name"), version)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"message":    fmt.Sprintf("Device pinned to profile version %d", version),
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) UnpinDeviceProfileVersion(c echo.Context) error {
+	edgeErr := h.service.SetDeviceProfileVersion(c.Request().Context(), c.Param("name"), 0)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"message":    "Device follows the latest profile version",
+	}
+	return c.JSON(http.StatusOK, response)
+}
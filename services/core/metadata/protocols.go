@@ -0,0 +1,98 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"iiot-backend/middleware/sensitive"
+)
+
+// ProtocolsField returns the field the protocol credentials of the device with the given id are
+// encrypted for
+func ProtocolsField(id string) sensitive.Field {
+	return sensitive.Field{Table: "devices", Row: id, Name: "protocols"}
+}
+
+// encryptProtocols returns the JSON stored for the protocols of the device with the given id, with
+// the credentials among the protocol properties encrypted
+func (s *WorkingMetadataService) encryptProtocols(id string, protocols map[string]interface{}) ([]byte, EdgeXError) {
+	encrypted, err := s.keys.EncryptProperties(ProtocolsField(id), protocols)
+	if errors.Is(err, sensitive.ErrMasked) {
+		return nil, EdgeXError{Code: http.StatusBadRequest, Message: "device protocols carry masked credentials, provide the actual values"}
+	} else if errors.Is(err, sensitive.ErrEncryptedValue) {
+		return nil, EdgeXError{Code: http.StatusBadRequest, Message: "device protocols carry encrypted credentials, provide the actual values"}
+	} else if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to encrypt device protocols"}
+	}
+	return marshalOrDefault(encrypted, "{}"), EdgeXError{}
+}
+
+// decryptProtocols parses the stored protocols of the device with the given id, decrypting their
+// credentials when the caller may reveal them and masking them otherwise
+func (s *WorkingMetadataService) decryptProtocols(ctx context.Context, id string, protocolsJSON []byte) (map[string]interface{}, EdgeXError) {
+	var protocols map[string]interface{}
+	json.Unmarshal(protocolsJSON, &protocols)
+	decrypted, err := s.keys.DecryptProperties(ProtocolsField(id), protocols, sensitive.Revealed(ctx))
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to decrypt device protocols"}
+	}
+	return decrypted, EdgeXError{}
+}
+
+// maskedDevice returns the device with its protocol credentials masked, for the audit log
+func maskedDevice(device Device) Device {
+	device.Protocols = sensitive.MaskProperties(device.Protocols)
+	return device
+}
+
+// ReencryptSensitiveFields rewrites the protocol credentials of every device that are in plaintext
+// or encrypted with a retired key under the primary key, and returns the number of devices updated
+func (s *WorkingMetadataService) ReencryptSensitiveFields(ctx context.Context) (int, error) {
+	if !s.keys.Enabled() {
+		return 0, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, protocols FROM devices`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query devices: %w", err)
+	}
+	stale := map[string]map[string]interface{}{}
+	for rows.Next() {
+		var id string
+		var protocolsJSON []byte
+		if err := rows.Scan(&id, &protocolsJSON); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan device: %w", err)
+		}
+		var protocols map[string]interface{}
+		json.Unmarshal(protocolsJSON, &protocols)
+		if s.keys.PropertiesStale(protocols) {
+			stale[id] = protocols
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query devices: %w", err)
+	}
+
+	updated := 0
+	for id, protocols := range stale {
+		decrypted, err := s.keys.DecryptProperties(ProtocolsField(id), protocols, true)
+		if err != nil {
+			return updated, fmt.Errorf("failed to decrypt protocols of device %s: %w", id, err)
+		}
+		encrypted, err := s.keys.EncryptProperties(ProtocolsField(id), decrypted)
+		if err != nil {
+			return updated, fmt.Errorf("failed to encrypt protocols of device %s: %w", id, err)
+		}
+		if _, err := s.db.ExecContext(ctx, `UPDATE devices SET protocols = $2 WHERE id = $1`,
+			id, marshalOrDefault(encrypted, "{}")); err != nil {
+			return updated, fmt.Errorf("failed to update device %s: %w", id, err)
+		}
+		updated++
+	}
+	return updated, nil
+}
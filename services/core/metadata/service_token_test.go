@@ -0,0 +1,102 @@
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"iiot-backend/middleware/auth"
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
+	"iiot-backend/services/security/secretstore"
+)
+
+// emptyDriver is a database holding no rows, so that lookups reach the handler and answer 404
+type emptyDriver struct{}
+
+func (emptyDriver) Open(string) (driver.Conn, error) { return emptyConn{}, nil }
+
+type emptyConn struct{}
+
+func (emptyConn) Prepare(string) (driver.Stmt, error) { return emptyStmt{}, nil }
+func (emptyConn) Close() error                        { return nil }
+func (emptyConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+type emptyStmt struct{}
+
+func (emptyStmt) Close() error                               { return nil }
+func (emptyStmt) NumInput() int                              { return -1 }
+func (emptyStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (emptyStmt) Query([]driver.Value) (driver.Rows, error)  { return emptyRows{}, nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+func init() {
+	sql.Register("metadata-empty", emptyDriver{})
+}
+
+// TestCoreCommandLooksUpDevices runs a device lookup the way core-command sends it, with a token
+// issued by its own secret store sharing the secrets file with core-metadata, through the
+// authentication core-metadata serves its routes with
+func TestCoreCommandLooksUpDevices(t *testing.T) {
+	t.Setenv("SECRETSTORE_FILESTORE_PATH", filepath.Join(t.TempDir(), "secrets.json"))
+	t.Setenv("SECRETSTORE_FILESTORE_PASSPHRASE", "correct horse battery staple")
+	ctx := context.Background()
+	lc := logger.NewMockClient()
+	metadataStore, err := secretstore.FromEnvironment(ctx, "core-metadata", lc)
+	require.NoError(t, err)
+	commandStore, err := secretstore.FromEnvironment(ctx, "core-command", lc)
+	require.NoError(t, err)
+	// the store issues tokens once the secrets file exists
+	require.NoError(t, metadataStore.SaveSecret("postgres", map[string]string{"username": "metadata"}))
+	token, err := commandStore.GetSelfJWT("core-command")
+	require.NoError(t, err)
+
+	db, err := sql.Open("metadata-empty", "")
+	require.NoError(t, err)
+	e := echo.New()
+	service := NewWorkingMetadataService(db, nil, metadataStore)
+	permissions := RegisterWorkingEdgeXRoutes(e.Group("/api/v3"), service)
+	verifiers := auth.TokenVerifiers{
+		auth.NewTokenAuthority([]byte("users-service-key"), 0),
+		auth.NewServiceTokenVerifier(metadataStore, ServicePermissions...),
+	}
+	e.Use(auth.Authenticate(verifiers, nil, nil), auth.RequirePermissions(permissions), tenant.Resolve())
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		expected      int
+	}{
+		{"device lookup", "/api/v3/device/name/plc7", "Bearer " + token, http.StatusNotFound},
+		{"device lookup without token", "/api/v3/device/name/plc7", "", http.StatusUnauthorized},
+		{"device lookup with forged token", "/api/v3/device/name/plc7", "Bearer " + token + "x", http.StatusUnauthorized},
+		// services cannot manage device secrets
+		{"secret listing", "/api/v3/devicesecret/all", "Bearer " + token, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expected, rec.Code, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/tenant"
+)
+
+// Unit is an entry of the unit-of-measure catalogue. A value in the unit
+// converts to the base unit of its quantity as value*Factor + Offset.
+type Unit struct {
+	Id          string  `json:"id,omitempty"`
+	Name        string  `json:"name" validate:"required"`
+	Quantity    string  `json:"quantity" validate:"required"`
+	Description string  `json:"description,omitempty"`
+	Factor      float64 `json:"factor"`
+	Offset      float64 `json:"offset"`
+	Created     int64   `json:"created,omitempty"`
+	Modified    int64   `json:"modified,omitempty"`
+}
+
+type AddUnitRequest struct {
+	RequestId string `json:"requestId,omitempty"`
+	Unit      Unit   `json:"unit"`
+}
+
+func (r *AddUnitRequest) Validate() error {
+	if r.Unit.Name == "" {
+		return &ValidationError{Message: "unit name is required"}
+	}
+	if r.Unit.Quantity == "" {
+		return &ValidationError{Message: "unit quantity is required"}
+	}
+	if r.Unit.Factor == 0 {
+		return &ValidationError{Message: "unit factor must not be zero"}
+	}
+	return nil
+}
+
+// ConvertUnitValue converts value between two units of the same quantity
+func ConvertUnitValue(value float64, from, to Unit) (float64, error) {
+	if from.Quantity != to.Quantity {
+		return 0, fmt.Errorf("cannot convert %s (%s) to %s (%s)", from.Name, from.Quantity, to.Name, to.Quantity)
+	}
+	if from.Name == to.Name {
+		return value, nil
+	}
+	base := value*from.Factor + from.Offset
+	return (base - to.Offset) / to.Factor, nil
+}
+
+// Unit operations
+func (s *WorkingMetadataService) AddUnit(ctx context.Context, req Unit) (string, EdgeXError) {
+	if req.Name == "" {
+		return "", EdgeXError{Code: http.StatusBadRequest, Message: "unit name is required"}
+	}
+	if req.Quantity == "" {
+		return "", EdgeXError{Code: http.StatusBadRequest, Message: "unit quantity is required"}
+	}
+	if req.Factor == 0 {
+		return "", EdgeXError{Code: http.StatusBadRequest, Message: "unit factor must not be zero"}
+	}
+
+	id := uuid.New().String()
+	now := time.Now().Unix()
+
+	query := `
+		INSERT INTO units (id, name, quantity, description, factor, "offset", created, modified)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := s.db.ExecContext(ctx, query, id, req.Name, req.Quantity, req.Description, req.Factor, req.Offset, now, now)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return "", EdgeXError{Code: http.StatusConflict, Message: fmt.Sprintf("unit %s already exists", req.Name)}
+		}
+		return "", EdgeXError{Code: http.StatusInternalServerError, Message: "failed to add unit"}
+	}
+
+	req.Id, req.Created, req.Modified = id, now, now
+	audit.Change(ctx, "unit", req.Name, nil, req)
+	return id, EdgeXError{}
+}
+
+func (s *WorkingMetadataService) GetUnitByName(ctx context.Context, name string) (Unit, EdgeXError) {
+	if name == "" {
+		return Unit{}, EdgeXError{Code: http.StatusBadRequest, Message: "unit name is required"}
+	}
+
+	query := `
+		SELECT id, name, quantity, COALESCE(description, ''), factor, "offset", created, modified
+		FROM units
+		WHERE name = $1`
+
+	var unit Unit
+	err := s.db.QueryRowContext(ctx, query, name).Scan(&unit.Id, &unit.Name, &unit.Quantity,
+		&unit.Description, &unit.Factor, &unit.Offset, &unit.Created, &unit.Modified)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Unit{}, EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("unit %s not found", name)}
+		}
+		return Unit{}, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get unit"}
+	}
+
+	return unit, EdgeXError{}
+}
+
+// GetAllUnits returns the catalogue ordered by quantity and name, optionally
+// restricted to one quantity
+func (s *WorkingMetadataService) GetAllUnits(ctx context.Context, quantity string) ([]Unit, EdgeXError) {
+	query := `
+		SELECT id, name, quantity, COALESCE(description, ''), factor, "offset", created, modified
+		FROM units
+		WHERE ($1 = '' OR quantity = $1)
+		ORDER BY quantity, name`
+
+	rows, err := s.db.QueryContext(ctx, query, quantity)
+	if err != nil {
+		return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to query units"}
+	}
+	defer rows.Close()
+
+	var units []Unit
+	for rows.Next() {
+		var unit Unit
+		err := rows.Scan(&unit.Id, &unit.Name, &unit.Quantity, &unit.Description,
+			&unit.Factor, &unit.Offset, &unit.Created, &unit.Modified)
+		if err != nil {
+			return nil, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to scan unit"}
+		}
+		units = append(units, unit)
+	}
+
+	return units, EdgeXError{}
+}
+
+func (s *WorkingMetadataService) DeleteUnitByName(ctx context.Context, name string) EdgeXError {
+	if name == "" {
+		return EdgeXError{Code: http.StatusBadRequest, Message: "unit name is required"}
+	}
+	before, _ := s.GetUnitByName(ctx, name)
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM units WHERE name = $1`, name)
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to delete unit"}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get rows affected"}
+	}
+	if rowsAffected == 0 {
+		return EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("unit %s not found", name)}
+	}
+
+	audit.Change(ctx, "unit", name, before, nil)
+	return EdgeXError{}
+}
+
+func (s *WorkingMetadataService) ConvertUnit(ctx context.Context, value float64, fromName, toName string) (float64, EdgeXError) {
+	from, edgeErr := s.GetUnitByName(ctx, fromName)
+	if edgeErr.Code != 0 {
+		return 0, edgeErr
+	}
+	to, edgeErr := s.GetUnitByName(ctx, toName)
+	if edgeErr.Code != 0 {
+		return 0, edgeErr
+	}
+
+	converted, err := ConvertUnitValue(value, from, to)
+	if err != nil {
+		return 0, EdgeXError{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return converted, EdgeXError{}
+}
+
+// profileUnits returns the distinct units referenced by the profile resources
+func profileUnits(dp DeviceProfile) []string {
+	seen := make(map[string]bool)
+	var units []string
+	for _, resource := range dp.DeviceResources {
+		props, _ := resource["properties"].(map[string]interface{})
+		unit, _ := props["units"].(string)
+		if unit != "" && !seen[unit] {
+			seen[unit] = true
+			units = append(units, unit)
+		}
+	}
+	sort.Strings(units)
+	return units
+}
+
+// unknownProfileUnit returns the first unit of the profile missing from the
+// catalogue, or an empty string when all are known
+func unknownProfileUnit(dp DeviceProfile, known map[string]bool) string {
+	for _, unit := range profileUnits(dp) {
+		if !known[unit] {
+			return unit
+		}
+	}
+	return ""
+}
+
+// validateProfileUnits checks the units of the profile resources against the catalogue
+func (s *WorkingMetadataService) validateProfileUnits(ctx context.Context, dp DeviceProfile) EdgeXError {
+	// The unit catalogue is shared by every tenant
+	known, err := s.existingNames(ctx, "units", tenant.Scope{All: true})
+	if err != nil {
+		return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to load unit catalogue"}
+	}
+	if unit := unknownProfileUnit(dp, known); unit != "" {
+		return EdgeXError{Code: http.StatusBadRequest, Message: fmt.Sprintf("unit %s is not in the unit catalogue", unit)}
+	}
+	return EdgeXError{}
+}
+
+// Unit endpoints
+func (h *WorkingHandler) AddUnit(c echo.Context) error {
+	var req AddUnitRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	id, edgeErr := h.service.AddUnit(c.Request().Context(), req.Unit)
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusCreated,
+		"id":         id,
+	}
+	return c.JSON(http.StatusCreated, response)
+}
+
+func (h *WorkingHandler) GetUnitByName(c echo.Context) error {
+	unit, edgeErr := h.service.GetUnitByName(c.Request().Context(), c.Param("name"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"unit":       unit,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) GetAllUnits(c echo.Context) error {
+	units, edgeErr := h.service.GetAllUnits(c.Request().Context(), c.QueryParam("quantity"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"totalCount": len(units),
+		"units":      units,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) DeleteUnitByName(c echo.Context) error {
+	edgeErr := h.service.DeleteUnitByName(c.Request().Context(), c.Param("name"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"message":    "Unit deleted successfully",
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkingHandler) ConvertUnit(c echo.Context) error {
+	value, err := strconv.ParseFloat(c.QueryParam("value"), 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid value"})
+	}
+
+	converted, edgeErr := h.service.ConvertUnit(c.Request().Context(), value, c.QueryParam("from"), c.QueryParam("to"))
+	if edgeErr.Code != 0 {
+		return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+	}
+
+	response := map[string]interface{}{
+		"apiVersion": "v3",
+		"statusCode": http.StatusOK,
+		"value":      converted,
+		"units":      c.QueryParam("to"),
+	}
+	return c.JSON(http.StatusOK, response)
+}
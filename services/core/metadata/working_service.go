@@ -8,20 +8,31 @@ import (
         "fmt"
         "net/http"
         "strconv"
+        "strings"
         "time"
 
         "github.com/google/uuid"
         "github.com/labstack/echo/v4"
         "github.com/lib/pq"
+
+        "iiot-backend/middleware/audit"
+        "iiot-backend/middleware/auth"
+        "iiot-backend/middleware/sensitive"
+        "iiot-backend/middleware/tenant"
 )
 
 // Working service implementation
 type WorkingMetadataService struct {
-        db *sql.DB
+        db      *sql.DB
+        keys    *sensitive.Keyring
+        secrets DeviceSecretStore
 }
 
-func NewWorkingMetadataService(db *sql.DB) *WorkingMetadataService {
-        return &WorkingMetadataService{db: db}
+// NewWorkingMetadataService creates the service, device protocol credentials are encrypted with keys
+// when it holds any. Devices may reference credentials kept in secrets, without a store they can
+// only carry them inline.
+func NewWorkingMetadataService(db *sql.DB, keys *sensitive.Keyring, secrets DeviceSecretStore) *WorkingMetadataService {
+        return &WorkingMetadataService{db: db, keys: keys, secrets: secrets}
 }
 
 // Device Service operations
@@ -46,11 +57,11 @@ func (s *WorkingMetadataService) AddDeviceService(ctx context.Context, req Devic
         }
 
         query := `
-                INSERT INTO device_services (id, name, description, base_address, admin_state, labels, created, modified)
-                VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+                INSERT INTO device_services (id, name, description, base_address, admin_state, labels, created, modified, tenant_id)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
         
         _, err := s.db.ExecContext(ctx, query, id, req.Name, req.Description, req.BaseAddress, 
-                req.AdminState, labelsJSON, now, now)
+                req.AdminState, labelsJSON, now, now, tenant.FromContext(ctx).Tenant)
         if err != nil {
                 if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
                         return "", EdgeXError{Code: http.StatusConflict, Message: fmt.Sprintf("device service %s already exists", req.Name)}
@@ -58,6 +69,8 @@ func (s *WorkingMetadataService) AddDeviceService(ctx context.Context, req Devic
                 return "", EdgeXError{Code: http.StatusInternalServerError, Message: "failed to add device service"}
         }
         
+        req.Id, req.Created, req.Modified = id, now, now
+        audit.Change(ctx, "deviceservice", req.Name, nil, req)
         return id, EdgeXError{}
 }
 
@@ -69,12 +82,13 @@ func (s *WorkingMetadataService) GetDeviceServiceByName(ctx context.Context, nam
         var ds DeviceService
         var labelsJSON []byte
         
+        args := []interface{}{name}
         query := `
                 SELECT id, name, description, base_address, admin_state, labels, created, modified
                 FROM device_services 
-                WHERE name = $1`
+                WHERE name = $1 AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args)
         
-        err := s.db.QueryRowContext(ctx, query, name).Scan(
+        err := s.db.QueryRowContext(ctx, query, args...).Scan(
                 &ds.Id, &ds.Name, &ds.Description, &ds.BaseAddress, &ds.AdminState, 
                 &labelsJSON, &ds.Created, &ds.Modified)
         if err != nil {
@@ -105,20 +119,25 @@ func (s *WorkingMetadataService) GetAllDeviceServices(ctx context.Context, offse
         var services []DeviceService
         var totalCount uint32
         
+        scope := tenant.FromContext(ctx)
+        
         // Get total count
-        countQuery := `SELECT COUNT(*) FROM device_services`
-        err := s.db.QueryRowContext(ctx, countQuery).Scan(&totalCount)
+        var countArgs []interface{}
+        countQuery := `SELECT COUNT(*) FROM device_services WHERE ` + scope.Condition("tenant_id", &countArgs)
+        err := s.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&totalCount)
         if err != nil {
                 return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get total count"}
         }
         
         // Get paginated results
+        args := []interface{}{limit, offset}
         query := `
                 SELECT id, name, description, base_address, admin_state, labels, created, modified
                 FROM device_services
+                WHERE ` + scope.Condition("tenant_id", &args) + `
                 ORDER BY name LIMIT $1 OFFSET $2`
         
-        rows, err := s.db.QueryContext(ctx, query, limit, offset)
+        rows, err := s.db.QueryContext(ctx, query, args...)
         if err != nil {
                 return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to query device services"}
         }
@@ -148,9 +167,11 @@ func (s *WorkingMetadataService) DeleteDeviceServiceByName(ctx context.Context,
         if name == "" {
                 return EdgeXError{Code: http.StatusBadRequest, Message: "device service name is required"}
         }
+        before, _ := s.GetDeviceServiceByName(ctx, name)
         
-        query := `DELETE FROM device_services WHERE name = $1`
-        result, err := s.db.ExecContext(ctx, query, name)
+        args := []interface{}{name}
+        query := `DELETE FROM device_services WHERE name = $1 AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args)
+        result, err := s.db.ExecContext(ctx, query, args...)
         if err != nil {
                 return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to delete device service"}
         }
@@ -164,6 +185,7 @@ func (s *WorkingMetadataService) DeleteDeviceServiceByName(ctx context.Context,
                 return EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("device service %s not found", name)}
         }
         
+        audit.Change(ctx, "deviceservice", name, before, nil)
         return EdgeXError{}
 }
 
@@ -191,21 +213,38 @@ func (s *WorkingMetadataService) AddDevice(ctx context.Context, req Device) (str
         if err.Code != 0 {
                 return "", EdgeXError{Code: http.StatusBadRequest, Message: fmt.Sprintf("device service %s not found", req.ServiceName)}
         }
+
+        // Verify profile exists, lookups are tenant scoped so a device cannot use another tenant's profile
+        if _, err := s.GetDeviceProfileByName(ctx, req.ProfileName); err.Code != 0 {
+                return "", EdgeXError{Code: http.StatusBadRequest, Message: fmt.Sprintf("device profile %s not found", req.ProfileName)}
+        }
+
+        // Verify asset node exists
+        if req.AssetNode != "" {
+                if _, err := s.GetAssetNodeByName(ctx, req.AssetNode); err.Code != 0 {
+                        return "", EdgeXError{Code: http.StatusBadRequest, Message: fmt.Sprintf("asset node %s not found", req.AssetNode)}
+                }
+        }
+
+        // Verify referenced device secrets exist
+        if edgeErr := s.checkSecretReferences(ctx, req.Protocols); edgeErr.Code != 0 {
+                return "", edgeErr
+        }
         
         id := uuid.New().String()
         now := time.Now().Unix()
         
+        protocolsJSON, edgeErr := s.encryptProtocols(id, req.Protocols)
+        if edgeErr.Code != 0 {
+                return "", edgeErr
+        }
         labelsJSON, _ := json.Marshal(req.Labels)
-        protocolsJSON, _ := json.Marshal(req.Protocols)
         autoEventsJSON, _ := json.Marshal(req.AutoEvents)
         locationJSON, _ := json.Marshal(req.Location)
         
         if labelsJSON == nil {
                 labelsJSON = []byte("[]")
         }
-        if protocolsJSON == nil {
-                protocolsJSON = []byte("{}")
-        }
         if autoEventsJSON == nil {
                 autoEventsJSON = []byte("[]")
         }
@@ -215,13 +254,13 @@ func (s *WorkingMetadataService) AddDevice(ctx context.Context, req Device) (str
 
         query := `
                 INSERT INTO devices (id, name, description, admin_state, operating_state, protocols, 
-                        labels, location, service_name, profile_name, auto_events, tags, properties, created, modified)
-                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
+                        labels, location, service_name, profile_name, auto_events, tags, properties, created, modified, asset_node, tenant_id)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NULLIF($16, ''), $17)`
         
         _, err2 := s.db.ExecContext(ctx, query, id, req.Name, req.Description, 
                 req.AdminState, req.OperatingState, protocolsJSON, labelsJSON, 
                 locationJSON, req.ServiceName, req.ProfileName, autoEventsJSON, 
-                []byte("{}"), []byte("{}"), now, now)
+                []byte("{}"), []byte("{}"), now, now, req.AssetNode, tenant.FromContext(ctx).Tenant)
         if err2 != nil {
                 if pqErr, ok := err2.(*pq.Error); ok && pqErr.Code == "23505" {
                         return "", EdgeXError{Code: http.StatusConflict, Message: fmt.Sprintf("device %s already exists", req.Name)}
@@ -229,6 +268,8 @@ func (s *WorkingMetadataService) AddDevice(ctx context.Context, req Device) (str
                 return "", EdgeXError{Code: http.StatusInternalServerError, Message: "failed to add device"}
         }
         
+        req.Id, req.Created, req.Modified = id, now, now
+        audit.Change(ctx, "device", req.Name, nil, maskedDevice(req))
         return id, EdgeXError{}
 }
 
@@ -240,17 +281,18 @@ func (s *WorkingMetadataService) GetDeviceByName(ctx context.Context, name strin
         var device Device
         var labelsJSON, protocolsJSON, autoEventsJSON, locationJSON []byte
         
+        args := []interface{}{name}
         query := `
                 SELECT id, name, description, admin_state, operating_state, protocols, labels, 
-                        location, service_name, profile_name, auto_events, created, modified
+                        location, service_name, profile_name, auto_events, COALESCE(asset_node, ''), COALESCE(profile_version, 0), created, modified
                 FROM devices 
-                WHERE name = $1`
+                WHERE name = $1 AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args)
         
-        err := s.db.QueryRowContext(ctx, query, name).Scan(
+        err := s.db.QueryRowContext(ctx, query, args...).Scan(
                 &device.Id, &device.Name, &device.Description, &device.AdminState, 
                 &device.OperatingState, &protocolsJSON, &labelsJSON, &locationJSON,
                 &device.ServiceName, &device.ProfileName, &autoEventsJSON, 
-                &device.Created, &device.Modified)
+                &device.AssetNode, &device.ProfileVersion, &device.Created, &device.Modified)
         if err != nil {
                 if err == sql.ErrNoRows {
                         return Device{}, EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("device %s not found", name)}
@@ -260,9 +302,13 @@ func (s *WorkingMetadataService) GetDeviceByName(ctx context.Context, name strin
         
         // Unmarshal JSON fields
         json.Unmarshal(labelsJSON, &device.Labels)
-        json.Unmarshal(protocolsJSON, &device.Protocols)
         json.Unmarshal(autoEventsJSON, &device.AutoEvents)
         json.Unmarshal(locationJSON, &device.Location)
+        protocols, edgeErr := s.decryptProtocols(ctx, device.Id, protocolsJSON)
+        if edgeErr.Code != 0 {
+                return Device{}, edgeErr
+        }
+        device.Protocols = protocols
         
         return device, EdgeXError{}
 }
@@ -281,21 +327,26 @@ func (s *WorkingMetadataService) GetAllDevices(ctx context.Context, offset, limi
         var devices []Device
         var totalCount uint32
         
+        scope := tenant.FromContext(ctx)
+        
         // Get total count
-        countQuery := `SELECT COUNT(*) FROM devices`
-        err := s.db.QueryRowContext(ctx, countQuery).Scan(&totalCount)
+        var countArgs []interface{}
+        countQuery := `SELECT COUNT(*) FROM devices WHERE ` + scope.Condition("tenant_id", &countArgs)
+        err := s.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&totalCount)
         if err != nil {
                 return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to get total count"}
         }
         
         // Get paginated results
+        args := []interface{}{limit, offset}
         query := `
                 SELECT id, name, description, admin_state, operating_state, protocols, labels, 
-                        location, service_name, profile_name, auto_events, created, modified
+                        location, service_name, profile_name, auto_events, COALESCE(asset_node, ''), COALESCE(profile_version, 0), created, modified
                 FROM devices
+                WHERE ` + scope.Condition("tenant_id", &args) + `
                 ORDER BY name LIMIT $1 OFFSET $2`
         
-        rows, err := s.db.QueryContext(ctx, query, limit, offset)
+        rows, err := s.db.QueryContext(ctx, query, args...)
         if err != nil {
                 return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to query devices"}
         }
@@ -308,16 +359,20 @@ func (s *WorkingMetadataService) GetAllDevices(ctx context.Context, offset, limi
                 err := rows.Scan(&device.Id, &device.Name, &device.Description, &device.AdminState,
                         &device.OperatingState, &protocolsJSON, &labelsJSON, &locationJSON,
                         &device.ServiceName, &device.ProfileName, &autoEventsJSON,
-                        &device.Created, &device.Modified)
+                        &device.AssetNode, &device.ProfileVersion, &device.Created, &device.Modified)
                 if err != nil {
                         return nil, 0, EdgeXError{Code: http.StatusInternalServerError, Message: "failed to scan device"}
                 }
                 
                 // Unmarshal JSON fields
                 json.Unmarshal(labelsJSON, &device.Labels)
-                json.Unmarshal(protocolsJSON, &device.Protocols)
                 json.Unmarshal(autoEventsJSON, &device.AutoEvents)
                 json.Unmarshal(locationJSON, &device.Location)
+                protocols, edgeErr := s.decryptProtocols(ctx, device.Id, protocolsJSON)
+                if edgeErr.Code != 0 {
+                        return nil, 0, edgeErr
+                }
+                device.Protocols = protocols
                 
                 devices = append(devices, device)
         }
@@ -329,9 +384,11 @@ func (s *WorkingMetadataService) DeleteDeviceByName(ctx context.Context, name st
         if name == "" {
                 return EdgeXError{Code: http.StatusBadRequest, Message: "device name is required"}
         }
+        before, _ := s.GetDeviceByName(ctx, name)
         
-        query := `DELETE FROM devices WHERE name = $1`
-        result, err := s.db.ExecContext(ctx, query, name)
+        args := []interface{}{name}
+        query := `DELETE FROM devices WHERE name = $1 AND ` + tenant.FromContext(ctx).Condition("tenant_id", &args)
+        result, err := s.db.ExecContext(ctx, query, args...)
         if err != nil {
                 return EdgeXError{Code: http.StatusInternalServerError, Message: "failed to delete device"}
         }
@@ -345,6 +402,7 @@ func (s *WorkingMetadataService) DeleteDeviceByName(ctx context.Context, name st
                 return EdgeXError{Code: http.StatusNotFound, Message: fmt.Sprintf("device %s not found", name)}
         }
         
+        audit.Change(ctx, "device", name, maskedDevice(before), nil)
         return EdgeXError{}
 }
 
@@ -517,23 +575,158 @@ func (h *WorkingHandler) DeleteDeviceByName(c echo.Context) error {
         return c.JSON(http.StatusOK, response)
 }
 
-// Route registration
-func RegisterWorkingEdgeXRoutes(g *echo.Group, service *WorkingMetadataService) {
+// Bulk import/export endpoints
+func (h *WorkingHandler) ImportDevices(c echo.Context) error {
+        format := c.QueryParam("format")
+        if format == "" {
+                format = bulkFormatFromContentType(c.Request().Header.Get(echo.HeaderContentType))
+        }
+        dryRun, _ := strconv.ParseBool(c.QueryParam("dryRun"))
+
+        req, err := ParseBulkImport(format, c.Request().Body)
+        if err != nil {
+                return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+        }
+
+        result, edgeErr := h.service.ImportBulk(c.Request().Context(), req, dryRun)
+        if edgeErr.Code != 0 && len(result.Errors) == 0 {
+                return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+        }
+
+        statusCode := http.StatusCreated
+        switch {
+        case edgeErr.Code != 0:
+                statusCode = edgeErr.Code
+        case len(result.Errors) > 0:
+                statusCode = http.StatusBadRequest
+        case dryRun:
+                statusCode = http.StatusOK
+        }
+
+        response := map[string]interface{}{
+                "apiVersion": "v3",
+                "statusCode": statusCode,
+                "result":     result,
+        }
+        return c.JSON(statusCode, response)
+}
+
+func (h *WorkingHandler) ExportDevices(c echo.Context) error {
+        filter := DeviceExportFilter{
+                ServiceName: c.QueryParam("service"),
+                ProfileName: c.QueryParam("profile"),
+                Label:       c.QueryParam("label"),
+        }
+
+        devices, edgeErr := h.service.ExportDevices(c.Request().Context(), filter)
+        if edgeErr.Code != 0 {
+                return c.JSON(edgeErr.Code, map[string]string{"error": edgeErr.Message})
+        }
+
+        data, contentType, err := EncodeDeviceExport(c.QueryParam("format"), devices)
+        if err != nil {
+                return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+        }
+        return c.Blob(http.StatusOK, contentType, data)
+}
+
+func bulkFormatFromContentType(contentType string) string {
+        switch {
+        case strings.Contains(contentType, "yaml"):
+                return BulkFormatYAML
+        case strings.Contains(contentType, "csv"):
+                return BulkFormatCSV
+        default:
+                return BulkFormatJSON
+        }
+}
+
+const (
+        permissionDeviceRead  = "device:read"
+        permissionDeviceWrite = "device:write"
+        // The unit catalogue is shared by every tenant, changing it additionally requires tenant:all
+        permissionUnitManage  = "unit:manage"
+        permissionSecretRead  = "secret:read"
+        permissionSecretWrite = "secret:write"
+)
+
+// PublicRoutes lists the metadata routes, relative to the group they are registered on, that need no
+// user credentials. Device services authenticate to the resolved device route with a service token
+// and a client certificate.
+var PublicRoutes = []string{"/ping", "/version", "/device/name/:name/resolved"}
+
+// ServicePermissions are held by the services authenticating with a secret store token, core-command
+// looking devices up and device services registering themselves and the devices they discover, for
+// every tenant
+var ServicePermissions = []string{permissionDeviceRead, permissionDeviceWrite, tenant.PermissionAllTenants}
+
+// RegisterWorkingEdgeXRoutes registers the metadata routes and returns the permission each of them
+// requires, for use with auth.RequirePermissions. The routes listed in PublicRoutes need none.
+func RegisterWorkingEdgeXRoutes(g *echo.Group, service *WorkingMetadataService) auth.RoutePermissions {
         handler := NewWorkingHandler(service)
+        permissions := auth.RoutePermissions{}
+        permit := func(route *echo.Route, required ...string) {
+                permissions[auth.Route(route.Method, route.Path)] = required
+        }
 
         // Common endpoints
         g.GET("/ping", handler.Ping)
         g.GET("/version", handler.Version)
 
         // Device Service endpoints
-        g.POST("/deviceservice", handler.AddDeviceService)
-        g.GET("/deviceservice/all", handler.GetAllDeviceServices)
-        g.GET("/deviceservice/name/:name", handler.GetDeviceServiceByName)
-        g.DELETE("/deviceservice/name/:name", handler.DeleteDeviceServiceByName)
+        permit(g.POST("/deviceservice", handler.AddDeviceService), permissionDeviceWrite)
+        permit(g.GET("/deviceservice/all", handler.GetAllDeviceServices), permissionDeviceRead)
+        permit(g.GET("/deviceservice/name/:name", handler.GetDeviceServiceByName), permissionDeviceRead)
+        permit(g.DELETE("/deviceservice/name/:name", handler.DeleteDeviceServiceByName), permissionDeviceWrite)
 
         // Device endpoints
-        g.POST("/device", handler.AddDevice)
-        g.GET("/device/all", handler.GetAllDevices)
-        g.GET("/device/name/:name", handler.GetDeviceByName)
-        g.DELETE("/device/name/:name", handler.DeleteDeviceByName)
+        permit(g.POST("/device", handler.AddDevice), permissionDeviceWrite)
+        permit(g.GET("/device/all", handler.GetAllDevices), permissionDeviceRead)
+        permit(g.GET("/device/name/:name", handler.GetDeviceByName), permissionDeviceRead)
+        permit(g.DELETE("/device/name/:name", handler.DeleteDeviceByName), permissionDeviceWrite)
+        permit(g.PUT("/device/name/:name/profileversion/:version", handler.PinDeviceProfileVersion), permissionDeviceWrite)
+        permit(g.DELETE("/device/name/:name/profileversion", handler.UnpinDeviceProfileVersion), permissionDeviceWrite)
+        // Credentials are resolved for device services only, authenticated with a service token and a
+        // client certificate
+        g.GET("/device/name/:name/resolved", handler.GetResolvedDeviceByName, handler.requireServiceToken)
+
+        // Device secret endpoints, the secret values are never returned
+        permit(g.GET("/devicesecret/all", handler.GetAllDeviceSecrets), permissionSecretRead)
+        permit(g.GET("/devicesecret/name/:name", handler.GetDeviceSecretByName), permissionSecretRead)
+        permit(g.PUT("/devicesecret/name/:name", handler.UpdateDeviceSecret), permissionSecretWrite)
+
+        // Device profile endpoints
+        permit(g.POST("/deviceprofile", handler.AddDeviceProfile), permissionDeviceWrite)
+        permit(g.PUT("/deviceprofile", handler.UpdateDeviceProfile), permissionDeviceWrite)
+        permit(g.GET("/deviceprofile/all", handler.GetAllDeviceProfiles), permissionDeviceRead)
+        permit(g.GET("/deviceprofile/name/:name", handler.GetDeviceProfileByName), permissionDeviceRead)
+        permit(g.DELETE("/deviceprofile/name/:name", handler.DeleteDeviceProfileByName), permissionDeviceWrite)
+        permit(g.GET("/deviceprofile/name/:name/history", handler.GetDeviceProfileHistory), permissionDeviceRead)
+        permit(g.GET("/deviceprofile/name/:name/version/:version", handler.GetDeviceProfileVersion), permissionDeviceRead)
+        permit(g.GET("/deviceprofile/name/:name/diff", handler.DiffDeviceProfileVersions), permissionDeviceRead)
+        permit(g.POST("/deviceprofile/name/:name/rollback", handler.RollbackDeviceProfile), permissionDeviceWrite)
+
+        // Unit-of-measure catalogue endpoints
+        permit(g.POST("/unit", handler.AddUnit), permissionUnitManage, tenant.PermissionAllTenants)
+        permit(g.GET("/unit/all", handler.GetAllUnits), permissionDeviceRead)
+        permit(g.GET("/unit/convert", handler.ConvertUnit), permissionDeviceRead)
+        permit(g.GET("/unit/name/:name", handler.GetUnitByName), permissionDeviceRead)
+        permit(g.DELETE("/unit/name/:name", handler.DeleteUnitByName), permissionUnitManage, tenant.PermissionAllTenants)
+
+        // Asset hierarchy endpoints
+        permit(g.POST("/asset", handler.AddAssetNode), permissionDeviceWrite)
+        permit(g.GET("/asset/all", handler.GetAllAssetNodes), permissionDeviceRead)
+        permit(g.GET("/asset/name/:name", handler.GetAssetNodeByName), permissionDeviceRead)
+        permit(g.PATCH("/asset/name/:name", handler.UpdateAssetNode), permissionDeviceWrite)
+        permit(g.DELETE("/asset/name/:name", handler.DeleteAssetNodeByName), permissionDeviceWrite)
+        permit(g.GET("/asset/name/:name/tree", handler.GetAssetTree), permissionDeviceRead)
+        permit(g.GET("/asset/name/:name/devices", handler.GetDevicesUnderAssetNode), permissionDeviceRead)
+        permit(g.PUT("/asset/name/:name/device/:device", handler.AttachDeviceToAssetNode), permissionDeviceWrite)
+        permit(g.DELETE("/asset/name/:name/device/:device", handler.DetachDeviceFromAssetNode), permissionDeviceWrite)
+
+        // Bulk import/export endpoints
+        permit(g.POST("/device/import", handler.ImportDevices), permissionDeviceWrite)
+        permit(g.GET("/device/export", handler.ExportDevices), permissionDeviceRead)
+
+        return permissions
 }
\ No newline at end of file
@@ -0,0 +1,21 @@
+package metadata
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"iiot-backend/middleware/auth"
+	"iiot-backend/middleware/tenant"
+)
+
+func TestRegisterWorkingEdgeXRoutesPermissions(t *testing.T) {
+	permissions := RegisterWorkingEdgeXRoutes(echo.New().Group("/api/v3"), NewWorkingMetadataService(nil, nil, nil))
+
+	// the unit catalogue is shared by every tenant, tenant administrators cannot change it
+	assert.Equal(t, []string{permissionUnitManage, tenant.PermissionAllTenants}, permissions[auth.Route(http.MethodPost, "/api/v3/unit")])
+	assert.Equal(t, []string{permissionUnitManage, tenant.PermissionAllTenants}, permissions[auth.Route(http.MethodDelete, "/api/v3/unit/name/:name")])
+	assert.Equal(t, []string{permissionDeviceRead}, permissions[auth.Route(http.MethodGet, "/api/v3/unit/all")])
+}
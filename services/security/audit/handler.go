@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/utils"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// scoped returns the service restricted to the tenants the request may access
+func (h *Handler) scoped(c echo.Context) *Service {
+	return h.service.WithTenant(tenant.FromContext(c.Request().Context()))
+}
+
+func filterFrom(c echo.Context) Filter {
+	start, _ := strconv.ParseInt(c.QueryParam("start"), 10, 64)
+	end, _ := strconv.ParseInt(c.QueryParam("end"), 10, 64)
+	return Filter{
+		Username:   c.QueryParam("username"),
+		Method:     c.QueryParam("method"),
+		Route:      c.QueryParam("route"),
+		EntityType: c.QueryParam("entityType"),
+		EntityID:   c.QueryParam("entityId"),
+		Start:      start,
+		End:        end,
+	}
+}
+
+func (h *Handler) GetEntries(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+
+	if limit <= 0 {
+		limit = 100
+	}
+
+	entries, err := h.scoped(c).GetEntries(c.Request().Context(), filterFrom(c), limit, offset)
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve audit entries", err)
+	}
+	return utils.SuccessResponse(c, entries)
+}
+
+func (h *Handler) ExportEntries(c echo.Context) error {
+	entries, err := h.scoped(c).GetEntries(c.Request().Context(), filterFrom(c), 0, 0)
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to export audit entries", err)
+	}
+
+	data, contentType, err := EncodeExport(c.QueryParam("format"), entries)
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Failed to export audit entries", err)
+	}
+	return c.Blob(http.StatusOK, contentType, data)
+}
+
+func (h *Handler) Verify(c echo.Context) error {
+	result, err := h.scoped(c).Verify(c.Request().Context())
+	if errors.Is(err, ErrForbidden) {
+		return utils.ErrorResponse(c, http.StatusForbidden, "Failed to verify audit log", err)
+	} else if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify audit log", err)
+	}
+	return utils.SuccessResponse(c, result)
+}
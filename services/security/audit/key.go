@@ -0,0 +1,28 @@
+package audit
+
+import (
+	"context"
+
+	"iiot-backend/middleware/audit"
+	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
+	"iiot-backend/services/security/secretstore"
+)
+
+// LogFromEnvironment returns the audit log stored in db, chained with the key of the audit-log
+// secret of the encrypted file secret store when SECRETSTORE_FILESTORE_PATH is set, or else with
+// AUDIT_LOG_KEY. Services writing to the same audit_log table must use the same key.
+func LogFromEnvironment(ctx context.Context, db audit.Conn, serviceKey string) (*audit.Log, error) {
+	client, err := secretstore.FromEnvironment(ctx, serviceKey, logger.NewClient(serviceKey, "INFO"))
+	if err != nil {
+		return nil, err
+	}
+	var retrieve func(secretName string, keys ...string) (map[string]string, error)
+	if client != nil {
+		retrieve = client.RetrieveSecret
+	}
+	key, err := audit.LogKey(retrieve)
+	if err != nil {
+		return nil, err
+	}
+	return audit.NewLog(db, key), nil
+}
@@ -0,0 +1,26 @@
+package audit
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"iiot-backend/middleware/auth"
+)
+
+const permissionAuditRead = "audit:read"
+
+// RegisterRoutes registers the audit log query, export and verification routes and returns the
+// permission each of them requires, for use with auth.RequirePermissions
+func RegisterRoutes(g *echo.Group, service *Service) auth.RoutePermissions {
+	handler := NewHandler(service)
+	permissions := auth.RoutePermissions{}
+	permit := func(route *echo.Route, required ...string) {
+		permissions[auth.Route(route.Method, route.Path)] = required
+	}
+
+	entries := g.Group("/audit")
+	permit(entries.GET("", handler.GetEntries), permissionAuditRead)
+	permit(entries.GET("/export", handler.ExportEntries), permissionAuditRead)
+	permit(entries.GET("/verify", handler.Verify), permissionAuditRead)
+
+	return permissions
+}
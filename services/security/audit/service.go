@@ -0,0 +1,155 @@
+// Package audit serves queries and exports of the audit log of mutating API calls and checks its
+// hash chain
+package audit
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/models"
+)
+
+// ErrForbidden is returned when a tenant scoped caller asks to verify the log
+var ErrForbidden = errors.New("audit log verification requires access to every tenant")
+
+// Filter selects audit entries, zero fields match every entry. Start and End are in milliseconds.
+type Filter struct {
+	Username   string
+	Method     string
+	Route      string
+	EntityType string
+	EntityID   string
+	Start      int64
+	End        int64
+}
+
+type Service struct {
+	db    *sql.DB
+	log   *audit.Log
+	scope tenant.Scope
+}
+
+func NewService(db *sql.DB, log *audit.Log) *Service {
+	return &Service{db: db, log: log, scope: tenant.Unrestricted()}
+}
+
+// WithTenant returns a copy of the service restricted to the entries of the given scope
+func (s *Service) WithTenant(scope tenant.Scope) *Service {
+	scoped := *s
+	scoped.scope = scope
+	return &scoped
+}
+
+// GetEntries returns the entries matching the filter, newest first. A limit of 0 returns every
+// matching entry.
+func (s *Service) GetEntries(ctx context.Context, filter Filter, limit, offset int) ([]models.AuditEntry, error) {
+	args := []interface{}{}
+	conditions := []string{s.scope.Condition("tenant_id", &args)}
+	where := func(column string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s $%d", column, len(args)))
+	}
+	if filter.Username != "" {
+		where("username =", filter.Username)
+	}
+	if filter.Method != "" {
+		where("method =", strings.ToUpper(filter.Method))
+	}
+	if filter.Route != "" {
+		where("route =", filter.Route)
+	}
+	if filter.EntityType != "" {
+		where("entity_type =", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		where("entity_id =", filter.EntityID)
+	}
+	if filter.Start > 0 {
+		where("created >=", filter.Start)
+	}
+	if filter.End > 0 {
+		where("created <=", filter.End)
+	}
+
+	query := `SELECT ` + audit.Columns + ` FROM audit_log WHERE ` + strings.Join(conditions, " AND ") +
+		` ORDER BY seq DESC`
+	if limit > 0 {
+		args = append(args, limit, offset)
+		query += fmt.Sprintf(` LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		entry, err := audit.ScanEntry(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entry.Diff = audit.Diff(entry.Before, entry.After)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// Verify checks the hash chain of the whole log. Tenant scoped callers could only check part of
+// the chain, so verification requires an unrestricted scope.
+func (s *Service) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	if !s.scope.All {
+		return nil, ErrForbidden
+	}
+	return s.log.Verify(ctx)
+}
+
+var entryCSVHeader = []string{
+	"seq", "id", "created", "userId", "username", "tenant", "method", "route", "path", "entityType",
+	"entityId", "statusCode", "remoteAddr", "requestId", "before", "after", "prevHash", "hash",
+	"keyed",
+}
+
+// EncodeExport renders entries in the requested format, json or csv, and returns the encoded
+// payload with its content type. Exports keep the hashes so they can be checked independently.
+func EncodeExport(format string, entries []models.AuditEntry) ([]byte, string, error) {
+	switch strings.ToLower(format) {
+	case "json", "":
+		data, err := json.Marshal(entries)
+		return data, "application/json", err
+	case "csv":
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		if err := writer.Write(entryCSVHeader); err != nil {
+			return nil, "", err
+		}
+		for _, e := range entries {
+			record := []string{
+				strconv.FormatInt(e.Seq, 10), e.ID, strconv.FormatInt(e.Created, 10), e.UserID, e.Username,
+				e.Tenant, e.Method, e.Route, e.Path, e.EntityType, e.EntityID, strconv.Itoa(e.StatusCode),
+				e.RemoteAddr, e.RequestID, string(e.Before), string(e.After), e.PrevHash, e.Hash,
+				strconv.FormatBool(e.Keyed),
+			}
+			if err := writer.Write(record); err != nil {
+				return nil, "", err
+			}
+		}
+		writer.Flush()
+		return buf.Bytes(), "text/csv", writer.Error()
+	default:
+		return nil, "", fmt.Errorf("unsupported export format %s", format)
+	}
+}
@@ -0,0 +1,78 @@
+// Package encryption exposes the state of the field encryption keys and re-encrypts the sensitive
+// fields stored by the services once a new primary key was added to the keyring
+package encryption
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/sensitive"
+	"iiot-backend/utils"
+)
+
+// Reencrypter rewrites the sensitive fields a service stores under the primary key, returning the
+// number of records updated
+type Reencrypter interface {
+	ReencryptSensitiveFields(ctx context.Context) (int, error)
+}
+
+// Status describes the field encryption keys in use
+type Status struct {
+	Enabled      bool   `json:"enabled"`
+	PrimaryKeyID string `json:"primaryKeyId,omitempty"`
+}
+
+// RotationResult reports the records re-encrypted by each service
+type RotationResult struct {
+	PrimaryKeyID string         `json:"primaryKeyId"`
+	Reencrypted  map[string]int `json:"reencrypted"`
+}
+
+type Handler struct {
+	keys   *sensitive.Keyring
+	stores map[string]Reencrypter
+}
+
+func NewHandler(keys *sensitive.Keyring, stores map[string]Reencrypter) *Handler {
+	return &Handler{keys: keys, stores: stores}
+}
+
+func (h *Handler) GetStatus(c echo.Context) error {
+	return utils.SuccessResponse(c, Status{Enabled: h.keys.Enabled(), PrimaryKeyID: h.keys.PrimaryKeyID()})
+}
+
+// Rotate reloads the keys, so that a primary key added to the secret takes effect, and rewrites
+// every stored value in plaintext or encrypted with another key under the primary key. Retired keys
+// can be removed from the secret once it succeeded.
+func (h *Handler) Rotate(c echo.Context) error {
+	if err := h.keys.Reload(); err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reload field encryption keys", err)
+	}
+	if !h.keys.Enabled() {
+		return utils.ErrorResponse(c, http.StatusConflict, "Field encryption is not configured", nil)
+	}
+
+	names := make([]string, 0, len(h.stores))
+	for name := range h.stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ctx := c.Request().Context()
+	result := RotationResult{PrimaryKeyID: h.keys.PrimaryKeyID(), Reencrypted: map[string]int{}}
+	// Record the records rewritten so far even when a store fails
+	defer audit.Change(ctx, "fieldencryption", result.PrimaryKeyID, nil, &result)
+	for _, name := range names {
+		updated, err := h.stores[name].ReencryptSensitiveFields(ctx)
+		result.Reencrypted[name] = updated
+		if err != nil {
+			return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to re-encrypt "+name, err)
+		}
+	}
+
+	return utils.SuccessResponse(c, result)
+}
@@ -0,0 +1,81 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"iiot-backend/middleware/sensitive"
+	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
+	secretsPkg "iiot-backend/pkg/go-mod-secrets/pkg"
+	"iiot-backend/pkg/go-mod-secrets/secrets"
+	"iiot-backend/services/security/secretstore"
+)
+
+// secretCheckInterval bounds how often the secrets file is checked for rotated field encryption keys
+const secretCheckInterval = 5 * time.Second
+
+// KeysFromEnvironment returns the keyring encrypting sensitive fields at rest. With
+// SECRETSTORE_FILESTORE_PATH the keys are the field-encryption secret of the encrypted file secret
+// store, opened with SECRETSTORE_FILESTORE_MASTERKEYFILE or SECRETSTORE_FILESTORE_PASSPHRASE and
+// reloaded whenever the secret changes. Otherwise FIELD_ENCRYPTION_KEYS lists id:base64-key pairs,
+// the first one being the primary key. Without either, sensitive fields are stored in plaintext and
+// only masked in responses.
+//
+// The secret is read from the store of serviceKey unless SECRETSTORE_STORENAME names another one,
+// services sharing the devices or subscriptions tables must use the same keys.
+func KeysFromEnvironment(ctx context.Context, serviceKey string) (*sensitive.Keyring, error) {
+	lc := logger.NewClient(serviceKey, "INFO")
+	client, err := secretstore.FromEnvironment(ctx, serviceKey, lc)
+	if err != nil {
+		return nil, err
+	}
+	if client != nil {
+		return fileStoreKeys(ctx, client, lc)
+	}
+
+	source, err := sensitive.ParseKeys(os.Getenv("FIELD_ENCRYPTION_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+	return sensitive.NewKeyring(source, sensitive.SecretName)
+}
+
+func fileStoreKeys(ctx context.Context, client secrets.SecretClient, lc logger.LoggerClient) (*sensitive.Keyring, error) {
+	keys, err := sensitive.NewKeyring(optionalSecret{client}, sensitive.SecretName)
+	if err != nil {
+		return nil, err
+	}
+
+	if versioned, ok := client.(secrets.VersionedSecretClient); ok {
+		go versioned.WatchSecrets(ctx, secretCheckInterval, func(secretNames []string) {
+			for _, name := range secretNames {
+				if name != sensitive.SecretName {
+					continue
+				}
+				if err := keys.Reload(); err != nil {
+					lc.Errorf("unable to reload field encryption keys: %v", err)
+				} else {
+					lc.Infof("field encryption keys reloaded, primary key is '%s'", keys.PrimaryKeyID())
+				}
+			}
+		})
+	}
+	return keys, nil
+}
+
+// optionalSecret treats a missing secret as holding no keys, so that encryption is turned on by
+// saving the secret later on
+type optionalSecret struct {
+	secrets.SecretClient
+}
+
+func (o optionalSecret) RetrieveSecret(secretName string, keys ...string) (map[string]string, error) {
+	data, err := o.SecretClient.RetrieveSecret(secretName, keys...)
+	var notFound secretsPkg.ErrSecretNameNotFound
+	if errors.As(err, &notFound) {
+		return map[string]string{}, nil
+	}
+	return data, err
+}
@@ -0,0 +1,25 @@
+package encryption
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"iiot-backend/middleware/auth"
+	"iiot-backend/middleware/sensitive"
+)
+
+// RegisterRoutes registers the field encryption status and rotation routes for the stores whose
+// sensitive fields are encrypted with keys, and returns the permission each route requires, for use
+// with auth.RequirePermissions
+func RegisterRoutes(g *echo.Group, keys *sensitive.Keyring, stores map[string]Reencrypter) auth.RoutePermissions {
+	handler := NewHandler(keys, stores)
+	permissions := auth.RoutePermissions{}
+	permit := func(route *echo.Route, required ...string) {
+		permissions[auth.Route(route.Method, route.Path)] = required
+	}
+
+	encryption := g.Group("/security/fieldencryption")
+	permit(encryption.GET("", handler.GetStatus), sensitive.PermissionRotate)
+	permit(encryption.POST("/rotate", handler.Rotate), sensitive.PermissionRotate)
+
+	return permissions
+}
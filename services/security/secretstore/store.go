@@ -0,0 +1,44 @@
+// Package secretstore opens the encrypted file secret store shared by the backend services
+package secretstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"iiot-backend/pkg/go-mod-core-contracts/clients/logger"
+	"iiot-backend/pkg/go-mod-secrets/pkg/types"
+	"iiot-backend/pkg/go-mod-secrets/secrets"
+)
+
+// FromEnvironment opens the encrypted file secret store at SECRETSTORE_FILESTORE_PATH with
+// SECRETSTORE_FILESTORE_MASTERKEYFILE or SECRETSTORE_FILESTORE_PASSPHRASE, and returns nil when no
+// path is set.
+//
+// Secrets are read from the store of serviceKey unless SECRETSTORE_STORENAME names another one.
+func FromEnvironment(ctx context.Context, serviceKey string, lc logger.LoggerClient) (secrets.SecretClient, error) {
+	filePath := os.Getenv("SECRETSTORE_FILESTORE_PATH")
+	if filePath == "" {
+		return nil, nil
+	}
+
+	storeName := os.Getenv("SECRETSTORE_STORENAME")
+	if storeName == "" {
+		storeName = serviceKey
+	}
+
+	client, err := secrets.NewSecretsClient(ctx, types.SecretConfig{
+		Type:     secrets.FileSecretStore,
+		BasePath: "/" + path.Join("v1", "secret", "iiot", storeName),
+		FileStore: types.FileStoreInfo{
+			Path:          filePath,
+			MasterKeyFile: os.Getenv("SECRETSTORE_FILESTORE_MASTERKEYFILE"),
+			Passphrase:    os.Getenv("SECRETSTORE_FILESTORE_PASSPHRASE"),
+		},
+	}, lc, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open secrets file: %w", err)
+	}
+	return client, nil
+}
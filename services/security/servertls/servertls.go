@@ -0,0 +1,133 @@
+// Package servertls configures the TLS listeners of the backend services from certificate files
+package servertls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certificateCheckInterval bounds how often the certificate files are checked for changes
+const certificateCheckInterval = 10 * time.Second
+
+// FromEnvironment returns the TLS configuration of an HTTP server read from TLS_CERT_FILE and
+// TLS_KEY_FILE, nil to serve plain HTTP when they are not set. With TLS_CLIENT_CA_FILE clients may
+// authenticate with a certificate issued by that CA, TLS_CLIENT_AUTH=require makes one mandatory.
+// Renewed certificate files are picked up without a restart.
+func FromEnvironment() (*tls.Config, error) {
+	files := &certificateFiles{
+		certFile:     os.Getenv("TLS_CERT_FILE"),
+		keyFile:      os.Getenv("TLS_KEY_FILE"),
+		clientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		clientAuth:   tls.NoClientCert,
+	}
+	if files.certFile == "" && files.keyFile == "" {
+		return nil, nil
+	}
+	if files.certFile == "" || files.keyFile == "" {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if files.clientCAFile != "" {
+		files.clientAuth = tls.VerifyClientCertIfGiven
+		if os.Getenv("TLS_CLIENT_AUTH") == "require" {
+			files.clientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if os.Getenv("TLS_CLIENT_AUTH") == "require" {
+		return nil, errors.New("TLS_CLIENT_AUTH=require needs TLS_CLIENT_CA_FILE")
+	}
+
+	if err := files.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return files.current(), nil
+		},
+	}, nil
+}
+
+// certificateFiles keeps the server TLS configuration built from the certificate files and rebuilds
+// it when one of them is modified
+type certificateFiles struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	clientAuth   tls.ClientAuthType
+
+	mutex     sync.Mutex
+	config    *tls.Config
+	modified  time.Time
+	lastCheck time.Time
+}
+
+func (f *certificateFiles) current() *tls.Config {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if time.Since(f.lastCheck) >= certificateCheckInterval {
+		f.lastCheck = time.Now()
+		if modified, err := f.lastModified(); err == nil && modified.After(f.modified) {
+			if err := f.reload(modified); err != nil {
+				fmt.Printf("Failed to reload TLS certificates, keeping the previous ones: %v\n", err)
+			} else {
+				fmt.Println("Reloaded TLS certificates")
+			}
+		}
+	}
+	return f.config
+}
+
+func (f *certificateFiles) load() error {
+	modified, err := f.lastModified()
+	if err != nil {
+		return err
+	}
+	return f.reload(modified)
+}
+
+// lastModified returns the most recent modification time of the certificate files
+func (f *certificateFiles) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{f.certFile, f.keyFile, f.clientCAFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (f *certificateFiles) reload(modified time.Time) error {
+	certificate, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificate},
+		ClientAuth:   f.clientAuth,
+	}
+	if f.clientCAFile != "" {
+		pem, err := os.ReadFile(f.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no CA certificate found in %s", f.clientCAFile)
+		}
+	}
+	f.config, f.modified = config, modified
+	return nil
+}
@@ -0,0 +1,310 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/auth"
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/models"
+	"iiot-backend/utils"
+)
+
+type Handler struct {
+	service *Service
+	tokens  *auth.TokenAuthority
+}
+
+func NewHandler(service *Service, tokens *auth.TokenAuthority) *Handler {
+	return &Handler{service: service, tokens: tokens}
+}
+
+// scoped returns the service restricted to the tenants the request may access and acting for its
+// principal
+func (h *Handler) scoped(c echo.Context) *Service {
+	return h.service.WithTenant(tenant.FromContext(c.Request().Context())).WithPrincipal(auth.PrincipalFrom(c))
+}
+
+// errorStatus maps the service errors to the HTTP status reported to the caller
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrRoleNotFound), errors.Is(err, ErrAPIKeyNotFound),
+		errors.Is(err, ErrTenantNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrInvalidCredentials):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// Authentication handlers
+func (h *Handler) Login(c echo.Context) error {
+	var req models.LoginRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+	}
+
+	principal, err := h.service.Login(req.Username, req.Password)
+	if err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Login failed", err)
+	}
+	token, expiresAt, err := h.tokens.Issue(principal)
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to issue token", err)
+	}
+	return utils.SuccessResponse(c, models.LoginResponse{Token: token, TokenType: "Bearer", ExpiresAt: expiresAt})
+}
+
+func (h *Handler) CurrentPrincipal(c echo.Context) error {
+	principal := auth.PrincipalFrom(c)
+	if principal == nil {
+		return utils.ErrorResponse(c, http.StatusUnauthorized, "Not authenticated", nil)
+	}
+	return utils.SuccessResponse(c, map[string]interface{}{
+		"userId":      principal.UserID,
+		"username":    principal.Username,
+		"roles":       principal.Roles,
+		"permissions": principal.Permissions,
+		"apiKeyId":    principal.APIKeyID,
+		"scopes":      principal.Scopes,
+		"tenant":      principal.Tenant,
+	})
+}
+
+// User handlers
+func (h *Handler) GetUsers(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+
+	if limit == 0 {
+		limit = 50
+	}
+
+	users, err := h.scoped(c).GetUsers(limit, offset)
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve users", err)
+	}
+	return utils.SuccessResponse(c, users)
+}
+
+func (h *Handler) GetUser(c echo.Context) error {
+	user, err := h.scoped(c).GetUserByUsername(c.Param("username"))
+	if err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to retrieve user", err)
+	}
+	return utils.SuccessResponse(c, user)
+}
+
+func (h *Handler) CreateUser(c echo.Context) error {
+	var req models.UserRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+	}
+
+	service := h.scoped(c)
+	id, err := service.CreateUser(&req)
+	if err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to create user", err)
+	}
+	after, _ := service.GetUserByUsername(req.Username)
+	audit.Change(c.Request().Context(), "user", req.Username, nil, after)
+	return utils.SuccessResponseWithStatus(c, http.StatusCreated, map[string]string{"id": id})
+}
+
+func (h *Handler) UpdateUser(c echo.Context) error {
+	var req models.UpdateUserRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+
+	service, username := h.scoped(c), c.Param("username")
+	// States that cannot be read are recorded as missing
+	before, _ := service.GetUserByUsername(username)
+	if err := service.UpdateUser(username, &req); err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to update user", err)
+	}
+	after, _ := service.GetUserByUsername(username)
+	audit.Change(c.Request().Context(), "user", username, before, after)
+	return utils.SuccessResponse(c, map[string]string{"message": "User updated successfully"})
+}
+
+func (h *Handler) DeleteUser(c echo.Context) error {
+	service, username := h.scoped(c), c.Param("username")
+	before, _ := service.GetUserByUsername(username)
+	if err := service.DeleteUser(username); err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to delete user", err)
+	}
+	audit.Change(c.Request().Context(), "user", username, before, nil)
+	return utils.SuccessResponse(c, map[string]string{"message": "User deleted successfully"})
+}
+
+// Role handlers
+func (h *Handler) GetRoles(c echo.Context) error {
+	roles, err := h.scoped(c).GetRoles()
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve roles", err)
+	}
+	return utils.SuccessResponse(c, roles)
+}
+
+func (h *Handler) GetRole(c echo.Context) error {
+	role, err := h.scoped(c).GetRoleByName(c.Param("name"))
+	if err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to retrieve role", err)
+	}
+	return utils.SuccessResponse(c, role)
+}
+
+func (h *Handler) CreateRole(c echo.Context) error {
+	var req models.RoleRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+	}
+
+	service := h.scoped(c)
+	id, err := service.CreateRole(&req)
+	if err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to create role", err)
+	}
+	after, _ := service.GetRoleByName(req.Name)
+	audit.Change(c.Request().Context(), "role", req.Name, nil, after)
+	return utils.SuccessResponseWithStatus(c, http.StatusCreated, map[string]string{"id": id})
+}
+
+func (h *Handler) UpdateRole(c echo.Context) error {
+	var req models.RoleRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+	}
+
+	service, name := h.scoped(c), c.Param("name")
+	before, _ := service.GetRoleByName(name)
+	if err := service.UpdateRole(name, &req); err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to update role", err)
+	}
+	after, _ := service.GetRoleByName(req.Name)
+	audit.Change(c.Request().Context(), "role", name, before, after)
+	return utils.SuccessResponse(c, map[string]string{"message": "Role updated successfully"})
+}
+
+func (h *Handler) DeleteRole(c echo.Context) error {
+	service, name := h.scoped(c), c.Param("name")
+	before, _ := service.GetRoleByName(name)
+	if err := service.DeleteRole(name); err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to delete role", err)
+	}
+	audit.Change(c.Request().Context(), "role", name, before, nil)
+	return utils.SuccessResponse(c, map[string]string{"message": "Role deleted successfully"})
+}
+
+// API key handlers
+
+// canManageAPIKeys lets callers manage their own API keys, and anyone's with user:manage
+func canManageAPIKeys(c echo.Context, username string) bool {
+	principal := auth.PrincipalFrom(c)
+	return principal != nil && (principal.Username == username || principal.Can(permissionUserManage))
+}
+
+func (h *Handler) GetAPIKeys(c echo.Context) error {
+	username := c.Param("username")
+	if !canManageAPIKeys(c, username) {
+		return utils.ErrorResponse(c, http.StatusForbidden, "Insufficient permissions", nil)
+	}
+
+	keys, err := h.scoped(c).GetAPIKeys(username)
+	if err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to retrieve API keys", err)
+	}
+	return utils.SuccessResponse(c, keys)
+}
+
+func (h *Handler) CreateAPIKey(c echo.Context) error {
+	username := c.Param("username")
+	if !canManageAPIKeys(c, username) {
+		return utils.ErrorResponse(c, http.StatusForbidden, "Insufficient permissions", nil)
+	}
+
+	var req models.APIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+	}
+
+	key, err := h.scoped(c).CreateAPIKey(username, &req)
+	if err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to create API key", err)
+	}
+	// Only the key's metadata is recorded, never the key itself
+	audit.Change(c.Request().Context(), "apikey", key.ID, nil, key.APIKey)
+	return utils.SuccessResponseWithStatus(c, http.StatusCreated, key)
+}
+
+func (h *Handler) RevokeAPIKey(c echo.Context) error {
+	username := c.Param("username")
+	if !canManageAPIKeys(c, username) {
+		return utils.ErrorResponse(c, http.StatusForbidden, "Insufficient permissions", nil)
+	}
+
+	if err := h.scoped(c).RevokeAPIKey(username, c.Param("id")); err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to revoke API key", err)
+	}
+	audit.Change(c.Request().Context(), "apikey", c.Param("id"), nil, nil)
+	return utils.SuccessResponse(c, map[string]string{"message": "API key revoked successfully"})
+}
+
+// Tenant handlers
+func (h *Handler) GetTenants(c echo.Context) error {
+	tenants, err := h.scoped(c).GetTenants()
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve tenants", err)
+	}
+	return utils.SuccessResponse(c, tenants)
+}
+
+func (h *Handler) CreateTenant(c echo.Context) error {
+	var req models.TenantRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+	}
+
+	if err := h.scoped(c).CreateTenant(&req); err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to create tenant", err)
+	}
+	audit.Change(c.Request().Context(), "tenant", req.Name, nil, req)
+	return utils.SuccessResponseWithStatus(c, http.StatusCreated, map[string]string{"name": req.Name})
+}
+
+func (h *Handler) DeleteTenant(c echo.Context) error {
+	if err := h.scoped(c).DeleteTenant(c.Param("name")); err != nil {
+		return utils.ErrorResponse(c, errorStatus(err), "Failed to delete tenant", err)
+	}
+	audit.Change(c.Request().Context(), "tenant", c.Param("name"), nil, nil)
+	return utils.SuccessResponse(c, map[string]string{"message": "Tenant deleted successfully"})
+}
@@ -0,0 +1,65 @@
+package users
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"iiot-backend/middleware/auth"
+)
+
+// LoginPath is the login route below the group the routes are registered on, it has to be
+// reachable without credentials
+const LoginPath = "/auth/login"
+
+const (
+	permissionUserRead   = "user:read"
+	permissionUserManage = "user:manage"
+	permissionRoleRead   = "role:read"
+	permissionRoleManage = "role:manage"
+	permissionAPIKey     = "apikey:manage"
+	permissionTenantRead = "tenant:read"
+	// Creating and deleting tenants additionally requires tenant:all
+	permissionTenantManage = "tenant:manage"
+)
+
+// RegisterRoutes registers the user, role, API key and tenant routes and returns the permission each of
+// them requires, for use with auth.RequirePermissions
+func RegisterRoutes(g *echo.Group, service *Service, tokens *auth.TokenAuthority) auth.RoutePermissions {
+	handler := NewHandler(service, tokens)
+	permissions := auth.RoutePermissions{}
+	permit := func(route *echo.Route, required ...string) {
+		permissions[auth.Route(route.Method, route.Path)] = required
+	}
+
+	// Authentication routes
+	g.POST(LoginPath, handler.Login)
+	permit(g.GET("/auth/me", handler.CurrentPrincipal))
+
+	// User routes
+	users := g.Group("/user")
+	permit(users.GET("", handler.GetUsers), permissionUserRead)
+	permit(users.GET("/:username", handler.GetUser), permissionUserRead)
+	permit(users.POST("", handler.CreateUser), permissionUserManage)
+	permit(users.PUT("/:username", handler.UpdateUser), permissionUserManage)
+	permit(users.DELETE("/:username", handler.DeleteUser), permissionUserManage)
+
+	// API key routes, the handlers limit callers without user:manage to their own keys
+	permit(users.GET("/:username/apikey", handler.GetAPIKeys), permissionAPIKey)
+	permit(users.POST("/:username/apikey", handler.CreateAPIKey), permissionAPIKey)
+	permit(users.DELETE("/:username/apikey/:id", handler.RevokeAPIKey), permissionAPIKey)
+
+	// Role routes
+	roles := g.Group("/role")
+	permit(roles.GET("", handler.GetRoles), permissionRoleRead)
+	permit(roles.GET("/:name", handler.GetRole), permissionRoleRead)
+	permit(roles.POST("", handler.CreateRole), permissionRoleManage)
+	permit(roles.PUT("/:name", handler.UpdateRole), permissionRoleManage)
+	permit(roles.DELETE("/:name", handler.DeleteRole), permissionRoleManage)
+
+	// Tenant routes
+	tenants := g.Group("/tenant")
+	permit(tenants.GET("", handler.GetTenants), permissionTenantRead)
+	permit(tenants.POST("", handler.CreateTenant), permissionTenantManage)
+	permit(tenants.DELETE("/:name", handler.DeleteTenant), permissionTenantManage)
+
+	return permissions
+}
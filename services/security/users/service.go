@@ -0,0 +1,761 @@
+// Package users manages operator accounts, their roles and permissions, and per-user API keys
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+
+	"iiot-backend/middleware/auth"
+	"iiot-backend/middleware/tenant"
+	"iiot-backend/models"
+)
+
+const (
+	// MinPasswordLength is the shortest password accepted for a user
+	MinPasswordLength = 12
+	// bcrypt ignores anything past 72 bytes
+	maxPasswordLength = 72
+
+	apiKeyPrefix       = "iiot_"
+	apiKeyPrefixLength = len(apiKeyPrefix) + 8
+	apiKeyRandomBytes  = 32
+	adminRole          = "admin"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrAPIKeyNotFound     = errors.New("API key not found")
+	ErrTenantNotFound     = errors.New("tenant not found")
+	ErrAlreadyExists      = errors.New("already exists")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidRequest     = errors.New("invalid request")
+	ErrForbidden          = errors.New("forbidden")
+)
+
+// dummyPasswordHash is compared against when a login names an unknown user, so that response times
+// do not reveal which usernames exist
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("iiot-backend-dummy-password"), bcrypt.DefaultCost)
+
+type Service struct {
+	db     *sql.DB
+	scope  tenant.Scope
+	caller *auth.Principal
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db, scope: tenant.Unrestricted()}
+}
+
+// WithTenant returns a copy of the service restricted to the users of the given scope. Roles are
+// shared by every tenant, so only unrestricted scopes may change them.
+func (s *Service) WithTenant(scope tenant.Scope) *Service {
+	scoped := *s
+	scoped.scope = scope
+	return &scoped
+}
+
+// WithPrincipal returns a copy of the service acting for principal, which may only grant the
+// permissions it holds itself. A nil principal holds no permission.
+func (s *Service) WithPrincipal(principal *auth.Principal) *Service {
+	if principal == nil {
+		principal = &auth.Principal{}
+	}
+	scoped := *s
+	scoped.caller = principal
+	return &scoped
+}
+
+const userColumns = `
+	u.id, u.username, COALESCE(u.display_name, ''), COALESCE(u.email, ''), u.enabled,
+	COALESCE((SELECT json_agg(r.name ORDER BY r.name) FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = u.id), '[]'),
+	u.tenant_id, u.created, u.modified`
+
+func scanUser(scan func(dest ...interface{}) error) (models.User, error) {
+	var user models.User
+	var rolesJSON []byte
+	err := scan(&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.Enabled, &rolesJSON,
+		&user.Tenant, &user.Created, &user.Modified)
+	if err != nil {
+		return user, err
+	}
+	json.Unmarshal(rolesJSON, &user.Roles)
+	return user, nil
+}
+
+// User methods
+func (s *Service) GetUsers(limit, offset int) ([]models.User, error) {
+	args := []interface{}{limit, offset}
+	query := `SELECT ` + userColumns + ` FROM users u WHERE ` + s.scope.Condition("u.tenant_id", &args) +
+		` ORDER BY u.username LIMIT $1 OFFSET $2`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (s *Service) GetUserByUsername(username string) (*models.User, error) {
+	args := []interface{}{username}
+	query := `SELECT ` + userColumns + ` FROM users u WHERE u.username = $1 AND ` +
+		s.scope.Condition("u.tenant_id", &args)
+
+	user, err := scanUser(s.db.QueryRow(query, args...).Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+func (s *Service) CreateUser(req *models.UserRequest) (string, error) {
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		return "", err
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	tenantID := req.Tenant
+	if tenantID == "" {
+		tenantID = s.scope.Tenant
+	}
+	if !s.scope.Allows(tenantID) {
+		return "", fmt.Errorf("%w: users cannot be created in tenant %s", ErrForbidden, tenantID)
+	}
+	if err := s.checkAssignableRoles(req.Roles); err != nil {
+		return "", err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	id := uuid.New().String()
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO users (id, username, password_hash, display_name, email, enabled, tenant_id, created, modified)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		id, req.Username, passwordHash, req.DisplayName, req.Email, enabled, tenantID, now, now)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return "", fmt.Errorf("user %s %w", req.Username, ErrAlreadyExists)
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return "", fmt.Errorf("%w: tenant %s does not exist", ErrInvalidRequest, tenantID)
+		}
+		return "", fmt.Errorf("failed to create user: %w", err)
+	}
+	if err := setUserRoles(tx, id, req.Roles); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to create user: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateUser applies the fields set in req. Tokens already issued keep their permissions until
+// they expire, API keys follow the change immediately.
+func (s *Service) UpdateUser(username string, req *models.UpdateUserRequest) error {
+	if req.Tenant != nil && !s.scope.Allows(*req.Tenant) {
+		return fmt.Errorf("%w: users cannot be moved to tenant %s", ErrForbidden, *req.Tenant)
+	}
+	if req.Roles != nil {
+		if err := s.checkAssignableRoles(*req.Roles); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id string
+	args := []interface{}{username}
+	query := `SELECT id FROM users WHERE username = $1 AND ` + s.scope.Condition("tenant_id", &args) + ` FOR UPDATE`
+	if err := tx.QueryRow(query, args...).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	var passwordHash *string
+	if req.Password != nil {
+		hash, err := hashPassword(*req.Password)
+		if err != nil {
+			return err
+		}
+		passwordHash = &hash
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users
+		SET password_hash = COALESCE($2, password_hash), display_name = COALESCE($3, display_name),
+		    email = COALESCE($4, email), enabled = COALESCE($5, enabled),
+		    tenant_id = COALESCE($6, tenant_id), modified = $7
+		WHERE id = $1`,
+		id, passwordHash, req.DisplayName, req.Email, req.Enabled, req.Tenant, time.Now())
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("%w: tenant %s does not exist", ErrInvalidRequest, *req.Tenant)
+		}
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if req.Roles != nil {
+		if _, err := tx.Exec(`DELETE FROM user_roles WHERE user_id = $1`, id); err != nil {
+			return fmt.Errorf("failed to update user roles: %w", err)
+		}
+		if err := setUserRoles(tx, id, *req.Roles); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) DeleteUser(username string) error {
+	args := []interface{}{username}
+	result, err := s.db.Exec(`DELETE FROM users WHERE username = $1 AND `+s.scope.Condition("tenant_id", &args), args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("%w: password must be at least %d characters", ErrInvalidRequest, MinPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return "", fmt.Errorf("%w: password must not be longer than %d bytes", ErrInvalidRequest, maxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// checkAssignableRoles refuses roles granting access to every tenant to callers restricted to one,
+// and roles granting any permission the caller does not hold, so that an administrator cannot give a
+// user they manage more access than their own
+func (s *Service) checkAssignableRoles(roles []string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	permissions, err := s.RolePermissions(context.Background(), roles)
+	if err != nil {
+		return err
+	}
+	if !s.scope.All && (&auth.Principal{Permissions: permissions}).Can(tenant.PermissionAllTenants) {
+		return fmt.Errorf("%w: roles granting %s cannot be assigned", ErrForbidden, tenant.PermissionAllTenants)
+	}
+	return s.checkGrantable(permissions)
+}
+
+// checkGrantable refuses permissions the caller does not hold. A service without a principal acts for
+// the backend itself, e.g. when seeding the first administrator, and may grant any permission.
+func (s *Service) checkGrantable(permissions []string) error {
+	if s.caller == nil {
+		return nil
+	}
+	for _, permission := range permissions {
+		if !s.caller.Can(permission) {
+			return fmt.Errorf("%w: permission %s cannot be granted by a caller not holding it", ErrForbidden, permission)
+		}
+	}
+	return nil
+}
+
+func setUserRoles(tx *sql.Tx, userID string, roles []string) error {
+	for _, role := range roles {
+		result, err := tx.Exec(`
+			INSERT INTO user_roles (user_id, role_id)
+			SELECT $1, id FROM roles WHERE name = $2
+			ON CONFLICT DO NOTHING`, userID, role)
+		if err != nil {
+			return fmt.Errorf("failed to assign role %s: %w", role, err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			var exists bool
+			if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`, role).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to assign role %s: %w", role, err)
+			}
+			if !exists {
+				return fmt.Errorf("%w: role %s does not exist", ErrInvalidRequest, role)
+			}
+		}
+	}
+	return nil
+}
+
+// Role methods
+func scanRole(scan func(dest ...interface{}) error) (models.Role, error) {
+	var role models.Role
+	var permissionsJSON []byte
+	err := scan(&role.ID, &role.Name, &role.Description, &permissionsJSON, &role.Created, &role.Modified)
+	if err != nil {
+		return role, err
+	}
+	json.Unmarshal(permissionsJSON, &role.Permissions)
+	return role, nil
+}
+
+func (s *Service) GetRoles() ([]models.Role, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, COALESCE(description, ''), permissions, created, modified
+		FROM roles ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		role, err := scanRole(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (s *Service) GetRoleByName(name string) (*models.Role, error) {
+	role, err := scanRole(s.db.QueryRow(`
+		SELECT id, name, COALESCE(description, ''), permissions, created, modified
+		FROM roles WHERE name = $1`, name).Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return &role, nil
+}
+
+func (s *Service) CreateRole(req *models.RoleRequest) (string, error) {
+	if !s.scope.All {
+		return "", fmt.Errorf("%w: roles are shared by every tenant", ErrForbidden)
+	}
+	if err := validatePermissions(req.Permissions); err != nil {
+		return "", err
+	}
+	if err := s.checkGrantable(req.Permissions); err != nil {
+		return "", err
+	}
+	permissionsJSON, _ := json.Marshal(req.Permissions)
+
+	id := uuid.New().String()
+	now := time.Now()
+	_, err := s.db.Exec(`
+		INSERT INTO roles (id, name, description, permissions, created, modified)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		id, req.Name, req.Description, permissionsJSON, now, now)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return "", fmt.Errorf("role %s %w", req.Name, ErrAlreadyExists)
+		}
+		return "", fmt.Errorf("failed to create role: %w", err)
+	}
+	return id, nil
+}
+
+func (s *Service) UpdateRole(name string, req *models.RoleRequest) error {
+	if !s.scope.All {
+		return fmt.Errorf("%w: roles are shared by every tenant", ErrForbidden)
+	}
+	if err := validatePermissions(req.Permissions); err != nil {
+		return err
+	}
+	if err := s.checkGrantable(req.Permissions); err != nil {
+		return err
+	}
+	permissionsJSON, _ := json.Marshal(req.Permissions)
+
+	result, err := s.db.Exec(`
+		UPDATE roles SET name = $2, description = $3, permissions = $4, modified = $5
+		WHERE name = $1`,
+		name, req.Name, req.Description, permissionsJSON, time.Now())
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("role %s %w", req.Name, ErrAlreadyExists)
+		}
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+func (s *Service) DeleteRole(name string) error {
+	if !s.scope.All {
+		return fmt.Errorf("%w: roles are shared by every tenant", ErrForbidden)
+	}
+	result, err := s.db.Exec(`DELETE FROM roles WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+func validatePermissions(permissions []string) error {
+	for _, p := range permissions {
+		if !auth.ValidPermission(p) {
+			return fmt.Errorf("%w: permission %q must be written as resource:verb", ErrInvalidRequest, p)
+		}
+	}
+	return nil
+}
+
+// Authentication methods
+
+// Login verifies a username and password and returns the user's principal
+func (s *Service) Login(username, password string) (*auth.Principal, error) {
+	var id, passwordHash, tenantID string
+	var enabled bool
+	err := s.db.QueryRow(`SELECT id, password_hash, enabled, tenant_id FROM users WHERE username = $1`, username).
+		Scan(&id, &passwordHash, &enabled, &tenantID)
+	if err == sql.ErrNoRows {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil || !enabled {
+		return nil, ErrInvalidCredentials
+	}
+	return s.principal(context.Background(), id, username, tenantID)
+}
+
+// principal loads the roles of a user and the union of their permissions
+func (s *Service) principal(ctx context.Context, userID, username, tenantID string) (*auth.Principal, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.name, r.permissions FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1 ORDER BY r.name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user roles: %w", err)
+	}
+	defer rows.Close()
+
+	p := &auth.Principal{UserID: userID, Username: username, Tenant: tenantID}
+	p.Roles, p.Permissions, err = scanRolePermissions(rows)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// RolePermissions returns the union of the permissions of the named roles, names without a
+// matching role are ignored. It resolves the roles of callers authenticated by an external
+// identity provider.
+func (s *Service) RolePermissions(ctx context.Context, roles []string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, permissions FROM roles WHERE name = ANY($1) ORDER BY name`, pq.Array(roles))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles: %w", err)
+	}
+	defer rows.Close()
+
+	_, permissions, err := scanRolePermissions(rows)
+	return permissions, err
+}
+
+// scanRolePermissions reads name and permissions rows into the role names and the sorted union of
+// their permissions
+func scanRolePermissions(rows *sql.Rows) ([]string, []string, error) {
+	var roles []string
+	permissions := make(map[string]bool)
+	for rows.Next() {
+		var role string
+		var permissionsJSON []byte
+		if err := rows.Scan(&role, &permissionsJSON); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, role)
+		var rolePermissions []string
+		json.Unmarshal(permissionsJSON, &rolePermissions)
+		for _, permission := range rolePermissions {
+			permissions[permission] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read roles: %w", err)
+	}
+
+	sorted := make([]string, 0, len(permissions))
+	for permission := range permissions {
+		sorted = append(sorted, permission)
+	}
+	sort.Strings(sorted)
+	return roles, sorted, nil
+}
+
+// EnsureAdmin creates the given user with the admin role when no user exists yet, so that a fresh
+// installation can be administered
+func (s *Service) EnsureAdmin(username, password string) (bool, error) {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users)`).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to count users: %w", err)
+	}
+	if exists {
+		return false, nil
+	}
+	_, err := s.CreateUser(&models.UserRequest{Username: username, Password: password, Roles: []string{adminRole}})
+	return err == nil, err
+}
+
+// API key methods
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey generates a key for the user, the key is returned once and only its hash is stored
+func (s *Service) CreateAPIKey(username string, req *models.APIKeyRequest) (*models.APIKeyResponse, error) {
+	if err := validatePermissions(req.Scopes); err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expiresAt must be in the future", ErrInvalidRequest)
+	}
+
+	var userID string
+	args := []interface{}{username}
+	query := `SELECT id FROM users WHERE username = $1 AND ` + s.scope.Condition("tenant_id", &args)
+	if err := s.db.QueryRow(query, args...).Scan(&userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	random := make([]byte, apiKeyRandomBytes)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	scopes := req.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	apiKey := models.APIKey{
+		ID:        uuid.New().String(),
+		Username:  username,
+		Name:      req.Name,
+		Prefix:    key[:apiKeyPrefixLength],
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+		Created:   time.Now(),
+	}
+	scopesJSON, _ := json.Marshal(apiKey.Scopes)
+
+	_, err := s.db.Exec(`
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at, created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		apiKey.ID, userID, apiKey.Name, apiKey.Prefix, hashAPIKey(key), scopesJSON, apiKey.ExpiresAt, apiKey.Created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	return &models.APIKeyResponse{APIKey: apiKey, Key: key}, nil
+}
+
+func (s *Service) GetAPIKeys(username string) ([]models.APIKey, error) {
+	if _, err := s.GetUserByUsername(username); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT k.id, k.name, k.prefix, k.scopes, k.expires_at, k.last_used, k.revoked, k.created
+		FROM api_keys k JOIN users u ON u.id = k.user_id
+		WHERE u.username = $1
+		ORDER BY k.created DESC`, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key := models.APIKey{Username: username}
+		var scopesJSON []byte
+		err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &scopesJSON, &key.ExpiresAt, &key.LastUsed,
+			&key.Revoked, &key.Created)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		json.Unmarshal(scopesJSON, &key.Scopes)
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *Service) RevokeAPIKey(username, id string) error {
+	args := []interface{}{id, username}
+	result, err := s.db.Exec(`
+		UPDATE api_keys SET revoked = TRUE
+		WHERE id = $1 AND user_id = (SELECT id FROM users WHERE username = $2 AND `+
+		s.scope.Condition("tenant_id", &args)+`)`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// ValidateAPIKey resolves a key to its owner's principal, restricted to the key's scopes. Revoked
+// and expired keys and keys of disabled users are rejected.
+func (s *Service) ValidateAPIKey(ctx context.Context, key string) (*auth.Principal, error) {
+	var keyID, userID, username, tenantID string
+	var scopesJSON []byte
+	var expiresAt sql.NullTime
+	var revoked, enabled bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT k.id, k.scopes, k.expires_at, k.revoked, u.id, u.username, u.enabled, u.tenant_id
+		FROM api_keys k JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1`, hashAPIKey(key)).
+		Scan(&keyID, &scopesJSON, &expiresAt, &revoked, &userID, &username, &enabled, &tenantID)
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	if revoked || !enabled || (expiresAt.Valid && !expiresAt.Time.After(time.Now())) {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	p, err := s.principal(ctx, userID, username, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	p.APIKeyID = keyID
+	json.Unmarshal(scopesJSON, &p.Scopes)
+
+	if _, err := s.db.ExecContext(ctx, `UPDATE api_keys SET last_used = $2 WHERE id = $1`, keyID, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to record API key use: %w", err)
+	}
+	return p, nil
+}
+
+// ValidateCertificate resolves a verified client certificate to the enabled user named by the first
+// of its identities that matches a username, so services and devices holding a certificate are given
+// the roles of a user of the same name
+func (s *Service) ValidateCertificate(ctx context.Context, identities []string) (*auth.Principal, error) {
+	var userID, username, tenantID string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, username, tenant_id FROM users WHERE username = ANY($1) AND enabled
+		ORDER BY array_position($1, username) LIMIT 1`, pq.Array(identities)).
+		Scan(&userID, &username, &tenantID)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get certificate user: %w", err)
+	}
+	return s.principal(ctx, userID, username, tenantID)
+}
+
+// Tenant methods
+
+func (s *Service) GetTenants() ([]models.Tenant, error) {
+	args := []interface{}{}
+	rows, err := s.db.Query(`
+		SELECT name, COALESCE(description, ''), created FROM tenants
+		WHERE `+s.scope.Condition("name", &args)+` ORDER BY name`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenants: %w", err)
+	}
+	defer rows.Close()
+
+	tenants := []models.Tenant{}
+	for rows.Next() {
+		var t models.Tenant
+		if err := rows.Scan(&t.Name, &t.Description, &t.Created); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %w", err)
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, nil
+}
+
+func (s *Service) CreateTenant(req *models.TenantRequest) error {
+	if !s.scope.All {
+		return fmt.Errorf("%w: tenants can only be created by principals holding %s", ErrForbidden, tenant.PermissionAllTenants)
+	}
+	_, err := s.db.Exec(`INSERT INTO tenants (name, description, created) VALUES ($1, $2, $3)`,
+		req.Name, req.Description, time.Now())
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("tenant %s %w", req.Name, ErrAlreadyExists)
+		}
+		return fmt.Errorf("failed to create tenant: %w", err)
+	}
+	return nil
+}
+
+// DeleteTenant removes a tenant that no longer owns any records
+func (s *Service) DeleteTenant(name string) error {
+	if !s.scope.All {
+		return fmt.Errorf("%w: tenants can only be deleted by principals holding %s", ErrForbidden, tenant.PermissionAllTenants)
+	}
+	if name == tenant.DefaultTenant {
+		return fmt.Errorf("%w: the default tenant cannot be deleted", ErrInvalidRequest)
+	}
+	result, err := s.db.Exec(`DELETE FROM tenants WHERE name = $1`, name)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("%w: tenant %s still owns records", ErrInvalidRequest, name)
+		}
+		return fmt.Errorf("failed to delete tenant: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTenantNotFound
+	}
+	return nil
+}
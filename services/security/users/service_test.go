@@ -0,0 +1,35 @@
+package users
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"iiot-backend/middleware/auth"
+	"iiot-backend/middleware/tenant"
+)
+
+func TestCheckGrantable(t *testing.T) {
+	tenantAdmin := NewService(nil).WithTenant(tenant.Scope{Tenant: "plant-b"}).WithPrincipal(&auth.Principal{
+		Permissions: []string{"device:read", "device:write", "user:manage", "role:read"},
+	})
+
+	assert.NoError(t, tenantAdmin.checkGrantable([]string{"device:read", "device:write"}))
+	// the permissions of secret-custodian
+	err := tenantAdmin.checkGrantable([]string{"secret:reveal", "secret:rotate"})
+	assert.True(t, errors.Is(err, ErrForbidden))
+	// a wildcard grants more than any of the permissions it covers
+	assert.True(t, errors.Is(tenantAdmin.checkGrantable([]string{"device:*"}), ErrForbidden))
+
+	admin := NewService(nil).WithPrincipal(&auth.Principal{Permissions: []string{"*:*"}})
+	assert.NoError(t, admin.checkGrantable([]string{"secret:reveal", "tenant:all"}))
+
+	// an API key scoped below its owner grants no more than its scopes
+	scopedKey := NewService(nil).WithPrincipal(&auth.Principal{Permissions: []string{"*:*"}, Scopes: []string{"device:read"}})
+	assert.True(t, errors.Is(scopedKey.checkGrantable([]string{"device:write"}), ErrForbidden))
+
+	// requests without a principal grant nothing, the backend itself grants anything
+	assert.True(t, errors.Is(NewService(nil).WithPrincipal(nil).checkGrantable([]string{"device:read"}), ErrForbidden))
+	assert.NoError(t, NewService(nil).checkGrantable([]string{"secret:reveal"}))
+}
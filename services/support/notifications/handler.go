@@ -1,11 +1,15 @@
 package notifications
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/sensitive"
+	"iiot-backend/middleware/tenant"
 	"iiot-backend/models"
 	"iiot-backend/utils"
 )
@@ -18,6 +22,22 @@ func NewHandler(service *Service) *Handler {
 	return &Handler{service: service}
 }
 
+// scoped returns the service restricted to the tenant of the request, revealing channel credentials
+// only to callers allowed to see them
+func (h *Handler) scoped(c echo.Context) *Service {
+	ctx := c.Request().Context()
+	return h.service.WithTenant(tenant.FromContext(ctx)).WithReveal(sensitive.Revealed(ctx))
+}
+
+// writeStatus returns the status of a failed subscription write, masked or encrypted credentials
+// in place of new ones are the caller's mistake
+func writeStatus(err error) int {
+	if errors.Is(err, sensitive.ErrMasked) || errors.Is(err, sensitive.ErrEncryptedValue) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 // Notification handlers
 func (h *Handler) GetNotifications(c echo.Context) error {
 	category := c.QueryParam("category")
@@ -52,7 +72,7 @@ func (h *Handler) GetNotifications(c echo.Context) error {
 		}
 	}
 
-	notifications, err := h.service.GetNotifications(filter)
+	notifications, err := h.scoped(c).GetNotifications(filter)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve notifications", err)
 	}
@@ -62,7 +82,7 @@ func (h *Handler) GetNotifications(c echo.Context) error {
 
 func (h *Handler) GetNotification(c echo.Context) error {
 	id := c.Param("id")
-	notification, err := h.service.GetNotificationByID(id)
+	notification, err := h.scoped(c).GetNotificationByID(id)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Notification not found", err)
 	}
@@ -71,7 +91,7 @@ func (h *Handler) GetNotification(c echo.Context) error {
 
 func (h *Handler) GetNotificationBySlug(c echo.Context) error {
 	slug := c.Param("slug")
-	notification, err := h.service.GetNotificationBySlug(slug)
+	notification, err := h.scoped(c).GetNotificationBySlug(slug)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Notification not found", err)
 	}
@@ -88,10 +108,13 @@ func (h *Handler) CreateNotification(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
 	}
 
-	id, err := h.service.CreateNotification(&req)
+	service := h.scoped(c)
+	id, err := service.CreateNotification(&req)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create notification", err)
 	}
+	after, _ := service.GetNotificationByID(id)
+	audit.Change(c.Request().Context(), "notification", id, nil, after)
 
 	return utils.SuccessResponse(c, map[string]string{"id": id})
 }
@@ -103,24 +126,31 @@ func (h *Handler) UpdateNotification(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
 	}
 
-	if err := h.service.UpdateNotification(id, &req); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetNotificationByID(id)
+	if err := service.UpdateNotification(id, &req); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update notification", err)
 	}
+	after, _ := service.GetNotificationByID(id)
+	audit.Change(c.Request().Context(), "notification", id, before, after)
 
 	return utils.SuccessResponse(c, map[string]string{"message": "Notification updated successfully"})
 }
 
 func (h *Handler) DeleteNotification(c echo.Context) error {
 	id := c.Param("id")
-	if err := h.service.DeleteNotification(id); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetNotificationByID(id)
+	if err := service.DeleteNotification(id); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete notification", err)
 	}
+	audit.Change(c.Request().Context(), "notification", id, before, nil)
 	return utils.SuccessResponse(c, map[string]string{"message": "Notification deleted successfully"})
 }
 
 func (h *Handler) DeleteNotificationBySlug(c echo.Context) error {
 	slug := c.Param("slug")
-	if err := h.service.DeleteNotificationBySlug(slug); err != nil {
+	if err := h.scoped(c).DeleteNotificationBySlug(slug); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete notification", err)
 	}
 	return utils.SuccessResponse(c, map[string]string{"message": "Notification deleted successfully"})
@@ -133,7 +163,7 @@ func (h *Handler) CleanupNotifications(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid age parameter", err)
 	}
 
-	count, err := h.service.CleanupNotifications(age)
+	count, err := h.scoped(c).CleanupNotifications(age)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to cleanup notifications", err)
 	}
@@ -153,7 +183,7 @@ func (h *Handler) GetSubscriptions(c echo.Context) error {
 		limit = 50
 	}
 
-	subscriptions, err := h.service.GetAllSubscriptions(limit, offset)
+	subscriptions, err := h.scoped(c).GetAllSubscriptions(limit, offset)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve subscriptions", err)
 	}
@@ -162,7 +192,7 @@ func (h *Handler) GetSubscriptions(c echo.Context) error {
 
 func (h *Handler) GetSubscription(c echo.Context) error {
 	id := c.Param("id")
-	subscription, err := h.service.GetSubscriptionByID(id)
+	subscription, err := h.scoped(c).GetSubscriptionByID(id)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Subscription not found", err)
 	}
@@ -171,7 +201,7 @@ func (h *Handler) GetSubscription(c echo.Context) error {
 
 func (h *Handler) GetSubscriptionByName(c echo.Context) error {
 	name := c.Param("name")
-	subscription, err := h.service.GetSubscriptionByName(name)
+	subscription, err := h.scoped(c).GetSubscriptionByName(name)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Subscription not found", err)
 	}
@@ -188,10 +218,13 @@ func (h *Handler) CreateSubscription(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
 	}
 
-	id, err := h.service.CreateSubscription(&req)
+	service := h.scoped(c)
+	id, err := service.CreateSubscription(&req)
 	if err != nil {
-		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create subscription", err)
+		return utils.ErrorResponse(c, writeStatus(err), "Failed to create subscription", err)
 	}
+	after, _ := service.GetSubscriptionByID(id)
+	audit.Change(c.Request().Context(), "subscription", id, nil, maskedSubscription(after))
 
 	return utils.SuccessResponse(c, map[string]string{"id": id})
 }
@@ -203,24 +236,31 @@ func (h *Handler) UpdateSubscription(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
 	}
 
-	if err := h.service.UpdateSubscription(id, &req); err != nil {
-		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update subscription", err)
+	service := h.scoped(c)
+	before, _ := service.GetSubscriptionByID(id)
+	if err := service.UpdateSubscription(id, &req); err != nil {
+		return utils.ErrorResponse(c, writeStatus(err), "Failed to update subscription", err)
 	}
+	after, _ := service.GetSubscriptionByID(id)
+	audit.Change(c.Request().Context(), "subscription", id, maskedSubscription(before), maskedSubscription(after))
 
 	return utils.SuccessResponse(c, map[string]string{"message": "Subscription updated successfully"})
 }
 
 func (h *Handler) DeleteSubscription(c echo.Context) error {
 	id := c.Param("id")
-	if err := h.service.DeleteSubscription(id); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetSubscriptionByID(id)
+	if err := service.DeleteSubscription(id); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete subscription", err)
 	}
+	audit.Change(c.Request().Context(), "subscription", id, maskedSubscription(before), nil)
 	return utils.SuccessResponse(c, map[string]string{"message": "Subscription deleted successfully"})
 }
 
 func (h *Handler) TransmitNotification(c echo.Context) error {
 	id := c.Param("id")
-	err := h.service.TransmitNotification(id)
+	err := h.scoped(c).TransmitNotification(id)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to transmit notification", err)
 	}
@@ -1,21 +1,45 @@
 package notifications
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"iiot-backend/middleware/sensitive"
+	"iiot-backend/middleware/tenant"
 	"iiot-backend/models"
 )
 
 type Service struct {
-	db *sql.DB
+	db     *sql.DB
+	scope  tenant.Scope
+	keys   *sensitive.Keyring
+	reveal bool
 }
 
-func NewService(db *sql.DB) *Service {
-	return &Service{db: db}
+// NewService creates the service, channel credentials are encrypted with keys when it holds any and
+// masked until WithReveal allows revealing them
+func NewService(db *sql.DB, keys *sensitive.Keyring) *Service {
+	return &Service{db: db, scope: tenant.Unrestricted(), keys: keys}
+}
+
+// WithTenant returns a copy of the service restricted to the notifications and subscriptions
+// visible in scope
+func (s *Service) WithTenant(scope tenant.Scope) *Service {
+	scoped := *s
+	scoped.scope = scope
+	return &scoped
+}
+
+// WithReveal returns a copy of the service returning channel credentials in plaintext when reveal
+// is set and masked otherwise
+func (s *Service) WithReveal(reveal bool) *Service {
+	revealed := *s
+	revealed.reveal = reveal
+	return &revealed
 }
 
 // Notification methods
@@ -29,6 +53,7 @@ func (s *Service) GetNotifications(filter models.NotificationFilter) ([]models.N
 		  AND ($3 = '' OR status = $3)
 		  AND ($4::timestamp IS NULL OR created >= $4)
 		  AND ($5::timestamp IS NULL OR created <= $5)
+		  AND %s
 		ORDER BY created DESC
 		LIMIT $6 OFFSET $7
 	`
@@ -41,8 +66,10 @@ func (s *Service) GetNotifications(filter models.NotificationFilter) ([]models.N
 		end = filter.End
 	}
 
-	rows, err := s.db.Query(query, filter.Category, filter.Severity, filter.Status,
-		start, end, filter.Limit, filter.Offset)
+	args := []interface{}{filter.Category, filter.Severity, filter.Status, start, end, filter.Limit, filter.Offset}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query notifications: %w", err)
 	}
@@ -74,17 +101,18 @@ func (s *Service) GetNotifications(filter models.NotificationFilter) ([]models.N
 }
 
 func (s *Service) GetNotificationByID(id string) (*models.Notification, error) {
+	args := []interface{}{id}
 	query := `
 		SELECT id, slug, sender, category, severity, content, description, status,
 		       labels, content_type, created, modified
 		FROM notifications
-		WHERE id = $1
+		WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 
 	var notification models.Notification
 	var labelsJSON []byte
 
-	err := s.db.QueryRow(query, id).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&notification.ID, &notification.Slug, &notification.Sender,
 		&notification.Category, &notification.Severity, &notification.Content,
 		&notification.Description, &notification.Status, &labelsJSON,
@@ -102,17 +130,18 @@ func (s *Service) GetNotificationByID(id string) (*models.Notification, error) {
 }
 
 func (s *Service) GetNotificationBySlug(slug string) (*models.Notification, error) {
+	args := []interface{}{slug}
 	query := `
 		SELECT id, slug, sender, category, severity, content, description, status,
 		       labels, content_type, created, modified
 		FROM notifications
-		WHERE slug = $1
+		WHERE slug = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 
 	var notification models.Notification
 	var labelsJSON []byte
 
-	err := s.db.QueryRow(query, slug).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&notification.ID, &notification.Slug, &notification.Sender,
 		&notification.Category, &notification.Severity, &notification.Content,
 		&notification.Description, &notification.Status, &labelsJSON,
@@ -153,19 +182,20 @@ func (s *Service) CreateNotification(req *models.NotificationRequest) (string, e
 
 	query := `
 		INSERT INTO notifications (id, slug, sender, category, severity, content, description,
-		                         status, labels, content_type, created, modified)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		                         status, labels, content_type, created, modified, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
 
 	_, err := s.db.Exec(query, notification.ID, notification.Slug, notification.Sender,
 		notification.Category, notification.Severity, notification.Content, notification.Description,
-		notification.Status, labelsJSON, notification.ContentType, notification.Created, notification.Modified)
+		notification.Status, labelsJSON, notification.ContentType, notification.Created, notification.Modified,
+		s.scope.Tenant)
 	if err != nil {
 		return "", fmt.Errorf("failed to create notification: %w", err)
 	}
 
 	// Process subscriptions for this notification
-	go s.processNotificationSubscriptions(notification)
+	go s.processNotificationSubscriptions(notification, s.scope.Tenant)
 
 	return notification.ID, nil
 }
@@ -182,11 +212,14 @@ func (s *Service) UpdateNotification(id string, req *models.NotificationRequest)
 		UPDATE notifications 
 		SET slug = $2, sender = $3, category = $4, severity = $5, content = $6,
 		    description = $7, labels = $8, content_type = $9, modified = $10
-		WHERE id = $1
+		WHERE id = $1 AND %s
 	`
 
-	_, err := s.db.Exec(query, id, req.Slug, req.Sender, req.Category, req.Severity,
-		req.Content, req.Description, labelsJSON, contentType, time.Now())
+	args := []interface{}{id, req.Slug, req.Sender, req.Category, req.Severity,
+		req.Content, req.Description, labelsJSON, contentType, time.Now()}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update notification: %w", err)
 	}
@@ -195,8 +228,9 @@ func (s *Service) UpdateNotification(id string, req *models.NotificationRequest)
 }
 
 func (s *Service) DeleteNotification(id string) error {
-	query := `DELETE FROM notifications WHERE id = $1`
-	_, err := s.db.Exec(query, id)
+	args := []interface{}{id}
+	query := `DELETE FROM notifications WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args)
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete notification: %w", err)
 	}
@@ -204,8 +238,9 @@ func (s *Service) DeleteNotification(id string) error {
 }
 
 func (s *Service) DeleteNotificationBySlug(slug string) error {
-	query := `DELETE FROM notifications WHERE slug = $1`
-	_, err := s.db.Exec(query, slug)
+	args := []interface{}{slug}
+	query := `DELETE FROM notifications WHERE slug = $1 AND ` + s.scope.Condition("tenant_id", &args)
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete notification: %w", err)
 	}
@@ -215,8 +250,9 @@ func (s *Service) DeleteNotificationBySlug(slug string) error {
 func (s *Service) CleanupNotifications(ageInMilliseconds int64) (int64, error) {
 	cutoffTime := time.Now().Add(-time.Duration(ageInMilliseconds) * time.Millisecond)
 
-	query := `DELETE FROM notifications WHERE created < $1`
-	result, err := s.db.Exec(query, cutoffTime)
+	args := []interface{}{cutoffTime}
+	query := `DELETE FROM notifications WHERE created < $1 AND ` + s.scope.Condition("tenant_id", &args)
+	result, err := s.db.Exec(query, args...)
 	if err != nil {
 		return 0, fmt.Errorf("failed to cleanup notifications: %w", err)
 	}
@@ -231,11 +267,15 @@ func (s *Service) GetAllSubscriptions(limit, offset int) ([]models.Subscription,
 		SELECT id, name, slug, description, receiver, subscribed_categories, subscribed_labels,
 		       channels, resend_limit, resend_interval, admin_state, created, modified
 		FROM subscriptions
+		WHERE %s
 		ORDER BY created DESC
 		LIMIT $1 OFFSET $2
 	`
 
-	rows, err := s.db.Query(query, limit, offset)
+	args := []interface{}{limit, offset}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query subscriptions: %w", err)
 	}
@@ -266,6 +306,9 @@ func (s *Service) GetAllSubscriptions(limit, offset int) ([]models.Subscription,
 		if len(channelsJSON) > 0 {
 			json.Unmarshal(channelsJSON, &subscription.Channels)
 		}
+		if err := s.decryptChannels(subscription.ID, subscription.Channels); err != nil {
+			return nil, err
+		}
 
 		subscriptions = append(subscriptions, subscription)
 	}
@@ -274,17 +317,18 @@ func (s *Service) GetAllSubscriptions(limit, offset int) ([]models.Subscription,
 }
 
 func (s *Service) GetSubscriptionByID(id string) (*models.Subscription, error) {
+	args := []interface{}{id}
 	query := `
 		SELECT id, name, slug, description, receiver, subscribed_categories, subscribed_labels,
 		       channels, resend_limit, resend_interval, admin_state, created, modified
 		FROM subscriptions
-		WHERE id = $1
+		WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 
 	var subscription models.Subscription
 	var subscribedCategoriesJSON, subscribedLabelsJSON, channelsJSON []byte
 
-	err := s.db.QueryRow(query, id).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&subscription.ID, &subscription.Name, &subscription.Slug, &subscription.Description,
 		&subscription.Receiver, &subscribedCategoriesJSON, &subscribedLabelsJSON,
 		&channelsJSON, &subscription.ResendLimit, &subscription.ResendInterval,
@@ -304,22 +348,26 @@ func (s *Service) GetSubscriptionByID(id string) (*models.Subscription, error) {
 	if len(channelsJSON) > 0 {
 		json.Unmarshal(channelsJSON, &subscription.Channels)
 	}
+	if err := s.decryptChannels(subscription.ID, subscription.Channels); err != nil {
+		return nil, err
+	}
 
 	return &subscription, nil
 }
 
 func (s *Service) GetSubscriptionByName(name string) (*models.Subscription, error) {
+	args := []interface{}{name}
 	query := `
 		SELECT id, name, slug, description, receiver, subscribed_categories, subscribed_labels,
 		       channels, resend_limit, resend_interval, admin_state, created, modified
 		FROM subscriptions
-		WHERE name = $1
+		WHERE name = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 
 	var subscription models.Subscription
 	var subscribedCategoriesJSON, subscribedLabelsJSON, channelsJSON []byte
 
-	err := s.db.QueryRow(query, name).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&subscription.ID, &subscription.Name, &subscription.Slug, &subscription.Description,
 		&subscription.Receiver, &subscribedCategoriesJSON, &subscribedLabelsJSON,
 		&channelsJSON, &subscription.ResendLimit, &subscription.ResendInterval,
@@ -339,6 +387,9 @@ func (s *Service) GetSubscriptionByName(name string) (*models.Subscription, erro
 	if len(channelsJSON) > 0 {
 		json.Unmarshal(channelsJSON, &subscription.Channels)
 	}
+	if err := s.decryptChannels(subscription.ID, subscription.Channels); err != nil {
+		return nil, err
+	}
 
 	return &subscription, nil
 }
@@ -364,22 +415,27 @@ func (s *Service) CreateSubscription(req *models.SubscriptionRequest) (string, e
 		subscription.AdminState = "UNLOCKED"
 	}
 
+	channels, err := s.encryptChannels(subscription.ID, subscription.Channels, nil)
+	if err != nil {
+		return "", err
+	}
+
 	// Marshal JSON fields
 	subscribedCategoriesJSON, _ := json.Marshal(subscription.SubscribedCategories)
 	subscribedLabelsJSON, _ := json.Marshal(subscription.SubscribedLabels)
-	channelsJSON, _ := json.Marshal(subscription.Channels)
+	channelsJSON, _ := json.Marshal(channels)
 
 	query := `
 		INSERT INTO subscriptions (id, name, slug, description, receiver, subscribed_categories,
 		                         subscribed_labels, channels, resend_limit, resend_interval,
-		                         admin_state, created, modified)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		                         admin_state, created, modified, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`
 
-	_, err := s.db.Exec(query, subscription.ID, subscription.Name, subscription.Slug,
+	_, err = s.db.Exec(query, subscription.ID, subscription.Name, subscription.Slug,
 		subscription.Description, subscription.Receiver, subscribedCategoriesJSON,
 		subscribedLabelsJSON, channelsJSON, subscription.ResendLimit, subscription.ResendInterval,
-		subscription.AdminState, subscription.Created, subscription.Modified)
+		subscription.AdminState, subscription.Created, subscription.Modified, s.scope.Tenant)
 	if err != nil {
 		return "", fmt.Errorf("failed to create subscription: %w", err)
 	}
@@ -388,10 +444,19 @@ func (s *Service) CreateSubscription(req *models.SubscriptionRequest) (string, e
 }
 
 func (s *Service) UpdateSubscription(id string, req *models.SubscriptionRequest) error {
+	stored, err := s.storedChannels(id)
+	if err != nil {
+		return err
+	}
+	channels, err := s.encryptChannels(id, req.Channels, stored)
+	if err != nil {
+		return err
+	}
+
 	// Marshal JSON fields
 	subscribedCategoriesJSON, _ := json.Marshal(req.SubscribedCategories)
 	subscribedLabelsJSON, _ := json.Marshal(req.SubscribedLabels)
-	channelsJSON, _ := json.Marshal(req.Channels)
+	channelsJSON, _ := json.Marshal(channels)
 
 	adminState := req.AdminState
 	if adminState == "" {
@@ -403,12 +468,15 @@ func (s *Service) UpdateSubscription(id string, req *models.SubscriptionRequest)
 		SET name = $2, slug = $3, description = $4, receiver = $5, subscribed_categories = $6,
 		    subscribed_labels = $7, channels = $8, resend_limit = $9, resend_interval = $10,
 		    admin_state = $11, modified = $12
-		WHERE id = $1
+		WHERE id = $1 AND %s
 	`
 
-	_, err := s.db.Exec(query, id, req.Name, req.Slug, req.Description, req.Receiver,
+	args := []interface{}{id, req.Name, req.Slug, req.Description, req.Receiver,
 		subscribedCategoriesJSON, subscribedLabelsJSON, channelsJSON, req.ResendLimit,
-		req.ResendInterval, adminState, time.Now())
+		req.ResendInterval, adminState, time.Now()}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	_, err = s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update subscription: %w", err)
 	}
@@ -417,8 +485,9 @@ func (s *Service) UpdateSubscription(id string, req *models.SubscriptionRequest)
 }
 
 func (s *Service) DeleteSubscription(id string) error {
-	query := `DELETE FROM subscriptions WHERE id = $1`
-	_, err := s.db.Exec(query, id)
+	args := []interface{}{id}
+	query := `DELETE FROM subscriptions WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args)
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete subscription: %w", err)
 	}
@@ -432,21 +501,24 @@ func (s *Service) TransmitNotification(id string) error {
 	}
 
 	// Update notification status to indicate transmission attempt
-	query := `UPDATE notifications SET status = $1, modified = $2 WHERE id = $3`
-	_, err = s.db.Exec(query, "PROCESSED", time.Now(), id)
+	var notificationTenant string
+	query := `UPDATE notifications SET status = $1, modified = $2 WHERE id = $3 RETURNING tenant_id`
+	err = s.db.QueryRow(query, "PROCESSED", time.Now(), id).Scan(&notificationTenant)
 	if err != nil {
 		return fmt.Errorf("failed to update notification status: %w", err)
 	}
 
 	// Process subscriptions for this notification
-	go s.processNotificationSubscriptions(notification)
+	go s.processNotificationSubscriptions(notification, notificationTenant)
 
 	return nil
 }
 
 // Helper methods
-func (s *Service) processNotificationSubscriptions(notification *models.Notification) {
-	subscriptions, err := s.GetAllSubscriptions(1000, 0) // Get all subscriptions
+
+// processNotificationSubscriptions matches the notification against the subscriptions of its tenant
+func (s *Service) processNotificationSubscriptions(notification *models.Notification, tenantID string) {
+	subscriptions, err := s.WithTenant(tenant.Scope{Tenant: tenantID}).WithReveal(true).GetAllSubscriptions(1000, 0) // Get all subscriptions
 	if err != nil {
 		return
 	}
@@ -501,3 +573,199 @@ func (s *Service) matchesSubscription(notification *models.Notification, subscri
 
 	return true
 }
+
+// storedChannels returns the channels of a subscription as stored, with their credentials encrypted
+func (s *Service) storedChannels(id string) ([]models.Channel, error) {
+	args := []interface{}{id}
+	query := `SELECT channels FROM subscriptions WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args)
+
+	var channelsJSON []byte
+	err := s.db.QueryRow(query, args...).Scan(&channelsJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	var channels []models.Channel
+	if len(channelsJSON) > 0 {
+		json.Unmarshal(channelsJSON, &channels)
+	}
+	return channels, nil
+}
+
+// channelField returns the field of the channel at index i of the subscription with the given id,
+// the credentials of the channel are encrypted for fields below it
+func channelField(id string, i int) sensitive.Field {
+	return sensitive.Field{Table: "subscriptions", Row: id, Name: fmt.Sprintf("channels.%d", i)}
+}
+
+// sealCredential returns the credential to store for field, the stored one when value is the mask
+// and value encrypted otherwise
+func (s *Service) sealCredential(field sensitive.Field, value, stored string) (string, error) {
+	if value != sensitive.Mask {
+		return s.keys.Encrypt(field, value)
+	}
+	if stored == sensitive.Mask {
+		return "", sensitive.ErrMasked
+	}
+	return stored, nil
+}
+
+// encryptChannels returns a copy of the channels of the subscription with the given id, with the
+// mail server password and the credential headers of webhooks encrypted. Masked values keep the
+// value of the stored channel at the same position, provided it is of the same type.
+func (s *Service) encryptChannels(id string, channels []models.Channel, stored []models.Channel) ([]models.Channel, error) {
+	encrypted := make([]models.Channel, len(channels))
+	for i, channel := range channels {
+		var previous models.Channel
+		if i < len(stored) && stored[i].Type == channel.Type {
+			previous = stored[i]
+		}
+		field := channelField(id, i)
+
+		password, err := s.sealCredential(field.Child("mailServerPassword"), channel.MailServerPassword, previous.MailServerPassword)
+		if err != nil {
+			return nil, fmt.Errorf("mail server password of channel %d: %w", i+1, err)
+		}
+		channel.MailServerPassword = password
+
+		if channel.HTTPHeaders != nil {
+			headers := make(map[string]string, len(channel.HTTPHeaders))
+			for name, value := range channel.HTTPHeaders {
+				if sensitive.IsSensitiveHeader(name) {
+					value, err = s.sealCredential(field.Child("httpHeaders").Child(name), value, previous.HTTPHeaders[name])
+					if err != nil {
+						return nil, fmt.Errorf("header %s of channel %d: %w", name, i+1, err)
+					}
+				}
+				headers[name] = value
+			}
+			channel.HTTPHeaders = headers
+		}
+		encrypted[i] = channel
+	}
+	return encrypted, nil
+}
+
+// decryptChannels decrypts the credentials of the channels of the subscription with the given id
+// in place, or masks them when the service may not reveal them
+func (s *Service) decryptChannels(id string, channels []models.Channel) error {
+	open := s.keys.Decrypt
+	if !s.reveal {
+		open = func(_ sensitive.Field, value string) (string, error) {
+			return sensitive.MaskValue(value), nil
+		}
+	}
+
+	for i := range channels {
+		field := channelField(id, i)
+		password, err := open(field.Child("mailServerPassword"), channels[i].MailServerPassword)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt mail server password: %w", err)
+		}
+		channels[i].MailServerPassword = password
+
+		for name, value := range channels[i].HTTPHeaders {
+			if !sensitive.IsSensitiveHeader(name) {
+				continue
+			}
+			if channels[i].HTTPHeaders[name], err = open(field.Child("httpHeaders").Child(name), value); err != nil {
+				return fmt.Errorf("failed to decrypt header %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// channelsStale reports whether any channel credential must be rewritten under the primary key
+func (s *Service) channelsStale(channels []models.Channel) bool {
+	for _, channel := range channels {
+		if s.keys.Stale(channel.MailServerPassword) {
+			return true
+		}
+		for name, value := range channel.HTTPHeaders {
+			if sensitive.IsSensitiveHeader(name) && s.keys.Stale(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReencryptSensitiveFields rewrites the channel credentials of every subscription that are in
+// plaintext or encrypted with a retired key under the primary key, and returns the number of
+// subscriptions updated
+func (s *Service) ReencryptSensitiveFields(ctx context.Context) (int, error) {
+	if !s.keys.Enabled() {
+		return 0, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, channels FROM subscriptions`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query subscriptions: %w", err)
+	}
+	stale := map[string][]models.Channel{}
+	for rows.Next() {
+		var id string
+		var channelsJSON []byte
+		if err := rows.Scan(&id, &channelsJSON); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		var channels []models.Channel
+		if len(channelsJSON) > 0 {
+			json.Unmarshal(channelsJSON, &channels)
+		}
+		if s.channelsStale(channels) {
+			stale[id] = channels
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query subscriptions: %w", err)
+	}
+
+	revealed := s.WithReveal(true)
+	updated := 0
+	for id, channels := range stale {
+		if err := revealed.decryptChannels(id, channels); err != nil {
+			return updated, fmt.Errorf("subscription %s: %w", id, err)
+		}
+		encrypted, err := s.encryptChannels(id, channels, nil)
+		if err != nil {
+			return updated, fmt.Errorf("subscription %s: %w", id, err)
+		}
+		channelsJSON, _ := json.Marshal(encrypted)
+		if _, err := s.db.ExecContext(ctx, `UPDATE subscriptions SET channels = $2 WHERE id = $1`, id, channelsJSON); err != nil {
+			return updated, fmt.Errorf("failed to update subscription %s: %w", id, err)
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// maskedSubscription returns a copy of the subscription with its channel credentials masked, for
+// the audit log
+func maskedSubscription(subscription *models.Subscription) *models.Subscription {
+	if subscription == nil {
+		return nil
+	}
+	masked := *subscription
+	masked.Channels = make([]models.Channel, len(subscription.Channels))
+	for i, channel := range subscription.Channels {
+		channel.MailServerPassword = sensitive.MaskValue(channel.MailServerPassword)
+		if channel.HTTPHeaders != nil {
+			headers := make(map[string]string, len(channel.HTTPHeaders))
+			for name, value := range channel.HTTPHeaders {
+				if sensitive.IsSensitiveHeader(name) {
+					value = sensitive.MaskValue(value)
+				}
+				headers[name] = value
+			}
+			channel.HTTPHeaders = headers
+		}
+		masked.Channels[i] = channel
+	}
+	return &masked
+}
@@ -5,6 +5,8 @@ import (
 	"strconv"
 
 	"github.com/labstack/echo/v4"
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/tenant"
 	"iiot-backend/models"
 	"iiot-backend/utils"
 )
@@ -17,6 +19,11 @@ func NewHandler(service *Service) *Handler {
 	return &Handler{service: service}
 }
 
+// scoped returns the service restricted to the tenant of the request
+func (h *Handler) scoped(c echo.Context) *Service {
+	return h.service.WithTenant(tenant.FromContext(c.Request().Context()))
+}
+
 // Rule handlers
 func (h *Handler) GetRules(c echo.Context) error {
 	enabled := c.QueryParam("enabled")
@@ -33,7 +40,7 @@ func (h *Handler) GetRules(c echo.Context) error {
 		enabledFilter = &enabledValue
 	}
 
-	rules, err := h.service.GetRules(enabledFilter, limit, offset)
+	rules, err := h.scoped(c).GetRules(enabledFilter, limit, offset)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve rules", err)
 	}
@@ -42,7 +49,7 @@ func (h *Handler) GetRules(c echo.Context) error {
 
 func (h *Handler) GetRule(c echo.Context) error {
 	id := c.Param("id")
-	rule, err := h.service.GetRuleByID(id)
+	rule, err := h.scoped(c).GetRuleByID(id)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Rule not found", err)
 	}
@@ -51,7 +58,7 @@ func (h *Handler) GetRule(c echo.Context) error {
 
 func (h *Handler) GetRuleByName(c echo.Context) error {
 	name := c.Param("name")
-	rule, err := h.service.GetRuleByName(name)
+	rule, err := h.scoped(c).GetRuleByName(name)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Rule not found", err)
 	}
@@ -68,10 +75,13 @@ func (h *Handler) CreateRule(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
 	}
 
-	id, err := h.service.CreateRule(&req)
+	service := h.scoped(c)
+	id, err := service.CreateRule(&req)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create rule", err)
 	}
+	after, _ := service.GetRuleByID(id)
+	audit.Change(c.Request().Context(), "rule", id, nil, after)
 
 	return utils.SuccessResponse(c, map[string]string{"id": id})
 }
@@ -83,34 +93,49 @@ func (h *Handler) UpdateRule(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
 	}
 
-	if err := h.service.UpdateRule(id, &req); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetRuleByID(id)
+	if err := service.UpdateRule(id, &req); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update rule", err)
 	}
+	after, _ := service.GetRuleByID(id)
+	audit.Change(c.Request().Context(), "rule", id, before, after)
 
 	return utils.SuccessResponse(c, map[string]string{"message": "Rule updated successfully"})
 }
 
 func (h *Handler) DeleteRule(c echo.Context) error {
 	id := c.Param("id")
-	if err := h.service.DeleteRule(id); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetRuleByID(id)
+	if err := service.DeleteRule(id); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete rule", err)
 	}
+	audit.Change(c.Request().Context(), "rule", id, before, nil)
 	return utils.SuccessResponse(c, map[string]string{"message": "Rule deleted successfully"})
 }
 
 func (h *Handler) EnableRule(c echo.Context) error {
 	id := c.Param("id")
-	if err := h.service.SetRuleEnabled(id, true); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetRuleByID(id)
+	if err := service.SetRuleEnabled(id, true); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to enable rule", err)
 	}
+	after, _ := service.GetRuleByID(id)
+	audit.Change(c.Request().Context(), "rule", id, before, after)
 	return utils.SuccessResponse(c, map[string]string{"message": "Rule enabled successfully"})
 }
 
 func (h *Handler) DisableRule(c echo.Context) error {
 	id := c.Param("id")
-	if err := h.service.SetRuleEnabled(id, false); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetRuleByID(id)
+	if err := service.SetRuleEnabled(id, false); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to disable rule", err)
 	}
+	after, _ := service.GetRuleByID(id)
+	audit.Change(c.Request().Context(), "rule", id, before, after)
 	return utils.SuccessResponse(c, map[string]string{"message": "Rule disabled successfully"})
 }
 
@@ -130,7 +155,7 @@ func (h *Handler) GetPipelines(c echo.Context) error {
 		enabledFilter = &enabledValue
 	}
 
-	pipelines, err := h.service.GetPipelines(enabledFilter, limit, offset)
+	pipelines, err := h.scoped(c).GetPipelines(enabledFilter, limit, offset)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve pipelines", err)
 	}
@@ -139,7 +164,7 @@ func (h *Handler) GetPipelines(c echo.Context) error {
 
 func (h *Handler) GetPipeline(c echo.Context) error {
 	id := c.Param("id")
-	pipeline, err := h.service.GetPipelineByID(id)
+	pipeline, err := h.scoped(c).GetPipelineByID(id)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Pipeline not found", err)
 	}
@@ -148,7 +173,7 @@ func (h *Handler) GetPipeline(c echo.Context) error {
 
 func (h *Handler) GetPipelineByName(c echo.Context) error {
 	name := c.Param("name")
-	pipeline, err := h.service.GetPipelineByName(name)
+	pipeline, err := h.scoped(c).GetPipelineByName(name)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Pipeline not found", err)
 	}
@@ -165,10 +190,13 @@ func (h *Handler) CreatePipeline(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
 	}
 
-	id, err := h.service.CreatePipeline(&req)
+	service := h.scoped(c)
+	id, err := service.CreatePipeline(&req)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create pipeline", err)
 	}
+	after, _ := service.GetPipelineByID(id)
+	audit.Change(c.Request().Context(), "pipeline", id, nil, after)
 
 	return utils.SuccessResponse(c, map[string]string{"id": id})
 }
@@ -180,40 +208,55 @@ func (h *Handler) UpdatePipeline(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
 	}
 
-	if err := h.service.UpdatePipeline(id, &req); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetPipelineByID(id)
+	if err := service.UpdatePipeline(id, &req); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update pipeline", err)
 	}
+	after, _ := service.GetPipelineByID(id)
+	audit.Change(c.Request().Context(), "pipeline", id, before, after)
 
 	return utils.SuccessResponse(c, map[string]string{"message": "Pipeline updated successfully"})
 }
 
 func (h *Handler) DeletePipeline(c echo.Context) error {
 	id := c.Param("id")
-	if err := h.service.DeletePipeline(id); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetPipelineByID(id)
+	if err := service.DeletePipeline(id); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete pipeline", err)
 	}
+	audit.Change(c.Request().Context(), "pipeline", id, before, nil)
 	return utils.SuccessResponse(c, map[string]string{"message": "Pipeline deleted successfully"})
 }
 
 func (h *Handler) EnablePipeline(c echo.Context) error {
 	id := c.Param("id")
-	if err := h.service.SetPipelineEnabled(id, true); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetPipelineByID(id)
+	if err := service.SetPipelineEnabled(id, true); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to enable pipeline", err)
 	}
+	after, _ := service.GetPipelineByID(id)
+	audit.Change(c.Request().Context(), "pipeline", id, before, after)
 	return utils.SuccessResponse(c, map[string]string{"message": "Pipeline enabled successfully"})
 }
 
 func (h *Handler) DisablePipeline(c echo.Context) error {
 	id := c.Param("id")
-	if err := h.service.SetPipelineEnabled(id, false); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetPipelineByID(id)
+	if err := service.SetPipelineEnabled(id, false); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to disable pipeline", err)
 	}
+	after, _ := service.GetPipelineByID(id)
+	audit.Change(c.Request().Context(), "pipeline", id, before, after)
 	return utils.SuccessResponse(c, map[string]string{"message": "Pipeline disabled successfully"})
 }
 
 func (h *Handler) ExecuteRule(c echo.Context) error {
 	id := c.Param("id")
-	execution, err := h.service.ExecuteRule(id)
+	execution, err := h.scoped(c).ExecuteRule(id)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to execute rule", err)
 	}
@@ -229,7 +272,7 @@ func (h *Handler) GetRuleExecutions(c echo.Context) error {
 		limit = 50
 	}
 
-	executions, err := h.service.GetRuleExecutions(ruleID, limit, offset)
+	executions, err := h.scoped(c).GetRuleExecutions(ruleID, limit, offset)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve rule executions", err)
 	}
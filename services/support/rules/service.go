@@ -7,15 +7,24 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"iiot-backend/middleware/tenant"
 	"iiot-backend/models"
 )
 
 type Service struct {
-	db *sql.DB
+	db    *sql.DB
+	scope tenant.Scope
 }
 
 func NewService(db *sql.DB) *Service {
-	return &Service{db: db}
+	return &Service{db: db, scope: tenant.Unrestricted()}
+}
+
+// WithTenant returns a copy of the service restricted to the rules and pipelines visible in scope
+func (s *Service) WithTenant(scope tenant.Scope) *Service {
+	scoped := *s
+	scoped.scope = scope
+	return &scoped
 }
 
 // Rule methods
@@ -24,11 +33,15 @@ func (s *Service) GetRules(enabledFilter *bool, limit, offset int) ([]models.Rul
 		SELECT id, name, description, enabled, priority, conditions, actions, tags, created, modified
 		FROM rules
 		WHERE ($1::boolean IS NULL OR enabled = $1)
+		  AND %s
 		ORDER BY priority DESC, created DESC
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := s.db.Query(query, enabledFilter, limit, offset)
+	args := []interface{}{enabledFilter, limit, offset}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query rules: %w", err)
 	}
@@ -65,16 +78,17 @@ func (s *Service) GetRules(enabledFilter *bool, limit, offset int) ([]models.Rul
 }
 
 func (s *Service) GetRuleByID(id string) (*models.Rule, error) {
+	args := []interface{}{id}
 	query := `
 		SELECT id, name, description, enabled, priority, conditions, actions, tags, created, modified
 		FROM rules
-		WHERE id = $1
+		WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 
 	var rule models.Rule
 	var conditionsJSON, actionsJSON, tagsJSON []byte
 
-	err := s.db.QueryRow(query, id).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&rule.ID, &rule.Name, &rule.Description, &rule.Enabled, &rule.Priority,
 		&conditionsJSON, &actionsJSON, &tagsJSON, &rule.Created, &rule.Modified,
 	)
@@ -97,16 +111,17 @@ func (s *Service) GetRuleByID(id string) (*models.Rule, error) {
 }
 
 func (s *Service) GetRuleByName(name string) (*models.Rule, error) {
+	args := []interface{}{name}
 	query := `
 		SELECT id, name, description, enabled, priority, conditions, actions, tags, created, modified
 		FROM rules
-		WHERE name = $1
+		WHERE name = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 
 	var rule models.Rule
 	var conditionsJSON, actionsJSON, tagsJSON []byte
 
-	err := s.db.QueryRow(query, name).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&rule.ID, &rule.Name, &rule.Description, &rule.Enabled, &rule.Priority,
 		&conditionsJSON, &actionsJSON, &tagsJSON, &rule.Created, &rule.Modified,
 	)
@@ -148,12 +163,12 @@ func (s *Service) CreateRule(req *models.RuleRequest) (string, error) {
 	tagsJSON, _ := json.Marshal(rule.Tags)
 
 	query := `
-		INSERT INTO rules (id, name, description, enabled, priority, conditions, actions, tags, created, modified)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO rules (id, name, description, enabled, priority, conditions, actions, tags, created, modified, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
 
 	_, err := s.db.Exec(query, rule.ID, rule.Name, rule.Description, rule.Enabled,
-		rule.Priority, conditionsJSON, actionsJSON, tagsJSON, rule.Created, rule.Modified)
+		rule.Priority, conditionsJSON, actionsJSON, tagsJSON, rule.Created, rule.Modified, s.scope.Tenant)
 	if err != nil {
 		return "", fmt.Errorf("failed to create rule: %w", err)
 	}
@@ -171,11 +186,14 @@ func (s *Service) UpdateRule(id string, req *models.RuleRequest) error {
 		UPDATE rules 
 		SET name = $2, description = $3, enabled = $4, priority = $5, 
 		    conditions = $6, actions = $7, tags = $8, modified = $9
-		WHERE id = $1
+		WHERE id = $1 AND %s
 	`
 
-	_, err := s.db.Exec(query, id, req.Name, req.Description, req.Enabled, req.Priority,
-		conditionsJSON, actionsJSON, tagsJSON, time.Now())
+	args := []interface{}{id, req.Name, req.Description, req.Enabled, req.Priority,
+		conditionsJSON, actionsJSON, tagsJSON, time.Now()}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update rule: %w", err)
 	}
@@ -184,8 +202,9 @@ func (s *Service) UpdateRule(id string, req *models.RuleRequest) error {
 }
 
 func (s *Service) DeleteRule(id string) error {
-	query := `DELETE FROM rules WHERE id = $1`
-	_, err := s.db.Exec(query, id)
+	args := []interface{}{id}
+	query := `DELETE FROM rules WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args)
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete rule: %w", err)
 	}
@@ -193,8 +212,9 @@ func (s *Service) DeleteRule(id string) error {
 }
 
 func (s *Service) SetRuleEnabled(id string, enabled bool) error {
-	query := `UPDATE rules SET enabled = $2, modified = $3 WHERE id = $1`
-	_, err := s.db.Exec(query, id, enabled, time.Now())
+	args := []interface{}{id, enabled, time.Now()}
+	query := `UPDATE rules SET enabled = $2, modified = $3 WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args)
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update rule enabled status: %w", err)
 	}
@@ -207,11 +227,15 @@ func (s *Service) GetPipelines(enabledFilter *bool, limit, offset int) ([]models
 		SELECT id, name, description, enabled, functions, triggers, targets, tags, created, modified
 		FROM pipelines
 		WHERE ($1::boolean IS NULL OR enabled = $1)
+		  AND %s
 		ORDER BY created DESC
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := s.db.Query(query, enabledFilter, limit, offset)
+	args := []interface{}{enabledFilter, limit, offset}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query pipelines: %w", err)
 	}
@@ -252,16 +276,17 @@ func (s *Service) GetPipelines(enabledFilter *bool, limit, offset int) ([]models
 }
 
 func (s *Service) GetPipelineByID(id string) (*models.Pipeline, error) {
+	args := []interface{}{id}
 	query := `
 		SELECT id, name, description, enabled, functions, triggers, targets, tags, created, modified
 		FROM pipelines
-		WHERE id = $1
+		WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 
 	var pipeline models.Pipeline
 	var functionsJSON, triggersJSON, targetsJSON, tagsJSON []byte
 
-	err := s.db.QueryRow(query, id).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&pipeline.ID, &pipeline.Name, &pipeline.Description, &pipeline.Enabled,
 		&functionsJSON, &triggersJSON, &targetsJSON, &tagsJSON,
 		&pipeline.Created, &pipeline.Modified,
@@ -288,16 +313,17 @@ func (s *Service) GetPipelineByID(id string) (*models.Pipeline, error) {
 }
 
 func (s *Service) GetPipelineByName(name string) (*models.Pipeline, error) {
+	args := []interface{}{name}
 	query := `
 		SELECT id, name, description, enabled, functions, triggers, targets, tags, created, modified
 		FROM pipelines
-		WHERE name = $1
+		WHERE name = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 
 	var pipeline models.Pipeline
 	var functionsJSON, triggersJSON, targetsJSON, tagsJSON []byte
 
-	err := s.db.QueryRow(query, name).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&pipeline.ID, &pipeline.Name, &pipeline.Description, &pipeline.Enabled,
 		&functionsJSON, &triggersJSON, &targetsJSON, &tagsJSON,
 		&pipeline.Created, &pipeline.Modified,
@@ -344,12 +370,12 @@ func (s *Service) CreatePipeline(req *models.PipelineRequest) (string, error) {
 	tagsJSON, _ := json.Marshal(pipeline.Tags)
 
 	query := `
-		INSERT INTO pipelines (id, name, description, enabled, functions, triggers, targets, tags, created, modified)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO pipelines (id, name, description, enabled, functions, triggers, targets, tags, created, modified, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
 
 	_, err := s.db.Exec(query, pipeline.ID, pipeline.Name, pipeline.Description, pipeline.Enabled,
-		functionsJSON, triggersJSON, targetsJSON, tagsJSON, pipeline.Created, pipeline.Modified)
+		functionsJSON, triggersJSON, targetsJSON, tagsJSON, pipeline.Created, pipeline.Modified, s.scope.Tenant)
 	if err != nil {
 		return "", fmt.Errorf("failed to create pipeline: %w", err)
 	}
@@ -368,11 +394,14 @@ func (s *Service) UpdatePipeline(id string, req *models.PipelineRequest) error {
 		UPDATE pipelines 
 		SET name = $2, description = $3, enabled = $4, functions = $5, 
 		    triggers = $6, targets = $7, tags = $8, modified = $9
-		WHERE id = $1
+		WHERE id = $1 AND %s
 	`
 
-	_, err := s.db.Exec(query, id, req.Name, req.Description, req.Enabled,
-		functionsJSON, triggersJSON, targetsJSON, tagsJSON, time.Now())
+	args := []interface{}{id, req.Name, req.Description, req.Enabled,
+		functionsJSON, triggersJSON, targetsJSON, tagsJSON, time.Now()}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update pipeline: %w", err)
 	}
@@ -381,8 +410,9 @@ func (s *Service) UpdatePipeline(id string, req *models.PipelineRequest) error {
 }
 
 func (s *Service) DeletePipeline(id string) error {
-	query := `DELETE FROM pipelines WHERE id = $1`
-	_, err := s.db.Exec(query, id)
+	args := []interface{}{id}
+	query := `DELETE FROM pipelines WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args)
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete pipeline: %w", err)
 	}
@@ -390,8 +420,9 @@ func (s *Service) DeletePipeline(id string) error {
 }
 
 func (s *Service) SetPipelineEnabled(id string, enabled bool) error {
-	query := `UPDATE pipelines SET enabled = $2, modified = $3 WHERE id = $1`
-	_, err := s.db.Exec(query, id, enabled, time.Now())
+	args := []interface{}{id, enabled, time.Now()}
+	query := `UPDATE pipelines SET enabled = $2, modified = $3 WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args)
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update pipeline enabled status: %w", err)
 	}
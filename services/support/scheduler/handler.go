@@ -1,10 +1,14 @@
 package scheduler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/labstack/echo/v4"
+	"iiot-backend/middleware/audit"
+	"iiot-backend/middleware/sensitive"
+	"iiot-backend/middleware/tenant"
 	"iiot-backend/models"
 	"iiot-backend/utils"
 )
@@ -17,6 +21,22 @@ func NewHandler(service *Service) *Handler {
 	return &Handler{service: service}
 }
 
+// scoped returns the service restricted to the tenant of the request, revealing interval action
+// passwords only to callers allowed to see them
+func (h *Handler) scoped(c echo.Context) *Service {
+	ctx := c.Request().Context()
+	return h.service.WithTenant(tenant.FromContext(ctx)).WithReveal(sensitive.Revealed(ctx))
+}
+
+// writeStatus returns the status of a failed interval action write, a masked or encrypted password
+// in place of a new one is the caller's mistake
+func writeStatus(err error) int {
+	if errors.Is(err, sensitive.ErrMasked) || errors.Is(err, sensitive.ErrEncryptedValue) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 // Interval handlers
 func (h *Handler) GetIntervals(c echo.Context) error {
 	limit, _ := strconv.Atoi(c.QueryParam("limit"))
@@ -26,7 +46,7 @@ func (h *Handler) GetIntervals(c echo.Context) error {
 		limit = 50
 	}
 
-	intervals, err := h.service.GetAllIntervals(limit, offset)
+	intervals, err := h.scoped(c).GetAllIntervals(limit, offset)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve intervals", err)
 	}
@@ -35,7 +55,7 @@ func (h *Handler) GetIntervals(c echo.Context) error {
 
 func (h *Handler) GetInterval(c echo.Context) error {
 	id := c.Param("id")
-	interval, err := h.service.GetIntervalByID(id)
+	interval, err := h.scoped(c).GetIntervalByID(id)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Interval not found", err)
 	}
@@ -44,7 +64,7 @@ func (h *Handler) GetInterval(c echo.Context) error {
 
 func (h *Handler) GetIntervalByName(c echo.Context) error {
 	name := c.Param("name")
-	interval, err := h.service.GetIntervalByName(name)
+	interval, err := h.scoped(c).GetIntervalByName(name)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Interval not found", err)
 	}
@@ -61,10 +81,13 @@ func (h *Handler) CreateInterval(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
 	}
 
-	id, err := h.service.CreateInterval(&req)
+	service := h.scoped(c)
+	id, err := service.CreateInterval(&req)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create interval", err)
 	}
+	after, _ := service.GetIntervalByID(id)
+	audit.Change(c.Request().Context(), "interval", id, nil, after)
 
 	return utils.SuccessResponse(c, map[string]string{"id": id})
 }
@@ -76,18 +99,25 @@ func (h *Handler) UpdateInterval(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
 	}
 
-	if err := h.service.UpdateInterval(id, &req); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetIntervalByID(id)
+	if err := service.UpdateInterval(id, &req); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update interval", err)
 	}
+	after, _ := service.GetIntervalByID(id)
+	audit.Change(c.Request().Context(), "interval", id, before, after)
 
 	return utils.SuccessResponse(c, map[string]string{"message": "Interval updated successfully"})
 }
 
 func (h *Handler) DeleteInterval(c echo.Context) error {
 	id := c.Param("id")
-	if err := h.service.DeleteInterval(id); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetIntervalByID(id)
+	if err := service.DeleteInterval(id); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete interval", err)
 	}
+	audit.Change(c.Request().Context(), "interval", id, before, nil)
 	return utils.SuccessResponse(c, map[string]string{"message": "Interval deleted successfully"})
 }
 
@@ -101,7 +131,7 @@ func (h *Handler) GetIntervalActions(c echo.Context) error {
 		limit = 50
 	}
 
-	actions, err := h.service.GetIntervalActions(intervalName, limit, offset)
+	actions, err := h.scoped(c).GetIntervalActions(intervalName, limit, offset)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve interval actions", err)
 	}
@@ -110,7 +140,7 @@ func (h *Handler) GetIntervalActions(c echo.Context) error {
 
 func (h *Handler) GetIntervalAction(c echo.Context) error {
 	id := c.Param("id")
-	action, err := h.service.GetIntervalActionByID(id)
+	action, err := h.scoped(c).GetIntervalActionByID(id)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Interval action not found", err)
 	}
@@ -119,7 +149,7 @@ func (h *Handler) GetIntervalAction(c echo.Context) error {
 
 func (h *Handler) GetIntervalActionByName(c echo.Context) error {
 	name := c.Param("name")
-	action, err := h.service.GetIntervalActionByName(name)
+	action, err := h.scoped(c).GetIntervalActionByName(name)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Interval action not found", err)
 	}
@@ -136,10 +166,13 @@ func (h *Handler) CreateIntervalAction(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
 	}
 
-	id, err := h.service.CreateIntervalAction(&req)
+	service := h.scoped(c)
+	id, err := service.CreateIntervalAction(&req)
 	if err != nil {
-		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create interval action", err)
+		return utils.ErrorResponse(c, writeStatus(err), "Failed to create interval action", err)
 	}
+	after, _ := service.GetIntervalActionByID(id)
+	audit.Change(c.Request().Context(), "intervalaction", id, nil, maskedAction(after))
 
 	return utils.SuccessResponse(c, map[string]string{"id": id})
 }
@@ -151,25 +184,32 @@ func (h *Handler) UpdateIntervalAction(c echo.Context) error {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
 	}
 
-	if err := h.service.UpdateIntervalAction(id, &req); err != nil {
-		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update interval action", err)
+	service := h.scoped(c)
+	before, _ := service.GetIntervalActionByID(id)
+	if err := service.UpdateIntervalAction(id, &req); err != nil {
+		return utils.ErrorResponse(c, writeStatus(err), "Failed to update interval action", err)
 	}
+	after, _ := service.GetIntervalActionByID(id)
+	audit.Change(c.Request().Context(), "intervalaction", id, maskedAction(before), maskedAction(after))
 
 	return utils.SuccessResponse(c, map[string]string{"message": "Interval action updated successfully"})
 }
 
 func (h *Handler) DeleteIntervalAction(c echo.Context) error {
 	id := c.Param("id")
-	if err := h.service.DeleteIntervalAction(id); err != nil {
+	service := h.scoped(c)
+	before, _ := service.GetIntervalActionByID(id)
+	if err := service.DeleteIntervalAction(id); err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete interval action", err)
 	}
+	audit.Change(c.Request().Context(), "intervalaction", id, maskedAction(before), nil)
 	return utils.SuccessResponse(c, map[string]string{"message": "Interval action deleted successfully"})
 }
 
 // Schedule Status handlers
 func (h *Handler) GetScheduleStatus(c echo.Context) error {
 	intervalName := c.Param("name")
-	status, err := h.service.GetScheduleStatus(intervalName)
+	status, err := h.scoped(c).GetScheduleStatus(intervalName)
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusNotFound, "Schedule status not found", err)
 	}
@@ -177,7 +217,7 @@ func (h *Handler) GetScheduleStatus(c echo.Context) error {
 }
 
 func (h *Handler) GetAllScheduleStatuses(c echo.Context) error {
-	statuses, err := h.service.GetAllScheduleStatuses()
+	statuses, err := h.scoped(c).GetAllScheduleStatuses()
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve schedule statuses", err)
 	}
@@ -1,20 +1,44 @@
 package scheduler
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"iiot-backend/middleware/sensitive"
+	"iiot-backend/middleware/tenant"
 	"iiot-backend/models"
 )
 
 type Service struct {
-	db *sql.DB
+	db     *sql.DB
+	scope  tenant.Scope
+	keys   *sensitive.Keyring
+	reveal bool
 }
 
-func NewService(db *sql.DB) *Service {
-	return &Service{db: db}
+// NewService creates the service, interval action passwords are encrypted with keys when it holds
+// any and masked until WithReveal allows revealing them
+func NewService(db *sql.DB, keys *sensitive.Keyring) *Service {
+	return &Service{db: db, scope: tenant.Unrestricted(), keys: keys}
+}
+
+// WithTenant returns a copy of the service restricted to the intervals and interval actions visible
+// in scope
+func (s *Service) WithTenant(scope tenant.Scope) *Service {
+	scoped := *s
+	scoped.scope = scope
+	return &scoped
+}
+
+// WithReveal returns a copy of the service returning interval action passwords in plaintext when
+// reveal is set and masked otherwise
+func (s *Service) WithReveal(reveal bool) *Service {
+	revealed := *s
+	revealed.reveal = reveal
+	return &revealed
 }
 
 // Interval methods
@@ -22,11 +46,15 @@ func (s *Service) GetAllIntervals(limit, offset int) ([]models.Interval, error)
 	query := `
 		SELECT id, name, start_time, end_time, interval_time, run_once, created, modified
 		FROM intervals
+		WHERE %s
 		ORDER BY created DESC
 		LIMIT $1 OFFSET $2
 	`
 	
-	rows, err := s.db.Query(query, limit, offset)
+	args := []interface{}{limit, offset}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query intervals: %w", err)
 	}
@@ -49,14 +77,15 @@ func (s *Service) GetAllIntervals(limit, offset int) ([]models.Interval, error)
 }
 
 func (s *Service) GetIntervalByID(id string) (*models.Interval, error) {
+	args := []interface{}{id}
 	query := `
 		SELECT id, name, start_time, end_time, interval_time, run_once, created, modified
 		FROM intervals
-		WHERE id = $1
+		WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 	
 	var interval models.Interval
-	err := s.db.QueryRow(query, id).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&interval.ID, &interval.Name, &interval.Start, &interval.End,
 		&interval.Interval, &interval.RunOnce, &interval.Created, &interval.Modified,
 	)
@@ -68,14 +97,15 @@ func (s *Service) GetIntervalByID(id string) (*models.Interval, error) {
 }
 
 func (s *Service) GetIntervalByName(name string) (*models.Interval, error) {
+	args := []interface{}{name}
 	query := `
 		SELECT id, name, start_time, end_time, interval_time, run_once, created, modified
 		FROM intervals
-		WHERE name = $1
+		WHERE name = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 	
 	var interval models.Interval
-	err := s.db.QueryRow(query, name).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&interval.ID, &interval.Name, &interval.Start, &interval.End,
 		&interval.Interval, &interval.RunOnce, &interval.Created, &interval.Modified,
 	)
@@ -99,12 +129,12 @@ func (s *Service) CreateInterval(req *models.IntervalRequest) (string, error) {
 	}
 
 	query := `
-		INSERT INTO intervals (id, name, start_time, end_time, interval_time, run_once, created, modified)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO intervals (id, name, start_time, end_time, interval_time, run_once, created, modified, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 	
 	_, err := s.db.Exec(query, interval.ID, interval.Name, interval.Start, interval.End,
-		interval.Interval, interval.RunOnce, interval.Created, interval.Modified)
+		interval.Interval, interval.RunOnce, interval.Created, interval.Modified, s.scope.Tenant)
 	if err != nil {
 		return "", fmt.Errorf("failed to create interval: %w", err)
 	}
@@ -117,11 +147,13 @@ func (s *Service) UpdateInterval(id string, req *models.IntervalRequest) error {
 		UPDATE intervals 
 		SET name = $2, start_time = $3, end_time = $4, interval_time = $5, 
 		    run_once = $6, modified = $7
-		WHERE id = $1
+		WHERE id = $1 AND %s
 	`
 	
-	_, err := s.db.Exec(query, id, req.Name, req.Start, req.End,
-		req.Interval, req.RunOnce, time.Now())
+	args := []interface{}{id, req.Name, req.Start, req.End, req.Interval, req.RunOnce, time.Now()}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update interval: %w", err)
 	}
@@ -130,15 +162,18 @@ func (s *Service) UpdateInterval(id string, req *models.IntervalRequest) error {
 }
 
 func (s *Service) DeleteInterval(id string) error {
+	args := []interface{}{id}
+	condition := s.scope.Condition("tenant_id", &args)
+
 	// First delete associated interval actions
-	_, err := s.db.Exec("DELETE FROM interval_actions WHERE interval_name = (SELECT name FROM intervals WHERE id = $1)", id)
+	_, err := s.db.Exec("DELETE FROM interval_actions WHERE interval_name = (SELECT name FROM intervals WHERE id = $1 AND "+condition+")", args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete associated interval actions: %w", err)
 	}
 
 	// Then delete the interval
-	query := `DELETE FROM intervals WHERE id = $1`
-	_, err = s.db.Exec(query, id)
+	query := `DELETE FROM intervals WHERE id = $1 AND ` + condition
+	_, err = s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete interval: %w", err)
 	}
@@ -152,11 +187,15 @@ func (s *Service) GetIntervalActions(intervalName string, limit, offset int) ([]
 		       http_method, address, publisher, target, user, password, topic, created, modified
 		FROM interval_actions
 		WHERE ($1 = '' OR interval_name = $1)
+		  AND %s
 		ORDER BY created DESC
 		LIMIT $2 OFFSET $3
 	`
 	
-	rows, err := s.db.Query(query, intervalName, limit, offset)
+	args := []interface{}{intervalName, limit, offset}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query interval actions: %w", err)
 	}
@@ -174,6 +213,9 @@ func (s *Service) GetIntervalActions(intervalName string, limit, offset int) ([]
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan interval action: %w", err)
 		}
+		if action.Password, err = s.openPassword(action.ID, action.Password); err != nil {
+			return nil, err
+		}
 		actions = append(actions, action)
 	}
 
@@ -181,15 +223,16 @@ func (s *Service) GetIntervalActions(intervalName string, limit, offset int) ([]
 }
 
 func (s *Service) GetIntervalActionByID(id string) (*models.IntervalAction, error) {
+	args := []interface{}{id}
 	query := `
 		SELECT id, name, interval_name, protocol, host, port, path, parameters,
 		       http_method, address, publisher, target, user, password, topic, created, modified
 		FROM interval_actions
-		WHERE id = $1
+		WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 	
 	var action models.IntervalAction
-	err := s.db.QueryRow(query, id).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&action.ID, &action.Name, &action.IntervalName, &action.Protocol,
 		&action.Host, &action.Port, &action.Path, &action.Parameters,
 		&action.HTTPMethod, &action.Address, &action.Publisher, &action.Target,
@@ -198,20 +241,24 @@ func (s *Service) GetIntervalActionByID(id string) (*models.IntervalAction, erro
 	if err != nil {
 		return nil, fmt.Errorf("failed to get interval action: %w", err)
 	}
+	if action.Password, err = s.openPassword(action.ID, action.Password); err != nil {
+		return nil, err
+	}
 
 	return &action, nil
 }
 
 func (s *Service) GetIntervalActionByName(name string) (*models.IntervalAction, error) {
+	args := []interface{}{name}
 	query := `
 		SELECT id, name, interval_name, protocol, host, port, path, parameters,
 		       http_method, address, publisher, target, user, password, topic, created, modified
 		FROM interval_actions
-		WHERE name = $1
+		WHERE name = $1 AND ` + s.scope.Condition("tenant_id", &args) + `
 	`
 	
 	var action models.IntervalAction
-	err := s.db.QueryRow(query, name).Scan(
+	err := s.db.QueryRow(query, args...).Scan(
 		&action.ID, &action.Name, &action.IntervalName, &action.Protocol,
 		&action.Host, &action.Port, &action.Path, &action.Parameters,
 		&action.HTTPMethod, &action.Address, &action.Publisher, &action.Target,
@@ -220,6 +267,9 @@ func (s *Service) GetIntervalActionByName(name string) (*models.IntervalAction,
 	if err != nil {
 		return nil, fmt.Errorf("failed to get interval action: %w", err)
 	}
+	if action.Password, err = s.openPassword(action.ID, action.Password); err != nil {
+		return nil, err
+	}
 
 	return &action, nil
 }
@@ -231,8 +281,17 @@ func (s *Service) CreateIntervalAction(req *models.IntervalActionRequest) (strin
 		return "", fmt.Errorf("interval %s not found: %w", req.IntervalName, err)
 	}
 
+	if req.Password == sensitive.Mask {
+		return "", fmt.Errorf("interval action password: %w", sensitive.ErrMasked)
+	}
+	id := uuid.New().String()
+	password, err := s.keys.Encrypt(passwordField(id), req.Password)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt interval action password: %w", err)
+	}
+
 	action := &models.IntervalAction{
-		ID:           uuid.New().String(),
+		ID:           id,
 		Name:         req.Name,
 		IntervalName: req.IntervalName,
 		Protocol:     req.Protocol,
@@ -245,7 +304,7 @@ func (s *Service) CreateIntervalAction(req *models.IntervalActionRequest) (strin
 		Publisher:    req.Publisher,
 		Target:       req.Target,
 		User:         req.User,
-		Password:     req.Password,
+		Password:     password,
 		Topic:        req.Topic,
 		Created:      time.Now(),
 		Modified:     time.Now(),
@@ -254,14 +313,14 @@ func (s *Service) CreateIntervalAction(req *models.IntervalActionRequest) (strin
 	query := `
 		INSERT INTO interval_actions (id, name, interval_name, protocol, host, port, path,
 		                            parameters, http_method, address, publisher, target,
-		                            user, password, topic, created, modified)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		                            user, password, topic, created, modified, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 	`
 	
 	_, err = s.db.Exec(query, action.ID, action.Name, action.IntervalName, action.Protocol,
 		action.Host, action.Port, action.Path, action.Parameters, action.HTTPMethod,
 		action.Address, action.Publisher, action.Target, action.User, action.Password,
-		action.Topic, action.Created, action.Modified)
+		action.Topic, action.Created, action.Modified, s.scope.Tenant)
 	if err != nil {
 		return "", fmt.Errorf("failed to create interval action: %w", err)
 	}
@@ -275,18 +334,25 @@ func (s *Service) UpdateIntervalAction(id string, req *models.IntervalActionRequ
 	if err != nil {
 		return fmt.Errorf("interval %s not found: %w", req.IntervalName, err)
 	}
+	password, err := s.updatedPassword(id, req.Password)
+	if err != nil {
+		return err
+	}
 
 	query := `
 		UPDATE interval_actions 
 		SET name = $2, interval_name = $3, protocol = $4, host = $5, port = $6, path = $7,
 		    parameters = $8, http_method = $9, address = $10, publisher = $11, target = $12,
 		    user = $13, password = $14, topic = $15, modified = $16
-		WHERE id = $1
+		WHERE id = $1 AND %s
 	`
 	
-	_, err = s.db.Exec(query, id, req.Name, req.IntervalName, req.Protocol, req.Host,
+	args := []interface{}{id, req.Name, req.IntervalName, req.Protocol, req.Host,
 		req.Port, req.Path, req.Parameters, req.HTTPMethod, req.Address, req.Publisher,
-		req.Target, req.User, req.Password, req.Topic, time.Now())
+		req.Target, req.User, password, req.Topic, time.Now()}
+	query = fmt.Sprintf(query, s.scope.Condition("tenant_id", &args))
+
+	_, err = s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update interval action: %w", err)
 	}
@@ -295,8 +361,9 @@ func (s *Service) UpdateIntervalAction(id string, req *models.IntervalActionRequ
 }
 
 func (s *Service) DeleteIntervalAction(id string) error {
-	query := `DELETE FROM interval_actions WHERE id = $1`
-	_, err := s.db.Exec(query, id)
+	args := []interface{}{id}
+	query := `DELETE FROM interval_actions WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args)
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete interval action: %w", err)
 	}
@@ -350,3 +417,98 @@ func (s *Service) GetAllScheduleStatuses() ([]models.ScheduleStatus, error) {
 
 	return statuses, nil
 }
+
+// passwordField returns the field the password of the interval action with the given id is
+// encrypted for
+func passwordField(id string) sensitive.Field {
+	return sensitive.Field{Table: "interval_actions", Row: id, Name: "password"}
+}
+
+// openPassword decrypts the stored password of the interval action with the given id, or masks it
+// when the service may not reveal it
+func (s *Service) openPassword(id, stored string) (string, error) {
+	if !s.reveal {
+		return sensitive.MaskValue(stored), nil
+	}
+	password, err := s.keys.Decrypt(passwordField(id), stored)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt interval action password: %w", err)
+	}
+	return password, nil
+}
+
+// updatedPassword returns the password to store for an updated interval action, the stored one
+// when the request carries the mask
+func (s *Service) updatedPassword(id, password string) (string, error) {
+	if password == sensitive.Mask {
+		args := []interface{}{id}
+		query := `SELECT password FROM interval_actions WHERE id = $1 AND ` + s.scope.Condition("tenant_id", &args)
+		var stored string
+		err := s.db.QueryRow(query, args...).Scan(&stored)
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("interval action password: %w", sensitive.ErrMasked)
+		} else if err != nil {
+			return "", fmt.Errorf("failed to get interval action: %w", err)
+		}
+		return stored, nil
+	}
+	encrypted, err := s.keys.Encrypt(passwordField(id), password)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt interval action password: %w", err)
+	}
+	return encrypted, nil
+}
+
+// ReencryptSensitiveFields rewrites the interval action passwords that are in plaintext or
+// encrypted with a retired key under the primary key, and returns the number of actions updated
+func (s *Service) ReencryptSensitiveFields(ctx context.Context) (int, error) {
+	if !s.keys.Enabled() {
+		return 0, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, password FROM interval_actions WHERE password <> ''`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query interval actions: %w", err)
+	}
+	stale := map[string]string{}
+	for rows.Next() {
+		var id, password string
+		if err := rows.Scan(&id, &password); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan interval action: %w", err)
+		}
+		if s.keys.Stale(password) {
+			stale[id] = password
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query interval actions: %w", err)
+	}
+
+	updated := 0
+	for id, stored := range stale {
+		password, err := s.keys.Decrypt(passwordField(id), stored)
+		if err != nil {
+			return updated, fmt.Errorf("interval action %s: %w", id, err)
+		}
+		if password, err = s.keys.Encrypt(passwordField(id), password); err != nil {
+			return updated, fmt.Errorf("interval action %s: %w", id, err)
+		}
+		if _, err := s.db.ExecContext(ctx, `UPDATE interval_actions SET password = $2 WHERE id = $1`, id, password); err != nil {
+			return updated, fmt.Errorf("failed to update interval action %s: %w", id, err)
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// maskedAction returns a copy of the interval action with its password masked, for the audit log
+func maskedAction(action *models.IntervalAction) *models.IntervalAction {
+	if action == nil {
+		return nil
+	}
+	masked := *action
+	masked.Password = sensitive.MaskValue(action.Password)
+	return &masked
+}